@@ -0,0 +1,95 @@
+// Package adminsetup implements the create-admin command, which creates or
+// resets the GoExpress admin account.
+package adminsetup
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"goexpress-api/utils"
+)
+
+// PasswordEnv is read when no -password flag is given. Prefer it over the
+// flag, which is visible in the process list and shell history.
+const PasswordEnv = "ADMIN_PASSWORD"
+
+var ErrNoPassword = errors.New("no admin password provided: set " + PasswordEnv + " or pass -password")
+
+type Options struct {
+	Name         string
+	Email        string
+	Password     string
+	DryRun       bool
+	ShowPassword bool
+}
+
+// ParseOptions parses command-line arguments, falling back to getenv for the password.
+func ParseOptions(args []string, getenv func(string) string, output io.Writer) (Options, error) {
+	var opts Options
+
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&opts.Name, "name", "GoExpress Admin", "admin display name")
+	flags.StringVar(&opts.Email, "email", "admin@goexpress.com", "admin email")
+	flags.StringVar(&opts.Password, "password", "", "admin password (defaults to $"+PasswordEnv+")")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "print what would happen without writing to the database")
+	flags.BoolVar(&opts.ShowPassword, "show-password", false, "print the plaintext password")
+
+	if err := flags.Parse(args); err != nil {
+		return Options{}, err
+	}
+
+	if opts.Password == "" {
+		opts.Password = getenv(PasswordEnv)
+	}
+	if opts.Password == "" {
+		return Options{}, ErrNoPassword
+	}
+
+	return opts, nil
+}
+
+// Run creates the admin user, or resets its password if the email already
+// exists. In dry-run mode db is not used and may be nil.
+func Run(db *sql.DB, opts Options, output io.Writer) error {
+	opts.Email = utils.NormalizeEmail(opts.Email)
+	if opts.DryRun {
+		fmt.Fprintln(output, "Dry run: no changes will be written")
+		fmt.Fprintf(output, "Would create or update admin user %q <%s>\n", opts.Name, opts.Email)
+		printPassword(output, opts)
+		return nil
+	}
+
+	hashedPassword, err := utils.HashPassword(opts.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	// Update or insert admin user
+	_, err = db.Exec(`
+		INSERT INTO users (name, email, password_hash, role) 
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (email) 
+		DO UPDATE SET 
+			password_hash = EXCLUDED.password_hash`,
+		opts.Name, opts.Email, hashedPassword, "admin")
+	if err != nil {
+		return fmt.Errorf("failed to create/update admin user: %w", err)
+	}
+
+	fmt.Fprintln(output, "✅ Admin user created/updated successfully!")
+	fmt.Fprintf(output, "Email: %s\n", opts.Email)
+	printPassword(output, opts)
+	return nil
+}
+
+func printPassword(output io.Writer, opts Options) {
+	if opts.ShowPassword {
+		fmt.Fprintf(output, "Password: %s\n", opts.Password)
+		return
+	}
+	fmt.Fprintln(output, "Password: (hidden, use -show-password to print it)")
+}
@@ -0,0 +1,64 @@
+package audit
+
+import "database/sql"
+
+// ActionImpersonate is recorded when an admin starts impersonating a user.
+const ActionImpersonate = "impersonate"
+
+// ActionReopenShipment is recorded when an admin reopens a cancelled
+// shipment. The entry's action names the shipment, e.g. "reopen_shipment 42".
+const ActionReopenShipment = "reopen_shipment"
+
+// ActionRezoneShipment is recorded when an admin moves a shipment to another
+// zone. The entry's action names the shipment and both zones, e.g.
+// "rezone_shipment 42 1->3".
+const ActionRezoneShipment = "rezone_shipment"
+
+// ActionDeclineAssignment is recorded when a driver declines a shipment
+// assigned to them, e.g. "decline_assignment 42".
+const ActionDeclineAssignment = "decline_assignment"
+
+// ActionOffboardDriver is recorded when an admin offboards a driver. The
+// entry's user is the driver and its action names who took over their open
+// shipments, e.g. "offboard_driver 7->9", or "offboard_driver 7->unassigned".
+const ActionOffboardDriver = "offboard_driver"
+
+// Entry is one audited action: who really performed it, on whose behalf, and
+// how it ended.
+type Entry struct {
+	ActorID    int
+	UserID     int
+	Action     string
+	StatusCode int
+}
+
+// Recorder stores audit entries.
+type Recorder interface {
+	Record(Entry) error
+	// RecordTx records the entry in tx, so it only exists if the audited
+	// change commits.
+	RecordTx(*sql.Tx, Entry) error
+}
+
+// Log records audit entries in the audit_log table.
+type Log struct {
+	db *sql.DB
+}
+
+func NewLog(db *sql.DB) *Log {
+	return &Log{db: db}
+}
+
+const insertEntry = `
+	INSERT INTO audit_log (actor_id, user_id, action, status_code)
+	VALUES ($1, $2, $3, $4)`
+
+func (l *Log) Record(e Entry) error {
+	_, err := l.db.Exec(insertEntry, e.ActorID, e.UserID, e.Action, e.StatusCode)
+	return err
+}
+
+func (l *Log) RecordTx(tx *sql.Tx, e Entry) error {
+	_, err := tx.Exec(insertEntry, e.ActorID, e.UserID, e.Action, e.StatusCode)
+	return err
+}
@@ -0,0 +1,35 @@
+// Package buildinfo holds build metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X goexpress-api/buildinfo.Version=1.2.0 \
+//	  -X goexpress-api/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X goexpress-api/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+// Set via -ldflags; left as "dev" for local builds.
+var (
+	Version   = "dev"
+	Commit    = "dev"
+	BuildDate = "dev"
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+}
+
+// Get returns the build metadata of the running binary.
+func Get() Info {
+	return Info{
+		Version:   valueOrDev(Version),
+		Commit:    valueOrDev(Commit),
+		BuildDate: valueOrDev(BuildDate),
+	}
+}
+
+func valueOrDev(value string) string {
+	if value == "" {
+		return "dev"
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"goexpress-api/models"
+)
+
+// maxQuoteEntries bounds the quote cache. Weights are free-form, so the key
+// space is unbounded.
+const maxQuoteEntries = 10000
+
+// Quotes caches computed shipping quotes by zone and weight. Only the base
+// quote is cached; promo codes are applied on top of it per request.
+// Implementations must be safe for concurrent use.
+type Quotes interface {
+	// Get returns the cached quote, or false if there is none or it expired.
+	Get(zoneID int, weight float64) (models.QuoteResponse, bool)
+	Set(quote models.QuoteResponse)
+	// InvalidateZone drops every cached quote for the zone, e.g. after its
+	// price changed.
+	InvalidateZone(zoneID int)
+}
+
+type quoteKey struct {
+	zoneID int
+	weight float64
+}
+
+type quoteEntry struct {
+	quote     models.QuoteResponse
+	expiresAt time.Time
+}
+
+// TTLQuotes keeps quotes for a fixed TTL. A TTL of zero disables caching.
+type TTLQuotes struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[quoteKey]quoteEntry
+}
+
+func NewTTLQuotes(ttl time.Duration) *TTLQuotes {
+	return &TTLQuotes{ttl: ttl, entries: make(map[quoteKey]quoteEntry)}
+}
+
+func (c *TTLQuotes) Get(zoneID int, weight float64) (models.QuoteResponse, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[quoteKey{zoneID, weight}]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return models.QuoteResponse{}, false
+	}
+	return entry.quote, true
+}
+
+func (c *TTLQuotes) Set(quote models.QuoteResponse) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxQuoteEntries {
+		for key, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxQuoteEntries {
+			c.entries = make(map[quoteKey]quoteEntry)
+		}
+	}
+	c.entries[quoteKey{quote.ZoneID, quote.Weight}] = quoteEntry{quote: quote, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *TTLQuotes) InvalidateZone(zoneID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.zoneID == zoneID {
+			delete(c.entries, key)
+		}
+	}
+}
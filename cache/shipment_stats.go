@@ -0,0 +1,61 @@
+// Package cache holds short-lived in-memory caches for expensive queries.
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"goexpress-api/models"
+)
+
+// ShipmentStats caches the shipment counts shown on admin dashboards.
+// Implementations must be safe for concurrent use.
+type ShipmentStats interface {
+	// Get returns the cached stats, or false if there are none or they expired.
+	Get() (models.ShipmentStats, bool)
+	Set(stats models.ShipmentStats)
+	// Invalidate drops the cached stats so the next read hits the database.
+	Invalidate()
+}
+
+// TTLShipmentStats keeps shipment stats for a fixed TTL. A TTL of zero disables caching.
+type TTLShipmentStats struct {
+	mu        sync.RWMutex
+	ttl       time.Duration
+	stats     models.ShipmentStats
+	expiresAt time.Time
+}
+
+func NewTTLShipmentStats(ttl time.Duration) *TTLShipmentStats {
+	return &TTLShipmentStats{ttl: ttl}
+}
+
+func (c *TTLShipmentStats) Get() (models.ShipmentStats, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.expiresAt.IsZero() || time.Now().After(c.expiresAt) {
+		return models.ShipmentStats{}, false
+	}
+	return c.stats.Clone(), true
+}
+
+func (c *TTLShipmentStats) Set(stats models.ShipmentStats) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats = stats.Clone()
+	c.expiresAt = time.Now().Add(c.ttl)
+}
+
+func (c *TTLShipmentStats) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats = models.ShipmentStats{}
+	c.expiresAt = time.Time{}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"goexpress-api/adminsetup"
+	"goexpress-api/config"
+	"goexpress-api/database"
+)
+
+func main() {
+	opts, err := adminsetup.ParseOptions(os.Args[1:], os.Getenv, os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatal(err)
+	}
+
+	if opts.DryRun {
+		if err := adminsetup.Run(nil, opts, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Load configuration
+	cfg := config.Load()
+
+	// Connect to database
+	db, err := database.New(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	if err := adminsetup.Run(db.DB, opts, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// masked stands in for the value of a secret in Summary.
+const masked = "****"
+
+// Summary renders the effective configuration as space-separated
+// ENV_NAME=value pairs, for logging at startup. Secrets are masked, and
+// DATABASE_URL and the webhook URLs are reduced to their hosts.
+func (c *Config) Summary() string {
+	dbHost, dbName := databaseHost(c.DatabaseURL)
+
+	fields := []struct {
+		key   string
+		value interface{}
+	}{
+		{"ENVIRONMENT", c.Environment},
+		{"PORT", c.Port},
+		{"LOG_LEVEL", c.LogLevel},
+		{"DATABASE_HOST", dbHost},
+		{"DATABASE_NAME", dbName},
+		{"JWT_SECRET", maskSecret(c.JWTSecret, defaultJWTSecret)},
+		{"JWT_REFRESH_SECRET", maskSecret(c.JWTRefreshSecret, defaultJWTRefreshSecret)},
+		{"MAINTENANCE_MODE", c.MaintenanceMode},
+		{"MAINTENANCE_BLOCK_READS", c.MaintenanceBlockReads},
+		{"CLIENT_READONLY", c.ClientReadOnly},
+		{"PASSWORD_HISTORY_SIZE", c.PasswordHistorySize},
+		{"UPLOAD_DIR", c.UploadDir},
+		{"FILE_URL_SECRET", maskSecret(c.FileURLSecret, defaultFileURLSecret)},
+		{"TRACKING_LINK_SECRET", maskSecret(c.TrackingLinkSecret, defaultTrackingLinkSecret)},
+		{"STATS_CACHE_TTL", c.StatsCacheTTL},
+		{"APPROXIMATE_COUNTS", c.ApproximateCounts},
+		{"QUOTE_CACHE_TTL", c.QuoteCacheTTL},
+		{"TRACKING_DEDUPE_WINDOW", c.TrackingDedupeWindow},
+		{"DRIVER_MAX_CONCURRENT_SHIPMENTS", c.DefaultDriverCapacity},
+		{"DRIVER_COMMISSION_RATE", c.DriverCommissionRate},
+		{"PRIORITY_EXPRESS_MULTIPLIER", c.ExpressMultiplier},
+		{"PRIORITY_OVERNIGHT_MULTIPLIER", c.OvernightMultiplier},
+		{"TRACK_BATCH_RATE_LIMIT", c.TrackBatchRateLimit},
+		{"COMPRESSION_ENABLED", c.CompressionEnabled},
+		{"COMPRESSION_MIN_SIZE", c.CompressionMinSize},
+		{"DEFAULT_PAGE_SIZE", c.DefaultPageSize},
+		{"MAX_PAGE_SIZE", c.MaxPageSize},
+		{"CORS_MAX_AGE", c.CORSMaxAge},
+		{"CORS_EXPOSED_HEADERS", c.CORSExposedHeaders},
+		{"TRUSTED_PROXIES", c.TrustedProxies},
+		{"INTEGRATION_API_KEYS", fmt.Sprintf("%d configured", len(c.IntegrationAPIKeys))},
+		{"IMPERSONATION_TOKEN_TTL", c.ImpersonationTTL},
+		{"SLOW_REQUEST_THRESHOLD", c.SlowRequestThreshold},
+		{"SLOW_QUERY_THRESHOLD", c.SlowQueryThreshold},
+		{"SHIPMENT_EMAILS_ENABLED", c.ShipmentEmailsEnabled},
+		{"REGISTRATION_ENABLED", c.RegistrationEnabled},
+		{"REQUIRE_VERIFIED_CUSTOMERS", c.RequireVerification},
+		{"WELCOME_EMAILS_ENABLED", c.WelcomeEmailsEnabled},
+		{"STATUS_NOTIFICATIONS_ENABLED", c.StatusNotifications},
+		{"RESEND_NOTIFICATION_INTERVAL", c.ResendInterval},
+		{"SMTP_HOST", c.SMTPHost},
+		{"SMTP_PORT", c.SMTPPort},
+		{"SMTP_USERNAME", c.SMTPUsername},
+		{"SMTP_PASSWORD", maskSecret(c.SMTPPassword, "")},
+		{"MAIL_FROM", c.MailFrom},
+		{"MAIL_QUEUE_SIZE", c.MailQueueSize},
+		{"MAIL_MAX_ATTEMPTS", c.MailMaxAttempts},
+		{"MAIL_RETRY_BACKOFF", c.MailRetryBackoff},
+		{"WEBHOOK_HOST", urlHost(c.WebhookURL)},
+		{"WEBHOOK_TIMEOUT", c.WebhookTimeout},
+		{"WEBHOOK_SECRET", maskSecret(c.WebhookSecret, "")},
+		{"OUTBOX_RELAY_INTERVAL", c.OutboxRelayInterval},
+		{"QUOTE_WEBHOOK_HOST", urlHost(c.QuoteWebhookURL)},
+		{"QUOTE_WEBHOOK_TIMEOUT", c.QuoteWebhookTimeout},
+	}
+
+	pairs := make([]string, len(fields))
+	for i, field := range fields {
+		pairs[i] = field.key + "=" + formatValue(field.value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// maskSecret hides a secret but still tells whether it is unset (empty) or
+// left at its built-in default ("default"), which is what startup logs are
+// usually read for.
+func maskSecret(value, defaultValue string) string {
+	switch {
+	case value == "":
+		return ""
+	case value == defaultValue:
+		return "default"
+	}
+	return masked
+}
+
+// urlHost keeps only the host of a URL, which may carry credentials or
+// tokens in its user info, path or query.
+func urlHost(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "invalid"
+	}
+	return u.Host
+}
+
+func formatValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case []string:
+		s = strings.Join(v, ",")
+	case time.Duration:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " \"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
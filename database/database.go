@@ -3,45 +3,40 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"sync/atomic"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DB struct {
 	*sql.DB
+	ready atomic.Bool
+}
+
+// Ready reports whether RunMigrations has completed, i.e. the schema is the
+// one this binary expects. It is safe to call from any goroutine.
+func (db *DB) Ready() bool {
+	return db.ready.Load()
 }
 
 func New(databaseURL string) (*DB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+	connector, err := pq.NewConnector(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
-
-	return &DB{db}, nil
-}
-
-func (db *DB) RunMigrations() error {
-	migrationPath := filepath.Join("supabase", "migrations", "20250704001632_weathered_block.sql")
-	
-	migrationSQL, err := os.ReadFile(migrationPath)
-	if err != nil {
-		return fmt.Errorf("failed to read migration file: %w", err)
+	var db *sql.DB
+	if slowQueryThreshold > 0 {
+		db = sql.OpenDB(slowQueryConnector{Connector: connector, threshold: slowQueryThreshold})
+	} else {
+		db = sql.OpenDB(connector)
 	}
 
-	_, err = db.Exec(string(migrationSQL))
-	if err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("✅ GoExpress database migrations completed successfully")
-	return nil
+	return &DB{DB: db}, nil
 }
 
 func (db *DB) Close() error {
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations are numbered SQL files, e.g. 0002_scheduled_pickups.sql. Each is
+// applied once, in version order, and recorded in schema_migrations.
+//
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// migrationLockKey serializes runners across processes via pg_advisory_xact_lock.
+const migrationLockKey = 72010419
+
+type migration struct {
+	version int64
+	name    string
+	sql     string
+}
+
+// RunMigrations applies the migrations bundled with the binary and, once
+// they all succeed, marks the database ready.
+func (db *DB) RunMigrations() error {
+	migrations, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	if err := db.Migrate(migrations); err != nil {
+		return err
+	}
+
+	db.ready.Store(true)
+	log.Println("✅ GoExpress database migrations completed successfully")
+	return nil
+}
+
+// Migrate applies every migration in fsys that is not yet recorded in
+// schema_migrations. Each migration runs in its own transaction; a failure
+// rolls that migration back and stops the run.
+func (db *DB) Migrate(fsys fs.FS) error {
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		applied, err := db.applyMigration(m)
+		if err != nil {
+			return fmt.Errorf("failed to run migration %d (%s): %w", m.version, m.name, err)
+		}
+		if applied {
+			log.Printf("✅ Applied migration %d: %s", m.version, m.name)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) applyMigration(m migration) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Another instance may be migrating concurrently; take the lock before
+	// checking whether this version is still pending.
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockKey); err != nil {
+		return false, err
+	}
+
+	var applied bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", m.version).Scan(&applied)
+	if err != nil {
+		return false, err
+	}
+	if applied {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	seen := make(map[int64]string)
+	var migrations []migration
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s must start with a version number", file)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, file, version)
+		}
+		seen[version] = file
+
+		contents, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(contents)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 if none.
+func SchemaVersion(db *sql.DB) (int64, error) {
+	var version int64
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
@@ -64,4 +64,4 @@ CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
 CREATE INDEX IF NOT EXISTS idx_shipments_tracking ON shipments(tracking_number);
 CREATE INDEX IF NOT EXISTS idx_shipments_customer ON shipments(customer_id);
 CREATE INDEX IF NOT EXISTS idx_shipments_driver ON shipments(driver_id);
-CREATE INDEX IF NOT EXISTS idx_tracking_shipment ON tracking_updates(shipment_id);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_tracking_shipment ON tracking_updates(shipment_id);
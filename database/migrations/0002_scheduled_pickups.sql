@@ -0,0 +1,4 @@
+-- Scheduled pickups
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS pickup_scheduled_at TIMESTAMP;
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS pickup_window INTEGER; -- window length in minutes
+CREATE INDEX IF NOT EXISTS idx_shipments_pickup_scheduled ON shipments(pickup_scheduled_at);
@@ -0,0 +1,4 @@
+-- Stored shipment cost, fixed at creation time
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS cost DECIMAL(10,2) NOT NULL DEFAULT 0;
+UPDATE shipments s SET cost = ROUND(s.weight * z.price_per_kg, 2)
+FROM zones z WHERE s.zone_id = z.id AND s.cost = 0;
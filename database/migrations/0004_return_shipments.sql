@@ -0,0 +1,3 @@
+-- Return shipments (reverse logistics)
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS return_of INTEGER REFERENCES shipments(id);
+CREATE INDEX IF NOT EXISTS idx_shipments_return_of ON shipments(return_of);
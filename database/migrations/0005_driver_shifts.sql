@@ -0,0 +1,13 @@
+-- Driver shifts: drivers are only available while checked in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS driver_status VARCHAR(20) NOT NULL DEFAULT 'offline'
+    CHECK (driver_status IN ('available', 'busy', 'offline'));
+
+CREATE TABLE IF NOT EXISTS driver_shifts (
+    id SERIAL PRIMARY KEY,
+    driver_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_driver_shifts_driver ON driver_shifts(driver_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_driver_shifts_open ON driver_shifts(driver_id) WHERE ended_at IS NULL;
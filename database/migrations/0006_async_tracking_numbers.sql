@@ -0,0 +1,3 @@
+-- Async shipment creation: tracking numbers are assigned after insert
+ALTER TABLE shipments ALTER COLUMN tracking_number DROP NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_shipments_status ON shipments(status);
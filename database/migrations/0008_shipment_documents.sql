@@ -0,0 +1,13 @@
+-- Shipment documents (files stored on disk under UPLOAD_DIR)
+CREATE TABLE IF NOT EXISTS shipment_documents (
+    id SERIAL PRIMARY KEY,
+    shipment_id INTEGER REFERENCES shipments(id) ON DELETE CASCADE NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_path VARCHAR(512) NOT NULL,
+    uploaded_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipment_documents_shipment ON shipment_documents(shipment_id);
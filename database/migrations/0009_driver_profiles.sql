@@ -0,0 +1,16 @@
+-- Driver profiles: vehicle and contact details, and per-driver assignment capacity
+CREATE TABLE IF NOT EXISTS driver_profiles (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    phone VARCHAR(50),
+    license_number VARCHAR(100),
+    vehicle_type VARCHAR(50),
+    vehicle_number VARCHAR(50),
+    current_location VARCHAR(255),
+    max_concurrent_shipments INTEGER CHECK (max_concurrent_shipments > 0), -- NULL uses the configured default
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO driver_profiles (user_id)
+SELECT id FROM users WHERE role = 'driver'
+ON CONFLICT (user_id) DO NOTHING;
@@ -0,0 +1,32 @@
+-- Keep updated_at current on every row update, including updates made outside the API.
+-- An UPDATE that sets updated_at explicitly (e.g. backfills) keeps its value.
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.updated_at IS NOT DISTINCT FROM OLD.updated_at THEN
+        NEW.updated_at = CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE tracking_updates ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+DROP TRIGGER IF EXISTS users_set_updated_at ON users;
+CREATE TRIGGER users_set_updated_at BEFORE UPDATE ON users
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS zones_set_updated_at ON zones;
+CREATE TRIGGER zones_set_updated_at BEFORE UPDATE ON zones
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS shipments_set_updated_at ON shipments;
+CREATE TRIGGER shipments_set_updated_at BEFORE UPDATE ON shipments
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS tracking_updates_set_updated_at ON tracking_updates;
+CREATE TRIGGER tracking_updates_set_updated_at BEFORE UPDATE ON tracking_updates
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS driver_profiles_set_updated_at ON driver_profiles;
+CREATE TRIGGER driver_profiles_set_updated_at BEFORE UPDATE ON driver_profiles
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
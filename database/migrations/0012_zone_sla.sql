@@ -0,0 +1,28 @@
+-- Promised delivery time per zone, and when each shipment was delivered so
+-- deliveries can be checked against it.
+ALTER TABLE zones ADD COLUMN IF NOT EXISTS sla_hours INTEGER NOT NULL DEFAULT 72 CHECK (sla_hours > 0);
+
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP;
+
+UPDATE shipments s SET delivered_at = COALESCE(
+    (SELECT MIN(t.timestamp) FROM tracking_updates t WHERE t.shipment_id = s.id AND t.status = 'delivered'),
+    s.updated_at
+)
+WHERE s.status = 'delivered' AND s.delivered_at IS NULL;
+
+-- Stamp delivered_at whenever a shipment becomes delivered, unless the
+-- statement sets it explicitly.
+CREATE OR REPLACE FUNCTION set_delivered_at() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status = 'delivered' AND NEW.delivered_at IS NULL THEN
+        NEW.delivered_at = CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS shipments_set_delivered_at ON shipments;
+CREATE TRIGGER shipments_set_delivered_at BEFORE INSERT OR UPDATE ON shipments
+    FOR EACH ROW EXECUTE FUNCTION set_delivered_at();
+
+CREATE INDEX IF NOT EXISTS idx_shipments_delivered_at ON shipments(delivered_at);
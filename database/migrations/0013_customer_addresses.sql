@@ -0,0 +1,22 @@
+-- Saved billing and shipping addresses for customers
+CREATE TABLE IF NOT EXISTS customer_addresses (
+    id SERIAL PRIMARY KEY,
+    customer_id INTEGER REFERENCES customers(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('billing', 'shipping', 'both')),
+    label VARCHAR(50) NOT NULL,
+    address_line1 VARCHAR(255) NOT NULL,
+    address_line2 VARCHAR(255),
+    city VARCHAR(100) NOT NULL,
+    state VARCHAR(100) NOT NULL,
+    postal_code VARCHAR(20) NOT NULL,
+    country VARCHAR(100) NOT NULL,
+    is_default BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_addresses_customer_id ON customer_addresses(customer_id);
+
+DROP TRIGGER IF EXISTS customer_addresses_set_updated_at ON customer_addresses;
+CREATE TRIGGER customer_addresses_set_updated_at BEFORE UPDATE ON customer_addresses
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
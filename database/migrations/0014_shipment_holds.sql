@@ -0,0 +1,4 @@
+-- Shipments can be paused (e.g. for customs or payment) without being cancelled.
+-- hold_reason is set only while the shipment is on hold.
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS on_hold BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS hold_reason TEXT;
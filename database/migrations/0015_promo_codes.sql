@@ -0,0 +1,22 @@
+-- Discount codes for quotes and shipments. Codes are stored upper-case and
+-- give either a percentage or a flat amount off.
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL CHECK (code = UPPER(code)),
+    percent_off DECIMAL(5,2) CHECK (percent_off > 0 AND percent_off <= 100),
+    flat_off DECIMAL(10,2) CHECK (flat_off > 0),
+    valid_from TIMESTAMP,
+    valid_until TIMESTAMP,
+    max_uses INTEGER CHECK (max_uses > 0),
+    used_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((percent_off IS NULL) <> (flat_off IS NULL))
+);
+
+DROP TRIGGER IF EXISTS promo_codes_set_updated_at ON promo_codes;
+CREATE TRIGGER promo_codes_set_updated_at BEFORE UPDATE ON promo_codes
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS promo_code_id INTEGER REFERENCES promo_codes(id);
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS discount DECIMAL(10,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Drivers are paid a commission on the cost of each shipment they deliver.
+-- NULL uses the configured default rate.
+ALTER TABLE driver_profiles ADD COLUMN IF NOT EXISTS commission_rate DECIMAL(5,4)
+    CHECK (commission_rate >= 0 AND commission_rate <= 1);
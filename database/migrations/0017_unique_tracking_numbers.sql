@@ -0,0 +1,9 @@
+-- Tracking numbers must be unique. 0001 declared the column UNIQUE; make sure
+-- the constraint exists under the name the API maps violations from.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'shipments_tracking_number_key') THEN
+        ALTER TABLE shipments ADD CONSTRAINT shipments_tracking_number_key UNIQUE (tracking_number);
+    END IF;
+END
+$$;
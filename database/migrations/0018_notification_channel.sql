@@ -0,0 +1,3 @@
+-- Customers choose how they hear about status changes on their shipments.
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS notification_channel VARCHAR(10) NOT NULL DEFAULT 'email'
+    CHECK (notification_channel IN ('email', 'sms'));
@@ -0,0 +1,49 @@
+-- Human-friendly shipment references, e.g. GEX-2025-000123, numbered without
+-- gaps per year of creation. The per-year counter row is locked by each
+-- insert until its transaction ends, so concurrent creates cannot share a
+-- number and a rolled-back create gives its number back.
+CREATE TABLE IF NOT EXISTS shipment_sequences (
+    year INTEGER PRIMARY KEY,
+    last_value INTEGER NOT NULL
+);
+
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS reference VARCHAR(20);
+
+WITH numbered AS (
+    SELECT id, EXTRACT(YEAR FROM created_at)::INTEGER AS year,
+           ROW_NUMBER() OVER (PARTITION BY EXTRACT(YEAR FROM created_at) ORDER BY created_at, id) AS n
+    FROM shipments
+    WHERE reference IS NULL
+)
+UPDATE shipments s SET reference = 'GEX-' || numbered.year || '-' || LPAD(numbered.n::TEXT, 6, '0')
+FROM numbered
+WHERE s.id = numbered.id;
+
+INSERT INTO shipment_sequences (year, last_value)
+SELECT EXTRACT(YEAR FROM created_at)::INTEGER, COUNT(*)
+FROM shipments
+GROUP BY 1
+ON CONFLICT (year) DO NOTHING;
+
+CREATE OR REPLACE FUNCTION set_shipment_reference() RETURNS TRIGGER AS $$
+DECLARE
+    seq_year INTEGER;
+    seq_value INTEGER;
+BEGIN
+    IF NEW.reference IS NULL THEN
+        seq_year := EXTRACT(YEAR FROM COALESCE(NEW.created_at, CURRENT_TIMESTAMP))::INTEGER;
+        INSERT INTO shipment_sequences (year, last_value) VALUES (seq_year, 1)
+        ON CONFLICT (year) DO UPDATE SET last_value = shipment_sequences.last_value + 1
+        RETURNING last_value INTO seq_value;
+        NEW.reference := 'GEX-' || seq_year || '-' || LPAD(seq_value::TEXT, 6, '0');
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS shipments_set_reference ON shipments;
+CREATE TRIGGER shipments_set_reference BEFORE INSERT ON shipments
+    FOR EACH ROW EXECUTE FUNCTION set_shipment_reference();
+
+ALTER TABLE shipments ALTER COLUMN reference SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shipments_reference ON shipments(reference);
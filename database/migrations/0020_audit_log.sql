@@ -0,0 +1,13 @@
+-- Actions taken on someone else's behalf, e.g. by an admin impersonating a
+-- customer. actor_id is who really acted. No foreign keys, so entries
+-- outlive the users they name.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id SERIAL PRIMARY KEY,
+    actor_id INTEGER NOT NULL,
+    user_id INTEGER,
+    action VARCHAR(255) NOT NULL,
+    status_code INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id, created_at);
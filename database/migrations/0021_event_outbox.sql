@@ -0,0 +1,15 @@
+-- Transactional outbox: shipment events are written in the same transaction
+-- as the change they describe, then delivered to webhooks by a background
+-- relay, so no event is lost if the process dies between commit and send.
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(100) NOT NULL,
+    shipment_id INTEGER,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_outbox_pending ON event_outbox(id) WHERE delivered_at IS NULL;
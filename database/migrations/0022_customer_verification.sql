@@ -0,0 +1,12 @@
+-- Customers verify their email or phone with a one-time code before
+-- shipping. Customers who have already shipped are treated as verified.
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS phone_verified_at TIMESTAMP;
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS verification_channel VARCHAR(10);
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS verification_code_hash VARCHAR(255);
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS verification_expires_at TIMESTAMP;
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS verification_attempts INTEGER NOT NULL DEFAULT 0;
+
+UPDATE customers c SET email_verified_at = CURRENT_TIMESTAMP
+WHERE c.email_verified_at IS NULL AND c.phone_verified_at IS NULL
+  AND EXISTS (SELECT 1 FROM shipments s WHERE s.customer_id = c.user_id);
@@ -0,0 +1,3 @@
+-- Finding a shipment's latest tracking update, e.g. for the inactive
+-- shipments report, reads one index entry instead of all its updates.
+CREATE INDEX IF NOT EXISTS idx_tracking_shipment_timestamp ON tracking_updates(shipment_id, timestamp DESC);
@@ -0,0 +1,3 @@
+-- Tracking updates can explain themselves, e.g. when an admin re-zones a
+-- shipment without changing its status.
+ALTER TABLE tracking_updates ADD COLUMN IF NOT EXISTS note TEXT;
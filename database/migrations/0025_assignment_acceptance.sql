@@ -0,0 +1,3 @@
+-- Drivers accept or decline the shipments assigned to them. accepted_at is
+-- cleared whenever the shipment changes driver.
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS accepted_at TIMESTAMP;
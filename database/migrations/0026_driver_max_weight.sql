@@ -0,0 +1,3 @@
+-- The heaviest single shipment a driver's vehicle can carry, in kg, e.g.
+-- far less for a motorcycle than a truck. NULL means no limit.
+ALTER TABLE driver_profiles ADD COLUMN IF NOT EXISTS max_weight DECIMAL(10,2) CHECK (max_weight > 0);
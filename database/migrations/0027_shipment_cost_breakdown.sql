@@ -0,0 +1,4 @@
+-- The itemized cost of a shipment as priced at creation (base price, promo
+-- discount, ...), summing to cost. NULL for shipments created before it was
+-- stored; their breakdown is derived from cost and discount.
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS cost_breakdown JSONB;
@@ -0,0 +1,17 @@
+-- Staff notes on a customer, kept as an append-only thread instead of the
+-- single customers.notes field that each update overwrote.
+CREATE TABLE IF NOT EXISTS customer_notes (
+    id SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_notes_customer_id ON customer_notes(customer_id, created_at);
+
+-- Existing notes start the thread. Their author was never recorded, and
+-- updated_at is the closest to when they were written.
+INSERT INTO customer_notes (customer_id, body, created_at)
+SELECT id, notes, updated_at FROM customers
+WHERE NULLIF(TRIM(notes), '') IS NOT NULL;
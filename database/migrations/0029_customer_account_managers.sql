@@ -0,0 +1,4 @@
+-- The admin who manages an enterprise customer's account, if any.
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS account_manager_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_customers_account_manager_id ON customers(account_manager_id);
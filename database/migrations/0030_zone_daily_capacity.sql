@@ -0,0 +1,2 @@
+-- The most shipments a zone's hub takes in per (UTC) day. NULL means no limit.
+ALTER TABLE zones ADD COLUMN IF NOT EXISTS daily_capacity INTEGER CHECK (daily_capacity > 0);
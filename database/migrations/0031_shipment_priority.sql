@@ -0,0 +1,4 @@
+-- How urgently a shipment is handled. Express and overnight cost more and are
+-- assigned and listed on manifests ahead of standard shipments.
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS priority VARCHAR(20) NOT NULL DEFAULT 'standard'
+    CHECK (priority IN ('standard', 'express', 'overnight'));
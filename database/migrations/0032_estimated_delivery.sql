@@ -0,0 +1,4 @@
+-- When the recipient expects a shipment, set when a delivery is rescheduled.
+-- NULL means no date was agreed.
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS estimated_delivery TIMESTAMP;
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS delivery_window INTEGER CHECK (delivery_window > 0); -- window length in minutes
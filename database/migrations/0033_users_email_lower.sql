@@ -0,0 +1,5 @@
+-- Emails are unique regardless of case. The API lowercases emails on write;
+-- existing addresses are lowercased here first. This fails if two accounts
+-- differ only by case, and those must be merged by hand before migrating.
+UPDATE users SET email = LOWER(email) WHERE email <> LOWER(email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email));
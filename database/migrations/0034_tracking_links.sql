@@ -0,0 +1,13 @@
+-- Shareable tracking links. The token handed out is random and carries no
+-- shipment data; only its SHA-256 hash is stored. expires_at NULL means the
+-- link never expires.
+CREATE TABLE IF NOT EXISTS tracking_links (
+    id SERIAL PRIMARY KEY,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    shipment_id INTEGER NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tracking_links_shipment_id ON tracking_links(shipment_id);
@@ -0,0 +1,24 @@
+-- Drivers' answers to their assignments. These used to be written to
+-- tracking_updates as "accepted"/"unassigned", which customers could see on
+-- the public timeline along with the driver's decline reason. Existing rows
+-- are moved here; their driver is no longer known. Driver offboarding also
+-- wrote "unassigned" rows, but without a location, while responses always
+-- carried one, so those are left alone.
+CREATE TABLE IF NOT EXISTS assignment_responses (
+    id SERIAL PRIMARY KEY,
+    shipment_id INTEGER NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    driver_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    accepted BOOLEAN NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_assignment_responses_shipment_id ON assignment_responses(shipment_id, created_at);
+
+INSERT INTO assignment_responses (shipment_id, accepted, reason, created_at)
+SELECT shipment_id, status = 'accepted', note, timestamp
+FROM tracking_updates
+WHERE status = 'accepted' OR (status = 'unassigned' AND location IS NOT NULL);
+
+DELETE FROM tracking_updates
+WHERE status = 'accepted' OR (status = 'unassigned' AND location IS NOT NULL);
@@ -0,0 +1,5 @@
+-- Limits on sending verification codes: a minimum gap between codes and a
+-- cap per hour, counted from verification_window_started_at.
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS verification_sent_at TIMESTAMP;
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS verification_window_started_at TIMESTAMP;
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS verification_starts INTEGER NOT NULL DEFAULT 0;
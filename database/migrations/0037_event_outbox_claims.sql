@@ -0,0 +1,4 @@
+-- The relay claims a batch of events by setting claimed_until, commits, and
+-- only then dispatches them, so no locks are held during webhook calls. An
+-- expired claim means the relay died mid-batch and the event is due again.
+ALTER TABLE event_outbox ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP;
@@ -0,0 +1,11 @@
+-- Driver offboarding used to add "unassigned" or "reassigned" rows, with no
+-- location, to the shipments' tracking timelines, which customers see. It is
+-- audited now; existing rows move to audit_log. The admin who offboarded was
+-- never recorded, so their actor_id is 0.
+INSERT INTO audit_log (actor_id, action, created_at)
+SELECT 0, 'offboard_driver shipment ' || shipment_id || ' ' || status, timestamp
+FROM tracking_updates
+WHERE status = 'reassigned' OR (status = 'unassigned' AND location IS NULL);
+
+DELETE FROM tracking_updates
+WHERE status = 'reassigned' OR (status = 'unassigned' AND location IS NULL);
@@ -0,0 +1,3 @@
+-- Tracking link tokens are now HMAC-signed and carry their own expiry, so
+-- they no longer need to be stored.
+DROP TABLE IF EXISTS tracking_links;
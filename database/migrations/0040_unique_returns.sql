@@ -0,0 +1,6 @@
+-- A shipment has at most one return. The API checks before creating one,
+-- but only this index stops two concurrent requests from both passing the
+-- check. This fails if a shipment already has several returns; all but one
+-- must be unlinked by hand before migrating.
+DROP INDEX IF EXISTS idx_shipments_return_of;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shipments_return_of ON shipments(return_of) WHERE return_of IS NOT NULL;
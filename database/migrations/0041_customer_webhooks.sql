@@ -0,0 +1,5 @@
+-- Customers can receive events for their own shipments at a URL of their
+-- choosing, signed with a secret issued to them alone.
+ALTER TABLE customers
+    ADD COLUMN IF NOT EXISTS webhook_url TEXT,
+    ADD COLUMN IF NOT EXISTS webhook_secret TEXT;
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"goexpress-api/models"
+	"goexpress-api/utils"
+)
+
+// Reads of a single resource by id answer "not yours" exactly like "does
+// not exist", so non-admins cannot probe which ids are in use. Admins can
+// see every resource and therefore only get a 404 for a missing one.
+
+// requireVisible writes a 404 for the resource unless visible is true, and
+// reports whether the handler may continue. Pass false both when the row
+// does not exist and when the caller may not see it.
+func requireVisible(w http.ResponseWriter, visible bool, resource string) bool {
+	if !visible {
+		http.Error(w, resource+" not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// canViewShipment reports whether the user may read the shipment: admins,
+// the owning client and the assigned driver.
+func canViewShipment(claims *utils.Claims, shipment *models.Shipment) bool {
+	switch claims.Role {
+	case "admin":
+		return true
+	case "client":
+		return shipment.CustomerID == claims.UserID
+	case "driver":
+		return shipment.DriverID != nil && *shipment.DriverID == claims.UserID
+	}
+	return false
+}
+
+// canViewUser reports whether the user may read the given user's record:
+// admins and the user themselves.
+func canViewUser(claims *utils.Claims, userID int) bool {
+	return claims.Role == "admin" || claims.UserID == userID
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+)
+
+// @Summary Export my account data
+// @Description Download everything stored about the authenticated user: profile, customer record, saved addresses and shipments
+// @Tags users
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.AccountExport
+// @Router /api/users/me/export [get]
+func (h *UserHandler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	export := models.AccountExport{
+		ExportedAt: models.NewUTCTime(time.Now()),
+		Addresses:  []models.CustomerAddress{},
+		Shipments:  []models.Shipment{},
+	}
+
+	err := h.db.QueryRow(`
+		SELECT id, name, email, role, is_active, created_at, updated_at 
+		FROM users WHERE id = $1`,
+		claims.UserID,
+	).Scan(&export.User.ID, &export.User.Name, &export.User.Email, &export.User.Role,
+		&export.User.IsActive, &export.User.CreatedAt, &export.User.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var customer models.Customer
+	err = h.db.QueryRow(customerSelect+`
+		WHERE c.user_id = $1`,
+		claims.UserID,
+	).Scan(customerFields(&customer)...)
+	switch {
+	case err == nil:
+		export.Customer = &customer
+	case err != sql.ErrNoRows:
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if export.Customer != nil {
+		rows, err := h.db.Query(`
+			SELECT id, customer_id, type, label, address_line1, COALESCE(address_line2, ''),
+				city, state, postal_code, country, COALESCE(is_default, FALSE), created_at, updated_at
+			FROM customer_addresses WHERE customer_id = $1 ORDER BY id`,
+			export.Customer.ID,
+		)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var a models.CustomerAddress
+			err := rows.Scan(&a.ID, &a.CustomerID, &a.Type, &a.Label, &a.AddressLine1, &a.AddressLine2,
+				&a.City, &a.State, &a.PostalCode, &a.Country, &a.IsDefault, &a.CreatedAt, &a.UpdatedAt)
+			if err != nil {
+				http.Error(w, "Failed to scan address", http.StatusInternalServerError)
+				return
+			}
+			export.Addresses = append(export.Addresses, a)
+		}
+	}
+
+	rows, err := h.db.Query(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE customer_id = $1 ORDER BY created_at, id`,
+		claims.UserID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.Shipment
+		if err := rows.Scan(shipmentFields(&s)...); err != nil {
+			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
+			return
+		}
+		export.Shipments = append(export.Shipments, s)
+	}
+
+	filename := "goexpress-account-" + strconv.Itoa(claims.UserID) + ".json"
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	json.NewEncoder(w).Encode(export)
+}
+
+// deletedAccountPasswordHash is not a valid bcrypt hash, so no password can
+// ever match an anonymized account.
+const deletedAccountPasswordHash = "!deleted"
+
+// @Summary Delete my account
+// @Description Anonymize the authenticated user's personal data after confirming their password. Shipments are kept for accounting, with the customer reference pointing at the anonymized account.
+// @Tags users
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.DeleteAccountRequest true "Current password"
+// @Success 200 {object} map[string]string
+// @Failure 403 {string} string "Password is incorrect"
+// @Router /api/users/me/delete [post]
+func (h *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var passwordHash string
+	err = tx.QueryRow("SELECT password_hash FROM users WHERE id = $1 FOR UPDATE", claims.UserID).Scan(&passwordHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.Password, passwordHash) {
+		http.Error(w, "Password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	if err := anonymizeUser(tx, claims.UserID); err != nil {
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Account deleted successfully",
+	})
+}
+
+// anonymizeUser replaces a user's personal data with placeholders and
+// deactivates the account. Rows that reference the user, such as shipments,
+// are left in place.
+func anonymizeUser(tx *sql.Tx, userID int) error {
+	id := strconv.Itoa(userID)
+	_, err := tx.Exec(`
+		UPDATE users SET name = 'Deleted User', email = $1, password_hash = $2, is_active = FALSE
+		WHERE id = $3`,
+		"deleted-"+id+"@deleted.invalid", deletedAccountPasswordHash, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM password_history WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM customer_addresses
+		WHERE customer_id IN (SELECT id FROM customers WHERE user_id = $1)`,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Staff notes are free text about the customer and may hold anything
+	_, err = tx.Exec(`
+		DELETE FROM customer_notes
+		WHERE customer_id IN (SELECT id FROM customers WHERE user_id = $1)`,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE customers SET company_name = 'Deleted Customer', contact_person = 'Deleted User', phone = '',
+			alternate_phone = NULL, website = NULL, tax_id = NULL, notes = NULL, status = 'inactive',
+			webhook_url = NULL, webhook_secret = NULL
+		WHERE user_id = $1`,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE driver_profiles SET phone = NULL, license_number = NULL, vehicle_number = NULL, current_location = NULL
+		WHERE user_id = $1`,
+		userID,
+	)
+	return err
+}
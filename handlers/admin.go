@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"goexpress-api/mailer"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+)
+
+type AdminHandler struct {
+	db          *sql.DB
+	maintenance *middleware.MaintenanceState
+	mailQueue   *mailer.Queue
+}
+
+func NewAdminHandler(db *sql.DB, maintenance *middleware.MaintenanceState) *AdminHandler {
+	return &AdminHandler{
+		db:          db,
+		maintenance: maintenance,
+	}
+}
+
+// SetMailQueue exposes the mail queue's delivery stats to admins.
+func (h *AdminHandler) SetMailQueue(q *mailer.Queue) {
+	h.mailQueue = q
+}
+
+// @Summary Get mail delivery status
+// @Description Get email delivery counters and the most recent send failures (admin only)
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} mailer.Stats
+// @Router /api/admin/mail [get]
+func (h *AdminHandler) GetMailStatus(w http.ResponseWriter, r *http.Request) {
+	stats := mailer.Stats{RecentFailures: []mailer.Failure{}}
+	if h.mailQueue != nil {
+		stats = h.mailQueue.Stats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// @Summary Get maintenance mode
+// @Description Get the current maintenance mode state (admin only)
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.MaintenanceStatus
+// @Router /api/admin/maintenance [get]
+func (h *AdminHandler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MaintenanceStatus{
+		Enabled:    h.maintenance.Enabled(),
+		BlockReads: h.maintenance.BlockReads(),
+	})
+}
+
+// @Summary Toggle maintenance mode
+// @Description Enable or disable maintenance mode without a restart (admin only)
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param maintenance body models.MaintenanceStatus true "Maintenance state"
+// @Success 200 {object} models.MaintenanceStatus
+// @Router /api/admin/maintenance [put]
+func (h *AdminHandler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req models.MaintenanceStatus
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	h.maintenance.Set(req.Enabled, req.BlockReads)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// @Summary List orphan shipments
+// @Description Data-integrity check listing shipments that have no tracking updates at all (admin only). Async shipments still waiting for a tracking number are not reported.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} models.Shipment
+// @Router /api/admin/orphan-shipments [get]
+func (h *AdminHandler) GetOrphanShipments(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT `+shipmentColumns+`
+		FROM shipments s
+		WHERE s.status <> $1
+		  AND NOT EXISTS (SELECT 1 FROM tracking_updates t WHERE t.shipment_id = s.id)
+		ORDER BY s.created_at ASC, s.id ASC`,
+		statusPendingTracking,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	shipments := []models.Shipment{}
+	for rows.Next() {
+		var s models.Shipment
+		if err := rows.Scan(shipmentFields(&s)...); err != nil {
+			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
+			return
+		}
+		shipments = append(shipments, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shipments)
+}
+
+const (
+	defaultActivityLimit = 50
+	maxActivityLimit     = 200
+)
+
+// activityFeedSQL merges the latest events of each kind; every branch is
+// limited on its own so only the newest rows of each table are read.
+// Initial "pending" tracking updates are left out since they duplicate the
+// shipment's creation.
+const activityFeedSQL = `
+	SELECT type, occurred_at, summary, shipment_id, user_id, zone_id FROM (
+		(SELECT 'shipment_created' AS type, s.created_at AS occurred_at,
+			'Shipment ' || COALESCE(s.tracking_number, '#' || s.id) || ' created from ' || s.origin || ' to ' || s.destination AS summary,
+			s.id AS shipment_id, s.customer_id AS user_id, s.zone_id AS zone_id
+		FROM shipments s
+		ORDER BY s.created_at DESC LIMIT $1)
+		UNION ALL
+		(SELECT 'status_changed', t.timestamp,
+			'Shipment ' || COALESCE(s.tracking_number, '#' || s.id) || ' is ' || t.status || COALESCE(' at ' || NULLIF(t.location, ''), ''),
+			s.id, NULL::int, NULL::int
+		FROM tracking_updates t
+		JOIN shipments s ON s.id = t.shipment_id
+		WHERE t.status <> 'pending'
+		ORDER BY t.timestamp DESC LIMIT $1)
+		UNION ALL
+		(SELECT 'user_created', u.created_at, 'User ' || u.name || ' (' || u.role || ') created',
+			NULL::int, u.id, NULL::int
+		FROM users u
+		ORDER BY u.created_at DESC LIMIT $1)
+		UNION ALL
+		(SELECT 'zone_created', z.created_at, 'Zone ' || z.name || ' created',
+			NULL::int, NULL::int, z.id
+		FROM zones z
+		ORDER BY z.created_at DESC LIMIT $1)
+		UNION ALL
+		(SELECT 'zone_updated', z.updated_at, 'Zone ' || z.name || ' updated',
+			NULL::int, NULL::int, z.id
+		FROM zones z
+		WHERE z.updated_at > z.created_at
+		ORDER BY z.updated_at DESC LIMIT $1)
+	) feed
+	ORDER BY occurred_at DESC
+	LIMIT $1`
+
+// @Summary Get activity feed
+// @Description Recent shipment creations, status changes, user creations and zone changes merged into one feed, newest first (admin only)
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param limit query int false "Number of events (default 50, max 200)"
+// @Success 200 {array} models.ActivityEvent
+// @Failure 400 {string} string "Invalid limit"
+// @Router /api/activity [get]
+func (h *AdminHandler) GetActivityFeed(w http.ResponseWriter, r *http.Request) {
+	limit := defaultActivityLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxActivityLimit {
+			http.Error(w, "Invalid limit (expected 1-"+strconv.Itoa(maxActivityLimit)+")", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	rows, err := h.db.Query(activityFeedSQL, limit)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	events := []models.ActivityEvent{}
+	for rows.Next() {
+		var e models.ActivityEvent
+		if err := rows.Scan(&e.Type, &e.OccurredAt, &e.Summary, &e.ShipmentID, &e.UserID, &e.ZoneID); err != nil {
+			http.Error(w, "Failed to scan activity", http.StatusInternalServerError)
+			return
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
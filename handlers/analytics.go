@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"goexpress-api/models"
+)
+
+type AnalyticsHandler struct {
+	db *sql.DB
+}
+
+func NewAnalyticsHandler(db *sql.DB) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		db: db,
+	}
+}
+
+// parseDateRange reads the from/to query params as YYYY-MM-DD or RFC3339.
+// A date-only "to" is inclusive of that whole day. Defaults to the last 30 days.
+func parseDateRange(r *http.Request) (time.Time, time.Time, bool) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, _, ok := parseDateParam(value)
+		if !ok {
+			return from, to, false
+		}
+		from = parsed
+	}
+
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, dateOnly, ok := parseDateParam(value)
+		if !ok {
+			return from, to, false
+		}
+		if dateOnly {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		to = parsed
+	}
+
+	return from, to, from.Before(to)
+}
+
+func parseDateParam(value string) (time.Time, bool, bool) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, true
+	}
+	return time.Time{}, false, false
+}
+
+// @Summary Get shipment status counts over time
+// @Description Get shipment counts per status bucketed by day, week or month (admin only)
+// @Tags analytics
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Start date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End date (YYYY-MM-DD or RFC3339)"
+// @Param interval query string false "Bucket size: day, week or month (default day)"
+// @Success 200 {object} models.ShipmentAnalytics
+// @Router /api/analytics/shipments [get]
+func (h *AnalyticsHandler) GetShipmentAnalytics(w http.ResponseWriter, r *http.Request) {
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+	if interval != "day" && interval != "week" && interval != "month" {
+		http.Error(w, "Invalid interval (expected day, week or month)", http.StatusBadRequest)
+		return
+	}
+
+	from, to, ok := parseDateRange(r)
+	if !ok {
+		http.Error(w, "Invalid date range", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT date_trunc($1, created_at) AS period, status, COUNT(*)
+		FROM shipments
+		WHERE created_at >= $2 AND created_at < $3
+		GROUP BY period, status
+		ORDER BY period, status`,
+		interval, from, to,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	response := models.ShipmentAnalytics{
+		From:     models.NewUTCTime(from),
+		To:       models.NewUTCTime(to),
+		Interval: interval,
+		Buckets:  []models.ShipmentStatusBucket{},
+	}
+
+	for rows.Next() {
+		var period models.UTCTime
+		var status string
+		var count int
+		if err := rows.Scan(&period, &status, &count); err != nil {
+			http.Error(w, "Failed to scan analytics", http.StatusInternalServerError)
+			return
+		}
+
+		buckets := response.Buckets
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Period.Equal(period.Time) {
+			response.Buckets = append(response.Buckets, models.ShipmentStatusBucket{
+				Period: period,
+				Counts: make(map[string]int),
+			})
+		}
+		bucket := &response.Buckets[len(response.Buckets)-1]
+		bucket.Counts[status] = count
+		bucket.Total += count
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// @Summary Get revenue analytics
+// @Description Get revenue from stored shipment costs grouped by zone or day, with a grand total (admin only).
+// @Description Cancelled shipments are excluded.
+// @Tags analytics
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Start date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End date (YYYY-MM-DD or RFC3339)"
+// @Param group_by query string false "zone or day (default zone)"
+// @Success 200 {object} models.RevenueReport
+// @Router /api/analytics/revenue [get]
+func (h *AnalyticsHandler) GetRevenueAnalytics(w http.ResponseWriter, r *http.Request) {
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "zone"
+	}
+
+	var query string
+	switch groupBy {
+	case "zone":
+		query = `
+		SELECT z.id, z.name, COUNT(s.id), COALESCE(SUM(s.cost), 0)
+		FROM shipments s
+		JOIN zones z ON s.zone_id = z.id
+		WHERE s.created_at >= $1 AND s.created_at < $2 AND s.status != 'cancelled'
+		GROUP BY z.id, z.name
+		ORDER BY z.name`
+	case "day":
+		query = `
+		SELECT date_trunc('day', created_at) AS period, COUNT(*), COALESCE(SUM(cost), 0)
+		FROM shipments
+		WHERE created_at >= $1 AND created_at < $2 AND status != 'cancelled'
+		GROUP BY period
+		ORDER BY period`
+	default:
+		http.Error(w, "Invalid group_by (expected zone or day)", http.StatusBadRequest)
+		return
+	}
+
+	from, to, ok := parseDateRange(r)
+	if !ok {
+		http.Error(w, "Invalid date range", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(query, from, to)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	report := models.RevenueReport{
+		From:    models.NewUTCTime(from),
+		To:      models.NewUTCTime(to),
+		GroupBy: groupBy,
+		Groups:  []models.RevenueGroup{},
+	}
+
+	for rows.Next() {
+		var group models.RevenueGroup
+		if groupBy == "zone" {
+			var zoneID int
+			err = rows.Scan(&zoneID, &group.ZoneName, &group.Shipments, &group.Revenue)
+			group.ZoneID = &zoneID
+		} else {
+			var period models.UTCTime
+			err = rows.Scan(&period, &group.Shipments, &group.Revenue)
+			group.Period = &period
+		}
+		if err != nil {
+			http.Error(w, "Failed to scan revenue", http.StatusInternalServerError)
+			return
+		}
+
+		report.Groups = append(report.Groups, group)
+		report.TotalRevenue += group.Revenue
+	}
+	report.TotalRevenue = math.Round(report.TotalRevenue*100) / 100
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// @Summary Get SLA breach report
+// @Description Per-zone count of shipments delivered in the date range and how many missed the zone's SLA (admin only)
+// @Tags analytics
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Start date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End date (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} models.SLAReport
+// @Router /api/analytics/sla [get]
+func (h *AnalyticsHandler) GetSLAReport(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := parseDateRange(r)
+	if !ok {
+		http.Error(w, "Invalid date range", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT z.id, z.name, z.sla_hours, COUNT(s.id),
+			COUNT(s.id) FILTER (WHERE s.delivered_at > COALESCE(s.pickup_scheduled_at, s.created_at) + z.sla_hours * INTERVAL '1 hour')
+		FROM zones z
+		LEFT JOIN shipments s ON s.zone_id = z.id AND s.status = 'delivered'
+			AND s.delivered_at >= $1 AND s.delivered_at < $2
+		GROUP BY z.id, z.name, z.sla_hours
+		ORDER BY z.name`,
+		from, to,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	report := models.SLAReport{
+		From:  models.NewUTCTime(from),
+		To:    models.NewUTCTime(to),
+		Zones: []models.ZoneSLA{},
+	}
+
+	for rows.Next() {
+		var zone models.ZoneSLA
+		if err := rows.Scan(&zone.ZoneID, &zone.ZoneName, &zone.SLAHours, &zone.Delivered, &zone.Breached); err != nil {
+			http.Error(w, "Failed to scan SLA report", http.StatusInternalServerError)
+			return
+		}
+		if zone.Delivered > 0 {
+			zone.BreachRate = math.Round(float64(zone.Breached)/float64(zone.Delivered)*10000) / 10000
+		}
+		report.Zones = append(report.Zones, zone)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// @Summary Get delivery density
+// @Description Count deliveries per destination, busiest first, e.g. for a heatmap (admin only). Shipments are counted by delivery date.
+// @Tags analytics
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Start date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End date (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} models.DeliveryDensity
+// @Router /api/analytics/delivery-density [get]
+func (h *AnalyticsHandler) GetDeliveryDensity(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := parseDateRange(r)
+	if !ok {
+		http.Error(w, "Invalid date range", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT MODE() WITHIN GROUP (ORDER BY TRIM(destination)), COUNT(*)
+		FROM shipments
+		WHERE status = 'delivered' AND delivered_at >= $1 AND delivered_at < $2
+		GROUP BY LOWER(TRIM(destination))
+		ORDER BY COUNT(*) DESC, LOWER(TRIM(destination))`,
+		from, to,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	density := models.DeliveryDensity{
+		From:         models.NewUTCTime(from),
+		To:           models.NewUTCTime(to),
+		Destinations: []models.DestinationDensity{},
+	}
+
+	for rows.Next() {
+		var destination models.DestinationDensity
+		if err := rows.Scan(&destination.Destination, &destination.Deliveries); err != nil {
+			http.Error(w, "Failed to scan delivery density", http.StatusInternalServerError)
+			return
+		}
+		density.Destinations = append(density.Destinations, destination)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(density)
+}
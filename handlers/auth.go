@@ -3,8 +3,14 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
 
+	"goexpress-api/audit"
+	"goexpress-api/mailer"
+	"goexpress-api/middleware"
 	"goexpress-api/models"
 	"goexpress-api/utils"
 	"github.com/go-playground/validator/v10"
@@ -15,6 +21,10 @@ type AuthHandler struct {
 	validator *validator.Validate
 	jwtSecret string
 	refreshSecret string
+	mailer        mailer.Mailer
+	auditLog      audit.Recorder
+	impersonationTTL time.Duration
+	registrationDisabled bool
 }
 
 func NewAuthHandler(db *sql.DB, jwtSecret, refreshSecret string) *AuthHandler {
@@ -23,11 +33,24 @@ func NewAuthHandler(db *sql.DB, jwtSecret, refreshSecret string) *AuthHandler {
 		validator: validator.New(),
 		jwtSecret: jwtSecret,
 		refreshSecret: refreshSecret,
+		impersonationTTL: defaultImpersonationTTL,
 	}
 }
 
+// SetMailer enables welcome emails to newly registered users. With no mailer
+// set, no emails are sent.
+func (h *AuthHandler) SetMailer(m mailer.Mailer) {
+	h.mailer = m
+}
+
+// SetRegistrationEnabled turns public self-registration on or off. Admins
+// can still create users either way.
+func (h *AuthHandler) SetRegistrationEnabled(enabled bool) {
+	h.registrationDisabled = !enabled
+}
+
 // @Summary User registration
-// @Description Register a new user with GoExpress
+// @Description Register a new user with GoExpress. Returns 403 when self-registration is disabled.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -35,6 +58,11 @@ func NewAuthHandler(db *sql.DB, jwtSecret, refreshSecret string) *AuthHandler {
 // @Success 201 {object} models.AuthResponse
 // @Router /api/auth/register [post]
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	if h.registrationDisabled {
+		http.Error(w, "Registration is disabled", http.StatusForbidden)
+		return
+	}
+
 	var req models.UserRegistration
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
@@ -45,6 +73,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Email = utils.NormalizeEmail(req.Email)
 
 	// Check if user already exists
 	var existingID int
@@ -66,11 +95,15 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	err = h.db.QueryRow(`
 		INSERT INTO users (name, email, password_hash, role) 
 		VALUES ($1, $2, $3, $4) 
-		RETURNING id, name, email, role, created_at, updated_at`,
+		RETURNING id, name, email, role, is_active, created_at, updated_at`,
 		req.Name, req.Email, hashedPassword, req.Role,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
 	
 	if err != nil {
+		if isDuplicateEmail(err) {
+			http.Error(w, "User already exists", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
@@ -94,11 +127,33 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		User:         user,
 	}
 
+	h.sendWelcomeEmail(user)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
 
+// sendWelcomeEmail greets a newly registered user. The account already
+// exists, so a failure to send is logged rather than failing registration.
+func (h *AuthHandler) sendWelcomeEmail(user models.User) {
+	if h.mailer == nil {
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Welcome to GoExpress",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Your GoExpress account has been created.\n\n"+
+			"Thank you for shipping with GoExpress.\n",
+			user.Name),
+	}
+	if err := h.mailer.Send(msg); err != nil {
+		log.Printf("Failed to send welcome email to user %d: %v", user.ID, err)
+	}
+}
+
 // @Summary User login
 // @Description Authenticate user and return tokens
 // @Tags auth
@@ -118,14 +173,15 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Email = utils.NormalizeEmail(req.Email)
 
 	// Get user from database
 	var user models.User
 	err := h.db.QueryRow(`
-		SELECT id, name, email, password_hash, role, created_at, updated_at 
+		SELECT id, name, email, password_hash, role, is_active, created_at, updated_at 
 		FROM users WHERE email = $1`,
 		req.Email,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -142,6 +198,11 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !user.IsActive {
+		http.Error(w, "Account is deactivated", http.StatusForbidden)
+		return
+	}
+
 	// Generate tokens
 	token, err := utils.GenerateJWT(user.ID, user.Email, user.Role, h.jwtSecret)
 	if err != nil {
@@ -163,4 +224,61 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+}
+
+// @Summary Introspect a token
+// @Description Check whether an access token is active without performing an action. The token is
+// @Description read from the body, or from the Authorization header if the body has none.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body models.IntrospectRequest false "Token to introspect"
+// @Success 200 {object} models.IntrospectResponse
+// @Router /api/auth/introspect [post]
+func (h *AuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	var req models.IntrospectRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tokenString := req.Token
+	if tokenString == "" {
+		var err error
+		if tokenString, err = middleware.BearerToken(r); err != nil {
+			http.Error(w, "Token required", http.StatusBadRequest)
+			return
+		}
+	}
+
+	response := models.IntrospectResponse{Active: false}
+
+	claims, err := utils.ValidateJWT(tokenString, h.jwtSecret)
+	if err == nil {
+		// Tokens of deleted or deactivated users are treated as revoked
+		var exists bool
+		err = h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND is_active)", claims.UserID).Scan(&exists)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if exists {
+			response = models.IntrospectResponse{
+				Active:         true,
+				UserID:         claims.UserID,
+				Role:           claims.Role,
+				ImpersonatorID: claims.ImpersonatorID,
+			}
+			if claims.ExpiresAt != nil {
+				expiresAt := models.NewUTCTime(claims.ExpiresAt.Time)
+				response.ExpiresAt = &expiresAt
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -2,19 +2,31 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"goexpress-api/middleware"
 	"goexpress-api/models"
+	"goexpress-api/notifier"
 	"goexpress-api/utils"
 	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultTrendMonths = 12
+	maxTrendMonths     = 60
 )
 
 type CustomerHandler struct {
 	db        *sql.DB
 	validator *validator.Validate
+	notifier  notifier.Notifier
 }
 
 func NewCustomerHandler(db *sql.DB) *CustomerHandler {
@@ -24,36 +36,15 @@ func NewCustomerHandler(db *sql.DB) *CustomerHandler {
 	}
 }
 
-// @Summary Get all customers
-// @Description Get all customers with stats (admin only)
-// @Tags customers
-// @Security ApiKeyAuth
-// @Produce json
-// @Param status query string false "Filter by status"
-// @Param business_type query string false "Filter by business type"
-// @Success 200 {array} models.Customer
-// @Router /api/customers [get]
-func (h *CustomerHandler) GetCustomers(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Only admin can view all customers
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
-	}
-
-	statusFilter := r.URL.Query().Get("status")
-	businessTypeFilter := r.URL.Query().Get("business_type")
-	
-	query := `
+// customerSelect selects customers with their user details and shipment
+// totals; callers append the WHERE clause. Scan rows with customerFields.
+const customerSelect = `
 		SELECT 
 			c.id, c.user_id, c.company_name, c.contact_person, c.phone, 
-			c.alternate_phone, c.website, c.tax_id, c.business_type, 
-			c.status, c.credit_limit, c.payment_terms, c.notes,
+			COALESCE(c.alternate_phone, ''), COALESCE(c.website, ''), COALESCE(c.tax_id, ''),
+			COALESCE(c.business_type, ''), c.status, c.credit_limit,
+			COALESCE(c.payment_terms, ''), COALESCE(c.notes, ''), c.notification_channel,
+			c.email_verified_at, c.phone_verified_at, c.account_manager_id,
 			c.created_at, c.updated_at,
 			u.name, u.email,
 			COALESCE(s.total_shipments, 0) as total_shipments,
@@ -65,53 +56,57 @@ func (h *CustomerHandler) GetCustomers(w http.ResponseWriter, r *http.Request) {
 			SELECT 
 				customer_id,
 				COUNT(*) as total_shipments,
-				SUM(weight * z.price_per_kg) as total_spent,
+				SUM(cost) as total_spent,
 				MAX(created_at) as last_shipment
-			FROM shipments sh
-			JOIN zones z ON sh.zone_id = z.id
+			FROM shipments
 			GROUP BY customer_id
-		) s ON c.user_id = s.customer_id
-		WHERE 1=1`
-
-	var args []interface{}
-	argIndex := 1
+		) s ON c.user_id = s.customer_id`
 
-	if statusFilter != "" {
-		query += " AND c.status = $" + strconv.Itoa(argIndex)
-		args = append(args, statusFilter)
-		argIndex++
+func customerFields(c *models.Customer) []interface{} {
+	return []interface{}{
+		&c.ID, &c.UserID, &c.CompanyName, &c.ContactPerson, &c.Phone,
+		&c.AlternatePhone, &c.Website, &c.TaxID, &c.BusinessType,
+		&c.Status, &c.CreditLimit, &c.PaymentTerms, &c.Notes, &c.NotificationChannel,
+		&c.EmailVerifiedAt, &c.PhoneVerifiedAt, &c.AccountManagerID,
+		&c.CreatedAt, &c.UpdatedAt,
+		&c.Name, &c.Email,
+		&c.TotalShipments, &c.TotalSpent, &c.LastShipment,
 	}
+}
 
-	if businessTypeFilter != "" {
-		query += " AND c.business_type = $" + strconv.Itoa(argIndex)
-		args = append(args, businessTypeFilter)
-		argIndex++
+// @Summary Get all customers
+// @Description Get all customers with stats (admin only)
+// @Tags customers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Filter by status"
+// @Param business_type query string false "Filter by business type"
+// @Success 200 {array} models.Customer
+// @Router /api/customers [get]
+func (h *CustomerHandler) GetCustomers(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
 	}
 
-	query += " ORDER BY c.created_at DESC"
-
-	rows, err := h.db.Query(query, args...)
+	where, args := customerFilters(r)
+	rows, err := h.db.Query(customerSelect+where+" ORDER BY c.created_at DESC", args...)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
 
-	var customers []models.Customer
+	customers := []models.Customer{}
 	for rows.Next() {
 		var c models.Customer
-		err := rows.Scan(
-			&c.ID, &c.UserID, &c.CompanyName, &c.ContactPerson, &c.Phone,
-			&c.AlternatePhone, &c.Website, &c.TaxID, &c.BusinessType,
-			&c.Status, &c.CreditLimit, &c.PaymentTerms, &c.Notes,
-			&c.CreatedAt, &c.UpdatedAt,
-			&c.Name, &c.Email,
-			&c.TotalShipments, &c.TotalSpent, &c.LastShipment,
-		)
+		err := rows.Scan(customerFields(&c)...)
 		if err != nil {
 			http.Error(w, "Failed to scan customer", http.StatusInternalServerError)
 			return
 		}
+		RedactCustomer(claims, &c)
 		customers = append(customers, c)
 	}
 
@@ -119,26 +114,122 @@ func (h *CustomerHandler) GetCustomers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(customers)
 }
 
-// @Summary Get customer stats
-// @Description Get customer statistics (admin only)
+// customerFilters builds the WHERE clause for the status and business_type
+// query filters shared by the customer list and export.
+func customerFilters(r *http.Request) (string, []interface{}) {
+	where := `
+		WHERE 1=1`
+	var args []interface{}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		args = append(args, status)
+		where += " AND c.status = $" + strconv.Itoa(len(args))
+	}
+	if businessType := r.URL.Query().Get("business_type"); businessType != "" {
+		args = append(args, businessType)
+		where += " AND c.business_type = $" + strconv.Itoa(len(args))
+	}
+	return where, args
+}
+
+// customerExportHeader is the header row of the customer CSV export.
+var customerExportHeader = []string{
+	"id", "company_name", "contact_person", "name", "email", "phone", "business_type",
+	"status", "credit_limit", "total_shipments", "total_spent", "last_shipment", "created_at",
+}
+
+// customerExportFlushRows is how many rows are buffered before they are
+// flushed to the client.
+const customerExportFlushRows = 500
+
+// @Summary Export customers
+// @Description Stream all customers with their shipment totals as CSV, e.g. for CRM sync (admin only)
 // @Tags customers
 // @Security ApiKeyAuth
-// @Produce json
-// @Success 200 {object} models.CustomerStats
-// @Router /api/customers/stats [get]
-func (h *CustomerHandler) GetCustomerStats(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+// @Produce text/csv
+// @Param format query string false "Export format; only csv is supported (default csv)"
+// @Param status query string false "Filter by status"
+// @Param business_type query string false "Filter by business type"
+// @Success 200 {string} string "CSV file"
+// @Failure 400 {string} string "Unsupported format"
+// @Router /api/customers/export [get]
+func (h *CustomerHandler) ExportCustomers(w http.ResponseWriter, r *http.Request) {
+	if format := r.URL.Query().Get("format"); format != "" && format != "csv" {
+		http.Error(w, "Unsupported format (expected csv)", http.StatusBadRequest)
 		return
 	}
 
-	// Only admin can view stats
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+	where, args := customerFilters(r)
+	rows, err := h.db.Query(customerSelect+where+" ORDER BY c.id", args...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="customers.csv"`)
+
+	// Rows are written as they are read, so the export never holds every
+	// customer in memory. Once streaming has started the status is sent, so
+	// failures can only be logged.
+	writer := csv.NewWriter(w)
+	writer.Write(customerExportHeader)
+	written := 0
+	for rows.Next() {
+		var c models.Customer
+		if err := rows.Scan(customerFields(&c)...); err != nil {
+			log.Printf("Failed to export customers: %v", err)
+			break
+		}
+
+		lastShipment := ""
+		if c.LastShipment != nil {
+			lastShipment = c.LastShipment.UTC().Format(time.RFC3339)
+		}
+		writer.Write([]string{
+			strconv.Itoa(c.ID), csvCell(c.CompanyName), csvCell(c.ContactPerson), csvCell(c.Name), csvCell(c.Email),
+			csvCell(c.Phone), csvCell(c.BusinessType), c.Status, strconv.FormatFloat(c.CreditLimit, 'f', 2, 64),
+			strconv.Itoa(c.TotalShipments), strconv.FormatFloat(c.TotalSpent, 'f', 2, 64),
+			lastShipment, c.CreatedAt.UTC().Format(time.RFC3339),
+		})
+
+		written++
+		if written%customerExportFlushRows == 0 {
+			writer.Flush()
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to export customers: %v", err)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Failed to write customer export: %v", err)
+	}
+}
 
+// csvCell keeps customer-entered text from being read as a formula when
+// the export is opened in a spreadsheet, by prefixing a quote to any cell
+// that starts with a formula character.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// @Summary Get customer stats
+// @Description Get customer statistics (admin only)
+// @Tags customers
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.CustomerStats
+// @Router /api/customers/stats [get]
+func (h *CustomerHandler) GetCustomerStats(w http.ResponseWriter, r *http.Request) {
 	var stats models.CustomerStats
 
 	// Get customer counts
@@ -158,10 +249,9 @@ func (h *CustomerHandler) GetCustomerStats(w http.ResponseWriter, r *http.Reques
 	// Get revenue stats
 	err = h.db.QueryRow(`
 		SELECT 
-			COALESCE(SUM(weight * z.price_per_kg), 0) as total_revenue,
-			COALESCE(AVG(weight * z.price_per_kg), 0) as average_order_value
-		FROM shipments s
-		JOIN zones z ON s.zone_id = z.id`,
+			COALESCE(SUM(cost), 0) as total_revenue,
+			COALESCE(AVG(cost), 0) as average_order_value
+		FROM shipments`,
 	).Scan(&stats.TotalRevenue, &stats.AverageOrderValue)
 
 	if err != nil {
@@ -173,21 +263,491 @@ func (h *CustomerHandler) GetCustomerStats(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(stats)
 }
 
-// Placeholder methods for other customer operations
+// @Summary Get customer
+// @Description Get a customer by ID (admin, or the customer's own user)
+// @Tags customers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Customer ID"
+// @Success 200 {object} models.Customer
+// @Failure 404 {string} string "Customer not found"
+// @Router /api/customers/{id} [get]
 func (h *CustomerHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "Not implemented", http.StatusNotImplemented)
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	customerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+
+	var customer models.Customer
+	err = h.db.QueryRow(customerSelect+`
+		WHERE c.id = $1`,
+		customerID,
+	).Scan(customerFields(&customer)...)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if !requireVisible(w, err == nil && canViewUser(claims, customer.UserID), "Customer") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
 }
 
+// @Summary Get customer shipment trend
+// @Description Shipment count and spend per calendar month for a customer, oldest first, including empty months.
+// @Description Cancelled shipments are excluded (admin, or the customer's own user).
+// @Tags customers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Customer ID"
+// @Param months query int false "Number of months including the current one (default 12, max 60)"
+// @Success 200 {object} models.CustomerTrend
+// @Failure 404 {string} string "Customer not found"
+// @Router /api/customers/{id}/trend [get]
+func (h *CustomerHandler) GetCustomerTrend(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	customerID, ok := pathCustomerID(w, r)
+	if !ok {
+		return
+	}
+
+	months := defaultTrendMonths
+	if value := r.URL.Query().Get("months"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxTrendMonths {
+			http.Error(w, "Invalid months (expected 1-"+strconv.Itoa(maxTrendMonths)+")", http.StatusBadRequest)
+			return
+		}
+		months = parsed
+	}
+
+	var userID int
+	err := h.db.QueryRow("SELECT user_id FROM customers WHERE id = $1", customerID).Scan(&userID)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !requireVisible(w, err == nil && canViewUser(claims, userID), "Customer") {
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT m.month, COUNT(s.id), COALESCE(SUM(s.cost), 0)
+		FROM generate_series(
+			date_trunc('month', CURRENT_TIMESTAMP) - ($2 - 1) * INTERVAL '1 month',
+			date_trunc('month', CURRENT_TIMESTAMP),
+			INTERVAL '1 month'
+		) AS m(month)
+		LEFT JOIN shipments s ON s.customer_id = $1
+			AND s.status != 'cancelled'
+			AND date_trunc('month', s.created_at) = m.month
+		GROUP BY m.month
+		ORDER BY m.month`,
+		userID, months,
+	)
+	if err != nil {
+		http.Error(w, "Failed to get customer trend", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	trend := models.CustomerTrend{CustomerID: customerID, Months: []models.CustomerTrendMonth{}}
+	for rows.Next() {
+		var month models.CustomerTrendMonth
+		if err := rows.Scan(&month.Month, &month.Shipments, &month.Spend); err != nil {
+			http.Error(w, "Failed to scan customer trend", http.StatusInternalServerError)
+			return
+		}
+		trend.Months = append(trend.Months, month)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trend)
+}
+
+// customerBalanceSQL sums each customer's outstanding balance, keyed by
+// user id. There is no payments ledger yet, so a shipment is owed while it is
+// in progress and treated as settled once delivered; cancelled shipments
+// are never owed.
+const customerBalanceSQL = `
+		SELECT customer_id, SUM(cost) AS balance
+		FROM shipments
+		WHERE status NOT IN ('delivered', 'cancelled')
+		GROUP BY customer_id`
+
+// @Summary List customers over their credit limit
+// @Description Customers whose outstanding balance (cost of shipments not yet delivered or cancelled) exceeds their credit limit,
+// @Description largest overage first. Customers without a credit limit (0) are left out (admin only).
+// @Tags customers
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} models.CustomerOverLimit
+// @Router /api/customers/over-limit [get]
+func (h *CustomerHandler) GetOverLimitCustomers(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT c.id, c.user_id, c.company_name, u.name, u.email,
+			c.credit_limit, b.balance, b.balance - c.credit_limit AS overage
+		FROM customers c
+		JOIN users u ON c.user_id = u.id
+		JOIN (` + customerBalanceSQL + `
+		) b ON b.customer_id = c.user_id
+		WHERE c.credit_limit > 0 AND b.balance > c.credit_limit
+		ORDER BY overage DESC, c.id`)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	customers := []models.CustomerOverLimit{}
+	for rows.Next() {
+		var c models.CustomerOverLimit
+		if err := rows.Scan(&c.CustomerID, &c.UserID, &c.CompanyName, &c.Name, &c.Email,
+			&c.CreditLimit, &c.Balance, &c.Overage); err != nil {
+			http.Error(w, "Failed to scan customer", http.StatusInternalServerError)
+			return
+		}
+		customers = append(customers, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customers)
+}
+
+// Placeholder methods for other customer operations
+
 func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Not implemented", http.StatusNotImplemented)
 }
 
+// @Summary Update customer
+// @Description Update a customer's details and notification preference (admin, or the customer's own user).
+// @Description Only admins may change the status, credit limit or notes. An omitted notification_channel keeps the current one,
+// @Description and changing the phone number clears its verification.
+// @Tags customers
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Customer ID"
+// @Param customer body models.UpdateCustomerRequest true "Customer data"
+// @Success 200 {object} models.Customer
+// @Failure 403 {string} string "Only admins can change a customer's status, credit limit or notes"
+// @Failure 404 {string} string "Customer not found"
+// @Router /api/customers/{id} [put]
 func (h *CustomerHandler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "Not implemented", http.StatusNotImplemented)
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	customerID, ok := pathCustomerID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateCustomerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var status, notes string
+	var creditLimit float64
+	err = tx.QueryRow(
+		"SELECT user_id, status, credit_limit, COALESCE(notes, '') FROM customers WHERE id = $1 FOR UPDATE",
+		customerID,
+	).Scan(&userID, &status, &creditLimit, &notes)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !requireVisible(w, err == nil && canViewUser(claims, userID), "Customer") {
+		return
+	}
+
+	if claims.Role != "admin" && (req.Status != status || req.CreditLimit != creditLimit || req.Notes != notes) {
+		http.Error(w, "Only admins can change a customer's status, credit limit or notes", http.StatusForbidden)
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE customers SET
+			company_name = $1, contact_person = $2,
+			phone_verified_at = CASE WHEN phone = $3 THEN phone_verified_at END, phone = $3,
+			alternate_phone = NULLIF($4, ''), website = NULLIF($5, ''), tax_id = NULLIF($6, ''),
+			business_type = NULLIF($7, ''), status = $8, credit_limit = $9, payment_terms = NULLIF($10, ''),
+			notification_channel = COALESCE(NULLIF($11, ''), notification_channel), notes = NULLIF($12, '')
+		WHERE id = $13`,
+		req.CompanyName, req.ContactPerson, req.Phone, req.AlternatePhone, req.Website, req.TaxID,
+		req.BusinessType, req.Status, req.CreditLimit, req.PaymentTerms, req.NotificationChannel, req.Notes,
+		customerID,
+	)
+	if err != nil {
+		http.Error(w, "Failed to update customer", http.StatusInternalServerError)
+		return
+	}
+
+	var customer models.Customer
+	err = tx.QueryRow(customerSelect+`
+		WHERE c.id = $1`,
+		customerID,
+	).Scan(customerFields(&customer)...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to update customer", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
 }
 
+// @Summary Delete customer
+// @Description Mark a customer inactive (admin only). The record is kept and can be restored with the activate endpoint.
+// @Tags customers
+// @Security ApiKeyAuth
+// @Param id path int true "Customer ID"
+// @Success 204
+// @Router /api/customers/{id} [delete]
 func (h *CustomerHandler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "Not implemented", http.StatusNotImplemented)
+	customerID, ok := pathCustomerID(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.db.Exec("UPDATE customers SET status = 'inactive' WHERE id = $1", customerID)
+	if err != nil {
+		http.Error(w, "Failed to delete customer", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Activate customer
+// @Description Set an inactive or suspended customer back to active (admin only)
+// @Tags customers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Customer ID"
+// @Success 200 {object} models.Customer
+// @Failure 404 {string} string "Customer not found"
+// @Failure 409 {string} string "Customer is already active"
+// @Router /api/customers/{id}/activate [post]
+func (h *CustomerHandler) ActivateCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := pathCustomerID(w, r)
+	if !ok {
+		return
+	}
+
+	var status string
+	err := h.db.QueryRow("SELECT status FROM customers WHERE id = $1", customerID).Scan(&status)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := h.db.Exec("UPDATE customers SET status = 'active' WHERE id = $1 AND status <> 'active'", customerID)
+	if err != nil {
+		http.Error(w, "Failed to activate customer", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Customer is already active", http.StatusConflict)
+		return
+	}
+
+	var customer models.Customer
+	err = h.db.QueryRow(customerSelect+`
+		WHERE c.id = $1`,
+		customerID,
+	).Scan(customerFields(&customer)...)
+	if err != nil {
+		http.Error(w, "Failed to get customer", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
+}
+
+// @Summary Assign account manager
+// @Description Set the admin who manages a customer's account, or remove it with a null manager_id (admin only)
+// @Tags customers
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Customer ID"
+// @Param manager body models.AssignAccountManagerRequest true "Account manager"
+// @Success 200 {object} models.Customer
+// @Failure 400 {string} string "Account manager must be an active admin"
+// @Failure 404 {string} string "Customer not found"
+// @Router /api/customers/{id}/manager [put]
+func (h *CustomerHandler) AssignAccountManager(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := pathCustomerID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AssignAccountManagerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.ManagerID != nil {
+		var eligible bool
+		err := h.db.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND role = 'admin' AND is_active)`,
+			*req.ManagerID,
+		).Scan(&eligible)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !eligible {
+			http.Error(w, "Account manager must be an active admin", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := h.db.Exec("UPDATE customers SET account_manager_id = $1 WHERE id = $2", req.ManagerID, customerID)
+	if err != nil {
+		http.Error(w, "Failed to assign account manager", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+
+	var customer models.Customer
+	err = h.db.QueryRow(customerSelect+`
+		WHERE c.id = $1`,
+		customerID,
+	).Scan(customerFields(&customer)...)
+	if err != nil {
+		http.Error(w, "Failed to get customer", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
+}
+
+// @Summary Get my managed customers
+// @Description Get the customers whose account manager is the caller, by company name (admin only)
+// @Tags customers
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} models.Customer
+// @Router /api/users/me/customers [get]
+func (h *CustomerHandler) GetManagedCustomers(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := h.db.Query(customerSelect+`
+		WHERE c.account_manager_id = $1
+		ORDER BY c.company_name, c.id`,
+		claims.UserID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	customers := []models.Customer{}
+	for rows.Next() {
+		var customer models.Customer
+		if err := rows.Scan(customerFields(&customer)...); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		customers = append(customers, customer)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customers)
+}
+
+// pathCustomerID parses the customer ID from the path, writing a 400 itself
+// when it is not a number.
+func pathCustomerID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	customerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return customerID, true
 }
 
 func (h *CustomerHandler) GetCustomerShipments(w http.ResponseWriter, r *http.Request) {
@@ -199,3 +759,136 @@ func (h *CustomerHandler) AddCustomerAddress(w http.ResponseWriter, r *http.Requ
 }
 
 
+
+// customerNoteSelect selects notes with their author's name; callers append
+// the WHERE clause. Scan rows with customerNoteFields.
+const customerNoteSelect = `
+		SELECT n.id, n.customer_id, n.author_id, COALESCE(u.name, ''), n.body, n.created_at
+		FROM customer_notes n
+		LEFT JOIN users u ON n.author_id = u.id`
+
+func customerNoteFields(n *models.CustomerNote) []interface{} {
+	return []interface{}{&n.ID, &n.CustomerID, &n.AuthorID, &n.AuthorName, &n.Body, &n.CreatedAt}
+}
+
+// customerExists writes a 404 or 500 itself when the customer can't be found.
+func (h *CustomerHandler) customerExists(w http.ResponseWriter, customerID int) bool {
+	var exists bool
+	err := h.db.QueryRow("SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)", customerID).Scan(&exists)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// @Summary Get customer notes
+// @Description Get the staff note thread on a customer, newest first (admin only)
+// @Tags customers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Customer ID"
+// @Success 200 {array} models.CustomerNote
+// @Failure 404 {string} string "Customer not found"
+// @Router /api/customers/{id}/notes [get]
+func (h *CustomerHandler) GetCustomerNotes(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := pathCustomerID(w, r)
+	if !ok || !h.customerExists(w, customerID) {
+		return
+	}
+
+	rows, err := h.db.Query(customerNoteSelect+`
+		WHERE n.customer_id = $1
+		ORDER BY n.created_at DESC, n.id DESC`,
+		customerID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	notes := []models.CustomerNote{}
+	for rows.Next() {
+		var note models.CustomerNote
+		if err := rows.Scan(customerNoteFields(&note)...); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notes)
+}
+
+// @Summary Add customer note
+// @Description Append a note to the staff note thread on a customer, authored by the caller (admin only)
+// @Tags customers
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Customer ID"
+// @Param note body models.CreateCustomerNoteRequest true "Note"
+// @Success 201 {object} models.CustomerNote
+// @Failure 404 {string} string "Customer not found"
+// @Router /api/customers/{id}/notes [post]
+func (h *CustomerHandler) AddCustomerNote(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	customerID, ok := pathCustomerID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateCustomerNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.customerExists(w, customerID) {
+		return
+	}
+
+	var noteID int
+	err := h.db.QueryRow(`
+		INSERT INTO customer_notes (customer_id, author_id, body)
+		VALUES ($1, $2, $3) RETURNING id`,
+		customerID, claims.UserID, req.Body,
+	).Scan(&noteID)
+	if err != nil {
+		http.Error(w, "Failed to add note", http.StatusInternalServerError)
+		return
+	}
+
+	var note models.CustomerNote
+	err = h.db.QueryRow(customerNoteSelect+`
+		WHERE n.id = $1`,
+		noteID,
+	).Scan(customerNoteFields(&note)...)
+	if err != nil {
+		http.Error(w, "Failed to get note", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
@@ -0,0 +1,596 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"goexpress-api/audit"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errShipmentNotFound  = errors.New("shipment not found")
+	errShipmentClosed    = errors.New("shipment is already delivered or cancelled")
+	errDriverNotFound    = errors.New("driver not found")
+	errDriverAtCapacity  = errors.New("driver has reached the maximum number of open shipments")
+	errDriverOverweight  = errors.New("shipment is heavier than the driver's vehicle can carry")
+	errNoDriverAvailable = errors.New("no available driver has remaining capacity")
+)
+
+// openShipmentsCondition matches shipments that still count against a
+// driver's capacity.
+const openShipmentsCondition = `status NOT IN ('delivered', 'cancelled')`
+
+type DispatchHandler struct {
+	db                    *sql.DB
+	validator             *validator.Validate
+	defaultDriverCapacity int
+	auditLog              audit.Recorder
+}
+
+// NewDispatchHandler creates a handler for assigning shipments to drivers.
+// defaultDriverCapacity applies to drivers whose profile sets no
+// max_concurrent_shipments of their own.
+func NewDispatchHandler(db *sql.DB, defaultDriverCapacity int) *DispatchHandler {
+	return &DispatchHandler{
+		db:                    db,
+		validator:             validator.New(),
+		defaultDriverCapacity: defaultDriverCapacity,
+	}
+}
+
+// SetAuditLog enables driver offboarding, which is always audited.
+func (h *DispatchHandler) SetAuditLog(recorder audit.Recorder) {
+	h.auditLog = recorder
+}
+
+// @Summary Assign a driver to a shipment
+// @Description Assign a shipment to a specific driver, unless the driver is at capacity or the shipment is heavier than their vehicle can carry (admin only)
+// @Tags dispatch
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Param request body models.AssignDriverRequest true "Driver to assign"
+// @Success 200 {object} models.Shipment
+// @Failure 404 {string} string "Shipment or driver not found"
+// @Failure 409 {string} string "Driver at capacity, shipment too heavy or shipment closed"
+// @Router /api/shipments/{id}/assign [post]
+func (h *DispatchHandler) AssignDriver(w http.ResponseWriter, r *http.Request) {
+	shipmentID, ok := h.pathShipmentID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AssignDriverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.lockOpenShipment(tx, shipmentID); err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+
+	if err := h.assign(tx, shipmentID, req.DriverID); err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+
+	h.commitAndRespond(w, tx, shipmentID)
+}
+
+// @Summary Auto-assign a driver to a shipment
+// @Description Assign a shipment to the checked-in driver with the fewest open shipments, remaining capacity and a vehicle that can carry it (admin only)
+// @Tags dispatch
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Success 200 {object} models.Shipment
+// @Failure 404 {string} string "Shipment not found"
+// @Failure 409 {string} string "No driver with remaining capacity"
+// @Router /api/shipments/{id}/auto-assign [post]
+func (h *DispatchHandler) AutoAssign(w http.ResponseWriter, r *http.Request) {
+	shipmentID, ok := h.pathShipmentID(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.lockOpenShipment(tx, shipmentID); err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+
+	// Least loaded first; capacity is re-checked under lock by assign since
+	// another dispatcher may fill a candidate in the meantime.
+	rows, err := tx.Query(`
+		SELECT u.id
+		FROM users u
+		LEFT JOIN driver_profiles p ON p.user_id = u.id
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS open_count FROM shipments s
+			WHERE s.driver_id = u.id AND s.id <> $2 AND s.`+openShipmentsCondition+`
+		) load
+		WHERE u.role = 'driver' AND u.is_active AND u.driver_status = 'available'
+		  AND load.open_count < COALESCE(p.max_concurrent_shipments, $1)
+		  AND (p.max_weight IS NULL OR p.max_weight >= (SELECT weight FROM shipments WHERE id = $2))
+		ORDER BY load.open_count, u.id`,
+		h.defaultDriverCapacity, shipmentID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var candidates []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		candidates = append(candidates, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, driverID := range candidates {
+		err := h.assign(tx, shipmentID, driverID)
+		if errors.Is(err, errDriverAtCapacity) || errors.Is(err, errDriverOverweight) {
+			continue
+		}
+		if err != nil {
+			writeDispatchError(w, err)
+			return
+		}
+		h.commitAndRespond(w, tx, shipmentID)
+		return
+	}
+
+	writeDispatchError(w, errNoDriverAvailable)
+}
+
+// @Summary Assign a batch of shipments to a driver
+// @Description Assign the highest priority, then oldest, unassigned pending shipments in a zone that the driver's vehicle can carry, up to the limit and the driver's remaining capacity (admin only)
+// @Tags dispatch
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Driver ID"
+// @Param request body models.AssignBatchRequest true "Zone and optional limit"
+// @Success 200 {array} models.Shipment
+// @Failure 404 {string} string "Driver or zone not found"
+// @Failure 409 {string} string "Driver at capacity"
+// @Router /api/drivers/{id}/assign-batch [post]
+func (h *DispatchHandler) AssignBatch(w http.ResponseWriter, r *http.Request) {
+	driverID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid driver ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.AssignBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var zoneExists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM zones WHERE id = $1)", req.ZoneID).Scan(&zoneExists); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !zoneExists {
+		http.Error(w, "Zone not found", http.StatusNotFound)
+		return
+	}
+
+	remaining, err := h.lockDriverCapacity(tx, driverID, 0)
+	if err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+	if remaining <= 0 {
+		writeDispatchError(w, errDriverAtCapacity)
+		return
+	}
+
+	limit := remaining
+	if req.Limit > 0 && req.Limit < limit {
+		limit = req.Limit
+	}
+
+	rows, err := tx.Query(`
+		UPDATE shipments SET driver_id = $1
+		WHERE id IN (
+			SELECT id FROM shipments
+			WHERE zone_id = $2 AND status = 'pending' AND driver_id IS NULL
+			  AND weight <= COALESCE((SELECT max_weight FROM driver_profiles WHERE user_id = $1), weight)
+			ORDER BY `+priorityOrder+`, created_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+shipmentColumns,
+		driverID, req.ZoneID, limit,
+	)
+	if err != nil {
+		http.Error(w, "Failed to assign shipments", http.StatusInternalServerError)
+		return
+	}
+
+	assigned := []models.Shipment{}
+	for rows.Next() {
+		var shipment models.Shipment
+		if err := rows.Scan(shipmentFields(&shipment)...); err != nil {
+			rows.Close()
+			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
+			return
+		}
+		assigned = append(assigned, shipment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Failed to assign shipments", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to assign shipments", http.StatusInternalServerError)
+		return
+	}
+
+	// RETURNING order is unspecified; report the batch in the order it was
+	// picked: highest priority first, then oldest first
+	sort.SliceStable(assigned, func(i, j int) bool {
+		if ri, rj := models.PriorityRank(assigned[i].Priority), models.PriorityRank(assigned[j].Priority); ri != rj {
+			return ri > rj
+		}
+		if !assigned[i].CreatedAt.Equal(assigned[j].CreatedAt.Time) {
+			return assigned[i].CreatedAt.Before(assigned[j].CreatedAt.Time)
+		}
+		return assigned[i].ID < assigned[j].ID
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assigned)
+}
+
+// @Summary Offboard a driver
+// @Description Move all of a driver's open shipments to another driver, or back to unassigned when reassign_to is omitted, and deactivate the driver (admin only)
+// @Description The offboarding is audited rather than added to the shipments' tracking timelines, which customers see.
+// @Tags dispatch
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Driver ID"
+// @Param request body models.OffboardDriverRequest true "Driver taking over the shipments"
+// @Success 200 {object} models.OffboardDriverResponse
+// @Failure 404 {string} string "Driver not found"
+// @Failure 409 {string} string "Driver at capacity or cannot carry every shipment"
+// @Failure 503 {string} string "Offboarding is not enabled"
+// @Router /api/drivers/{id}/offboard [post]
+func (h *DispatchHandler) OffboardDriver(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.auditLog == nil {
+		http.Error(w, "Offboarding drivers is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	driverID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid driver ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.OffboardDriverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.ReassignTo != nil && *req.ReassignTo == driverID {
+		http.Error(w, "Cannot reassign shipments to the driver being offboarded", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the driver so no shipments are assigned to them meanwhile.
+	// Offboarding deactivates the driver, so an inactive one is already done.
+	var locked int
+	err = tx.QueryRow("SELECT id FROM users WHERE id = $1 AND role = 'driver' AND is_active FOR UPDATE", driverID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		writeDispatchError(w, errDriverNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var open int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM shipments
+		WHERE driver_id = $1 AND `+openShipmentsCondition,
+		driverID,
+	).Scan(&open)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	takenOverBy := "unassigned"
+	if req.ReassignTo != nil {
+		remaining, err := h.lockDriverCapacity(tx, *req.ReassignTo, 0)
+		if err != nil {
+			writeDispatchError(w, err)
+			return
+		}
+		if remaining < open {
+			writeDispatchError(w, errDriverAtCapacity)
+			return
+		}
+
+		var fits bool
+		err = tx.QueryRow(`
+			SELECT COALESCE(bool_and(p.max_weight IS NULL OR s.weight <= p.max_weight), true)
+			FROM shipments s LEFT JOIN driver_profiles p ON p.user_id = $1
+			WHERE s.driver_id = $2 AND s.`+openShipmentsCondition,
+			*req.ReassignTo, driverID,
+		).Scan(&fits)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !fits {
+			writeDispatchError(w, errDriverOverweight)
+			return
+		}
+		takenOverBy = strconv.Itoa(*req.ReassignTo)
+	}
+
+	rows, err := tx.Query(`
+		UPDATE shipments SET driver_id = $1, accepted_at = NULL
+		WHERE driver_id = $2 AND `+openShipmentsCondition+`
+		RETURNING `+shipmentColumns,
+		req.ReassignTo, driverID,
+	)
+	if err != nil {
+		http.Error(w, "Failed to reassign shipments", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.OffboardDriverResponse{DriverID: driverID, ReassignedTo: req.ReassignTo, Shipments: []models.Shipment{}}
+	for rows.Next() {
+		var shipment models.Shipment
+		if err := rows.Scan(shipmentFields(&shipment)...); err != nil {
+			rows.Close()
+			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
+			return
+		}
+		response.Shipments = append(response.Shipments, shipment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Failed to reassign shipments", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec("UPDATE users SET is_active = false, driver_status = 'offline' WHERE id = $1", driverID)
+	if err != nil {
+		http.Error(w, "Failed to deactivate driver", http.StatusInternalServerError)
+		return
+	}
+
+	// Kept off tracking_updates: a change of driver is not a step in the
+	// delivery, and the timeline is shown to customers
+	err = h.auditLog.RecordTx(tx, audit.Entry{
+		ActorID:    claims.UserID,
+		UserID:     driverID,
+		Action:     fmt.Sprintf("%s %d->%s", audit.ActionOffboardDriver, driverID, takenOverBy),
+		StatusCode: http.StatusOK,
+	})
+	if err != nil {
+		log.Printf("Failed to audit offboarding of driver %d by admin %d: %v", driverID, claims.UserID, err)
+		http.Error(w, "Failed to record offboarding", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to reassign shipments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// pathShipmentID parses the shipment ID from the path, writing a 400 itself
+// when it is not a number.
+func (h *DispatchHandler) pathShipmentID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return shipmentID, true
+}
+
+// lockOpenShipment locks the shipment row for the rest of the transaction and
+// checks that it can still be assigned.
+func (h *DispatchHandler) lockOpenShipment(tx *sql.Tx, shipmentID int) error {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM shipments WHERE id = $1 FOR UPDATE`, shipmentID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return errShipmentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status == "delivered" || status == "cancelled" {
+		return errShipmentClosed
+	}
+	return nil
+}
+
+// assign gives the shipment to the driver unless the driver already has as
+// many open shipments as their capacity allows, or the shipment is heavier
+// than their vehicle's max_weight. The driver row is locked so
+// concurrent assignments to the same driver are serialized.
+func (h *DispatchHandler) assign(tx *sql.Tx, shipmentID, driverID int) error {
+	remaining, err := h.lockDriverCapacity(tx, driverID, shipmentID)
+	if err != nil {
+		return err
+	}
+	if remaining <= 0 {
+		return errDriverAtCapacity
+	}
+
+	var fits bool
+	err = tx.QueryRow(`
+		SELECT p.max_weight IS NULL OR s.weight <= p.max_weight
+		FROM shipments s LEFT JOIN driver_profiles p ON p.user_id = $1
+		WHERE s.id = $2`,
+		driverID, shipmentID,
+	).Scan(&fits)
+	if err != nil {
+		return err
+	}
+	if !fits {
+		return errDriverOverweight
+	}
+
+	_, err = tx.Exec(`
+		UPDATE shipments SET driver_id = $1,
+			accepted_at = CASE WHEN driver_id = $1 THEN accepted_at END
+		WHERE id = $2`,
+		driverID, shipmentID,
+	)
+	return err
+}
+
+// lockDriverCapacity locks the driver row and returns how many more open
+// shipments the driver can take. Inactive drivers count as not found. excludeShipmentID (0 for none) is not
+// counted, so reassigning a shipment to its current driver is not refused.
+func (h *DispatchHandler) lockDriverCapacity(tx *sql.Tx, driverID, excludeShipmentID int) (int, error) {
+	var capacity int
+	err := tx.QueryRow(`
+		SELECT COALESCE(p.max_concurrent_shipments, $2)
+		FROM users u
+		LEFT JOIN driver_profiles p ON p.user_id = u.id
+		WHERE u.id = $1 AND u.role = 'driver' AND u.is_active
+		FOR UPDATE OF u`,
+		driverID, h.defaultDriverCapacity,
+	).Scan(&capacity)
+	if err == sql.ErrNoRows {
+		return 0, errDriverNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var open int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM shipments
+		WHERE driver_id = $1 AND id <> $2 AND `+openShipmentsCondition,
+		driverID, excludeShipmentID,
+	).Scan(&open)
+	if err != nil {
+		return 0, err
+	}
+	return capacity - open, nil
+}
+
+func (h *DispatchHandler) commitAndRespond(w http.ResponseWriter, tx *sql.Tx, shipmentID int) {
+	var shipment models.Shipment
+	err := tx.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1`,
+		shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil {
+		http.Error(w, "Failed to get updated shipment", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to assign driver", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shipment)
+}
+
+func writeDispatchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errShipmentNotFound):
+		http.Error(w, "Shipment not found", http.StatusNotFound)
+	case errors.Is(err, errDriverNotFound):
+		http.Error(w, "Driver not found", http.StatusNotFound)
+	case errors.Is(err, errShipmentClosed):
+		http.Error(w, "Shipment is already delivered or cancelled", http.StatusConflict)
+	case errors.Is(err, errDriverAtCapacity):
+		http.Error(w, "Driver has reached the maximum number of open shipments", http.StatusConflict)
+	case errors.Is(err, errDriverOverweight):
+		http.Error(w, "Shipment is heavier than the driver's vehicle can carry", http.StatusConflict)
+	case errors.Is(err, errNoDriverAvailable):
+		http.Error(w, "No available driver has remaining capacity", http.StatusConflict)
+	default:
+		http.Error(w, "Failed to assign driver", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxDocumentSize = 10 << 20 // 10 MB
+	documentURLTTL  = 5 * time.Minute
+)
+
+// inlineDocumentTypes are the only content types served for display in the
+// browser. Anything else is downloaded as an opaque attachment, so an
+// uploaded HTML or SVG file cannot run script on the API's origin.
+var inlineDocumentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+}
+
+type DocumentHandler struct {
+	db            *sql.DB
+	uploadDir     string
+	signingSecret string
+}
+
+func NewDocumentHandler(db *sql.DB, uploadDir, signingSecret string) *DocumentHandler {
+	return &DocumentHandler{
+		db:            db,
+		uploadDir:     uploadDir,
+		signingSecret: signingSecret,
+	}
+}
+
+// @Summary Upload a shipment document
+// @Description Attach a file to a shipment (owning customer, assigned driver or admin)
+// @Tags documents
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Param file formData file true "Document"
+// @Success 201 {object} models.ShipmentDocument
+// @Router /api/shipments/{id}/documents [post]
+func (h *DocumentHandler) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	claims, shipmentID, ok := h.authorizeShipment(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "A document must be uploaded in the \"file\" field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		http.Error(w, "Failed to store document", http.StatusInternalServerError)
+		return
+	}
+	storagePath := filepath.Join(strconv.Itoa(shipmentID), hex.EncodeToString(name)+filepath.Ext(header.Filename))
+
+	size, err := h.store(storagePath, file)
+	if err != nil {
+		http.Error(w, "Failed to store document", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	var doc models.ShipmentDocument
+	err = h.db.QueryRow(`
+		INSERT INTO shipment_documents (shipment_id, file_name, content_type, size, storage_path, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, shipment_id, file_name, content_type, size, storage_path, uploaded_by, created_at`,
+		shipmentID, filepath.Base(header.Filename), contentType, size, storagePath, claims.UserID,
+	).Scan(&doc.ID, &doc.ShipmentID, &doc.FileName, &doc.ContentType, &doc.Size, &doc.StoragePath, &doc.UploadedBy, &doc.CreatedAt)
+
+	if err != nil {
+		os.Remove(filepath.Join(h.uploadDir, storagePath))
+		http.Error(w, "Failed to save document", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// @Summary Get a signed document URL
+// @Description Get a short-lived URL to download a shipment document without a Bearer token
+// @Tags documents
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Param docId path int true "Document ID"
+// @Success 200 {object} models.DocumentURLResponse
+// @Router /api/shipments/{id}/documents/{docId}/url [get]
+func (h *DocumentHandler) GetDocumentURL(w http.ResponseWriter, r *http.Request) {
+	_, shipmentID, ok := h.authorizeShipment(w, r)
+	if !ok {
+		return
+	}
+
+	documentID, err := strconv.Atoi(mux.Vars(r)["docId"])
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	err = h.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM shipment_documents WHERE id = $1 AND shipment_id = $2)",
+		documentID, shipmentID,
+	).Scan(&exists)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+
+	expiresAt := time.Now().Add(documentURLTTL)
+	token := utils.SignFileToken(documentID, expiresAt, h.signingSecret)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DocumentURLResponse{
+		URL:       "/files/" + token,
+		ExpiresAt: models.NewUTCTime(expiresAt),
+	})
+}
+
+// @Summary Download a document
+// @Description Stream a shipment document using a signed token from the document URL endpoint. PDF, PNG and JPEG files are shown inline; anything else is downloaded as an attachment.
+// @Tags documents
+// @Param token path string true "Signed file token"
+// @Success 200 {file} file
+// @Router /files/{token} [get]
+func (h *DocumentHandler) ServeFile(w http.ResponseWriter, r *http.Request) {
+	documentID, err := utils.VerifyFileToken(mux.Vars(r)["token"], h.signingSecret, time.Now())
+	if err != nil {
+		if err == utils.ErrExpiredFileToken {
+			http.Error(w, "Link has expired", http.StatusGone)
+			return
+		}
+		http.Error(w, "Invalid link", http.StatusForbidden)
+		return
+	}
+
+	var doc models.ShipmentDocument
+	err = h.db.QueryRow(`
+		SELECT file_name, content_type, storage_path, created_at
+		FROM shipment_documents WHERE id = $1`,
+		documentID,
+	).Scan(&doc.FileName, &doc.ContentType, &doc.StoragePath, &doc.CreatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Document not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(h.uploadDir, doc.StoragePath))
+	if err != nil {
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	contentType, disposition := "application/octet-stream", "attachment"
+	if mediaType, _, err := mime.ParseMediaType(doc.ContentType); err == nil && inlineDocumentTypes[mediaType] {
+		contentType, disposition = mediaType, "inline"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, doc.FileName))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, doc.FileName, doc.CreatedAt.Time, file)
+}
+
+// authorizeShipment checks that the requester may access the shipment's documents:
+// admins, the owning customer, or the assigned driver.
+func (h *DocumentHandler) authorizeShipment(w http.ResponseWriter, r *http.Request) (*utils.Claims, int, bool) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, 0, false
+	}
+
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	var owner models.Shipment
+	err = h.db.QueryRow("SELECT customer_id, driver_id FROM shipments WHERE id = $1", shipmentID).Scan(&owner.CustomerID, &owner.DriverID)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+	if !requireVisible(w, err == nil && canViewShipment(claims, &owner), "Shipment") {
+		return nil, 0, false
+	}
+
+	return claims, shipmentID, true
+}
+
+func (h *DocumentHandler) store(storagePath string, src io.Reader) (int64, error) {
+	fullPath := filepath.Join(h.uploadDir, storagePath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return 0, err
+	}
+
+	dst, err := os.Create(fullPath)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(fullPath)
+		return 0, err
+	}
+	return size, nil
+}
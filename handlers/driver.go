@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"goexpress-api/middleware"
 	"goexpress-api/models"
@@ -13,25 +14,57 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// defaultDriverRating stands in for driver ratings until customers can
+// rate deliveries.
+const defaultDriverRating = 4.5
+
 type DriverHandler struct {
-	db        *sql.DB
-	validator *validator.Validate
+	db             *sql.DB
+	validator      *validator.Validate
+	commissionRate float64
 }
 
 func NewDriverHandler(db *sql.DB) *DriverHandler {
 	return &DriverHandler{
-		db:        db,
-		validator: validator.New(),
+		db:             db,
+		validator:      validator.New(),
+		commissionRate: defaultCommissionRate,
 	}
 }
 
+// SetDefaultCommissionRate sets the commission rate for drivers without a
+// rate of their own.
+func (h *DriverHandler) SetDefaultCommissionRate(rate float64) {
+	h.commissionRate = rate
+}
+
+// driverColumns selects a driver together with its profile, for use with
+// driverFrom and driverFields.
+const driverColumns = `u.id, u.name, u.email, u.role, u.driver_status,
+	COALESCE(p.phone, ''), COALESCE(p.license_number, ''), COALESCE(p.vehicle_type, ''),
+	COALESCE(p.vehicle_number, ''), COALESCE(p.current_location, ''), p.max_concurrent_shipments,
+	p.max_weight, p.commission_rate, u.created_at, u.updated_at`
+
+const driverFrom = `FROM users u LEFT JOIN driver_profiles p ON p.user_id = u.id`
+
+// driverFields returns scan destinations for a row selected with driverColumns.
+func driverFields(d *models.Driver) []interface{} {
+	return []interface{}{&d.ID, &d.Name, &d.Email, &d.Role, &d.Status,
+		&d.Phone, &d.LicenseNumber, &d.VehicleType, &d.VehicleNumber, &d.CurrentLocation,
+		&d.MaxConcurrentShipments, &d.MaxWeight, &d.CommissionRate, &d.CreatedAt, &d.UpdatedAt}
+}
+
 // @Summary Get all drivers
-// @Description Get all drivers with their details and stats
+// @Description Get a page of drivers with their details and stats (admin only)
 // @Tags drivers
 // @Security ApiKeyAuth
 // @Produce json
 // @Param status query string false "Filter by status"
-// @Success 200 {array} models.Driver
+// @Param vehicle_type query string false "Filter by vehicle type (bicycle, motorcycle, car, van, truck)"
+// @Param search query string false "Search by name or email"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Page size (DEFAULT_PAGE_SIZE, capped at MAX_PAGE_SIZE)"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Driver}
 // @Router /api/drivers [get]
 func (h *DriverHandler) GetDrivers(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
@@ -40,28 +73,51 @@ func (h *DriverHandler) GetDrivers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only admin can view all drivers
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+	pagination := utils.ParsePagination(r)
+
+	statusFilter := r.URL.Query().Get("status")
+	vehicleTypeFilter := r.URL.Query().Get("vehicle_type")
+	if vehicleTypeFilter != "" && !models.IsVehicleType(vehicleTypeFilter) {
+		http.Error(w, "Invalid vehicle_type, must be one of: "+strings.Join(models.VehicleTypes, ", "), http.StatusBadRequest)
 		return
 	}
+	search := strings.TrimSpace(r.URL.Query().Get("search"))
 
-	statusFilter := r.URL.Query().Get("status")
-	
-	query := `
-		SELECT 
-			u.id, u.name, u.email, u.role, u.created_at, u.updated_at
-		FROM users u
-		WHERE u.role = 'driver'`
+	where := " WHERE u.role = 'driver'"
 
 	var args []interface{}
+	argIndex := 1
 
 	if statusFilter != "" {
-		// For now, we'll just return all drivers since we don't have a drivers table
-		// In a real implementation, you'd join with a drivers table
+		where += " AND u.driver_status = $" + strconv.Itoa(argIndex)
+		args = append(args, statusFilter)
+		argIndex++
 	}
 
-	query += " ORDER BY u.created_at DESC"
+	if vehicleTypeFilter != "" {
+		where += " AND p.vehicle_type = $" + strconv.Itoa(argIndex)
+		args = append(args, vehicleTypeFilter)
+		argIndex++
+	}
+
+	if search != "" {
+		where += " AND (u.name ILIKE $" + strconv.Itoa(argIndex) + " OR u.email ILIKE $" + strconv.Itoa(argIndex) + ")"
+		args = append(args, "%"+search+"%")
+		argIndex++
+	}
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) "+driverFrom+where, args...).Scan(&total); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	query := `
+		SELECT ` + driverColumns + `
+		` + driverFrom + where + `
+		ORDER BY u.created_at DESC, u.id DESC
+		LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
+	args = append(args, pagination.Limit, pagination.Offset())
 
 	rows, err := h.db.Query(query, args...)
 	if err != nil {
@@ -70,25 +126,23 @@ func (h *DriverHandler) GetDrivers(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	var drivers []models.Driver
+	drivers := []models.Driver{}
 	for rows.Next() {
 		var d models.Driver
-		err := rows.Scan(
-			&d.ID, &d.Name, &d.Email, &d.Role, &d.CreatedAt, &d.UpdatedAt,
-		)
+		err := rows.Scan(driverFields(&d)...)
 		if err != nil {
 			http.Error(w, "Failed to scan driver", http.StatusInternalServerError)
 			return
 		}
 		// Set default values for driver-specific fields
-		d.Status = "available"
-		d.Rating = 4.5
+		d.Rating = defaultDriverRating
 		d.TotalDeliveries = 0
+		RedactDriver(claims, &d)
 		drivers = append(drivers, d)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(drivers)
+	json.NewEncoder(w).Encode(models.NewPaginatedResponse(drivers, pagination.Page, pagination.Limit, total))
 }
 
 // @Summary Get driver stats
@@ -99,26 +153,17 @@ func (h *DriverHandler) GetDrivers(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} models.DriverStats
 // @Router /api/drivers/stats [get]
 func (h *DriverHandler) GetDriverStats(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Only admin can view stats
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
-	}
-
 	var stats models.DriverStats
 
 	// Get driver counts from users table
 	err := h.db.QueryRow(`
 		SELECT 
-			COUNT(*) as total_drivers
+			COUNT(*) as total_drivers,
+			COUNT(CASE WHEN driver_status = 'available' THEN 1 END) as available_drivers,
+			COUNT(CASE WHEN driver_status = 'busy' THEN 1 END) as busy_drivers,
+			COUNT(CASE WHEN driver_status = 'offline' THEN 1 END) as offline_drivers
 		FROM users WHERE role = 'driver'`,
-	).Scan(&stats.TotalDrivers)
+	).Scan(&stats.TotalDrivers, &stats.AvailableDrivers, &stats.BusyDrivers, &stats.OfflineDrivers)
 
 	if err != nil {
 		http.Error(w, "Failed to get driver stats", http.StatusInternalServerError)
@@ -126,18 +171,53 @@ func (h *DriverHandler) GetDriverStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set default values for other stats
-	stats.AvailableDrivers = stats.TotalDrivers
-	stats.BusyDrivers = 0
-	stats.OfflineDrivers = 0
 	stats.TotalDeliveries = 0
-	stats.AverageRating = 4.5
+	stats.AverageRating = defaultDriverRating
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
 
+// @Summary Get my driver summary
+// @Description Get the authenticated driver's open, in-transit and delivered-today shipment counts and rating (drivers only)
+// @Tags drivers
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.DriverSummary
+// @Router /api/drivers/me/summary [get]
+func (h *DriverHandler) GetMySummary(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	summary := models.DriverSummary{Rating: defaultDriverRating}
+	err := h.db.QueryRow(`
+		SELECT 
+			COUNT(CASE WHEN `+openShipmentsCondition+` THEN 1 END),
+			COUNT(CASE WHEN status = 'in_transit' THEN 1 END),
+			COUNT(CASE WHEN status = 'delivered' AND delivered_at >= date_trunc('day', CURRENT_TIMESTAMP) THEN 1 END)
+		FROM shipments WHERE driver_id = $1`,
+		claims.UserID,
+	).Scan(&summary.Open, &summary.InTransit, &summary.DeliveredToday)
+	if err != nil {
+		http.Error(w, "Failed to get driver summary", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
 // Placeholder methods for other driver operations
 func (h *DriverHandler) GetDriver(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	vars := mux.Vars(r)
 	driverID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -147,10 +227,11 @@ func (h *DriverHandler) GetDriver(w http.ResponseWriter, r *http.Request) {
 
 	var driver models.Driver
 	err = h.db.QueryRow(`
-		SELECT id, name, email, role, created_at, updated_at
-		FROM users WHERE id = $1 AND role = 'driver'`,
+		SELECT `+driverColumns+`
+		`+driverFrom+`
+		WHERE u.id = $1 AND u.role = 'driver'`,
 		driverID,
-	).Scan(&driver.ID, &driver.Name, &driver.Email, &driver.Role, &driver.CreatedAt, &driver.UpdatedAt)
+	).Scan(driverFields(&driver)...)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -162,27 +243,15 @@ func (h *DriverHandler) GetDriver(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set default values for driver-specific fields
-	driver.Status = "available"
-	driver.Rating = 4.5
+	driver.Rating = defaultDriverRating
 	driver.TotalDeliveries = 0
+	RedactDriver(claims, &driver)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(driver)
 }
 
 func (h *DriverHandler) CreateDriver(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Only admin can create drivers
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
-	}
-
 	var req models.CreateDriverRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
@@ -193,6 +262,7 @@ func (h *DriverHandler) CreateDriver(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Email = utils.NormalizeEmail(req.Email)
 
 	// Check if user already exists
 	var existingID int
@@ -209,16 +279,27 @@ func (h *DriverHandler) CreateDriver(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
 	// Create driver user
 	var driver models.Driver
-	err = h.db.QueryRow(`
+	err = tx.QueryRow(`
 		INSERT INTO users (name, email, password_hash, role) 
 		VALUES ($1, $2, $3, 'driver') 
-		RETURNING id, name, email, role, created_at, updated_at`,
+		RETURNING id, name, email, role, driver_status, created_at, updated_at`,
 		req.Name, req.Email, hashedPassword,
-	).Scan(&driver.ID, &driver.Name, &driver.Email, &driver.Role, &driver.CreatedAt, &driver.UpdatedAt)
+	).Scan(&driver.ID, &driver.Name, &driver.Email, &driver.Role, &driver.Status, &driver.CreatedAt, &driver.UpdatedAt)
 	
 	if err != nil {
+		if isDuplicateEmail(err) {
+			http.Error(w, "User already exists", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to create driver", http.StatusInternalServerError)
 		return
 	}
@@ -229,8 +310,21 @@ func (h *DriverHandler) CreateDriver(w http.ResponseWriter, r *http.Request) {
 	driver.VehicleType = req.VehicleType
 	driver.VehicleNumber = req.VehicleNumber
 	driver.CurrentLocation = req.CurrentLocation
-	driver.Status = "available"
-	driver.Rating = 4.5
+	driver.MaxConcurrentShipments = req.MaxConcurrentShipments
+	driver.MaxWeight = req.MaxWeight
+	driver.CommissionRate = req.CommissionRate
+
+	if err := saveDriverProfile(tx, &driver); err != nil {
+		http.Error(w, "Failed to create driver", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to create driver", http.StatusInternalServerError)
+		return
+	}
+
+	driver.Rating = defaultDriverRating
 	driver.TotalDeliveries = 0
 
 	w.Header().Set("Content-Type", "application/json")
@@ -239,18 +333,6 @@ func (h *DriverHandler) CreateDriver(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DriverHandler) UpdateDriver(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Only admin can update drivers
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
-	}
-
 	vars := mux.Vars(r)
 	driverID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -268,11 +350,19 @@ func (h *DriverHandler) UpdateDriver(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
 
 	// Update driver user
 	var driver models.Driver
-	err = h.db.QueryRow(`
-		UPDATE users SET name = $1, email = $2, updated_at = CURRENT_TIMESTAMP 
+	err = tx.QueryRow(`
+		UPDATE users SET name = $1, email = $2 
 		WHERE id = $3 AND role = 'driver'
 		RETURNING id, name, email, role, created_at, updated_at`,
 		req.Name, req.Email, driverID,
@@ -283,6 +373,10 @@ func (h *DriverHandler) UpdateDriver(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Driver not found", http.StatusNotFound)
 			return
 		}
+		if isDuplicateEmail(err) {
+			http.Error(w, "Email already taken", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to update driver", http.StatusInternalServerError)
 		return
 	}
@@ -293,8 +387,22 @@ func (h *DriverHandler) UpdateDriver(w http.ResponseWriter, r *http.Request) {
 	driver.VehicleType = req.VehicleType
 	driver.VehicleNumber = req.VehicleNumber
 	driver.CurrentLocation = req.CurrentLocation
+	driver.MaxConcurrentShipments = req.MaxConcurrentShipments
+	driver.MaxWeight = req.MaxWeight
+	driver.CommissionRate = req.CommissionRate
+
+	if err := saveDriverProfile(tx, &driver); err != nil {
+		http.Error(w, "Failed to update driver", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to update driver", http.StatusInternalServerError)
+		return
+	}
+
 	driver.Status = req.Status
-	driver.Rating = 4.5
+	driver.Rating = defaultDriverRating
 	driver.TotalDeliveries = 0
 
 	w.Header().Set("Content-Type", "application/json")
@@ -302,22 +410,22 @@ func (h *DriverHandler) UpdateDriver(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DriverHandler) DeleteDriver(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	vars := mux.Vars(r)
+	driverID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid driver ID", http.StatusBadRequest)
 		return
 	}
 
-	// Only admin can delete drivers
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+	// Open shipments must be handed over with the offboard endpoint first
+	var open bool
+	err = h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM shipments WHERE driver_id = $1 AND "+openShipmentsCondition+")", driverID).Scan(&open)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-
-	vars := mux.Vars(r)
-	driverID, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid driver ID", http.StatusBadRequest)
+	if open {
+		http.Error(w, "Driver has open shipments; offboard the driver first", http.StatusConflict)
 		return
 	}
 
@@ -341,18 +449,145 @@ func (h *DriverHandler) DeleteDriver(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// @Summary Get driver shipments
+// @Description Get a driver's shipments as their manifest, highest priority first, then newest first (admin, or the driver themselves)
+// @Tags drivers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Driver ID"
+// @Success 200 {array} models.Shipment
+// @Failure 403 {string} string "Insufficient permissions"
+// @Failure 404 {string} string "Driver not found"
+// @Router /api/drivers/{id}/shipments [get]
 func (h *DriverHandler) GetDriverShipments(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	driverID, err := strconv.Atoi(vars["id"])
+	driverID, ok := h.shiftDriverID(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeDriverShipments(w, driverID)
+}
+
+// @Summary Get my shipments
+// @Description Get the authenticated driver's shipments as their manifest, highest priority first, then newest first (drivers only)
+// @Tags drivers
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} models.Shipment
+// @Router /api/drivers/me/shipments [get]
+func (h *DriverHandler) GetMyShipments(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.writeDriverShipments(w, claims.UserID)
+}
+
+// @Summary Set my availability
+// @Description Set the authenticated driver's status, e.g. busy while on a break. Only available drivers are picked by auto-assign (drivers only).
+// @Description Available and busy are for drivers on a shift, so they need a check-in first; going offline ends the open shift, like a check-out.
+// @Tags drivers
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.DriverStatusRequest true "available, busy or offline"
+// @Success 200 {object} models.Driver
+// @Failure 400 {string} string "Invalid status"
+// @Failure 409 {string} string "Driver is not checked in"
+// @Router /api/drivers/me/status [put]
+func (h *DriverHandler) UpdateMyStatus(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.DriverStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
 	if err != nil {
-		http.Error(w, "Invalid driver ID", http.StatusBadRequest)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Locked so a concurrent check-in or check-out can't slip in between
+	var locked int
+	err = tx.QueryRow("SELECT id FROM users WHERE id = $1 AND role = 'driver' FOR UPDATE", claims.UserID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Driver not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Status == "offline" {
+		// Going offline is checking out: the open shift, if any, ends
+		_, err = tx.Exec("UPDATE driver_shifts SET ended_at = CURRENT_TIMESTAMP WHERE driver_id = $1 AND ended_at IS NULL", claims.UserID)
+		if err != nil {
+			http.Error(w, "Failed to end shift", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		var onShift bool
+		err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM driver_shifts WHERE driver_id = $1 AND ended_at IS NULL)", claims.UserID).Scan(&onShift)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !onShift {
+			http.Error(w, "Driver is not checked in", http.StatusConflict)
+			return
+		}
+	}
+
+	_, err = tx.Exec("UPDATE users SET driver_status = $1 WHERE id = $2", req.Status, claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to update driver status", http.StatusInternalServerError)
 		return
 	}
 
+	var driver models.Driver
+	err = tx.QueryRow(`
+		SELECT `+driverColumns+`
+		`+driverFrom+`
+		WHERE u.id = $1`,
+		claims.UserID,
+	).Scan(driverFields(&driver)...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	driver.Rating = defaultDriverRating
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to update driver status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(driver)
+}
+
+// writeDriverShipments writes a driver's manifest: highest priority first,
+// then newest first.
+func (h *DriverHandler) writeDriverShipments(w http.ResponseWriter, driverID int) {
 	rows, err := h.db.Query(`
-		SELECT id, tracking_number, origin, destination, weight, zone_id, 
-		       status, customer_id, driver_id, created_at, updated_at
-		FROM shipments WHERE driver_id = $1 ORDER BY created_at DESC`,
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE driver_id = $1 ORDER BY `+priorityOrder+`, created_at DESC`,
 		driverID,
 	)
 	if err != nil {
@@ -361,11 +596,10 @@ func (h *DriverHandler) GetDriverShipments(w http.ResponseWriter, r *http.Reques
 	}
 	defer rows.Close()
 
-	var shipments []models.Shipment
+	shipments := []models.Shipment{}
 	for rows.Next() {
 		var s models.Shipment
-		err := rows.Scan(&s.ID, &s.TrackingNumber, &s.Origin, &s.Destination, &s.Weight,
-			&s.ZoneID, &s.Status, &s.CustomerID, &s.DriverID, &s.CreatedAt, &s.UpdatedAt)
+		err := rows.Scan(shipmentFields(&s)...)
 		if err != nil {
 			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
 			return
@@ -377,4 +611,169 @@ func (h *DriverHandler) GetDriverShipments(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(shipments)
 }
 
+// @Summary Check in a driver
+// @Description Start a shift and mark the driver available (the driver themselves or admin)
+// @Tags drivers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Driver ID"
+// @Success 201 {object} models.DriverShift
+// @Router /api/drivers/{id}/check-in [post]
+func (h *DriverHandler) CheckIn(w http.ResponseWriter, r *http.Request) {
+	driverID, ok := h.shiftDriverID(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
 
+	var openShiftID int
+	err = tx.QueryRow("SELECT id FROM driver_shifts WHERE driver_id = $1 AND ended_at IS NULL", driverID).Scan(&openShiftID)
+	if err == nil {
+		http.Error(w, "Driver is already checked in", http.StatusConflict)
+		return
+	}
+	if err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var shift models.DriverShift
+	err = tx.QueryRow(`
+		INSERT INTO driver_shifts (driver_id) VALUES ($1)
+		RETURNING id, driver_id, started_at, ended_at`,
+		driverID,
+	).Scan(&shift.ID, &shift.DriverID, &shift.StartedAt, &shift.EndedAt)
+	if err != nil {
+		http.Error(w, "Failed to start shift", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec("UPDATE users SET driver_status = 'available' WHERE id = $1", driverID)
+	if err != nil {
+		http.Error(w, "Failed to update driver status", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to start shift", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shift)
+}
+
+// @Summary Check out a driver
+// @Description End the current shift and mark the driver offline (the driver themselves or admin)
+// @Tags drivers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Driver ID"
+// @Success 200 {object} models.DriverShift
+// @Router /api/drivers/{id}/check-out [post]
+func (h *DriverHandler) CheckOut(w http.ResponseWriter, r *http.Request) {
+	driverID, ok := h.shiftDriverID(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var shift models.DriverShift
+	err = tx.QueryRow(`
+		UPDATE driver_shifts SET ended_at = CURRENT_TIMESTAMP
+		WHERE driver_id = $1 AND ended_at IS NULL
+		RETURNING id, driver_id, started_at, ended_at`,
+		driverID,
+	).Scan(&shift.ID, &shift.DriverID, &shift.StartedAt, &shift.EndedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Driver is not checked in", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to end shift", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec("UPDATE users SET driver_status = 'offline' WHERE id = $1", driverID)
+	if err != nil {
+		http.Error(w, "Failed to update driver status", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to end shift", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shift)
+}
+
+// shiftDriverID resolves the driver for a check-in/check-out, earnings or
+// shipments request. Drivers may only act on their own record; admins may act on any driver.
+func (h *DriverHandler) shiftDriverID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	vars := mux.Vars(r)
+	driverID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid driver ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	if claims.Role != "admin" && (claims.Role != "driver" || claims.UserID != driverID) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return 0, false
+	}
+
+	var exists bool
+	err = h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND role = 'driver')", driverID).Scan(&exists)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return 0, false
+	}
+	if !exists {
+		http.Error(w, "Driver not found", http.StatusNotFound)
+		return 0, false
+	}
+
+	return driverID, true
+}
+
+// saveDriverProfile creates or replaces the profile of the given driver.
+func saveDriverProfile(tx *sql.Tx, d *models.Driver) error {
+	_, err := tx.Exec(`
+		INSERT INTO driver_profiles (user_id, phone, license_number, vehicle_type, vehicle_number, 
+		                             current_location, max_concurrent_shipments, max_weight, commission_rate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id) DO UPDATE SET
+			phone = EXCLUDED.phone,
+			license_number = EXCLUDED.license_number,
+			vehicle_type = EXCLUDED.vehicle_type,
+			vehicle_number = EXCLUDED.vehicle_number,
+			current_location = EXCLUDED.current_location,
+			max_concurrent_shipments = EXCLUDED.max_concurrent_shipments,
+			max_weight = EXCLUDED.max_weight,
+			commission_rate = EXCLUDED.commission_rate`,
+		d.ID, d.Phone, d.LicenseNumber, d.VehicleType, d.VehicleNumber,
+		d.CurrentLocation, d.MaxConcurrentShipments, d.MaxWeight, d.CommissionRate,
+	)
+	return err
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+
+	"goexpress-api/models"
+)
+
+// defaultCommissionRate is the share of a delivered shipment's cost paid to
+// the driver when neither the driver nor the config sets a rate.
+const defaultCommissionRate = 0.1
+
+// @Summary Get driver earnings
+// @Description Get a driver's commission on the shipments they delivered in a period (the driver themselves or admin)
+// @Tags drivers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Driver ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End date (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} models.DriverEarnings
+// @Router /api/drivers/{id}/earnings [get]
+func (h *DriverHandler) GetDriverEarnings(w http.ResponseWriter, r *http.Request) {
+	driverID, ok := h.shiftDriverID(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDateRange(r)
+	if !ok {
+		http.Error(w, "Invalid date range", http.StatusBadRequest)
+		return
+	}
+
+	earnings := models.DriverEarnings{
+		DriverID: driverID,
+		From:     models.NewUTCTime(from),
+		To:       models.NewUTCTime(to),
+	}
+	err := h.db.QueryRow(`
+		SELECT COALESCE((SELECT commission_rate FROM driver_profiles WHERE user_id = $1), $2),
+		       COUNT(s.id), COALESCE(SUM(s.cost), 0)
+		FROM shipments s
+		WHERE s.driver_id = $1 AND s.status = 'delivered'
+		  AND s.delivered_at >= $3 AND s.delivered_at < $4`,
+		driverID, h.commissionRate, from, to,
+	).Scan(&earnings.CommissionRate, &earnings.Deliveries, &earnings.DeliveredCost)
+	if err != nil {
+		http.Error(w, "Failed to get driver earnings", http.StatusInternalServerError)
+		return
+	}
+	earnings.Earnings = math.Round(earnings.DeliveredCost*earnings.CommissionRate*100) / 100
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(earnings)
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Readiness serves the readiness probe: 200 once ready reports true, 503
+// before. Unlike /health, which only says the process is up, it tells load
+// balancers when the API can actually serve requests.
+//
+// @Summary Readiness check
+// @Description Reports whether the API is ready to serve, i.e. database migrations have completed
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /health/ready [get]
+func Readiness(ready func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, code := "ready", http.StatusOK
+		if !ready() {
+			status, code = "starting", http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]string{"status": status})
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goexpress-api/audit"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// defaultImpersonationTTL is how long an impersonation token lasts.
+const defaultImpersonationTTL = 15 * time.Minute
+
+// SetAuditLog enables admin impersonation, recording each impersonation in
+// the audit log.
+func (h *AuthHandler) SetAuditLog(recorder audit.Recorder) {
+	h.auditLog = recorder
+}
+
+// SetImpersonationTTL sets how long impersonation tokens last.
+func (h *AuthHandler) SetImpersonationTTL(ttl time.Duration) {
+	h.impersonationTTL = ttl
+}
+
+// @Summary Impersonate a user
+// @Description Issue a short-lived token for acting as a non-admin user, e.g. to see the app as a customer does (admin only). The token names the admin, and every request made with it is audited against them.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} models.ImpersonationResponse
+// @Failure 403 {string} string "Admins cannot be impersonated"
+// @Router /api/admin/impersonate/{userId} [post]
+func (h *AuthHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if claims.Role != "admin" || claims.ImpersonatorID != 0 {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if h.auditLog == nil {
+		http.Error(w, "Impersonation is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var user models.User
+	err = h.db.QueryRow(`
+		SELECT id, name, email, role, is_active, created_at, updated_at
+		FROM users WHERE id = $1`,
+		userID,
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Role == "admin" {
+		http.Error(w, "Admins cannot be impersonated", http.StatusForbidden)
+		return
+	}
+	if !user.IsActive {
+		http.Error(w, "User is inactive", http.StatusConflict)
+		return
+	}
+
+	// No token is issued unless the impersonation is on record
+	err = h.auditLog.Record(audit.Entry{
+		ActorID:    claims.UserID,
+		UserID:     user.ID,
+		Action:     audit.ActionImpersonate,
+		StatusCode: http.StatusOK,
+	})
+	if err != nil {
+		log.Printf("Failed to audit impersonation of user %d by admin %d: %v", user.ID, claims.UserID, err)
+		http.Error(w, "Failed to record impersonation", http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt := time.Now().Add(h.impersonationTTL)
+	token, err := utils.GenerateImpersonationJWT(claims.UserID, user.ID, user.Email, user.Role, h.jwtSecret, expiresAt)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ImpersonationResponse{
+		Token:     token,
+		ExpiresAt: models.NewUTCTime(expiresAt),
+		User:      user,
+	})
+}
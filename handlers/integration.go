@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goexpress-api/models"
+)
+
+// @Summary Ingest carrier scan events
+// @Description Apply a batch of carrier scan events in order. Each event moves its shipment along a valid transition and records a tracking update in its own transaction; unknown shipments, held shipments and invalid transitions are reported per item and not applied. If some events could not be stored the response is 207 and only those events, marked retryable, should be sent again.
+// @Tags integrations
+// @Security IntegrationKey
+// @Accept json
+// @Produce json
+// @Param events body []models.ScanEvent true "Scan events"
+// @Success 200 {object} models.ScanEventsResponse
+// @Success 207 {object} models.ScanEventsResponse
+// @Router /api/integrations/scan-events [post]
+func (h *ShipmentHandler) IngestScanEvents(w http.ResponseWriter, r *http.Request) {
+	var events []models.ScanEvent
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(events) == 0 {
+		http.Error(w, "At least one scan event is required", http.StatusBadRequest)
+		return
+	}
+	if len(events) > models.MaxScanEventsBatch {
+		http.Error(w, "At most "+strconv.Itoa(models.MaxScanEventsBatch)+" scan events can be ingested at once", http.StatusBadRequest)
+		return
+	}
+
+	response := models.ScanEventsResponse{Results: make([]models.ScanEventResult, 0, len(events))}
+	for _, event := range events {
+		result := models.ScanEventResult{TrackingNumber: event.TrackingNumber, Status: event.Status}
+		shipment, err := h.applyScanEvent(event)
+		switch {
+		case err == nil:
+			result.Applied = true
+			response.Applied++
+			go h.notifyStatusChange(shipment)
+		case err == errScanEventFailed:
+			// Earlier events are already committed, so failing the whole
+			// batch would make the carrier resend and duplicate them.
+			result.Error = "failed to apply scan event, retry it"
+			result.Retryable = true
+			response.Failed++
+		default:
+			result.Error = err.Error()
+			response.Rejected++
+		}
+		response.Results = append(response.Results, result)
+	}
+	if response.Applied > 0 {
+		h.statsCache.Invalidate()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Failed > 0 {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// scanEventError is why a scan event was rejected, reported back to the
+// carrier as is.
+type scanEventError string
+
+func (e scanEventError) Error() string { return string(e) }
+
+// errScanEventFailed is a database failure, as opposed to a rejected event.
+var errScanEventFailed = errors.New("failed to apply scan event")
+
+// applyScanEvent moves the event's shipment to its status and records the
+// tracking update in one transaction. Carriers may make the same transitions
+// as drivers.
+func (h *ShipmentHandler) applyScanEvent(event models.ScanEvent) (models.Shipment, error) {
+	var shipment models.Shipment
+	if event.TrackingNumber == "" {
+		return shipment, scanEventError("tracking_number is required")
+	}
+	if !models.IsShipmentStatus(event.Status) {
+		return shipment, scanEventError("unknown status")
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		log.Printf("Failed to start scan event transaction: %v", err)
+		return shipment, errScanEventFailed
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE tracking_number = $1
+		FOR UPDATE`,
+		event.TrackingNumber,
+	).Scan(shipmentFields(&shipment)...)
+	if err == sql.ErrNoRows {
+		return shipment, scanEventError("shipment not found")
+	}
+	if err != nil {
+		log.Printf("Failed to look up shipment %s for scan event: %v", event.TrackingNumber, err)
+		return shipment, errScanEventFailed
+	}
+	if shipment.OnHold {
+		return shipment, scanEventError("shipment is on hold")
+	}
+
+	allowed := false
+	for _, next := range nextStatuses(shipment.Status, "driver") {
+		if next == event.Status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return shipment, scanEventError("invalid transition from " + shipment.Status + " to " + event.Status)
+	}
+
+	var timestamp interface{}
+	if event.Timestamp != nil {
+		timestamp = event.Timestamp.UTC()
+	}
+	err = tx.QueryRow(`
+		UPDATE shipments SET status = $1 WHERE id = $2
+		RETURNING `+shipmentColumns,
+		event.Status, shipment.ID,
+	).Scan(shipmentFields(&shipment)...)
+	if err == nil {
+		_, err = tx.Exec(`
+			INSERT INTO tracking_updates (shipment_id, status, location, timestamp)
+			VALUES ($1, $2, $3, COALESCE($4::timestamp, CURRENT_TIMESTAMP))`,
+			shipment.ID, event.Status, event.Location, timestamp,
+		)
+	}
+	if err == nil {
+		err = writeStatusEvent(tx, shipment, event.Location)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Failed to apply scan event for shipment %d: %v", shipment.ID, err)
+		return shipment, errScanEventFailed
+	}
+	return shipment, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goexpress-api/mailer"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/notifier"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// defaultResendNotificationInterval is how often a shipment's tracking
+// notification may be resent.
+const defaultResendNotificationInterval = 5 * time.Minute
+
+// SetResendNotificationInterval sets how often a shipment's tracking
+// notification may be resent.
+func (h *ShipmentHandler) SetResendNotificationInterval(interval time.Duration) {
+	h.resendLimiter = middleware.NewRateLimiter(1, interval)
+}
+
+// SetNotifier sets how customers are told about status changes on their
+// shipments. The default discards them.
+func (h *ShipmentHandler) SetNotifier(n notifier.Notifier) {
+	h.notifier = n
+}
+
+// notifyStatusChange tells the customer their shipment's new status over
+// the channel they prefer. Customers without a customer record, or without a
+// phone number for SMS, are emailed. Failures are logged since the status
+// has already changed.
+func (h *ShipmentHandler) notifyStatusChange(shipment models.Shipment) {
+	var channel, phone string
+	err := h.db.QueryRow(`
+		SELECT COALESCE(c.notification_channel, $2), COALESCE(c.phone, '')
+		FROM users u LEFT JOIN customers c ON c.user_id = u.id
+		WHERE u.id = $1`,
+		shipment.CustomerID, notifier.ChannelEmail,
+	).Scan(&channel, &phone)
+	if err != nil {
+		log.Printf("Failed to look up notification preference for shipment %d: %v", shipment.ID, err)
+		return
+	}
+
+	if channel == notifier.ChannelSMS && phone != "" {
+		err = h.notifier.SendSMS(notifier.SMS{
+			To:   phone,
+			Body: fmt.Sprintf("GoExpress: shipment %s is now %s.", shipment.TrackingNumber, shipment.Status),
+		})
+	} else {
+		var msg mailer.Message
+		if msg, err = h.trackingNotification(shipment); err == nil && msg.To != "" {
+			err = h.notifier.SendEmail(msg)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to notify customer of status change for shipment %d: %v", shipment.ID, err)
+	}
+}
+
+// @Summary Resend tracking notification
+// @Description Email the customer the shipment's current tracking status again (admin or the owning client). Limited to one resend per shipment per interval.
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Param id path int true "Shipment ID"
+// @Success 202
+// @Failure 429 {string} string "Too many requests"
+// @Router /api/shipments/{id}/resend-notification [post]
+func (h *ShipmentHandler) ResendNotification(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	shipmentID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	var shipment models.Shipment
+	err = h.db.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1`,
+		shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !requireVisible(w, err == nil && canViewShipment(claims, &shipment), "Shipment") {
+		return
+	}
+
+	if h.mailer == nil {
+		http.Error(w, "Notifications are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	allowed, retryAfter := h.resendLimiter.Allow(strconv.Itoa(shipmentID))
+	if !allowed {
+		seconds := int(retryAfter.Round(time.Second) / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	msg, err := h.trackingNotification(shipment)
+	if err != nil {
+		http.Error(w, "Failed to prepare notification", http.StatusInternalServerError)
+		return
+	}
+	if err := h.mailer.Send(msg); err != nil {
+		log.Printf("Failed to resend notification for shipment %d: %v", shipment.ID, err)
+		http.Error(w, "Failed to send notification", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// trackingNotification builds the email telling the customer where their
+// shipment currently is.
+func (h *ShipmentHandler) trackingNotification(shipment models.Shipment) (mailer.Message, error) {
+	var name, email string
+	err := h.db.QueryRow("SELECT name, email FROM users WHERE id = $1", shipment.CustomerID).Scan(&name, &email)
+	if err != nil {
+		return mailer.Message{}, err
+	}
+
+	var location sql.NullString
+	err = h.db.QueryRow(`
+		SELECT location FROM tracking_updates
+		WHERE shipment_id = $1 ORDER BY timestamp DESC, id DESC LIMIT 1`,
+		shipment.ID,
+	).Scan(&location)
+	if err != nil && err != sql.ErrNoRows {
+		return mailer.Message{}, err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Here is the latest on your shipment from %s to %s.\n\n"+
+		"Tracking number: %s\n"+
+		"Status: %s\n",
+		name, shipment.Origin, shipment.Destination, shipment.TrackingNumber, shipment.Status)
+	if location.String != "" {
+		body += "Last seen: " + location.String + "\n"
+	}
+	body += "\nThank you for shipping with GoExpress.\n"
+
+	return mailer.Message{
+		To:      email,
+		Subject: "Your GoExpress shipment " + shipment.TrackingNumber,
+		Body:    body,
+	}, nil
+}
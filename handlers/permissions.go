@@ -0,0 +1,121 @@
+package handlers
+
+import "goexpress-api/middleware"
+
+var (
+	adminOnly     = []string{"admin"}
+	anyRole       = []string{"admin", "driver", "client"}
+	adminOrDriver = []string{"admin", "driver"}
+	adminOrClient = []string{"admin", "client"}
+)
+
+// RoutePermissions lists the roles allowed on every authenticated route. It
+// is enforced by middleware.Authorize before any handler runs; handlers only
+// check ownership (e.g. a client reading their own shipment), never roles
+// alone. Routes not listed here are refused.
+var RoutePermissions = middleware.Permissions{
+	// Users
+	"GET /api/users":                      adminOnly,
+	"POST /api/users":                     adminOnly,
+	"POST /api/users/import":              adminOnly,
+	"GET /api/users/profile":              anyRole,
+	"PUT /api/users/profile":              anyRole,
+	"GET /api/users/me/export":            anyRole,
+	"POST /api/users/me/delete":           anyRole,
+	"GET /api/users/me/customers":         adminOnly,
+	"POST /api/users/change-password":     anyRole,
+	"GET /api/users/{id}":                 anyRole,
+	"PUT /api/users/{id}":                 adminOnly,
+	"DELETE /api/users/{id}":              adminOnly,
+	"POST /api/users/{id}/reset-password": adminOnly,
+	"POST /api/users/{id}/activate":       adminOnly,
+
+	// Customers
+	"GET /api/customers":                          adminOnly,
+	"POST /api/customers":                         adminOnly,
+	"GET /api/customers/stats":                    adminOnly,
+	"GET /api/customers/over-limit":               adminOnly,
+	"GET /api/customers/export":                   adminOnly,
+	"POST /api/customers/me/verification":         {"client"},
+	"POST /api/customers/me/verification/confirm": {"client"},
+	"GET /api/customers/{id}":                     anyRole,
+	"PUT /api/customers/{id}":                     adminOrClient,
+	"DELETE /api/customers/{id}":                  adminOnly,
+	"GET /api/customers/{id}/shipments":           adminOrClient,
+	"GET /api/customers/{id}/trend":               adminOrClient,
+	"POST /api/customers/{id}/addresses":          adminOrClient,
+	"GET /api/customers/{id}/notes":               adminOnly,
+	"PUT /api/customers/{id}/manager":             adminOnly,
+	"POST /api/customers/{id}/notes":              adminOnly,
+	"POST /api/customers/{id}/activate":           adminOnly,
+	"POST /api/customers/{id}/verify":             adminOnly,
+
+	// Drivers
+	"GET /api/drivers":                    adminOnly,
+	"POST /api/drivers":                   adminOnly,
+	"GET /api/drivers/stats":              adminOnly,
+	"GET /api/drivers/me/summary":         {"driver"},
+	"GET /api/drivers/me/shipments":       {"driver"},
+	"PUT /api/drivers/me/status":          {"driver"},
+	"GET /api/drivers/{id}":               anyRole,
+	"PUT /api/drivers/{id}":               adminOnly,
+	"DELETE /api/drivers/{id}":            adminOnly,
+	"GET /api/drivers/{id}/shipments":     adminOrDriver,
+	"GET /api/drivers/{id}/earnings":      adminOrDriver,
+	"POST /api/drivers/{id}/check-in":     adminOrDriver,
+	"POST /api/drivers/{id}/check-out":    adminOrDriver,
+	"POST /api/drivers/{id}/assign-batch": adminOnly,
+	"POST /api/drivers/{id}/offboard":     adminOnly,
+
+	// Shipments
+	"GET /api/shipments":                            anyRole,
+	"POST /api/shipments":                           anyRole,
+	"GET /api/shipments/search":                     anyRole,
+	"GET /api/shipments/stuck":                      adminOnly,
+	"GET /api/shipments/inactive":                   adminOnly,
+	"GET /api/shipments/stats":                      adminOnly,
+	"GET /api/shipments/statuses":                   anyRole,
+	"GET /api/shipments/{id}":                       anyRole,
+	"GET /api/shipments/{id}/full":                  anyRole,
+	"GET /api/shipments/{id}/cost":                  anyRole,
+	"GET /api/shipments/{id}/tracking-history":      anyRole,
+	"PUT /api/shipments/{id}/status":                adminOrDriver,
+	"GET /api/shipments/{id}/next-statuses":         anyRole,
+	"POST /api/shipments/{id}/resend-notification":  adminOrClient,
+	"POST /api/shipments/{id}/hold":                 adminOrDriver,
+	"POST /api/shipments/{id}/release":              adminOrDriver,
+	"POST /api/shipments/{id}/reschedule":           anyRole,
+	"POST /api/shipments/{id}/return":               adminOrClient,
+	"POST /api/shipments/{id}/reopen":               adminOnly,
+	"PUT /api/shipments/{id}/zone":                  adminOnly,
+	"POST /api/shipments/{id}/respond":              {"driver"},
+	"POST /api/shipments/{id}/assign":               adminOnly,
+	"POST /api/shipments/{id}/auto-assign":          adminOnly,
+	"POST /api/shipments/{id}/tracking-link":        adminOrClient,
+	"GET /api/webhooks/verify-sample":               {"client"},
+	"PUT /api/webhooks/endpoint":                    {"client"},
+	"POST /api/shipments/{id}/documents":            anyRole,
+	"GET /api/shipments/{id}/documents/{docId}/url": anyRole,
+	"GET /api/pickups":                              adminOnly,
+
+	// Analytics
+	"GET /api/analytics/shipments":        adminOnly,
+	"GET /api/analytics/revenue":          adminOnly,
+	"GET /api/analytics/sla":              adminOnly,
+	"GET /api/analytics/delivery-density": adminOnly,
+
+	// Zones (reads are public)
+	"POST /api/zones":        adminOnly,
+	"GET /api/zones/load":    adminOnly,
+	"PUT /api/zones/{id}":    adminOnly,
+	"PATCH /api/zones/{id}":  adminOnly,
+	"DELETE /api/zones/{id}": adminOnly,
+
+	// Admin
+	"GET /api/admin/maintenance":           adminOnly,
+	"PUT /api/admin/maintenance":           adminOnly,
+	"GET /api/admin/orphan-shipments":      adminOnly,
+	"GET /api/admin/mail":                  adminOnly,
+	"GET /api/activity":                    adminOnly,
+	"POST /api/admin/impersonate/{userId}": adminOnly,
+}
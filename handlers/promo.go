@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+
+	"goexpress-api/models"
+)
+
+var (
+	errPromoNotFound  = errors.New("promo code not found")
+	errPromoNotActive = errors.New("promo code is outside its validity period")
+	errPromoExhausted = errors.New("promo code has reached its usage limit")
+)
+
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type promoCode struct {
+	ID         int
+	Code       string
+	PercentOff sql.NullFloat64
+	FlatOff    sql.NullFloat64
+}
+
+// findPromoCode looks up a code (case-insensitively) and checks that it is
+// within its validity period and has uses left. With lock set the row is
+// locked until the transaction ends, so redeeming it cannot race.
+func findPromoCode(q rowQuerier, code string, lock bool) (promoCode, error) {
+	query := `
+		SELECT id, code, percent_off, flat_off,
+			(valid_from IS NULL OR valid_from <= CURRENT_TIMESTAMP) AND (valid_until IS NULL OR valid_until > CURRENT_TIMESTAMP),
+			max_uses IS NULL OR used_count < max_uses
+		FROM promo_codes WHERE code = $1`
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	var p promoCode
+	var active, available bool
+	err := q.QueryRow(query, strings.ToUpper(strings.TrimSpace(code))).
+		Scan(&p.ID, &p.Code, &p.PercentOff, &p.FlatOff, &active, &available)
+	switch {
+	case err == sql.ErrNoRows:
+		return p, errPromoNotFound
+	case err != nil:
+		return p, err
+	case !active:
+		return p, errPromoNotActive
+	case !available:
+		return p, errPromoExhausted
+	}
+	return p, nil
+}
+
+// redeemPromoCode validates a code and counts one use of it within tx.
+func redeemPromoCode(tx *sql.Tx, code string) (promoCode, error) {
+	p, err := findPromoCode(tx, code, true)
+	if err != nil {
+		return p, err
+	}
+	_, err = tx.Exec("UPDATE promo_codes SET used_count = used_count + 1 WHERE id = $1", p.ID)
+	return p, err
+}
+
+// apply discounts a quote. A flat discount never takes the price below zero.
+func (p promoCode) apply(quote *models.QuoteResponse) {
+	var discount float64
+	if p.PercentOff.Valid {
+		discount = quote.TotalPrice * p.PercentOff.Float64 / 100
+	} else {
+		discount = math.Min(p.FlatOff.Float64, quote.TotalPrice)
+	}
+	discount = math.Round(discount*100) / 100
+
+	quote.PromoCode = p.Code
+	quote.Discount = discount
+	quote.TotalPrice = math.Round((quote.TotalPrice-discount)*100) / 100
+}
+
+// writePromoError answers a rejected promo code with 400 and anything else with 500.
+func writePromoError(w http.ResponseWriter, err error) {
+	switch err {
+	case errPromoNotFound:
+		http.Error(w, "Invalid promo code", http.StatusBadRequest)
+	case errPromoNotActive:
+		http.Error(w, "Promo code has expired or is not active yet", http.StatusBadRequest)
+	case errPromoExhausted:
+		http.Error(w, "Promo code has reached its usage limit", http.StatusBadRequest)
+	default:
+		http.Error(w, "Database error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"goexpress-api/models"
+	"goexpress-api/utils"
+)
+
+// canViewContactDetails reports whether the requester may see the email and
+// phone numbers of the user with the given ID: admins and the user themselves.
+func canViewContactDetails(claims *utils.Claims, userID int) bool {
+	return claims != nil && (claims.Role == "admin" || claims.UserID == userID)
+}
+
+// RedactCustomer clears the customer's contact details unless the requester may see them.
+func RedactCustomer(claims *utils.Claims, c *models.Customer) {
+	if canViewContactDetails(claims, c.UserID) {
+		return
+	}
+	c.Email = ""
+	c.Phone = ""
+	c.AlternatePhone = ""
+}
+
+// RedactDriver clears the driver's contact details and commission rate unless
+// the requester may see them.
+func RedactDriver(claims *utils.Claims, d *models.Driver) {
+	if canViewContactDetails(claims, d.ID) {
+		return
+	}
+	d.Email = ""
+	d.Phone = ""
+	d.CommissionRate = nil
+}
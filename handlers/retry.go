@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"time"
+
+	"goexpress-api/utils"
+)
+
+// Idempotent reads on hot paths are retried on transient database errors,
+// such as dropped connections while Postgres fails over.
+const (
+	readRetryAttempts = 3
+	readRetryBackoff  = 50 * time.Millisecond
+)
+
+func retryRead(fn func() error) error {
+	return utils.WithRetry(fn, readRetryAttempts, readRetryBackoff)
+}
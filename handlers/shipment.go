@@ -3,37 +3,270 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"goexpress-api/audit"
+	"goexpress-api/cache"
+	"goexpress-api/mailer"
 	"goexpress-api/middleware"
 	"goexpress-api/models"
+	"goexpress-api/notifier"
+	"goexpress-api/pricing"
 	"goexpress-api/utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
+const defaultStatsCacheTTL = 30 * time.Second
+
+// Default price multipliers for express and overnight shipments.
+const (
+	defaultExpressMultiplier   = 1.5
+	defaultOvernightMultiplier = 2.0
+)
+
+// defaultTrackingDedupeWindow is how long a repeated status update (same
+// status and location as the latest one) is treated as a duplicate.
+const defaultTrackingDedupeWindow = time.Minute
+
 type ShipmentHandler struct {
-	db        *sql.DB
-	validator *validator.Validate
+	db                *sql.DB
+	validator         *validator.Validate
+	trackingAssigner  *TrackingAssigner
+	statsCache        cache.ShipmentStats
+	quoteCache        cache.Quotes
+	quoteAdjuster     pricing.Adjuster
+	mailer            mailer.Mailer
+	dedupeWindow      time.Duration
+	newTrackingNumber func() (string, error)
+	resendLimiter     *middleware.RateLimiter
+	notifier          notifier.Notifier
+	requireVerified   bool
+	approximateCounts bool
+	priorityRates     map[string]float64
+	auditLog          audit.Recorder
 }
 
 func NewShipmentHandler(db *sql.DB) *ShipmentHandler {
 	return &ShipmentHandler{
-		db:        db,
-		validator: validator.New(),
+		db:                db,
+		validator:         validator.New(),
+		statsCache:        cache.NewTTLShipmentStats(defaultStatsCacheTTL),
+		quoteCache:        cache.NewTTLQuotes(0),
+		dedupeWindow:      defaultTrackingDedupeWindow,
+		newTrackingNumber: utils.GenerateTrackingNumber,
+		resendLimiter:     middleware.NewRateLimiter(1, defaultResendNotificationInterval),
+		notifier:          notifier.Nop{},
+		priorityRates: map[string]float64{
+			models.PriorityStandard:  1,
+			models.PriorityExpress:   defaultExpressMultiplier,
+			models.PriorityOvernight: defaultOvernightMultiplier,
+		},
+	}
+}
+
+// SetStatsCache replaces the cache used for shipment stats.
+func (h *ShipmentHandler) SetStatsCache(statsCache cache.ShipmentStats) {
+	h.statsCache = statsCache
+}
+
+// SetApproximateCounts makes shipment stats report the planner's row
+// estimate as the total instead of counting every shipment, which gets slow
+// on large tables. Per-status counts stay exact.
+func (h *ShipmentHandler) SetApproximateCounts(approximate bool) {
+	h.approximateCounts = approximate
+}
+
+// SetPriorityMultipliers sets what express and overnight shipments cost
+// relative to standard ones. Multipliers below 1 are ignored.
+func (h *ShipmentHandler) SetPriorityMultipliers(express, overnight float64) {
+	if express >= 1 {
+		h.priorityRates[models.PriorityExpress] = express
+	}
+	if overnight >= 1 {
+		h.priorityRates[models.PriorityOvernight] = overnight
+	}
+}
+
+// SetQuoteCache replaces the cache used for quotes. Share it with the
+// ZoneHandler so price changes invalidate it; by default quotes are not
+// cached.
+func (h *ShipmentHandler) SetQuoteCache(quoteCache cache.Quotes) {
+	h.quoteCache = quoteCache
+}
+
+// SetQuoteAdjuster lets an external pricing engine adjust the total of
+// each quote, and the price of each shipment created, before promo codes
+// are applied. If it fails, the internal price is used unchanged.
+func (h *ShipmentHandler) SetQuoteAdjuster(adjuster pricing.Adjuster) {
+	h.quoteAdjuster = adjuster
+}
+
+// adjustQuote replaces the quote's total with the pricing engine's, if one
+// is set. If the engine fails, the internal quote is kept.
+func (h *ShipmentHandler) adjustQuote(quote *models.QuoteResponse) {
+	if h.quoteAdjuster == nil {
+		return
+	}
+	if total, err := h.quoteAdjuster.Adjust(*quote); err != nil {
+		log.Printf("Quote adjustment failed, using internal quote: %v", err)
+	} else {
+		quote.TotalPrice = total
+		quote.Adjusted = true
+	}
+}
+
+// SetTrackingAssigner enables async shipment creation, where tracking numbers
+// are assigned in the background by the given assigner. The confirmation
+// email for those shipments is sent once their tracking number is assigned.
+// It must be called before the assigner is started.
+func (h *ShipmentHandler) SetTrackingAssigner(assigner *TrackingAssigner) {
+	h.trackingAssigner = assigner
+	assigner.onAssigned = func(shipment models.Shipment) {
+		if h.mailer != nil {
+			go h.sendShipmentCreatedEmail(shipment)
+		}
+	}
+}
+
+// SetTrackingNumberGenerator replaces the generator used for tracking numbers
+// of shipments created synchronously.
+func (h *ShipmentHandler) SetTrackingNumberGenerator(generate func() (string, error)) {
+	h.newTrackingNumber = generate
+}
+
+// SetTrackingDedupeWindow sets how long a status update identical to the
+// latest tracking update is skipped as a duplicate. Zero records every update.
+func (h *ShipmentHandler) SetTrackingDedupeWindow(window time.Duration) {
+	h.dedupeWindow = window
+}
+
+// SetMailer enables confirmation emails to customers when a shipment is
+// created. With no mailer set, no emails are sent.
+func (h *ShipmentHandler) SetMailer(m mailer.Mailer) {
+	h.mailer = m
+}
+
+// SetRequireVerifiedCustomers makes customers verify their email or phone
+// before they can create shipments. Admins are never blocked.
+func (h *ShipmentHandler) SetRequireVerifiedCustomers(require bool) {
+	h.requireVerified = require
+}
+
+// SetAuditLog enables reopening cancelled shipments, recording each reopen
+// in the audit log.
+func (h *ShipmentHandler) SetAuditLog(recorder audit.Recorder) {
+	h.auditLog = recorder
+}
+
+// shipmentColumns is the column list matching shipmentFields, for use in
+// SELECT and RETURNING clauses.
+const shipmentColumns = `id, COALESCE(tracking_number, '') AS tracking_number, reference, origin, destination, weight, zone_id, 
+	priority, status, customer_id, driver_id, accepted_at, pickup_scheduled_at, pickup_window, 
+	estimated_delivery, delivery_window, cost, discount, return_of, delivered_at, 
+	` + slaBreachedColumn + `, on_hold, hold_reason, created_at, updated_at`
+
+// slaBreachedColumn is NULL until a shipment is delivered, then whether it
+// took longer than its zone's SLA, counted from pickup (or creation when no
+// pickup was scheduled).
+const slaBreachedColumn = `delivered_at > COALESCE(pickup_scheduled_at, created_at) + 
+	(SELECT z.sla_hours FROM zones z WHERE z.id = zone_id) * INTERVAL '1 hour' AS sla_breached`
+
+// shipmentFields returns scan destinations for a row selected with shipmentColumns.
+func shipmentFields(s *models.Shipment) []interface{} {
+	return []interface{}{&s.ID, &s.TrackingNumber, &s.Reference, &s.Origin, &s.Destination, &s.Weight,
+		&s.ZoneID, &s.Priority, &s.Status, &s.CustomerID, &s.DriverID, &s.AcceptedAt, &s.PickupScheduledAt, &s.PickupWindow,
+		&s.EstimatedDelivery, &s.DeliveryWindow, &s.Cost, &s.Discount, &s.ReturnOf, &s.DeliveredAt, &s.SLABreached, &s.OnHold, &s.HoldReason, &s.CreatedAt, &s.UpdatedAt}
+}
+
+// calculateQuote prices a shipment of the given weight in a zone. It is the
+// single source of pricing for quotes and stored shipment costs.
+func calculateQuote(zone models.Zone, weight float64) models.QuoteResponse {
+	return models.QuoteResponse{
+		Weight:     weight,
+		ZoneID:     zone.ID,
+		ZoneName:   zone.Name,
+		PricePerKg: zone.PricePerKg,
+		TotalPrice: math.Round(weight*zone.PricePerKg*100) / 100,
+	}
+}
+
+// applyPriority charges the priority's multiplier on the quote's total,
+// recording the surcharge so it can be itemized. Apply it before any promo
+// code so the discount is taken off the full price.
+func (h *ShipmentHandler) applyPriority(quote *models.QuoteResponse, priority string) {
+	if priority == "" {
+		priority = models.PriorityStandard
+	}
+	multiplier, ok := h.priorityRates[priority]
+	if !ok {
+		multiplier = 1
+	}
+
+	total := math.Round(quote.TotalPrice*multiplier*100) / 100
+	quote.Priority = priority
+	quote.PriorityMultiplier = multiplier
+	quote.PrioritySurcharge = math.Round((total-quote.TotalPrice)*100) / 100
+	quote.TotalPrice = total
+}
+
+// priorityOrder sorts higher priority shipments first in SQL.
+const priorityOrder = `CASE priority WHEN 'overnight' THEN 2 WHEN 'express' THEN 1 ELSE 0 END DESC`
+
+// costComponents itemizes a quote as stored with a shipment: the base price,
+// any priority surcharge and any promo discount, summing to the quote's total.
+func costComponents(quote models.QuoteResponse) []models.CostComponent {
+	components := []models.CostComponent{{
+		Code:   "base",
+		Label:  fmt.Sprintf("%s: %g kg at %.2f/kg", quote.ZoneName, quote.Weight, quote.PricePerKg),
+		Amount: math.Round((quote.TotalPrice+quote.Discount-quote.PrioritySurcharge)*100) / 100,
+	}}
+	if quote.PrioritySurcharge > 0 {
+		components = append(components, models.CostComponent{
+			Code:   "priority",
+			Label:  fmt.Sprintf("Priority %s (x%g)", quote.Priority, quote.PriorityMultiplier),
+			Amount: quote.PrioritySurcharge,
+		})
+	}
+	if quote.Discount > 0 {
+		label := "Promo discount"
+		if quote.PromoCode != "" {
+			label = "Promo code " + quote.PromoCode
+		}
+		components = append(components, models.CostComponent{
+			Code:   "discount",
+			Label:  label,
+			Amount: -quote.Discount,
+		})
 	}
+	return components
 }
 
 // @Summary Get shipment tracking history
-// @Description Get tracking history for a shipment
+// @Description Get tracking history for a shipment (admin, the owning client or the assigned driver)
 // @Tags shipments
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Shipment ID"
 // @Success 200 {array} models.TrackingUpdate
+// @Failure 404 {string} string "Shipment not found"
 // @Router /api/shipments/{id}/tracking-history [get]
 func (h *ShipmentHandler) GetTrackingHistory(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	vars := mux.Vars(r)
 	shipmentID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -41,9 +274,19 @@ func (h *ShipmentHandler) GetTrackingHistory(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	var owner models.Shipment
+	err = h.db.QueryRow("SELECT customer_id, driver_id FROM shipments WHERE id = $1", shipmentID).Scan(&owner.CustomerID, &owner.DriverID)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !requireVisible(w, err == nil && canViewShipment(claims, &owner), "Shipment") {
+		return
+	}
+
 	// Get tracking updates
 	rows, err := h.db.Query(`
-		SELECT id, shipment_id, status, location, timestamp, created_at 
+		SELECT id, shipment_id, status, location, COALESCE(note, ''), timestamp, created_at 
 		FROM tracking_updates WHERE shipment_id = $1 ORDER BY timestamp DESC`,
 		shipmentID,
 	)
@@ -53,10 +296,10 @@ func (h *ShipmentHandler) GetTrackingHistory(w http.ResponseWriter, r *http.Requ
 	}
 	defer rows.Close()
 
-	var trackingUpdates []models.TrackingUpdate
+	trackingUpdates := []models.TrackingUpdate{}
 	for rows.Next() {
 		var tu models.TrackingUpdate
-		err := rows.Scan(&tu.ID, &tu.ShipmentID, &tu.Status, &tu.Location, &tu.Timestamp, &tu.CreatedAt)
+		err := rows.Scan(&tu.ID, &tu.ShipmentID, &tu.Status, &tu.Location, &tu.Note, &tu.Timestamp, &tu.CreatedAt)
 		if err != nil {
 			http.Error(w, "Failed to scan tracking update", http.StatusInternalServerError)
 			return
@@ -69,14 +312,21 @@ func (h *ShipmentHandler) GetTrackingHistory(w http.ResponseWriter, r *http.Requ
 }
 
 // @Summary Get shipment by ID
-// @Description Get shipment details by ID
+// @Description Get shipment details by ID (admin, the owning client or the assigned driver)
 // @Tags shipments
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Shipment ID"
 // @Success 200 {object} models.ShipmentResponse
+// @Failure 404 {string} string "Shipment not found"
 // @Router /api/shipments/{id} [get]
 func (h *ShipmentHandler) GetShipmentById(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	vars := mux.Vars(r)
 	shipmentID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -86,14 +336,13 @@ func (h *ShipmentHandler) GetShipmentById(w http.ResponseWriter, r *http.Request
 
 	// Get shipment
 	var shipment models.Shipment
-	err = h.db.QueryRow(`
-		SELECT id, tracking_number, origin, destination, weight, zone_id, 
-		       status, customer_id, driver_id, created_at, updated_at 
-		FROM shipments WHERE id = $1`,
-		shipmentID,
-	).Scan(&shipment.ID, &shipment.TrackingNumber, &shipment.Origin, &shipment.Destination,
-		&shipment.Weight, &shipment.ZoneID, &shipment.Status, &shipment.CustomerID,
-		&shipment.DriverID, &shipment.CreatedAt, &shipment.UpdatedAt)
+	err = retryRead(func() error {
+		return h.db.QueryRow(`
+			SELECT `+shipmentColumns+`
+			FROM shipments WHERE id = $1`,
+			shipmentID,
+		).Scan(shipmentFields(&shipment)...)
+	})
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -104,56 +353,197 @@ func (h *ShipmentHandler) GetShipmentById(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Get tracking updates
-	rows, err := h.db.Query(`
-		SELECT id, shipment_id, status, location, timestamp, created_at 
+	if !requireVisible(w, canViewShipment(claims, &shipment), "Shipment") {
+		return
+	}
+
+	var response models.ShipmentResponse
+	err = retryRead(func() (err error) {
+		response, err = loadShipmentResponse(h.db, shipment)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to get shipment details", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// loadShipmentResponse loads the tracking history and zone for a shipment.
+// It only reads, so callers may retry it.
+func loadShipmentResponse(db *sql.DB, shipment models.Shipment) (models.ShipmentResponse, error) {
+	response := models.ShipmentResponse{Shipment: shipment, TrackingUpdate: []models.TrackingUpdate{}}
+
+	rows, err := db.Query(`
+		SELECT id, shipment_id, status, location, COALESCE(note, ''), timestamp, created_at 
 		FROM tracking_updates WHERE shipment_id = $1 ORDER BY timestamp DESC`,
 		shipment.ID,
 	)
 	if err != nil {
-		http.Error(w, "Failed to get tracking updates", http.StatusInternalServerError)
-		return
+		return response, err
 	}
 	defer rows.Close()
 
-	var trackingUpdates []models.TrackingUpdate
 	for rows.Next() {
 		var tu models.TrackingUpdate
-		err := rows.Scan(&tu.ID, &tu.ShipmentID, &tu.Status, &tu.Location, &tu.Timestamp, &tu.CreatedAt)
+		err := rows.Scan(&tu.ID, &tu.ShipmentID, &tu.Status, &tu.Location, &tu.Note, &tu.Timestamp, &tu.CreatedAt)
 		if err != nil {
-			http.Error(w, "Failed to scan tracking update", http.StatusInternalServerError)
-			return
+			return response, err
 		}
-		trackingUpdates = append(trackingUpdates, tu)
+		response.TrackingUpdate = append(response.TrackingUpdate, tu)
+	}
+	if err := rows.Err(); err != nil {
+		return response, err
 	}
 
-	// Get zone info
-	var zone models.Zone
-	err = h.db.QueryRow(`
-		SELECT id, name, price_per_kg, created_at, updated_at 
+	err = db.QueryRow(`
+		SELECT `+zoneColumns+` 
 		FROM zones WHERE id = $1`,
 		shipment.ZoneID,
-	).Scan(&zone.ID, &zone.Name, &zone.PricePerKg, &zone.CreatedAt, &zone.UpdatedAt)
+	).Scan(zoneFields(&response.Zone)...)
+	return response, err
+}
+
+// @Summary Get full shipment
+// @Description Get a shipment with its tracking history, zone, customer and driver. Admins see contact details; the shipment's customer and driver get a reduced view.
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Success 200 {object} models.FullShipmentResponse
+// @Failure 404 {string} string "Shipment not found"
+// @Router /api/shipments/{id}/full [get]
+func (h *ShipmentHandler) GetFullShipment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	var shipment models.Shipment
+	err = h.db.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1`,
+		shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Shipment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
 
+	if !requireVisible(w, canViewShipment(claims, &shipment), "Shipment") {
+		return
+	}
+
+	details, err := loadShipmentResponse(h.db, shipment)
+	if err != nil {
+		http.Error(w, "Failed to get shipment details", http.StatusInternalServerError)
+		return
+	}
+	response := models.FullShipmentResponse{ShipmentResponse: details}
+
+	customer := &response.Customer
+	err = h.db.QueryRow(`
+		SELECT u.id, u.name, u.email, COALESCE(c.company_name, ''), COALESCE(c.contact_person, ''), COALESCE(c.phone, '')
+		FROM users u
+		LEFT JOIN customers c ON c.user_id = u.id
+		WHERE u.id = $1`,
+		shipment.CustomerID,
+	).Scan(&customer.ID, &customer.Name, &customer.Email, &customer.CompanyName, &customer.ContactPerson, &customer.Phone)
 	if err != nil {
-		http.Error(w, "Failed to get zone info", http.StatusInternalServerError)
+		http.Error(w, "Failed to get customer details", http.StatusInternalServerError)
 		return
 	}
 
-	response := models.ShipmentResponse{
-		Shipment:       shipment,
-		TrackingUpdate: trackingUpdates,
-		Zone:           zone,
+	if shipment.DriverID != nil {
+		var driver models.ShipmentDriverDetails
+		err = h.db.QueryRow(`
+			SELECT u.id, u.name, u.email, COALESCE(p.phone, ''), COALESCE(p.vehicle_type, ''), COALESCE(p.vehicle_number, '')
+			FROM users u
+			LEFT JOIN driver_profiles p ON p.user_id = u.id
+			WHERE u.id = $1`,
+			*shipment.DriverID,
+		).Scan(&driver.ID, &driver.Name, &driver.Email, &driver.Phone, &driver.VehicleType, &driver.VehicleNumber)
+		if err != nil {
+			http.Error(w, "Failed to get driver details", http.StatusInternalServerError)
+			return
+		}
+		response.Driver = &driver
+	}
+
+	// Customers and drivers only see who is on the other end, not how to reach them
+	if claims.Role != "admin" {
+		response.Customer = models.ShipmentCustomerDetails{
+			ID:          customer.ID,
+			Name:        customer.Name,
+			CompanyName: customer.CompanyName,
+		}
+		if response.Driver != nil {
+			response.Driver = &models.ShipmentDriverDetails{
+				ID:          response.Driver.ID,
+				Name:        response.Driver.Name,
+				VehicleType: response.Driver.VehicleType,
+			}
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// shipmentSortColumns maps the sort query param to the column it orders by.
+// Only these columns may be interpolated into ORDER BY.
+var shipmentSortColumns = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"weight":     "weight",
+	"status":     "status",
+}
+
+// shipmentOrderBy builds the ORDER BY clause from the sort and order query
+// params, defaulting to newest first. Ties are broken by id so pages are stable.
+func shipmentOrderBy(r *http.Request) (string, bool) {
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = "created_at"
+	}
+	column, ok := shipmentSortColumns[sortBy]
+	if !ok {
+		return "", false
+	}
+
+	direction := "DESC"
+	switch strings.ToLower(r.URL.Query().Get("order")) {
+	case "":
+	case "asc":
+		direction = "ASC"
+	case "desc":
+	default:
+		return "", false
+	}
+
+	return " ORDER BY " + column + " " + direction + ", id " + direction, true
+}
+
 // @Summary Get all shipments
 // @Description Get all shipments (filtered by user role)
 // @Tags shipments
 // @Security ApiKeyAuth
 // @Produce json
+// @Param sort query string false "created_at, updated_at, weight or status (default created_at)"
+// @Param order query string false "asc or desc (default desc)"
 // @Success 200 {array} models.Shipment
 // @Router /api/shipments [get]
 func (h *ShipmentHandler) GetShipments(w http.ResponseWriter, r *http.Request) {
@@ -163,24 +553,28 @@ func (h *ShipmentHandler) GetShipments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	orderBy, ok := shipmentOrderBy(r)
+	if !ok {
+		http.Error(w, "Invalid sort (expected created_at, updated_at, weight or status) or order (expected asc or desc)", http.StatusBadRequest)
+		return
+	}
+
 	var query string
 	var args []interface{}
 
 	switch claims.Role {
 	case "admin":
-		query = `SELECT id, tracking_number, origin, destination, weight, zone_id, 
-				 status, customer_id, driver_id, created_at, updated_at FROM shipments ORDER BY created_at DESC`
+		query = `SELECT ` + shipmentColumns + ` FROM shipments`
 	case "driver":
-		query = `SELECT id, tracking_number, origin, destination, weight, zone_id, 
-				 status, customer_id, driver_id, created_at, updated_at FROM shipments 
-				 WHERE driver_id = $1 ORDER BY created_at DESC`
+		query = `SELECT ` + shipmentColumns + ` FROM shipments 
+				 WHERE driver_id = $1`
 		args = append(args, claims.UserID)
 	default: // client
-		query = `SELECT id, tracking_number, origin, destination, weight, zone_id, 
-				 status, customer_id, driver_id, created_at, updated_at FROM shipments 
-				 WHERE customer_id = $1 ORDER BY created_at DESC`
+		query = `SELECT ` + shipmentColumns + ` FROM shipments 
+				 WHERE customer_id = $1`
 		args = append(args, claims.UserID)
 	}
+	query += orderBy
 
 	rows, err := h.db.Query(query, args...)
 	if err != nil {
@@ -189,11 +583,10 @@ func (h *ShipmentHandler) GetShipments(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	var shipments []models.Shipment
+	shipments := []models.Shipment{}
 	for rows.Next() {
 		var s models.Shipment
-		err := rows.Scan(&s.ID, &s.TrackingNumber, &s.Origin, &s.Destination, &s.Weight,
-			&s.ZoneID, &s.Status, &s.CustomerID, &s.DriverID, &s.CreatedAt, &s.UpdatedAt)
+		err := rows.Scan(shipmentFields(&s)...)
 		if err != nil {
 			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
 			return
@@ -205,273 +598,1634 @@ func (h *ShipmentHandler) GetShipments(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(shipments)
 }
 
-// @Summary Create a new shipment
-// @Description Create a new shipment with GoExpress
+// @Summary Get shipment cost breakdown
+// @Description Itemized cost of a shipment as priced at creation: base price and any promo discount (negative), summing to total.
+// @Description Shipments created before breakdowns were stored get one derived from their cost and discount (admin, the owning client or the assigned driver).
 // @Tags shipments
 // @Security ApiKeyAuth
-// @Accept json
 // @Produce json
-// @Param shipment body models.ShipmentRequest true "Shipment data"
-// @Success 201 {object} models.Shipment
-// @Router /api/shipments [post]
-func (h *ShipmentHandler) CreateShipment(w http.ResponseWriter, r *http.Request) {
+// @Param id path int true "Shipment ID"
+// @Success 200 {object} models.CostBreakdown
+// @Failure 404 {string} string "Shipment not found"
+// @Router /api/shipments/{id}/cost [get]
+func (h *ShipmentHandler) GetShipmentCost(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	var req models.ShipmentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
-	}
-
-	if err := h.validator.Struct(req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Generate tracking number with GoExpress prefix
-	trackingNumber, err := utils.GenerateTrackingNumber()
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Failed to generate tracking number", http.StatusInternalServerError)
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
 		return
 	}
 
-	// Create shipment
 	var shipment models.Shipment
+	var stored []byte
 	err = h.db.QueryRow(`
-		INSERT INTO shipments (tracking_number, origin, destination, weight, zone_id, customer_id, status) 
-		VALUES ($1, $2, $3, $4, $5, $6, 'pending') 
-		RETURNING id, tracking_number, origin, destination, weight, zone_id, status, customer_id, driver_id, created_at, updated_at`,
-		trackingNumber, req.Origin, req.Destination, req.Weight, req.ZoneID, claims.UserID,
-	).Scan(&shipment.ID, &shipment.TrackingNumber, &shipment.Origin, &shipment.Destination,
-		&shipment.Weight, &shipment.ZoneID, &shipment.Status, &shipment.CustomerID,
-		&shipment.DriverID, &shipment.CreatedAt, &shipment.UpdatedAt)
-
-	if err != nil {
-		http.Error(w, "Failed to create shipment", http.StatusInternalServerError)
+		SELECT `+shipmentColumns+`, cost_breakdown
+		FROM shipments WHERE id = $1`,
+		shipmentID,
+	).Scan(append(shipmentFields(&shipment), &stored)...)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-
-	// Create initial tracking update
-	_, err = h.db.Exec(`
-		INSERT INTO tracking_updates (shipment_id, status, location) 
-		VALUES ($1, $2, $3)`,
-		shipment.ID, "pending", req.Origin,
-	)
-	if err != nil {
-		http.Error(w, "Failed to create tracking update", http.StatusInternalServerError)
+	if !requireVisible(w, err == nil && canViewShipment(claims, &shipment), "Shipment") {
 		return
 	}
 
+	breakdown := models.CostBreakdown{ShipmentID: shipment.ID, Total: shipment.Cost}
+	if stored != nil {
+		if err := json.Unmarshal(stored, &breakdown.Components); err != nil {
+			http.Error(w, "Failed to read cost breakdown", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		breakdown.Components = costComponents(models.QuoteResponse{
+			Weight:     shipment.Weight,
+			TotalPrice: shipment.Cost,
+			Discount:   shipment.Discount,
+		})
+		breakdown.Components[0].Label = "Base price"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(shipment)
+	json.NewEncoder(w).Encode(breakdown)
 }
 
-// @Summary Get shipment by tracking number
-// @Description Get shipment details by tracking number (public endpoint)
+// maxSearchResults caps the shipments returned by one search.
+const maxSearchResults = 50
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern is a LIKE pattern matching text anywhere, with LIKE's
+// wildcards in text escaped so they match literally. Use it with ESCAPE '\'.
+func containsPattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
+
+// @Summary Search shipments
+// @Description Find shipments whose tracking number, origin or destination contains q (case-insensitive, with % and _ matched literally), newest first.
+// @Description Each result names the field that matched so it can be highlighted. Admins search all shipments, drivers and clients their own.
 // @Tags shipments
+// @Security ApiKeyAuth
 // @Produce json
-// @Param tracking_number path string true "Tracking number"
-// @Success 200 {object} models.ShipmentResponse
-// @Router /api/shipments/{tracking_number} [get]
-func (h *ShipmentHandler) GetShipmentByTracking(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	trackingNumber := vars["tracking_number"]
+// @Param q query string true "Text to search for"
+// @Success 200 {array} models.ShipmentSearchResult
+// @Failure 400 {string} string "Missing search query"
+// @Router /api/shipments/search [get]
+func (h *ShipmentHandler) SearchShipments(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	if !utils.ValidateTrackingNumber(trackingNumber) {
-		http.Error(w, "Invalid tracking number format", http.StatusBadRequest)
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "Missing search query (q)", http.StatusBadRequest)
 		return
 	}
 
-	// Get shipment
-	var shipment models.Shipment
-	err := h.db.QueryRow(`
-		SELECT id, tracking_number, origin, destination, weight, zone_id, 
-		       status, customer_id, driver_id, created_at, updated_at 
-		FROM shipments WHERE tracking_number = $1`,
-		trackingNumber,
-	).Scan(&shipment.ID, &shipment.TrackingNumber, &shipment.Origin, &shipment.Destination,
-		&shipment.Weight, &shipment.ZoneID, &shipment.Status, &shipment.CustomerID,
-		&shipment.DriverID, &shipment.CreatedAt, &shipment.UpdatedAt)
+	query := `
+		SELECT ` + shipmentColumns + `,
+			CASE
+				WHEN tracking_number ILIKE $1 ESCAPE '\' THEN 'tracking_number'
+				WHEN origin ILIKE $1 ESCAPE '\' THEN 'origin'
+				ELSE 'destination'
+			END
+		FROM shipments
+		WHERE (tracking_number ILIKE $1 ESCAPE '\' OR origin ILIKE $1 ESCAPE '\' OR destination ILIKE $1 ESCAPE '\')`
+	args := []interface{}{containsPattern(q)}
 
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Shipment not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
+	switch claims.Role {
+	case "admin":
+	case "driver":
+		query += " AND driver_id = $2"
+		args = append(args, claims.UserID)
+	default: // client
+		query += " AND customer_id = $2"
+		args = append(args, claims.UserID)
 	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + strconv.Itoa(maxSearchResults)
 
-	// Get tracking updates
-	rows, err := h.db.Query(`
-		SELECT id, shipment_id, status, location, timestamp, created_at 
-		FROM tracking_updates WHERE shipment_id = $1 ORDER BY timestamp DESC`,
-		shipment.ID,
-	)
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
-		http.Error(w, "Failed to get tracking updates", http.StatusInternalServerError)
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
 
-	var trackingUpdates []models.TrackingUpdate
+	results := []models.ShipmentSearchResult{}
 	for rows.Next() {
-		var tu models.TrackingUpdate
-		err := rows.Scan(&tu.ID, &tu.ShipmentID, &tu.Status, &tu.Location, &tu.Timestamp, &tu.CreatedAt)
-		if err != nil {
-			http.Error(w, "Failed to scan tracking update", http.StatusInternalServerError)
+		var result models.ShipmentSearchResult
+		if err := rows.Scan(append(shipmentFields(&result.Shipment), &result.MatchedField)...); err != nil {
+			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
 			return
 		}
-		trackingUpdates = append(trackingUpdates, tu)
+		results = append(results, result)
 	}
 
-	// Get zone info
-	var zone models.Zone
-	err = h.db.QueryRow(`
-		SELECT id, name, price_per_kg, created_at, updated_at 
-		FROM zones WHERE id = $1`,
-		shipment.ZoneID,
-	).Scan(&zone.ID, &zone.Name, &zone.PricePerKg, &zone.CreatedAt, &zone.UpdatedAt)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
 
-	if err != nil {
-		http.Error(w, "Failed to get zone info", http.StatusInternalServerError)
+// @Summary Create a new shipment
+// @Description Create a new shipment with GoExpress
+// @Description Once a zone has taken in its daily_capacity of shipments for the UTC day, further ones are refused until midnight UTC; admins may exceed it.
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param shipment body models.ShipmentRequest true "Shipment data"
+// @Param async query bool false "Return immediately and assign the tracking number in the background"
+// @Success 201 {object} models.Shipment
+// @Success 202 {object} models.Shipment
+// @Failure 409 {string} string "Zone has reached its daily capacity"
+// @Router /api/shipments [post]
+func (h *ShipmentHandler) CreateShipment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.ShipmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.requireVerified && claims.Role != "admin" {
+		verified, err := customerVerified(h.db, claims.UserID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !verified {
+			http.Error(w, "Verify your email or phone before creating a shipment", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Scheduled pickups must start in the future
+	if req.PickupScheduledAt != nil && !req.PickupScheduledAt.After(time.Now()) {
+		http.Error(w, "Pickup must be scheduled in the future", http.StatusBadRequest)
+		return
+	}
+	if req.PickupWindow != nil && req.PickupScheduledAt == nil {
+		http.Error(w, "pickup_window requires pickup_scheduled_at", http.StatusBadRequest)
+		return
+	}
+
+	// Price the shipment from its zone
+	var zone models.Zone
+	err := h.db.QueryRow(`
+		SELECT `+zoneColumns+` 
+		FROM zones WHERE id = $1`,
+		req.ZoneID,
+	).Scan(zoneFields(&zone)...)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Zone not found", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	// Charged exactly as GetQuote prices it. The engine is asked before the
+	// transaction starts, so no locks are held while it answers.
+	quote := calculateQuote(zone, req.Weight)
+	h.adjustQuote(&quote)
+	h.applyPriority(&quote, req.Priority)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Admins may create shipments past a zone's daily capacity
+	if zone.DailyCapacity != nil && claims.Role != "admin" {
+		full, resetsAt, err := zoneAtCapacity(tx, zone, time.Now())
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if full {
+			seconds := int(time.Until(resetsAt).Round(time.Second) / time.Second)
+			if seconds < 1 {
+				seconds = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			http.Error(w, "Zone has reached its daily capacity, it resets at "+resetsAt.Format(time.RFC3339), http.StatusConflict)
+			return
+		}
+	}
+
+	// Redeeming the code in the same transaction keeps its usage count
+	// in step with the shipments that actually got created
+	var promoCodeID *int
+	if req.PromoCode != "" {
+		promo, err := redeemPromoCode(tx, req.PromoCode)
+		if err != nil {
+			writePromoError(w, err)
+			return
+		}
+		promo.apply(&quote)
+		promoCodeID = &promo.ID
+	}
+
+	breakdown, err := json.Marshal(costComponents(quote))
+	if err != nil {
+		http.Error(w, "Failed to price shipment", http.StatusInternalServerError)
+		return
+	}
+
+	// In async mode the shipment is stored without a tracking number and the
+	// assigner fills it in, along with the initial tracking update, and then
+	// sends the confirmation email.
+	if r.URL.Query().Get("async") == "true" && h.trackingAssigner != nil {
+		var shipment models.Shipment
+		err = tx.QueryRow(`
+			INSERT INTO shipments (tracking_number, origin, destination, weight, zone_id, customer_id, status,
+			                       pickup_scheduled_at, pickup_window, cost, discount, promo_code_id, cost_breakdown, priority) 
+			VALUES (NULL, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) 
+			RETURNING `+shipmentColumns,
+			req.Origin, req.Destination, req.Weight, req.ZoneID, claims.UserID, statusPendingTracking,
+			req.PickupScheduledAt, req.PickupWindow, quote.TotalPrice, quote.Discount, promoCodeID, breakdown, quote.Priority,
+		).Scan(shipmentFields(&shipment)...)
+
+		if err != nil {
+			http.Error(w, "Failed to create shipment", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Failed to create shipment", http.StatusInternalServerError)
+			return
+		}
+
+		h.trackingAssigner.Enqueue(shipment.ID)
+		h.statsCache.Invalidate()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(shipment)
+		return
+	}
+
+	// Create shipment with a GoExpress tracking number, retrying on collisions
+	var shipment models.Shipment
+	for attempt := 0; ; attempt++ {
+		trackingNumber, err := h.newTrackingNumber()
+		if err != nil {
+			http.Error(w, "Failed to generate tracking number", http.StatusInternalServerError)
+			return
+		}
+
+		// A savepoint lets a colliding insert be retried in the same transaction
+		if _, err := tx.Exec("SAVEPOINT tracking_number"); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		err = tx.QueryRow(`
+			INSERT INTO shipments (tracking_number, origin, destination, weight, zone_id, customer_id, status,
+			                       pickup_scheduled_at, pickup_window, cost, discount, promo_code_id, cost_breakdown, priority) 
+			VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7, $8, $9, $10, $11, $12, $13) 
+			RETURNING `+shipmentColumns,
+			trackingNumber, req.Origin, req.Destination, req.Weight, req.ZoneID, claims.UserID,
+			req.PickupScheduledAt, req.PickupWindow, quote.TotalPrice, quote.Discount, promoCodeID, breakdown, quote.Priority,
+		).Scan(shipmentFields(&shipment)...)
+		if err == nil {
+			tx.Exec("RELEASE SAVEPOINT tracking_number")
+			break
+		}
+		if !isTrackingNumberConflict(err) {
+			http.Error(w, "Failed to create shipment", http.StatusInternalServerError)
+			return
+		}
+		if attempt == trackingAssignerAttempts-1 {
+			http.Error(w, "Could not allocate a unique tracking number, please retry", http.StatusConflict)
+			return
+		}
+		tx.Exec("ROLLBACK TO SAVEPOINT tracking_number")
+	}
+
+	// Create initial tracking update
+	_, err = tx.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location) 
+		VALUES ($1, $2, $3)`,
+		shipment.ID, "pending", req.Origin,
+	)
+	if err != nil {
+		http.Error(w, "Failed to create tracking update", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to create shipment", http.StatusInternalServerError)
+		return
+	}
+	h.statsCache.Invalidate()
+
+	if h.mailer != nil {
+		go h.sendShipmentCreatedEmail(shipment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shipment)
+}
+
+// zoneAtCapacity reports whether the zone already took in its daily capacity
+// of shipments on now's UTC day, and when that day ends. Cancelled shipments
+// don't count. The zone row stays locked until tx ends so concurrent
+// creations can't both take its last slot.
+func zoneAtCapacity(tx *sql.Tx, zone models.Zone, now time.Time) (bool, time.Time, error) {
+	dayStart := now.UTC().Truncate(24 * time.Hour)
+	resetsAt := dayStart.Add(24 * time.Hour)
+
+	if _, err := tx.Exec("SELECT id FROM zones WHERE id = $1 FOR UPDATE", zone.ID); err != nil {
+		return false, resetsAt, err
+	}
+
+	var created int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM shipments
+		WHERE zone_id = $1 AND created_at >= $2 AND status != 'cancelled'`,
+		zone.ID, dayStart,
+	).Scan(&created)
+	if err != nil {
+		return false, resetsAt, err
+	}
+	return created >= *zone.DailyCapacity, resetsAt, nil
+}
+
+// sendShipmentCreatedEmail emails the customer a confirmation with the
+// tracking number and estimated delivery date, which is the end of the
+// zone's SLA. Customers without an email address are skipped; failures are
+// logged since the shipment already exists.
+func (h *ShipmentHandler) sendShipmentCreatedEmail(shipment models.Shipment) {
+	var name, email string
+	var slaHours int
+	err := h.db.QueryRow(`
+		SELECT u.name, u.email, z.sla_hours
+		FROM users u, zones z
+		WHERE u.id = $1 AND z.id = $2`,
+		shipment.CustomerID, shipment.ZoneID,
+	).Scan(&name, &email, &slaHours)
+	if err != nil {
+		log.Printf("Failed to look up customer %d for shipment %d confirmation: %v", shipment.CustomerID, shipment.ID, err)
+		return
+	}
+	if email == "" {
+		return
+	}
+
+	estimatedFrom := shipment.CreatedAt.Time
+	if shipment.PickupScheduledAt != nil {
+		estimatedFrom = shipment.PickupScheduledAt.Time
+	}
+	estimatedDelivery := estimatedFrom.Add(time.Duration(slaHours) * time.Hour)
+
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Your GoExpress shipment " + shipment.TrackingNumber,
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Your shipment from %s to %s has been created.\n\n"+
+			"Tracking number: %s\n"+
+			"Estimated delivery: %s\n\n"+
+			"Thank you for shipping with GoExpress.\n",
+			name, shipment.Origin, shipment.Destination,
+			shipment.TrackingNumber, estimatedDelivery.Format("Monday, January 2, 2006")),
+	}
+	if err := h.mailer.Send(msg); err != nil {
+		log.Printf("Failed to send confirmation for shipment %d: %v", shipment.ID, err)
+	}
+}
+
+// @Summary Get shipment by tracking number
+// @Description Get shipment details by tracking number (public endpoint)
+// @Tags shipments
+// @Produce json
+// @Param tracking_number path string true "Tracking number"
+// @Success 200 {object} models.ShipmentResponse
+// @Router /api/shipments/{tracking_number} [get]
+func (h *ShipmentHandler) GetShipmentByTracking(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	trackingNumber := vars["tracking_number"]
+
+	if !utils.ValidateTrackingNumber(trackingNumber) {
+		http.Error(w, "Invalid tracking number format", http.StatusBadRequest)
+		return
+	}
+
+	// Get shipment
+	var shipment models.Shipment
+	err := retryRead(func() error {
+		return h.db.QueryRow(`
+			SELECT `+shipmentColumns+`
+			FROM shipments WHERE tracking_number = $1`,
+			trackingNumber,
+		).Scan(shipmentFields(&shipment)...)
+	})
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Shipment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var response models.ShipmentResponse
+	err = retryRead(func() (err error) {
+		response, err = loadShipmentResponse(h.db, shipment)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to get shipment details", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// @Summary Track several shipments
+// @Description Get the current status and last location of up to 50 tracking numbers (public endpoint)
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Param request body models.TrackBatchRequest true "Tracking numbers"
+// @Success 200 {array} models.TrackBatchResult
+// @Failure 429 {string} string "Too many requests"
+// @Router /api/shipments/track-batch [post]
+func (h *ShipmentHandler) TrackBatch(w http.ResponseWriter, r *http.Request) {
+	var req models.TrackBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.TrackingNumbers) > models.MaxTrackBatchSize {
+		http.Error(w, "At most "+strconv.Itoa(models.MaxTrackBatchSize)+" tracking numbers can be tracked at once", http.StatusBadRequest)
+		return
+	}
+
+	// Malformed numbers cannot match anything, so they are reported as not found
+	var lookup []string
+	for _, trackingNumber := range req.TrackingNumbers {
+		if utils.ValidateTrackingNumber(trackingNumber) {
+			lookup = append(lookup, trackingNumber)
+		}
+	}
+
+	found := make(map[string]models.TrackBatchResult)
+	if len(lookup) > 0 {
+		rows, err := h.db.Query(`
+			SELECT s.tracking_number, s.status, s.updated_at,
+				COALESCE((SELECT location FROM tracking_updates tu
+				          WHERE tu.shipment_id = s.id
+				          ORDER BY tu.timestamp DESC, tu.id DESC LIMIT 1), '')
+			FROM shipments s
+			WHERE s.tracking_number = ANY($1)`,
+			pq.Array(lookup),
+		)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var result models.TrackBatchResult
+			var updatedAt models.UTCTime
+			if err := rows.Scan(&result.TrackingNumber, &result.Status, &updatedAt, &result.LastLocation); err != nil {
+				http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
+				return
+			}
+			result.Found = true
+			result.UpdatedAt = &updatedAt
+			found[result.TrackingNumber] = result
+		}
+	}
+
+	results := make([]models.TrackBatchResult, 0, len(req.TrackingNumbers))
+	for _, trackingNumber := range req.TrackingNumbers {
+		result, ok := found[trackingNumber]
+		if !ok {
+			result = models.TrackBatchResult{TrackingNumber: trackingNumber}
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// @Summary Validate tracking numbers
+// @Description Check the format of up to 1000 tracking numbers, e.g. before scanning them in. Shipments are not looked up. (public endpoint)
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Param tracking_numbers body []string true "Tracking numbers"
+// @Success 200 {array} models.TrackingValidation
+// @Router /api/shipments/validate-tracking [post]
+func (h *ShipmentHandler) ValidateTrackingNumbers(w http.ResponseWriter, r *http.Request) {
+	var trackingNumbers []string
+	if err := json.NewDecoder(r.Body).Decode(&trackingNumbers); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(trackingNumbers) == 0 {
+		http.Error(w, "At least one tracking number is required", http.StatusBadRequest)
+		return
+	}
+	if len(trackingNumbers) > models.MaxValidateTrackingBatch {
+		http.Error(w, "At most "+strconv.Itoa(models.MaxValidateTrackingBatch)+" tracking numbers can be validated at once", http.StatusBadRequest)
+		return
+	}
+
+	results := make([]models.TrackingValidation, 0, len(trackingNumbers))
+	for _, trackingNumber := range trackingNumbers {
+		results = append(results, models.TrackingValidation{
+			TrackingNumber: trackingNumber,
+			Valid:          utils.ValidateTrackingNumber(trackingNumber),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// @Summary Get tracking number format
+// @Description Describe the tracking number format with a freshly generated example, e.g. for integrators validating their label parsers.
+// @Description The example is not assigned to any shipment. (public endpoint)
+// @Tags shipments
+// @Produce json
+// @Success 200 {object} models.TrackingFormat
+// @Router /api/shipments/tracking-format [get]
+func (h *ShipmentHandler) GetTrackingFormat(w http.ResponseWriter, r *http.Request) {
+	example, err := utils.GenerateTrackingNumber()
+	if err != nil {
+		http.Error(w, "Failed to generate tracking number", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TrackingFormat{
+		Prefix:  utils.TrackingNumberPrefix,
+		Length:  utils.TrackingNumberLength,
+		Example: example,
+	})
+}
+
+// @Summary Get shipping quote
+// @Description Get shipping quote based on weight and zone, raised by the priority multiplier for express or overnight and optionally discounted by a promo code
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Param quote body models.QuoteRequest true "Quote request data"
+// @Success 200 {object} models.QuoteResponse
+// @Router /api/quote [post]
+func (h *ShipmentHandler) GetQuote(w http.ResponseWriter, r *http.Request) {
+	var req models.QuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, cached := h.quoteCache.Get(req.ZoneID, req.Weight)
+	if !cached {
+		// Get zone info
+		var zone models.Zone
+		err := h.db.QueryRow(`
+			SELECT `+zoneColumns+` 
+			FROM zones WHERE id = $1`,
+			req.ZoneID,
+		).Scan(zoneFields(&zone)...)
+
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Zone not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		response = calculateQuote(zone, req.Weight)
+		h.quoteCache.Set(response)
+	}
+
+	// Only the internal quote is cached; the engine is asked every time
+	h.adjustQuote(&response)
+	h.applyPriority(&response, req.Priority)
+
+	if req.PromoCode != "" {
+		promo, err := findPromoCode(h.db, req.PromoCode, false)
+		if err != nil {
+			writePromoError(w, err)
+			return
+		}
+		promo.apply(&response)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// @Summary Get multi-leg shipping quote
+// @Description Price a shipment routed through several zones (e.g. via consolidation hubs), with at most 10 legs. Each leg is priced like a single-zone quote and the total is their sum.
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Param quote body models.MultiLegQuoteRequest true "Ordered legs"
+// @Success 200 {object} models.MultiLegQuoteResponse
+// @Failure 404 {string} string "Zone not found"
+// @Router /api/quote/multi-leg [post]
+func (h *ShipmentHandler) GetMultiLegQuote(w http.ResponseWriter, r *http.Request) {
+	var req models.MultiLegQuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Legs) > models.MaxQuoteLegs {
+		http.Error(w, "At most "+strconv.Itoa(models.MaxQuoteLegs)+" legs can be quoted at once", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	zoneIDs := make([]int64, len(req.Legs))
+	for i, leg := range req.Legs {
+		zoneIDs[i] = int64(leg.ZoneID)
+	}
+
+	rows, err := h.db.Query(`
+		SELECT `+zoneColumns+` 
+		FROM zones WHERE id = ANY($1)`,
+		pq.Array(zoneIDs),
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	zones := map[int]models.Zone{}
+	for rows.Next() {
+		var zone models.Zone
+		if err := rows.Scan(zoneFields(&zone)...); err != nil {
+			http.Error(w, "Failed to scan zone", http.StatusInternalServerError)
+			return
+		}
+		zones[zone.ID] = zone
+	}
+
+	response := models.MultiLegQuoteResponse{Legs: make([]models.QuoteResponse, 0, len(req.Legs))}
+	for _, leg := range req.Legs {
+		zone, ok := zones[leg.ZoneID]
+		if !ok {
+			http.Error(w, "Zone "+strconv.Itoa(leg.ZoneID)+" not found", http.StatusNotFound)
+			return
+		}
+		quote := calculateQuote(zone, leg.Weight)
+		response.Legs = append(response.Legs, quote)
+		response.TotalPrice += quote.TotalPrice
+	}
+	response.TotalPrice = math.Round(response.TotalPrice*100) / 100
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// @Summary Get quotes for all zones
+// @Description Get a shipping quote for the given weight in every zone, cheapest first
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Param quote body models.QuoteAllRequest true "Quote request"
+// @Success 200 {array} models.QuoteResponse
+// @Router /api/quote/all [post]
+func (h *ShipmentHandler) GetAllQuotes(w http.ResponseWriter, r *http.Request) {
+	var req models.QuoteAllRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT `+zoneColumns+` 
+		FROM zones ORDER BY id`)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	quotes := []models.QuoteResponse{}
+	for rows.Next() {
+		var zone models.Zone
+		if err := rows.Scan(zoneFields(&zone)...); err != nil {
+			http.Error(w, "Failed to scan zone", http.StatusInternalServerError)
+			return
+		}
+		quotes = append(quotes, calculateQuote(zone, req.Weight))
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return quotes[i].TotalPrice < quotes[j].TotalPrice
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quotes)
+}
+
+// @Summary Update shipment status
+// @Description Update shipment status (admin, or the driver assigned to the shipment)
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Param status body map[string]string true "Status update"
+// @Success 200 {object} models.Shipment
+// @Failure 404 {string} string "Shipment not found"
+// @Router /api/shipments/{id}/status [put]
+func (h *ShipmentHandler) UpdateShipmentStatus(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	shipmentID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.requireAssignedDriver(w, claims, shipmentID) {
+		return
+	}
+
+	var req struct {
+		Status   string `json:"status" validate:"required"`
+		Location string `json:"location"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !models.IsShipmentStatus(req.Status) {
+		http.Error(w, "Invalid status, must be one of: "+strings.Join(models.ShipmentStatuses, ", "), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Update shipment status; held shipments stay put until released
+	result, err := tx.Exec(`
+		UPDATE shipments SET status = $1 
+		WHERE id = $2 AND NOT on_hold`,
+		req.Status, shipmentID,
+	)
+	if err != nil {
+		http.Error(w, "Failed to update shipment", http.StatusInternalServerError)
+		return
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		h.writeNotUpdatedError(w, shipmentID)
+		return
+	}
+
+	// Add tracking update, unless it repeats the latest one (e.g. a double tap)
+	result, err = tx.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location) 
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (
+			SELECT 1 FROM (
+				SELECT status, location, timestamp FROM tracking_updates
+				WHERE shipment_id = $1 ORDER BY timestamp DESC, id DESC LIMIT 1
+			) latest
+			WHERE latest.status = $2 AND latest.location IS NOT DISTINCT FROM $3
+			  AND latest.timestamp > CURRENT_TIMESTAMP - $4 * INTERVAL '1 second'
+		)`,
+		shipmentID, req.Status, req.Location, h.dedupeWindow.Seconds(),
+	)
+	if err != nil {
+		http.Error(w, "Failed to add tracking update", http.StatusInternalServerError)
+		return
+	}
+	recorded, _ := result.RowsAffected()
+
+	// Get updated shipment
+	var shipment models.Shipment
+	err = tx.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1`,
+		shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+
+	if err != nil {
+		http.Error(w, "Failed to get updated shipment", http.StatusInternalServerError)
+		return
+	}
+
+	if recorded > 0 {
+		if err := writeStatusEvent(tx, shipment, req.Location); err != nil {
+			http.Error(w, "Failed to record shipment event", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+	// Status counts changed, e.g. a cancellation
+	h.statsCache.Invalidate()
+
+	// Repeats were not recorded, so the customer has already heard about them
+	if recorded > 0 {
+		go h.notifyStatusChange(shipment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shipment)
+}
+
+// requireAssignedDriver checks that the caller may work on the shipment,
+// writing a 404 otherwise. The routes using it are open to admins and
+// drivers, so that means admins and the shipment's own driver.
+func (h *ShipmentHandler) requireAssignedDriver(w http.ResponseWriter, claims *utils.Claims, shipmentID int) bool {
+	var owner models.Shipment
+	err := h.db.QueryRow("SELECT customer_id, driver_id FROM shipments WHERE id = $1", shipmentID).Scan(&owner.CustomerID, &owner.DriverID)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+	return requireVisible(w, err == nil && canViewShipment(claims, &owner), "Shipment")
+}
+
+// writeNotUpdatedError explains why a guarded shipment UPDATE matched no rows:
+// the shipment is missing or is on hold.
+func (h *ShipmentHandler) writeNotUpdatedError(w http.ResponseWriter, shipmentID int) {
+	var onHold bool
+	err := h.db.QueryRow("SELECT on_hold FROM shipments WHERE id = $1", shipmentID).Scan(&onHold)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Shipment not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, "Database error", http.StatusInternalServerError)
+	case onHold:
+		http.Error(w, "Shipment is on hold", http.StatusConflict)
+	default:
+		http.Error(w, "Shipment cannot be updated", http.StatusConflict)
+	}
+}
+
+// @Summary Put a shipment on hold
+// @Description Pause a shipment, e.g. for customs or payment, without cancelling it. Its status cannot change until it is released (admin, or the assigned driver).
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Param hold body models.HoldRequest true "Hold reason"
+// @Success 200 {object} models.Shipment
+// @Failure 409 {string} string "Shipment is already on hold or closed"
+// @Router /api/shipments/{id}/hold [post]
+func (h *ShipmentHandler) HoldShipment(w http.ResponseWriter, r *http.Request) {
+	shipmentID, ok := h.holdableShipmentID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.HoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.setHold(w, shipmentID, true, &req.Reason, req.Location)
+}
+
+// @Summary Release a held shipment
+// @Description Resume a shipment that was put on hold (admin, or the assigned driver)
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Param release body models.ReleaseRequest false "Release details"
+// @Success 200 {object} models.Shipment
+// @Failure 409 {string} string "Shipment is not on hold"
+// @Router /api/shipments/{id}/release [post]
+func (h *ShipmentHandler) ReleaseShipment(w http.ResponseWriter, r *http.Request) {
+	shipmentID, ok := h.holdableShipmentID(w, r)
+	if !ok {
+		return
+	}
+
+	// The body is optional
+	var req models.ReleaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	h.setHold(w, shipmentID, false, nil, req.Location)
+}
+
+// holdableShipmentID reads the shipment ID from the path and checks that the
+// caller is an admin or the shipment's driver.
+func (h *ShipmentHandler) holdableShipmentID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	if !h.requireAssignedDriver(w, claims, shipmentID) {
+		return 0, false
+	}
+	return shipmentID, true
+}
+
+// setHold flips a shipment's on_hold flag and records the change as an
+// "on_hold" or "released" tracking update. Closed shipments cannot be held.
+func (h *ShipmentHandler) setHold(w http.ResponseWriter, shipmentID int, hold bool, reason *string, location string) {
+	trackingStatus := "released"
+	if hold {
+		trackingStatus = "on_hold"
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var shipment models.Shipment
+	err = tx.QueryRow(`
+		UPDATE shipments SET on_hold = $1, hold_reason = $2
+		WHERE id = $3 AND on_hold <> $1 AND `+openShipmentsCondition+`
+		RETURNING `+shipmentColumns,
+		hold, reason, shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err == sql.ErrNoRows {
+		var onHold bool
+		var status string
+		err = tx.QueryRow("SELECT on_hold, status FROM shipments WHERE id = $1", shipmentID).Scan(&onHold, &status)
+		switch {
+		case err == sql.ErrNoRows:
+			http.Error(w, "Shipment not found", http.StatusNotFound)
+		case err != nil:
+			http.Error(w, "Database error", http.StatusInternalServerError)
+		case onHold == hold && hold:
+			http.Error(w, "Shipment is already on hold", http.StatusConflict)
+		case onHold == hold:
+			http.Error(w, "Shipment is not on hold", http.StatusConflict)
+		default:
+			http.Error(w, "Shipment is already "+status, http.StatusConflict)
+		}
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update shipment", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location) 
+		VALUES ($1, $2, $3)`,
+		shipmentID, trackingStatus, location,
+	)
+	if err != nil {
+		http.Error(w, "Failed to add tracking update", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to update shipment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shipment)
+}
+
+// @Summary Reschedule a delivery
+// @Description Move a shipment's expected delivery to a new date, e.g. when the recipient asks for a later delivery (owning client, assigned driver or admin).
+// @Description The change is recorded as a "rescheduled" tracking update. Delivered and cancelled shipments cannot be rescheduled.
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Param request body models.RescheduleRequest true "New delivery date and optional window"
+// @Success 200 {object} models.Shipment
+// @Failure 400 {string} string "Delivery date must be in the future"
+// @Failure 404 {string} string "Shipment not found"
+// @Failure 409 {string} string "Shipment is already delivered"
+// @Router /api/shipments/{id}/reschedule [post]
+func (h *ShipmentHandler) RescheduleShipment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.RescheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !req.EstimatedDelivery.After(time.Now()) {
+		http.Error(w, "Delivery date must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var shipment models.Shipment
+	err = tx.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1
+		FOR UPDATE`,
+		shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !requireVisible(w, err == nil && canViewShipment(claims, &shipment), "Shipment") {
+		return
+	}
+
+	if shipment.Status == "delivered" || shipment.Status == "cancelled" {
+		http.Error(w, "Shipment is already "+shipment.Status, http.StatusConflict)
+		return
+	}
+
+	err = tx.QueryRow(`
+		UPDATE shipments SET estimated_delivery = $1, delivery_window = $2
+		WHERE id = $3
+		RETURNING `+shipmentColumns,
+		req.EstimatedDelivery.UTC(), req.DeliveryWindow, shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil {
+		http.Error(w, "Failed to update shipment", http.StatusInternalServerError)
+		return
+	}
+
+	note := "Delivery rescheduled to " + req.EstimatedDelivery.UTC().Format(time.RFC3339)
+	if req.Reason != "" {
+		note += ": " + req.Reason
+	}
+	if err := addTrackingNote(tx, shipment, "rescheduled", note); err != nil {
+		http.Error(w, "Failed to add tracking update", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shipment)
+}
+
+// @Summary Get scheduled pickups
+// @Description Get shipments with a pickup scheduled on the given date, grouped by zone (admin only)
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Produce json
+// @Param date query string true "Pickup date (YYYY-MM-DD)"
+// @Success 200 {array} models.ZonePickups
+// @Router /api/pickups [get]
+func (h *ShipmentHandler) GetScheduledPickups(w http.ResponseWriter, r *http.Request) {
+	day, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
+	if err != nil {
+		http.Error(w, "Invalid or missing date (expected YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT `+shipmentColumns+`, (SELECT name FROM zones WHERE zones.id = shipments.zone_id)
+		FROM shipments 
+		WHERE pickup_scheduled_at >= $1 AND pickup_scheduled_at < $2
+		ORDER BY zone_id, pickup_scheduled_at`,
+		day, day.AddDate(0, 0, 1),
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	groups := []models.ZonePickups{}
+	for rows.Next() {
+		var s models.Shipment
+		var zoneName sql.NullString
+		if err := rows.Scan(append(shipmentFields(&s), &zoneName)...); err != nil {
+			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
+			return
+		}
+
+		if len(groups) == 0 || groups[len(groups)-1].ZoneID != s.ZoneID {
+			groups = append(groups, models.ZonePickups{ZoneID: s.ZoneID, ZoneName: zoneName.String})
+		}
+		last := &groups[len(groups)-1]
+		last.Pickups = append(last.Pickups, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+// @Summary Create a return shipment
+// @Description Create a linked return shipment with origin and destination swapped (owning customer or admin).
+// @Description Only delivered shipments can be returned unless an admin sets force.
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Original shipment ID"
+// @Param return body models.ReturnRequest false "Return options"
+// @Success 201 {object} models.Shipment
+// @Router /api/shipments/{id}/return [post]
+func (h *ShipmentHandler) CreateReturn(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	shipmentID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReturnRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	trackingNumber, err := utils.GenerateTrackingNumber()
+	if err != nil {
+		http.Error(w, "Failed to generate tracking number", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the original serializes concurrent returns of it
+	var original models.Shipment
+	err = tx.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1
+		FOR UPDATE`,
+		shipmentID,
+	).Scan(shipmentFields(&original)...)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !requireVisible(w, err == nil && canViewShipment(claims, &original), "Shipment") {
+		return
+	}
+
+	isAdmin := claims.Role == "admin"
+	if original.Status != "delivered" && !(isAdmin && req.Force) {
+		http.Error(w, "Only delivered shipments can be returned", http.StatusConflict)
+		return
+	}
+
+	var existingReturnID int
+	err = tx.QueryRow("SELECT id FROM shipments WHERE return_of = $1", original.ID).Scan(&existingReturnID)
+	if err == nil {
+		http.Error(w, "A return already exists for this shipment", http.StatusConflict)
+		return
+	}
+	if err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// The return travels back the way the original came
+	var shipment models.Shipment
+	err = tx.QueryRow(`
+		INSERT INTO shipments (tracking_number, origin, destination, weight, zone_id, customer_id, status, cost, return_of,
+		                       cost_breakdown, priority) 
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7, $8, (SELECT cost_breakdown FROM shipments WHERE id = $8), $9) 
+		RETURNING `+shipmentColumns,
+		trackingNumber, original.Destination, original.Origin, original.Weight, original.ZoneID,
+		original.CustomerID, original.Cost, original.ID, original.Priority,
+	).Scan(shipmentFields(&shipment)...)
+
+	if isDuplicateReturn(err) {
+		http.Error(w, "A return already exists for this shipment", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create return shipment", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location) 
+		VALUES ($1, $2, $3)`,
+		shipment.ID, "pending", shipment.Origin,
+	)
+	if err != nil {
+		http.Error(w, "Failed to create tracking update", http.StatusInternalServerError)
 		return
 	}
 
-	response := models.ShipmentResponse{
-		Shipment:       shipment,
-		TrackingUpdate: trackingUpdates,
-		Zone:           zone,
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to create return shipment", http.StatusInternalServerError)
+		return
 	}
+	h.statsCache.Invalidate()
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shipment)
 }
 
-// @Summary Get shipping quote
-// @Description Get shipping quote based on weight and zone
+// isDuplicateReturn reports whether err is a violation of the one return per
+// shipment index.
+func isDuplicateReturn(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505" && pqErr.Constraint == "idx_shipments_return_of"
+}
+
+// @Summary Re-zone a shipment
+// @Description Move a shipment to another zone, e.g. when the wrong one was selected, and reprice it with the new zone's rate.
+// @Description A promo code redeemed at creation is applied again. Delivered shipments cannot be re-zoned. Every re-zone is audited (admin only).
 // @Tags shipments
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
-// @Param quote body models.QuoteRequest true "Quote request data"
-// @Success 200 {object} models.QuoteResponse
-// @Router /api/quote [post]
-func (h *ShipmentHandler) GetQuote(w http.ResponseWriter, r *http.Request) {
-	var req models.QuoteRequest
+// @Param id path int true "Shipment ID"
+// @Param request body models.RezoneRequest true "New zone"
+// @Success 200 {object} models.Shipment
+// @Failure 400 {string} string "Zone not found"
+// @Failure 404 {string} string "Shipment not found"
+// @Failure 409 {string} string "Delivered shipments cannot be re-zoned"
+// @Router /api/shipments/{id}/zone [put]
+func (h *ShipmentHandler) RezoneShipment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.RezoneRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-
 	if err := h.validator.Struct(req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Get zone info
+	if h.auditLog == nil {
+		http.Error(w, "Re-zoning shipments is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var shipment models.Shipment
+	err = tx.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1
+		FOR UPDATE`,
+		shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Shipment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if shipment.Status == "delivered" {
+		http.Error(w, "Delivered shipments cannot be re-zoned", http.StatusConflict)
+		return
+	}
+	if shipment.ZoneID == req.ZoneID {
+		http.Error(w, "Shipment is already in that zone", http.StatusConflict)
+		return
+	}
+
 	var zone models.Zone
-	err := h.db.QueryRow(`
-		SELECT id, name, price_per_kg, created_at, updated_at 
+	err = tx.QueryRow(`
+		SELECT `+zoneColumns+`
 		FROM zones WHERE id = $1`,
 		req.ZoneID,
-	).Scan(&zone.ID, &zone.Name, &zone.PricePerKg, &zone.CreatedAt, &zone.UpdatedAt)
-
+	).Scan(zoneFields(&zone)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.Error(w, "Zone not found", http.StatusNotFound)
+			http.Error(w, "Zone not found", http.StatusBadRequest)
 			return
 		}
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
-	totalPrice := req.Weight * zone.PricePerKg
+	// The promo code was already redeemed when the shipment was created, so
+	// its discount carries over without checking validity or usage again.
+	quote := calculateQuote(zone, shipment.Weight)
+	h.applyPriority(&quote, shipment.Priority)
+	var promo promoCode
+	err = tx.QueryRow(`
+		SELECT p.id, p.code, p.percent_off, p.flat_off
+		FROM promo_codes p JOIN shipments s ON s.promo_code_id = p.id
+		WHERE s.id = $1`,
+		shipmentID,
+	).Scan(&promo.ID, &promo.Code, &promo.PercentOff, &promo.FlatOff)
+	switch {
+	case err == nil:
+		promo.apply(&quote)
+	case err != sql.ErrNoRows:
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
 
-	response := models.QuoteResponse{
-		Weight:     req.Weight,
-		ZoneID:     req.ZoneID,
-		ZoneName:   zone.Name,
-		PricePerKg: zone.PricePerKg,
-		TotalPrice: totalPrice,
+	breakdown, err := json.Marshal(costComponents(quote))
+	if err != nil {
+		http.Error(w, "Failed to price shipment", http.StatusInternalServerError)
+		return
+	}
+
+	previousZoneID, previousCost := shipment.ZoneID, shipment.Cost
+	err = tx.QueryRow(`
+		UPDATE shipments SET zone_id = $1, cost = $2, discount = $3, cost_breakdown = $4
+		WHERE id = $5
+		RETURNING `+shipmentColumns,
+		zone.ID, quote.TotalPrice, quote.Discount, breakdown, shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil {
+		http.Error(w, "Failed to update shipment", http.StatusInternalServerError)
+		return
+	}
+
+	err = addTrackingNote(tx, shipment, shipment.Status,
+		fmt.Sprintf("Re-zoned to %s, cost %.2f -> %.2f", zone.Name, previousCost, shipment.Cost))
+	if err != nil {
+		http.Error(w, "Failed to add tracking update", http.StatusInternalServerError)
+		return
+	}
+
+	// Audited in the same transaction, so there is never an unaudited re-zone
+	err = h.auditLog.RecordTx(tx, audit.Entry{
+		ActorID:    claims.UserID,
+		UserID:     shipment.CustomerID,
+		Action:     fmt.Sprintf("%s %d %d->%d", audit.ActionRezoneShipment, shipment.ID, previousZoneID, zone.ID),
+		StatusCode: http.StatusOK,
+	})
+	if err != nil {
+		log.Printf("Failed to audit re-zone of shipment %d by admin %d: %v", shipment.ID, claims.UserID, err)
+		http.Error(w, "Failed to record re-zone", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(shipment)
 }
 
-// @Summary Update shipment status
-// @Description Update shipment status (admin/driver only)
+// addTrackingNote records a tracking update that does not move the shipment,
+// e.g. a re-zone. It repeats the last known location (the origin when there
+// is none) and carries the explanation in its note.
+func addTrackingNote(tx *sql.Tx, shipment models.Shipment, status, note string) error {
+	_, err := tx.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location, note)
+		VALUES ($1, $2, COALESCE((
+			SELECT location FROM tracking_updates
+			WHERE shipment_id = $1 AND location IS NOT NULL
+			ORDER BY timestamp DESC LIMIT 1
+		), $3), NULLIF($4, ''))`,
+		shipment.ID, status, shipment.Origin, note,
+	)
+	return err
+}
+
+// @Summary Accept or decline an assignment
+// @Description The assigned driver accepts a shipment, committing to pick it up, or declines it, which returns it to unassigned.
+// @Description Only shipments not yet picked up can be answered, and only once. Responses are recorded for staff, not on the tracking timeline, and declines are audited (assigned driver only).
 // @Tags shipments
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "Shipment ID"
-// @Param status body map[string]string true "Status update"
+// @Param request body models.AssignmentResponse true "Accept or decline, with an optional reason"
 // @Success 200 {object} models.Shipment
-// @Router /api/shipments/{id}/status [put]
-func (h *ShipmentHandler) UpdateShipmentStatus(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	shipmentID, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+// @Failure 404 {string} string "Shipment not found"
+// @Failure 409 {string} string "Assignment already accepted or shipment already picked up"
+// @Router /api/shipments/{id}/respond [post]
+func (h *ShipmentHandler) RespondToAssignment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	var req struct {
-		Status   string `json:"status" validate:"required"`
-		Location string `json:"location"`
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
 	}
 
+	var req models.AssignmentResponse
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-
 	if err := h.validator.Struct(req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Update shipment status
-	_, err = h.db.Exec(`
-		UPDATE shipments SET status = $1, updated_at = CURRENT_TIMESTAMP 
-		WHERE id = $2`,
-		req.Status, shipmentID,
-	)
-	if err != nil {
-		http.Error(w, "Failed to update shipment", http.StatusInternalServerError)
+	if !*req.Accept && h.auditLog == nil {
+		http.Error(w, "Declining assignments is not enabled", http.StatusServiceUnavailable)
 		return
 	}
 
-	// Add tracking update
-	_, err = h.db.Exec(`
-		INSERT INTO tracking_updates (shipment_id, status, location) 
-		VALUES ($1, $2, $3)`,
-		shipmentID, req.Status, req.Location,
-	)
+	tx, err := h.db.Begin()
 	if err != nil {
-		http.Error(w, "Failed to add tracking update", http.StatusInternalServerError)
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	// Get updated shipment
 	var shipment models.Shipment
-	err = h.db.QueryRow(`
-		SELECT id, tracking_number, origin, destination, weight, zone_id, 
-		       status, customer_id, driver_id, created_at, updated_at 
-		FROM shipments WHERE id = $1`,
+	err = tx.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1
+		FOR UPDATE`,
+		shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !requireVisible(w, err == nil && canViewShipment(claims, &shipment), "Shipment") {
+		return
+	}
+	if shipment.Status != "pending" && shipment.Status != statusPendingTracking {
+		http.Error(w, "Shipment has already been picked up or closed", http.StatusConflict)
+		return
+	}
+	if shipment.AcceptedAt != nil {
+		http.Error(w, "Assignment already accepted", http.StatusConflict)
+		return
+	}
+
+	query := `UPDATE shipments SET accepted_at = CURRENT_TIMESTAMP`
+	if !*req.Accept {
+		query = `UPDATE shipments SET driver_id = NULL`
+	}
+	err = tx.QueryRow(query+`
+		WHERE id = $1
+		RETURNING `+shipmentColumns,
 		shipmentID,
-	).Scan(&shipment.ID, &shipment.TrackingNumber, &shipment.Origin, &shipment.Destination,
-		&shipment.Weight, &shipment.ZoneID, &shipment.Status, &shipment.CustomerID,
-		&shipment.DriverID, &shipment.CreatedAt, &shipment.UpdatedAt)
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil {
+		http.Error(w, "Failed to update shipment", http.StatusInternalServerError)
+		return
+	}
 
+	// Kept off tracking_updates: the timeline is shown to customers, and the
+	// decline reason is for staff only
+	_, err = tx.Exec(`
+		INSERT INTO assignment_responses (shipment_id, driver_id, accepted, reason)
+		VALUES ($1, $2, $3, NULLIF($4, ''))`,
+		shipment.ID, claims.UserID, *req.Accept, req.Reason,
+	)
 	if err != nil {
-		http.Error(w, "Failed to get updated shipment", http.StatusInternalServerError)
+		http.Error(w, "Failed to record response", http.StatusInternalServerError)
+		return
+	}
+
+	if !*req.Accept {
+		// Audited in the same transaction, so there is never an unaudited decline
+		err = h.auditLog.RecordTx(tx, audit.Entry{
+			ActorID:    claims.UserID,
+			UserID:     claims.UserID,
+			Action:     fmt.Sprintf("%s %d", audit.ActionDeclineAssignment, shipment.ID),
+			StatusCode: http.StatusOK,
+		})
+		if err != nil {
+			log.Printf("Failed to audit decline of shipment %d by driver %d: %v", shipment.ID, claims.UserID, err)
+			http.Error(w, "Failed to record decline", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
 		return
 	}
 
@@ -479,3 +2233,204 @@ func (h *ShipmentHandler) UpdateShipmentStatus(w http.ResponseWriter, r *http.Re
 	json.NewEncoder(w).Encode(shipment)
 }
 
+// @Summary List stuck shipments
+// @Description List assigned shipments that have stayed in a status for longer than a duration, with their driver (admin only)
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Shipment status (default pending)"
+// @Param older_than query string false "Minimum time in status, e.g. 2h or 90m (default 2h)"
+// @Success 200 {array} models.StuckShipment
+// @Router /api/shipments/stuck [get]
+func (h *ShipmentHandler) GetStuckShipments(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "pending"
+	}
+	if !models.IsShipmentStatus(status) {
+		http.Error(w, "Invalid status, must be one of: "+strings.Join(models.ShipmentStatuses, ", "), http.StatusBadRequest)
+		return
+	}
+
+	olderThan := 2 * time.Hour
+	if value := r.URL.Query().Get("older_than"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid older_than, expected a duration like 2h or 90m", http.StatusBadRequest)
+			return
+		}
+		olderThan = parsed
+	}
+
+	// updated_at is bumped by every update, not just status changes, so the
+	// time a shipment entered its status comes from its tracking updates
+	rows, err := h.db.Query(`
+		SELECT `+shipmentColumns+`,
+			(SELECT name FROM users WHERE users.id = shipments.driver_id),
+			(SELECT email FROM users WHERE users.id = shipments.driver_id),
+			COALESCE(entered.timestamp, shipments.created_at)
+		FROM shipments
+		LEFT JOIN LATERAL (
+			SELECT timestamp FROM tracking_updates
+			WHERE tracking_updates.shipment_id = shipments.id
+			  AND tracking_updates.status = shipments.status
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) entered ON true
+		WHERE status = $1 AND driver_id IS NOT NULL
+		  AND COALESCE(entered.timestamp, shipments.created_at) < CURRENT_TIMESTAMP - $2 * INTERVAL '1 second'
+		ORDER BY COALESCE(entered.timestamp, shipments.created_at) ASC`,
+		status, olderThan.Seconds(),
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	shipments := []models.StuckShipment{}
+	for rows.Next() {
+		var s models.StuckShipment
+		var driverName, driverEmail sql.NullString
+		err := rows.Scan(append(shipmentFields(&s.Shipment), &driverName, &driverEmail, &s.InStatusSince)...)
+		if err != nil {
+			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
+			return
+		}
+		if s.DriverID != nil {
+			s.Driver = &models.ShipmentDriver{ID: *s.DriverID, Name: driverName.String, Email: driverEmail.String}
+		}
+		shipments = append(shipments, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shipments)
+}
+
+// @Summary List inactive shipments
+// @Description List open shipments whose latest tracking update is older than a time, least recently updated first (admin only). Delivered and cancelled shipments are excluded.
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Produce json
+// @Param since query string true "Cutoff (YYYY-MM-DD or RFC3339)"
+// @Success 200 {array} models.InactiveShipment
+// @Router /api/shipments/inactive [get]
+func (h *ShipmentHandler) GetInactiveShipments(w http.ResponseWriter, r *http.Request) {
+	since, _, ok := parseDateParam(r.URL.Query().Get("since"))
+	if !ok {
+		http.Error(w, "Invalid since, expected YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT `+shipmentColumns+`, COALESCE(latest.timestamp, shipments.created_at)
+		FROM shipments
+		LEFT JOIN LATERAL (
+			SELECT timestamp FROM tracking_updates
+			WHERE tracking_updates.shipment_id = shipments.id
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) latest ON true
+		WHERE status NOT IN ('delivered', 'cancelled')
+		  AND COALESCE(latest.timestamp, shipments.created_at) < $1
+		ORDER BY COALESCE(latest.timestamp, shipments.created_at) ASC`,
+		since,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	shipments := []models.InactiveShipment{}
+	for rows.Next() {
+		var s models.InactiveShipment
+		if err := rows.Scan(append(shipmentFields(&s.Shipment), &s.LastEventAt)...); err != nil {
+			http.Error(w, "Failed to scan shipment", http.StatusInternalServerError)
+			return
+		}
+		shipments = append(shipments, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shipments)
+}
+
+// @Summary Get shipment stats
+// @Description Get shipment counts in total and per status (admin only). Counts are cached briefly.
+// @Description With APPROXIMATE_COUNTS the total is an estimate from table statistics, flagged by approximate. Per-status counts stay exact.
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.ShipmentStats
+// @Router /api/shipments/stats [get]
+func (h *ShipmentHandler) GetShipmentStats(w http.ResponseWriter, r *http.Request) {
+	stats, ok := h.statsCache.Get()
+	if !ok {
+		var err error
+		stats, err = h.countShipments()
+		if err != nil {
+			http.Error(w, "Failed to get shipment stats", http.StatusInternalServerError)
+			return
+		}
+		h.statsCache.Set(stats)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+func (h *ShipmentHandler) countShipments() (models.ShipmentStats, error) {
+	stats := models.ShipmentStats{ByStatus: map[string]int{}}
+
+	rows, err := h.db.Query("SELECT status, COUNT(*) FROM shipments GROUP BY status")
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status sql.NullString
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return stats, err
+		}
+		stats.ByStatus[status.String] += count
+		stats.Total += count
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	// Only the top-level total is estimated: planner statistics only know
+	// the most common statuses, and would drop rare ones from the breakdown
+	if h.approximateCounts {
+		estimate, ok, err := estimateRowCount(h.db, "shipments")
+		if err != nil {
+			return stats, err
+		}
+		if ok {
+			stats.Total = estimate
+			stats.Approximate = true
+		}
+	}
+	return stats, nil
+}
+
+// estimateRowCount returns the planner's estimate of a table's row count
+// from pg_class. ok is false while the table has never been vacuumed or
+// analyzed, when Postgres has no estimate.
+func estimateRowCount(db *sql.DB, table string) (estimate int, ok bool, err error) {
+	var reltuples float64
+	err = db.QueryRow("SELECT reltuples FROM pg_class WHERE oid = to_regclass($1)", table).Scan(&reltuples)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if reltuples < 0 {
+		return 0, false, nil
+	}
+	return int(reltuples), true, nil
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goexpress-api/audit"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/outbox"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// shipmentTransitions is the shipment status state machine: the statuses a
+// shipment may move to from its current one. Delivered and cancelled
+// shipments are final.
+var shipmentTransitions = map[string][]string{
+	statusPendingTracking: {"cancelled"},
+	"pending":             {"picked_up", "cancelled"},
+	"picked_up":           {"in_transit", "cancelled"},
+	"in_transit":          {"out_for_delivery", "delivered", "cancelled"},
+	"out_for_delivery":    {"delivered", "in_transit", "cancelled"},
+	"delivered":           {},
+	"cancelled":           {},
+}
+
+// adminOnlyStatuses may only be set by admins.
+var adminOnlyStatuses = map[string]bool{
+	"cancelled": true,
+}
+
+// nextStatuses lists the transitions out of status that the role may make.
+// Only admins and drivers move shipments, so clients get none. Statuses
+// missing from models.ShipmentStatuses are never offered.
+func nextStatuses(status, role string) []string {
+	next := []string{}
+	if role != "admin" && role != "driver" {
+		return next
+	}
+	for _, s := range shipmentTransitions[status] {
+		if !models.IsShipmentStatus(s) || (adminOnlyStatuses[s] && role != "admin") {
+			continue
+		}
+		next = append(next, s)
+	}
+	return next
+}
+
+// writeStatusEvent records a shipment's new status in the event outbox, in
+// the transaction that changed it.
+func writeStatusEvent(tx *sql.Tx, shipment models.Shipment, location string) error {
+	return outbox.Write(tx, outbox.EventShipmentStatusChanged, shipment.ID, models.ShipmentStatusEvent{
+		ShipmentID:     shipment.ID,
+		TrackingNumber: shipment.TrackingNumber,
+		Reference:      shipment.Reference,
+		Status:         shipment.Status,
+		Location:       location,
+		OccurredAt:     shipment.UpdatedAt,
+	})
+}
+
+// @Summary List shipment statuses
+// @Description List every status a shipment may be in, e.g. for filter dropdowns
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} string
+// @Router /api/shipments/statuses [get]
+func (h *ShipmentHandler) GetShipmentStatuses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ShipmentStatuses)
+}
+
+// @Summary Get next shipment statuses
+// @Description List the statuses the caller may move a shipment to from its current status. Held shipments have none until released.
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Success 200 {object} models.NextStatusesResponse
+// @Router /api/shipments/{id}/next-statuses [get]
+func (h *ShipmentHandler) GetNextStatuses(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	shipmentID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	var shipment models.Shipment
+	err = h.db.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1`,
+		shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Shipment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if !requireVisible(w, canViewShipment(claims, &shipment), "Shipment") {
+		return
+	}
+
+	response := models.NextStatusesResponse{
+		Status:       shipment.Status,
+		OnHold:       shipment.OnHold,
+		NextStatuses: []string{},
+	}
+	if !shipment.OnHold {
+		response.NextStatuses = nextStatuses(shipment.Status, claims.Role)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// @Summary Reopen a cancelled shipment
+// @Description Move a cancelled shipment back to pending, e.g. after a mistaken cancellation (admin only). Refused when the customer has since created the same shipment again. Every reopen is audited.
+// @Tags shipments
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Success 200 {object} models.Shipment
+// @Failure 404 {string} string "Shipment not found"
+// @Failure 409 {string} string "Only cancelled shipments can be reopened"
+// @Router /api/shipments/{id}/reopen [post]
+func (h *ShipmentHandler) ReopenShipment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	if h.auditLog == nil {
+		http.Error(w, "Reopening shipments is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var shipment models.Shipment
+	err = tx.QueryRow(`
+		SELECT `+shipmentColumns+`
+		FROM shipments WHERE id = $1
+		FOR UPDATE`,
+		shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Shipment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if shipment.Status != "cancelled" {
+		http.Error(w, "Only cancelled shipments can be reopened", http.StatusConflict)
+		return
+	}
+
+	// A live shipment for the same customer and route, created since this
+	// one, is taken to be its replacement. Reopening would ship twice.
+	var replacement string
+	err = tx.QueryRow(`
+		SELECT s.reference
+		FROM shipments s JOIN shipments o ON o.id = $1
+		WHERE s.id != o.id AND s.customer_id = o.customer_id
+		  AND s.origin = o.origin AND s.destination = o.destination
+		  AND s.created_at >= o.created_at
+		  AND s.status != 'cancelled' AND s.return_of IS NULL
+		ORDER BY s.created_at
+		LIMIT 1`,
+		shipmentID,
+	).Scan(&replacement)
+	if err == nil {
+		http.Error(w, "Shipment was re-created as "+replacement, http.StatusConflict)
+		return
+	}
+	if err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Shipments cancelled before they got a tracking number go back to
+	// waiting for one. The driver it had may since be inactive or at
+	// capacity, so a reopened shipment goes back to dispatch unassigned.
+	status := "pending"
+	if shipment.TrackingNumber == "" {
+		status = statusPendingTracking
+	}
+
+	err = tx.QueryRow(`
+		UPDATE shipments SET status = $1, driver_id = NULL, accepted_at = NULL
+		WHERE id = $2
+		RETURNING `+shipmentColumns,
+		status, shipmentID,
+	).Scan(shipmentFields(&shipment)...)
+	if err != nil {
+		http.Error(w, "Failed to update shipment", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location)
+		VALUES ($1, $2, $3)`,
+		shipment.ID, shipment.Status, shipment.Origin,
+	)
+	if err != nil {
+		http.Error(w, "Failed to add tracking update", http.StatusInternalServerError)
+		return
+	}
+
+	if err := writeStatusEvent(tx, shipment, shipment.Origin); err != nil {
+		http.Error(w, "Failed to record shipment event", http.StatusInternalServerError)
+		return
+	}
+
+	// Audited in the same transaction, so there is never an unaudited reopen
+	// nor an audited one that was rolled back
+	err = h.auditLog.RecordTx(tx, audit.Entry{
+		ActorID:    claims.UserID,
+		UserID:     shipment.CustomerID,
+		Action:     fmt.Sprintf("%s %d", audit.ActionReopenShipment, shipment.ID),
+		StatusCode: http.StatusOK,
+	})
+	if err != nil {
+		log.Printf("Failed to audit reopen of shipment %d by admin %d: %v", shipment.ID, claims.UserID, err)
+		http.Error(w, "Failed to record reopen", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+	h.statsCache.Invalidate()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shipment)
+}
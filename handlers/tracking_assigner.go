@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/lib/pq"
+)
+
+// Shipments created in async mode sit in this status until the assigner has
+// given them a tracking number.
+const statusPendingTracking = "pending_tracking"
+
+const (
+	trackingAssignerQueueSize = 1024
+	trackingAssignerAttempts  = 5
+)
+
+// TrackingAssigner assigns tracking numbers to shipments created in async mode.
+// Newly created shipments are queued for immediate assignment; a periodic sweep
+// picks up anything left behind by a full queue or a restart.
+type TrackingAssigner struct {
+	db            *sql.DB
+	queue         chan int
+	sweepInterval time.Duration
+	// onAssigned, if set, is called with each shipment once its tracking
+	// number is committed.
+	onAssigned func(models.Shipment)
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewTrackingAssigner(db *sql.DB, sweepInterval time.Duration) *TrackingAssigner {
+	return &TrackingAssigner{
+		db:            db,
+		queue:         make(chan int, trackingAssignerQueueSize),
+		sweepInterval: sweepInterval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start runs the assignment worker in the background. It does nothing once
+// the assigner has been stopped.
+func (a *TrackingAssigner) Start() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.started || a.stopped {
+		return
+	}
+	a.started = true
+	go a.run()
+}
+
+// Stop stops the worker and waits for the assignment in progress, if any, to
+// finish. Shipments still queued are picked up by the sweep on the next start.
+func (a *TrackingAssigner) Stop() {
+	a.mu.Lock()
+	if a.stopped {
+		a.mu.Unlock()
+		return
+	}
+	a.stopped = true
+	close(a.stop)
+	started := a.started
+	a.mu.Unlock()
+
+	if started {
+		<-a.done
+	}
+}
+
+// Enqueue schedules a shipment for tracking number assignment without blocking.
+// If the queue is full the shipment is left for the next sweep.
+func (a *TrackingAssigner) Enqueue(shipmentID int) {
+	select {
+	case a.queue <- shipmentID:
+	default:
+	}
+}
+
+func (a *TrackingAssigner) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.sweepInterval)
+	defer ticker.Stop()
+
+	a.sweep()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case id := <-a.queue:
+			if err := a.assign(id); err != nil {
+				log.Printf("Failed to assign tracking number to shipment %d: %v", id, err)
+			}
+		case <-ticker.C:
+			a.sweep()
+		}
+	}
+}
+
+func (a *TrackingAssigner) sweep() {
+	rows, err := a.db.Query("SELECT id FROM shipments WHERE status = $1 ORDER BY id", statusPendingTracking)
+	if err != nil {
+		log.Printf("Failed to load shipments pending tracking: %v", err)
+		return
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("Failed to scan shipment pending tracking: %v", err)
+			break
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		select {
+		case <-a.stop:
+			return
+		default:
+		}
+		if err := a.assign(id); err != nil {
+			log.Printf("Failed to assign tracking number to shipment %d: %v", id, err)
+		}
+	}
+}
+
+// assign gives a shipment its final tracking number, retrying on collisions, and
+// records the initial tracking update. Shipments that already have one are left alone.
+func (a *TrackingAssigner) assign(shipmentID int) error {
+	for attempt := 0; ; attempt++ {
+		trackingNumber, err := utils.GenerateTrackingNumber()
+		if err != nil {
+			return err
+		}
+
+		err = a.assignNumber(shipmentID, trackingNumber)
+		if isTrackingNumberConflict(err) && attempt < trackingAssignerAttempts-1 {
+			continue
+		}
+		return err
+	}
+}
+
+// isTrackingNumberConflict reports whether err is a violation of the unique
+// constraint on shipments.tracking_number.
+func isTrackingNumberConflict(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505" && pqErr.Constraint == "shipments_tracking_number_key"
+}
+
+func (a *TrackingAssigner) assignNumber(shipmentID int, trackingNumber string) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var shipment models.Shipment
+	err = tx.QueryRow(`
+		UPDATE shipments SET tracking_number = $1, status = 'pending'
+		WHERE id = $2 AND status = $3
+		RETURNING `+shipmentColumns,
+		trackingNumber, shipmentID, statusPendingTracking,
+	).Scan(shipmentFields(&shipment)...)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location) 
+		VALUES ($1, $2, $3)`,
+		shipmentID, "pending", shipment.Origin,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if a.onAssigned != nil {
+		a.onAssigned(shipment)
+	}
+	return nil
+}
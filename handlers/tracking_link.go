@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+// TrackingLinkHandler mints and resolves shareable tracking links, which let
+// customers share a shipment's tracking page without exposing the raw
+// tracking number. Link tokens are HMAC-signed and carry the tracking number
+// encrypted, along with an optional expiry.
+type TrackingLinkHandler struct {
+	db        *sql.DB
+	secret    string
+	validator *validator.Validate
+}
+
+func NewTrackingLinkHandler(db *sql.DB, secret string) *TrackingLinkHandler {
+	return &TrackingLinkHandler{
+		db:        db,
+		secret:    secret,
+		validator: validator.New(),
+	}
+}
+
+// @Summary Create a tracking link
+// @Description Mint a shareable tracking link for a shipment (owning customer or admin)
+// @Tags tracking
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Shipment ID"
+// @Param request body models.TrackingLinkRequest false "Link expiry"
+// @Success 201 {object} models.TrackingLinkResponse
+// @Router /api/shipments/{id}/tracking-link [post]
+func (h *TrackingLinkHandler) CreateTrackingLink(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.TrackingLinkRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var owner models.Shipment
+	var trackingNumber sql.NullString
+	err = h.db.QueryRow("SELECT customer_id, driver_id, tracking_number FROM shipments WHERE id = $1", shipmentID).Scan(&owner.CustomerID, &owner.DriverID, &trackingNumber)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !requireVisible(w, err == nil && canViewShipment(claims, &owner), "Shipment") {
+		return
+	}
+
+	if !trackingNumber.Valid {
+		http.Error(w, "Shipment has no tracking number yet", http.StatusConflict)
+		return
+	}
+
+	response := models.TrackingLinkResponse{}
+	var expiresAt time.Time
+	if req.ExpiresInHours > 0 {
+		expiresAt = time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiry := models.NewUTCTime(expiresAt)
+		response.ExpiresAt = &expiry
+	}
+
+	response.Token, err = utils.SignTrackingToken(trackingNumber.String, expiresAt, h.secret)
+	if err != nil {
+		http.Error(w, "Failed to create tracking link", http.StatusInternalServerError)
+		return
+	}
+	response.URL = "/api/track?token=" + url.QueryEscape(response.Token)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// @Summary Track a shipment by link
+// @Description Get a shipment's status and tracking history from a shareable tracking link token (public endpoint)
+// @Tags tracking
+// @Produce json
+// @Param token query string true "Tracking link token"
+// @Success 200 {object} models.TrackedShipment
+// @Failure 403 {string} string "Invalid link"
+// @Failure 410 {string} string "Link has expired"
+// @Router /api/track [get]
+func (h *TrackingLinkHandler) Track(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	trackingNumber, err := utils.VerifyTrackingToken(token, h.secret, time.Now())
+	if err != nil {
+		if err == utils.ErrExpiredTrackingToken {
+			http.Error(w, "Link has expired", http.StatusGone)
+			return
+		}
+		http.Error(w, "Invalid link", http.StatusForbidden)
+		return
+	}
+
+	var shipment models.Shipment
+	err = retryRead(func() error {
+		return h.db.QueryRow(`
+			SELECT `+shipmentColumns+`
+			FROM shipments WHERE tracking_number = $1`,
+			trackingNumber,
+		).Scan(shipmentFields(&shipment)...)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Shipment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var details models.ShipmentResponse
+	err = retryRead(func() (err error) {
+		details, err = loadShipmentResponse(h.db, shipment)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to get shipment details", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.TrackedShipment{
+		Status:            shipment.Status,
+		Origin:            shipment.Origin,
+		Destination:       shipment.Destination,
+		Priority:          shipment.Priority,
+		OnHold:            shipment.OnHold,
+		EstimatedDelivery: shipment.EstimatedDelivery,
+		DeliveredAt:       shipment.DeliveredAt,
+		TrackingUpdates:   details.TrackingUpdate,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,32 +1,50 @@
 package handlers
 
 import (
+	"encoding/csv"
 	"database/sql"
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"goexpress-api/middleware"
 	"goexpress-api/models"
 	"goexpress-api/utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 type UserHandler struct {
-	db        *sql.DB
-	validator *validator.Validate
-	jwtSecret string
+	db                  *sql.DB
+	validator           *validator.Validate
+	jwtSecret           string
+	passwordHistorySize int
 }
 
-func NewUserHandler(db *sql.DB, jwtSecret string) *UserHandler {
+// NewUserHandler creates a user handler. passwordHistorySize is how many of
+// their most recent passwords, counting the current one, a user may not
+// reuse; 0 disables the check.
+func NewUserHandler(db *sql.DB, jwtSecret string, passwordHistorySize int) *UserHandler {
 	return &UserHandler{
-		db:        db,
-		validator: validator.New(),
-		jwtSecret: jwtSecret,
+		db:                  db,
+		validator:           validator.New(),
+		jwtSecret:           jwtSecret,
+		passwordHistorySize: passwordHistorySize,
 	}
 }
 
+// isDuplicateEmail reports whether err is a unique violation on a user's
+// email. The existence checks before writes are racy, so this is what
+// finally keeps two accounts from sharing an address.
+func isDuplicateEmail(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505" &&
+		(pqErr.Constraint == "users_email_key" || pqErr.Constraint == "idx_users_email_lower")
+}
+
 // @Summary Get all users
 // @Description Get all users (admin only)
 // @Tags users
@@ -36,28 +54,16 @@ func NewUserHandler(db *sql.DB, jwtSecret string) *UserHandler {
 // @Success 200 {array} models.User
 // @Router /api/users [get]
 func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Only admin can view all users
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
-	}
-
 	roleFilter := r.URL.Query().Get("role")
 	
 	var query string
 	var args []interface{}
 
 	if roleFilter != "" {
-		query = `SELECT id, name, email, role, created_at, updated_at FROM users WHERE role = $1 ORDER BY created_at DESC`
+		query = `SELECT id, name, email, role, is_active, created_at, updated_at FROM users WHERE role = $1 ORDER BY created_at DESC`
 		args = append(args, roleFilter)
 	} else {
-		query = `SELECT id, name, email, role, created_at, updated_at FROM users ORDER BY created_at DESC`
+		query = `SELECT id, name, email, role, is_active, created_at, updated_at FROM users ORDER BY created_at DESC`
 	}
 
 	rows, err := h.db.Query(query, args...)
@@ -67,10 +73,10 @@ func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	var users []models.User
+	users := []models.User{}
 	for rows.Next() {
 		var u models.User
-		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt)
 		if err != nil {
 			http.Error(w, "Failed to scan user", http.StatusInternalServerError)
 			return
@@ -98,10 +104,57 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 
 	var user models.User
 	err := h.db.QueryRow(`
-		SELECT id, name, email, role, created_at, updated_at 
+		SELECT id, name, email, role, is_active, created_at, updated_at 
 		FROM users WHERE id = $1`,
 		claims.UserID,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// @Summary Get user
+// @Description Get a user by ID (admin, or the user themselves)
+// @Tags users
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 404 {string} string "User not found"
+// @Router /api/users/{id} [get]
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Checked before the lookup so non-admins learn nothing about other ids
+	if !requireVisible(w, canViewUser(claims, userID), "User") {
+		return
+	}
+
+	var user models.User
+	err = h.db.QueryRow(`
+		SELECT id, name, email, role, is_active, created_at, updated_at 
+		FROM users WHERE id = $1`,
+		userID,
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -142,6 +195,7 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Email = utils.NormalizeEmail(req.Email)
 
 	// Check if email is already taken by another user
 	var existingID int
@@ -154,13 +208,17 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	// Update user profile
 	var user models.User
 	err = h.db.QueryRow(`
-		UPDATE users SET name = $1, email = $2, updated_at = CURRENT_TIMESTAMP 
+		UPDATE users SET name = $1, email = $2 
 		WHERE id = $3 
-		RETURNING id, name, email, role, created_at, updated_at`,
+		RETURNING id, name, email, role, is_active, created_at, updated_at`,
 		req.Name, req.Email, claims.UserID,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
+		if isDuplicateEmail(err) {
+			http.Error(w, "Email already taken", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
 		return
 	}
@@ -210,6 +268,16 @@ func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	reused, err := h.isRecentPassword(claims.UserID, currentPasswordHash, req.NewPassword)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if reused {
+		http.Error(w, "New password must not match a recently used password", http.StatusBadRequest)
+		return
+	}
+
 	// Hash new password
 	newPasswordHash, err := utils.HashPassword(req.NewPassword)
 	if err != nil {
@@ -218,12 +286,7 @@ func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update password
-	_, err = h.db.Exec(`
-		UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP 
-		WHERE id = $2`,
-		newPasswordHash, claims.UserID,
-	)
-	if err != nil {
+	if err := h.updatePassword(claims.UserID, newPasswordHash); err != nil {
 		http.Error(w, "Failed to update password", http.StatusInternalServerError)
 		return
 	}
@@ -244,18 +307,6 @@ func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 // @Success 201 {object} models.User
 // @Router /api/users [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Only admin can create users
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
-	}
-
 	var req models.CreateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
@@ -266,6 +317,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Email = utils.NormalizeEmail(req.Email)
 
 	// Check if user already exists
 	var existingID int
@@ -287,11 +339,15 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	err = h.db.QueryRow(`
 		INSERT INTO users (name, email, password_hash, role) 
 		VALUES ($1, $2, $3, $4) 
-		RETURNING id, name, email, role, created_at, updated_at`,
+		RETURNING id, name, email, role, is_active, created_at, updated_at`,
 		req.Name, req.Email, hashedPassword, req.Role,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
+		if isDuplicateEmail(err) {
+			http.Error(w, "User already exists", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
@@ -301,6 +357,166 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+// @Summary Import users from CSV (Admin only)
+// @Description Bulk-create users from a CSV file with columns name,email,role,password.
+// @Description Invalid or duplicate rows are reported per row; pass strict=true to import nothing when any row fails.
+// @Tags users
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV file"
+// @Param strict query bool false "Abort the whole import if any row fails"
+// @Success 200 {object} models.UserImportResponse
+// @Router /api/users/import [post]
+func (h *UserHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	strict := r.URL.Query().Get("strict") == "true"
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "CSV file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		http.Error(w, "Failed to read CSV header", http.StatusBadRequest)
+		return
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "email", "role", "password"} {
+		if _, ok := columns[required]; !ok {
+			http.Error(w, "Missing CSV column: "+required, http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	response := models.UserImportResponse{Results: []models.UserImportResult{}}
+	seen := make(map[string]int)
+
+	for rowNumber := 2; ; rowNumber++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Malformed CSV at row "+strconv.Itoa(rowNumber), http.StatusBadRequest)
+			return
+		}
+
+		req := models.CreateUserRequest{
+			Name:     strings.TrimSpace(record[columns["name"]]),
+			Email:    strings.TrimSpace(record[columns["email"]]),
+			Role:     strings.TrimSpace(record[columns["role"]]),
+			Password: record[columns["password"]],
+		}
+		result := models.UserImportResult{Row: rowNumber, Email: req.Email}
+
+		if errMsg := h.importUser(tx, req, seen, rowNumber, &result); errMsg != "" {
+			result.Status = "error"
+			result.Error = errMsg
+			response.Failed++
+		} else {
+			result.Status = "created"
+			response.Created++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	if strict && response.Failed > 0 {
+		// Nothing is committed, so report the would-be-created rows as skipped
+		for i := range response.Results {
+			if response.Results[i].Status == "created" {
+				response.Results[i].Status = "skipped"
+				response.Results[i].UserID = 0
+			}
+		}
+		response.Created = 0
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to import users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// importUser validates and inserts a single CSV row inside the import
+// transaction. It returns a non-empty message when the row is rejected.
+func (h *UserHandler) importUser(tx *sql.Tx, req models.CreateUserRequest, seen map[string]int, rowNumber int, result *models.UserImportResult) string {
+	if err := h.validator.Struct(req); err != nil {
+		return err.Error()
+	}
+
+	req.Email = utils.NormalizeEmail(req.Email)
+	if firstRow, ok := seen[req.Email]; ok {
+		return "Duplicate email in file (first seen at row " + strconv.Itoa(firstRow) + ")"
+	}
+	seen[req.Email] = rowNumber
+
+	var existingID int
+	err := tx.QueryRow("SELECT id FROM users WHERE email = $1", req.Email).Scan(&existingID)
+	if err == nil {
+		return "User already exists"
+	}
+	if err != sql.ErrNoRows {
+		return "Database error"
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return "Failed to hash password"
+	}
+
+	// A savepoint keeps one failed insert from aborting the whole transaction
+	if _, err := tx.Exec("SAVEPOINT import_row"); err != nil {
+		return "Database error"
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO users (name, email, password_hash, role) 
+		VALUES ($1, $2, $3, $4) 
+		RETURNING id`,
+		req.Name, req.Email, hashedPassword, req.Role,
+	).Scan(&result.UserID)
+	if err != nil {
+		tx.Exec("ROLLBACK TO SAVEPOINT import_row")
+		if isDuplicateEmail(err) {
+			return "User already exists"
+		}
+		return "Failed to create user"
+	}
+
+	tx.Exec("RELEASE SAVEPOINT import_row")
+	return ""
+}
+
 // @Summary Update user (Admin only)
 // @Description Update a user
 // @Tags users
@@ -312,18 +528,6 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} models.User
 // @Router /api/users/{id} [put]
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Only admin can update users
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
-	}
-
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -341,6 +545,7 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Email = utils.NormalizeEmail(req.Email)
 
 	// Check if email is already taken by another user
 	var existingID int
@@ -353,17 +558,21 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	// Update user
 	var user models.User
 	err = h.db.QueryRow(`
-		UPDATE users SET name = $1, email = $2, role = $3, updated_at = CURRENT_TIMESTAMP 
+		UPDATE users SET name = $1, email = $2, role = $3 
 		WHERE id = $4 
-		RETURNING id, name, email, role, created_at, updated_at`,
+		RETURNING id, name, email, role, is_active, created_at, updated_at`,
 		req.Name, req.Email, req.Role, userID,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "User not found", http.StatusNotFound)
 			return
 		}
+		if isDuplicateEmail(err) {
+			http.Error(w, "Email already taken", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to update user", http.StatusInternalServerError)
 		return
 	}
@@ -373,7 +582,7 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Delete user (Admin only)
-// @Description Delete a user
+// @Description Deactivate a user. The account is kept and can be restored with the activate endpoint.
 // @Tags users
 // @Security ApiKeyAuth
 // @Param id path int true "User ID"
@@ -386,12 +595,6 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only admin can delete users
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
-	}
-
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -405,7 +608,7 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.db.Exec("DELETE FROM users WHERE id = $1", userID)
+	result, err := h.db.Exec("UPDATE users SET is_active = FALSE WHERE id = $1", userID)
 	if err != nil {
 		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
 		return
@@ -425,6 +628,54 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// @Summary Activate user (Admin only)
+// @Description Reactivate a deleted user
+// @Tags users
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 404 {string} string "User not found"
+// @Failure 409 {string} string "User is already active"
+// @Router /api/users/{id}/activate [post]
+func (h *UserHandler) ActivateUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	err = h.db.QueryRow(`
+		UPDATE users SET is_active = TRUE
+		WHERE id = $1 AND NOT is_active
+		RETURNING id, name, email, role, is_active, created_at, updated_at`,
+		userID,
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		var exists bool
+		if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&exists); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "User is already active", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to activate user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
 // @Summary Reset user password (Admin only)
 // @Description Reset a user's password
 // @Tags users
@@ -436,18 +687,6 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} map[string]string
 // @Router /api/users/{id}/reset-password [post]
 func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Only admin can reset passwords
-	if claims.Role != "admin" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
-	}
-
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -466,32 +705,37 @@ func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Hash new password
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	var currentPasswordHash string
+	err = h.db.QueryRow("SELECT password_hash FROM users WHERE id = $1", userID).Scan(&currentPasswordHash)
 	if err != nil {
-		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
-	// Update password
-	result, err := h.db.Exec(`
-		UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP 
-		WHERE id = $2`,
-		hashedPassword, userID,
-	)
+	reused, err := h.isRecentPassword(userID, currentPasswordHash, req.NewPassword)
 	if err != nil {
-		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if reused {
+		http.Error(w, "New password must not match a recently used password", http.StatusBadRequest)
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// Hash new password
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
 		return
 	}
 
-	if rowsAffected == 0 {
-		http.Error(w, "User not found", http.StatusNotFound)
+	// Update password
+	if err := h.updatePassword(userID, hashedPassword); err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
 		return
 	}
 
@@ -500,3 +744,123 @@ func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		"message": "Password reset successfully",
 	})
 }
+
+// isRecentPassword reports whether password matches any of the user's last
+// passwordHistorySize passwords, counting the current one. Passwords set
+// outside updatePassword, such as at sign-up, are not in the history yet, so
+// the current hash stands in as the newest entry when it is missing.
+func (h *UserHandler) isRecentPassword(userID int, currentHash, password string) (bool, error) {
+	if h.passwordHistorySize <= 0 {
+		return false, nil
+	}
+
+	rows, err := h.db.Query(`
+		SELECT password_hash FROM password_history 
+		WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`,
+		userID, h.passwordHistorySize,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return false, err
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if len(hashes) == 0 || hashes[0] != currentHash {
+		hashes = append([]string{currentHash}, hashes...)
+		if len(hashes) > h.passwordHistorySize {
+			hashes = hashes[:h.passwordHistorySize]
+		}
+	}
+	for _, hash := range hashes {
+		if utils.CheckPasswordHash(password, hash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// updatePassword sets a user's password hash and records it in their
+// password history, keeping only the most recent passwordHistorySize
+// entries. The newest entry is always the current password.
+func (h *UserHandler) updatePassword(userID int, passwordHash string) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previousHash string
+	err = tx.QueryRow("SELECT password_hash FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&previousHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET password_hash = $1 
+		WHERE id = $2`,
+		passwordHash, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if h.passwordHistorySize > 0 {
+		// The password being replaced is only missing from the history if it
+		// was set elsewhere, such as at sign-up
+		_, err = tx.Exec(`
+			INSERT INTO password_history (user_id, password_hash)
+			SELECT $1::int, $2::text
+			WHERE $2::text IS DISTINCT FROM (
+				SELECT password_hash FROM password_history WHERE user_id = $1
+				ORDER BY created_at DESC, id DESC LIMIT 1
+			)`,
+			userID, previousHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO password_history (user_id, password_hash) VALUES ($1, $2)", userID, passwordHash)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			DELETE FROM password_history 
+			WHERE user_id = $1 AND id NOT IN (
+				SELECT id FROM password_history WHERE user_id = $1 
+				ORDER BY created_at DESC, id DESC LIMIT $2
+			)`,
+			userID, h.passwordHistorySize,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UserIsActive returns a lookup for middleware.RequireActiveUser. Unknown
+// users count as inactive.
+func UserIsActive(db *sql.DB) func(userID int) (bool, error) {
+	return func(userID int) (bool, error) {
+		var active bool
+		err := db.QueryRow("SELECT is_active FROM users WHERE id = $1", userID).Scan(&active)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return active, err
+	}
+}
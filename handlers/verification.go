@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"goexpress-api/mailer"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/notifier"
+	"goexpress-api/utils"
+)
+
+const (
+	// verificationCodeTTL is how long a verification code can be used.
+	verificationCodeTTL = 15 * time.Minute
+	// maxVerificationAttempts wrong codes void the code, so it cannot be
+	// guessed.
+	maxVerificationAttempts = 5
+	// verificationResendInterval is the minimum gap between two codes sent to
+	// a customer, and maxVerificationStartsPerHour caps them, so new codes
+	// cannot be used to keep guessing or to flood a phone with SMS.
+	verificationResendInterval   = time.Minute
+	maxVerificationStartsPerHour = 5
+)
+
+// SetNotifier enables contact verification, sending codes over email or SMS.
+func (h *CustomerHandler) SetNotifier(n notifier.Notifier) {
+	h.notifier = n
+}
+
+// customerVerified reports whether the user's customer record has a verified
+// email or phone.
+func customerVerified(db *sql.DB, userID int) (bool, error) {
+	var verified bool
+	err := db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM customers
+			WHERE user_id = $1 AND (email_verified_at IS NOT NULL OR phone_verified_at IS NOT NULL)
+		)`,
+		userID,
+	).Scan(&verified)
+	return verified, err
+}
+
+func newVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// @Summary Start contact verification
+// @Description Send a one-time code to the authenticated customer's email or phone. Confirming it verifies that contact detail.
+// @Description Codes can be requested at most once a minute and five times an hour.
+// @Tags customers
+// @Security ApiKeyAuth
+// @Accept json
+// @Param request body models.StartVerificationRequest true "Channel to verify"
+// @Success 202
+// @Failure 429 {string} string "Too many verification requests"
+// @Router /api/customers/me/verification [post]
+func (h *CustomerHandler) StartVerification(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.StartVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.notifier == nil {
+		http.Error(w, "Verification is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var customerID int
+	var email, phone string
+	err := h.db.QueryRow(`
+		SELECT c.id, u.email, COALESCE(c.phone, '')
+		FROM customers c JOIN users u ON u.id = c.user_id
+		WHERE c.user_id = $1`,
+		claims.UserID,
+	).Scan(&customerID, &email, &phone)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Customer not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if req.Channel == notifier.ChannelSMS && phone == "" {
+		http.Error(w, "No phone number on file", http.StatusBadRequest)
+		return
+	}
+
+	code, err := newVerificationCode()
+	if err != nil {
+		http.Error(w, "Failed to generate verification code", http.StatusInternalServerError)
+		return
+	}
+	codeHash, err := utils.HashPassword(code)
+	if err != nil {
+		http.Error(w, "Failed to generate verification code", http.StatusInternalServerError)
+		return
+	}
+
+	// The limits are checked and counted in the same statement, so parallel
+	// requests cannot get past them
+	result, err := h.db.Exec(`
+		UPDATE customers
+		SET verification_channel = $2, verification_code_hash = $3,
+		    verification_expires_at = CURRENT_TIMESTAMP + $4 * INTERVAL '1 second',
+		    verification_attempts = 0,
+		    verification_sent_at = CURRENT_TIMESTAMP,
+		    verification_starts = CASE WHEN verification_window_started_at > CURRENT_TIMESTAMP - INTERVAL '1 hour'
+		        THEN verification_starts + 1 ELSE 1 END,
+		    verification_window_started_at = CASE WHEN verification_window_started_at > CURRENT_TIMESTAMP - INTERVAL '1 hour'
+		        THEN verification_window_started_at ELSE CURRENT_TIMESTAMP END
+		WHERE id = $1
+		  AND (verification_sent_at IS NULL OR verification_sent_at <= CURRENT_TIMESTAMP - $5 * INTERVAL '1 second')
+		  AND NOT (verification_window_started_at > CURRENT_TIMESTAMP - INTERVAL '1 hour' AND verification_starts >= $6)`,
+		customerID, req.Channel, codeHash, verificationCodeTTL.Seconds(),
+		verificationResendInterval.Seconds(), maxVerificationStartsPerHour,
+	)
+	if err != nil {
+		http.Error(w, "Failed to start verification", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Too many verification requests, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	text := fmt.Sprintf("Your GoExpress verification code is %s. It expires in %d minutes.", code, int(verificationCodeTTL.Minutes()))
+	if req.Channel == notifier.ChannelSMS {
+		err = h.notifier.SendSMS(notifier.SMS{To: phone, Body: text})
+	} else {
+		err = h.notifier.SendEmail(mailer.Message{To: email, Subject: "Your GoExpress verification code", Body: text + "\n"})
+	}
+	if err != nil {
+		log.Printf("Failed to send verification code to customer %d: %v", customerID, err)
+		http.Error(w, "Failed to send verification code", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary Confirm contact verification
+// @Description Confirm the code sent by the start endpoint, verifying the email or phone it was sent to
+// @Tags customers
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.ConfirmVerificationRequest true "Verification code"
+// @Success 200 {object} models.VerificationStatus
+// @Failure 429 {string} string "Too many attempts"
+// @Router /api/customers/me/verification/confirm [post]
+func (h *CustomerHandler) ConfirmVerification(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.ConfirmVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Each guess uses up an attempt before the code is compared, in one
+	// statement, so parallel guesses cannot exceed maxVerificationAttempts
+	var customerID int
+	var channel, codeHash string
+	err := h.db.QueryRow(`
+		UPDATE customers SET verification_attempts = verification_attempts + 1
+		WHERE user_id = $1 AND verification_code_hash IS NOT NULL
+		  AND verification_expires_at > CURRENT_TIMESTAMP AND verification_attempts < $2
+		RETURNING id, verification_channel, verification_code_hash`,
+		claims.UserID, maxVerificationAttempts,
+	).Scan(&customerID, &channel, &codeHash)
+	if err == sql.ErrNoRows {
+		h.writeNoVerificationError(w, claims.UserID)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.Code, codeHash) {
+		http.Error(w, "Invalid verification code", http.StatusBadRequest)
+		return
+	}
+
+	verifiedColumn := "email_verified_at"
+	if channel == notifier.ChannelSMS {
+		verifiedColumn = "phone_verified_at"
+	}
+	var status models.VerificationStatus
+	err = h.db.QueryRow(`
+		UPDATE customers
+		SET `+verifiedColumn+` = CURRENT_TIMESTAMP,
+		    verification_channel = NULL, verification_code_hash = NULL,
+		    verification_expires_at = NULL, verification_attempts = 0
+		WHERE id = $1 AND verification_code_hash = $2
+		RETURNING email_verified_at IS NOT NULL, phone_verified_at IS NOT NULL`,
+		customerID, codeHash,
+	).Scan(&status.EmailVerified, &status.PhoneVerified)
+	if err == sql.ErrNoRows {
+		// A new code was requested while this one was being checked
+		http.Error(w, "Verification code has expired", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to confirm verification", http.StatusInternalServerError)
+		return
+	}
+	status.Verified = status.EmailVerified || status.PhoneVerified
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// writeNoVerificationError explains why a confirmation found no code it could
+// check: no customer, no code, an expired code or no attempts left.
+func (h *CustomerHandler) writeNoVerificationError(w http.ResponseWriter, userID int) {
+	var pending, active bool
+	err := h.db.QueryRow(`
+		SELECT verification_code_hash IS NOT NULL, COALESCE(verification_expires_at > CURRENT_TIMESTAMP, FALSE)
+		FROM customers WHERE user_id = $1`,
+		userID,
+	).Scan(&pending, &active)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Customer not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, "Database error", http.StatusInternalServerError)
+	case !pending:
+		http.Error(w, "No verification in progress", http.StatusBadRequest)
+	case !active:
+		http.Error(w, "Verification code has expired", http.StatusBadRequest)
+	default:
+		http.Error(w, "Too many attempts, request a new code", http.StatusTooManyRequests)
+	}
+}
+
+// @Summary Verify customer
+// @Description Mark a customer's email as verified without a code, e.g. after checking it by other means (admin only)
+// @Tags customers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Customer ID"
+// @Success 200 {object} models.Customer
+// @Router /api/customers/{id}/verify [post]
+func (h *CustomerHandler) VerifyCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := pathCustomerID(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE customers SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+		WHERE id = $1`,
+		customerID,
+	)
+	if err != nil {
+		http.Error(w, "Failed to verify customer", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+
+	var customer models.Customer
+	err = h.db.QueryRow(customerSelect+`
+		WHERE c.id = $1`,
+		customerID,
+	).Scan(customerFields(&customer)...)
+	if err != nil {
+		http.Error(w, "Failed to get customer", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
+}
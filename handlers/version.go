@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"goexpress-api/buildinfo"
+	"goexpress-api/database"
+	"goexpress-api/models"
+)
+
+type VersionHandler struct {
+	db *sql.DB
+}
+
+func NewVersionHandler(db *sql.DB) *VersionHandler {
+	return &VersionHandler{db: db}
+}
+
+// @Summary Get API version
+// @Description Get the version, git commit, build date and database schema version of the running server
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.VersionResponse
+// @Router /api/version [get]
+func (h *VersionHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	response := models.VersionResponse{Info: buildinfo.Get()}
+	if h.db != nil {
+		if version, err := database.SchemaVersion(h.db); err == nil {
+			response.SchemaVersion = &version
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
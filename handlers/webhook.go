@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/outbox"
+	"goexpress-api/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+// WebhookHandler lets customers receive webhooks for their own shipments
+// and check how they verify them.
+type WebhookHandler struct {
+	db        *sql.DB
+	validator *validator.Validate
+}
+
+func NewWebhookHandler(db *sql.DB) *WebhookHandler {
+	return &WebhookHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// @Summary Set the webhook endpoint
+// @Description Set the URL the authenticated customer's shipment events are sent to, and issue a new secret they are signed with.
+// @Description The secret is only returned here; setting the endpoint again replaces it.
+// @Tags webhooks
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.WebhookEndpointRequest true "Webhook URL"
+// @Success 200 {object} models.WebhookEndpoint
+// @Failure 404 {string} string "Customer not found"
+// @Router /api/webhooks/endpoint [put]
+func (h *WebhookHandler) SetEndpoint(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.WebhookEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, err := utils.NewWebhookSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate webhook secret", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE customers SET webhook_url = $1, webhook_secret = $2
+		WHERE user_id = $3`,
+		req.URL, secret, claims.UserID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WebhookEndpoint{URL: req.URL, Secret: secret})
+}
+
+// @Summary Get a signed sample webhook
+// @Description Get a sample webhook body and the signature it would be sent with, to test signature verification.
+// @Description The sample is signed with the authenticated customer's own webhook secret, issued when they set their webhook endpoint.
+// @Description The signature is "sha256=" followed by the hex HMAC-SHA256 of the raw body keyed with the webhook secret, sent in the X-GoExpress-Signature header.
+// @Description The sample's event type is webhook.sample, which real deliveries never use.
+// @Tags webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.WebhookSample
+// @Failure 404 {string} string "Customer not found"
+// @Failure 409 {string} string "No webhook endpoint is set"
+// @Router /api/webhooks/verify-sample [get]
+func (h *WebhookHandler) GetVerifySample(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var secret sql.NullString
+	err := h.db.QueryRow("SELECT webhook_secret FROM customers WHERE user_id = $1", claims.UserID).Scan(&secret)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Customer not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if secret.String == "" {
+		http.Error(w, "No webhook endpoint is set", http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	payload, err := json.Marshal(models.ShipmentStatusEvent{
+		TrackingNumber: utils.TrackingNumberPrefix + "00000000",
+		Status:         "in_transit",
+		Location:       "Ouagadougou",
+		OccurredAt:     models.NewUTCTime(now),
+	})
+	if err != nil {
+		http.Error(w, "Failed to build sample", http.StatusInternalServerError)
+		return
+	}
+	// Marshalled exactly as the outbox dispatchers send real events
+	body, err := json.Marshal(outbox.Event{
+		Type:      outbox.EventWebhookSample,
+		Payload:   payload,
+		CreatedAt: now,
+	})
+	if err != nil {
+		http.Error(w, "Failed to build sample", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WebhookSample{
+		Header:    utils.WebhookSignatureHeader,
+		Algorithm: "HMAC-SHA256",
+		Body:      string(body),
+		Signature: utils.SignWebhook(secret.String, body),
+	})
+}
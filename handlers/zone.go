@@ -5,24 +5,43 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"goexpress-api/cache"
 	"goexpress-api/models"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
 type ZoneHandler struct {
-	db        *sql.DB
-	validator *validator.Validate
+	db         *sql.DB
+	validator  *validator.Validate
+	quoteCache cache.Quotes
 }
 
 func NewZoneHandler(db *sql.DB) *ZoneHandler {
 	return &ZoneHandler{
-		db:        db,
-		validator: validator.New(),
+		db:         db,
+		validator:  validator.New(),
+		quoteCache: cache.NewTTLQuotes(0),
 	}
 }
 
+// SetQuoteCache sets the quote cache to invalidate when a zone's pricing
+// changes. Pass the one the ShipmentHandler quotes from.
+func (h *ZoneHandler) SetQuoteCache(quoteCache cache.Quotes) {
+	h.quoteCache = quoteCache
+}
+
+// defaultZoneSLAHours is the promised delivery time for zones created without one.
+const defaultZoneSLAHours = 72
+
+const zoneColumns = `id, name, price_per_kg, sla_hours, daily_capacity, created_at, updated_at`
+
+func zoneFields(z *models.Zone) []interface{} {
+	return []interface{}{&z.ID, &z.Name, &z.PricePerKg, &z.SLAHours, &z.DailyCapacity, &z.CreatedAt, &z.UpdatedAt}
+}
+
 // @Summary Get all zones
 // @Description Get all GoExpress shipping zones
 // @Tags zones
@@ -30,9 +49,51 @@ func NewZoneHandler(db *sql.DB) *ZoneHandler {
 // @Success 200 {array} models.Zone
 // @Router /api/zones [get]
 func (h *ZoneHandler) GetZones(w http.ResponseWriter, r *http.Request) {
+	var zones []models.Zone
+	err := retryRead(func() error {
+		zones = []models.Zone{}
+		rows, err := h.db.Query(`
+			SELECT `+zoneColumns+` 
+			FROM zones ORDER BY name`,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var z models.Zone
+			if err := rows.Scan(zoneFields(&z)...); err != nil {
+				return err
+			}
+			zones = append(zones, z)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(zones)
+}
+
+// @Summary Get zone load
+// @Description Get every zone with its active (not delivered or cancelled) shipment count and their average weight, for capacity planning (admin only)
+// @Tags zones
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} models.ZoneLoad
+// @Router /api/zones/load [get]
+func (h *ZoneHandler) GetZoneLoad(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query(`
-		SELECT id, name, price_per_kg, created_at, updated_at 
-		FROM zones ORDER BY name`,
+		SELECT z.id, z.name, z.price_per_kg, z.sla_hours, z.daily_capacity, z.created_at, z.updated_at,
+		       COUNT(s.id), COALESCE(ROUND(AVG(s.weight)::numeric, 2), 0)
+		FROM zones z
+		LEFT JOIN shipments s ON s.zone_id = z.id AND s.status NOT IN ('delivered', 'cancelled')
+		GROUP BY z.id
+		ORDER BY z.name`,
 	)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -40,19 +101,22 @@ func (h *ZoneHandler) GetZones(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	var zones []models.Zone
+	loads := []models.ZoneLoad{}
 	for rows.Next() {
-		var z models.Zone
-		err := rows.Scan(&z.ID, &z.Name, &z.PricePerKg, &z.CreatedAt, &z.UpdatedAt)
-		if err != nil {
-			http.Error(w, "Failed to scan zone", http.StatusInternalServerError)
+		var load models.ZoneLoad
+		if err := rows.Scan(append(zoneFields(&load.Zone), &load.ActiveShipments, &load.AverageWeight)...); err != nil {
+			http.Error(w, "Failed to scan zone load", http.StatusInternalServerError)
 			return
 		}
-		zones = append(zones, z)
+		loads = append(loads, load)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(zones)
+	json.NewEncoder(w).Encode(loads)
 }
 
 // @Summary Create a new zone
@@ -76,13 +140,17 @@ func (h *ZoneHandler) CreateZone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.SLAHours == 0 {
+		req.SLAHours = defaultZoneSLAHours
+	}
+
 	var zone models.Zone
 	err := h.db.QueryRow(`
-		INSERT INTO zones (name, price_per_kg) 
-		VALUES ($1, $2) 
-		RETURNING id, name, price_per_kg, created_at, updated_at`,
-		req.Name, req.PricePerKg,
-	).Scan(&zone.ID, &zone.Name, &zone.PricePerKg, &zone.CreatedAt, &zone.UpdatedAt)
+		INSERT INTO zones (name, price_per_kg, sla_hours, daily_capacity) 
+		VALUES ($1, $2, $3, $4) 
+		RETURNING `+zoneColumns,
+		req.Name, req.PricePerKg, req.SLAHours, req.DailyCapacity,
+	).Scan(zoneFields(&zone)...)
 
 	if err != nil {
 		http.Error(w, "Failed to create zone", http.StatusInternalServerError)
@@ -95,7 +163,7 @@ func (h *ZoneHandler) CreateZone(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update a zone
-// @Description Update a GoExpress shipping zone (admin only)
+// @Description Update a GoExpress shipping zone (admin only). Omitting daily_capacity removes the zone's limit.
 // @Tags zones
 // @Security ApiKeyAuth
 // @Accept json
@@ -125,11 +193,80 @@ func (h *ZoneHandler) UpdateZone(w http.ResponseWriter, r *http.Request) {
 
 	var zone models.Zone
 	err = h.db.QueryRow(`
-		UPDATE zones SET name = $1, price_per_kg = $2, updated_at = CURRENT_TIMESTAMP 
-		WHERE id = $3 
-		RETURNING id, name, price_per_kg, created_at, updated_at`,
-		req.Name, req.PricePerKg, zoneID,
-	).Scan(&zone.ID, &zone.Name, &zone.PricePerKg, &zone.CreatedAt, &zone.UpdatedAt)
+		UPDATE zones SET name = $1, price_per_kg = $2, sla_hours = COALESCE(NULLIF($3, 0), sla_hours),
+			daily_capacity = $4
+		WHERE id = $5 
+		RETURNING `+zoneColumns,
+		req.Name, req.PricePerKg, req.SLAHours, req.DailyCapacity, zoneID,
+	).Scan(zoneFields(&zone)...)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Zone not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update zone", http.StatusInternalServerError)
+		return
+	}
+	h.quoteCache.InvalidateZone(zone.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(zone)
+}
+
+// @Summary Partially update a zone
+// @Description Update only the given fields of a GoExpress shipping zone (admin only)
+// @Tags zones
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Zone ID"
+// @Param zone body models.PatchZoneRequest true "Fields to change"
+// @Success 200 {object} models.Zone
+// @Router /api/zones/{id} [patch]
+func (h *ZoneHandler) PatchZone(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	zoneID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid zone ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.PatchZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == nil && req.PricePerKg == nil && req.SLAHours == nil && req.DailyCapacity == nil {
+		http.Error(w, "At least one of name, price_per_kg, sla_hours or daily_capacity is required", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		http.Error(w, "name must not be empty", http.StatusBadRequest)
+		return
+	}
+	if req.PricePerKg != nil && *req.PricePerKg <= 0 {
+		http.Error(w, "price_per_kg must be greater than 0", http.StatusBadRequest)
+		return
+	}
+	if req.SLAHours != nil && *req.SLAHours <= 0 {
+		http.Error(w, "sla_hours must be greater than 0", http.StatusBadRequest)
+		return
+	}
+	if req.DailyCapacity != nil && *req.DailyCapacity <= 0 {
+		http.Error(w, "daily_capacity must be greater than 0", http.StatusBadRequest)
+		return
+	}
+
+	var zone models.Zone
+	err = h.db.QueryRow(`
+		UPDATE zones SET name = COALESCE($1, name), price_per_kg = COALESCE($2, price_per_kg),
+			sla_hours = COALESCE($3, sla_hours), daily_capacity = COALESCE($4, daily_capacity)
+		WHERE id = $5 
+		RETURNING `+zoneColumns,
+		req.Name, req.PricePerKg, req.SLAHours, req.DailyCapacity, zoneID,
+	).Scan(zoneFields(&zone)...)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -139,6 +276,7 @@ func (h *ZoneHandler) UpdateZone(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to update zone", http.StatusInternalServerError)
 		return
 	}
+	h.quoteCache.InvalidateZone(zone.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(zone)
@@ -175,6 +313,7 @@ func (h *ZoneHandler) DeleteZone(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Zone not found", http.StatusNotFound)
 		return
 	}
+	h.quoteCache.InvalidateZone(zoneID)
 
 	w.WriteHeader(http.StatusNoContent)
 }
\ No newline at end of file
@@ -0,0 +1,278 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned by Queue.Send when the message could not be
+	// queued. The message is dropped and recorded as a failure.
+	ErrQueueFull = errors.New("mail queue is full")
+
+	// ErrQueueStopped is returned by Queue.Send once the queue is stopping.
+	// It is also recorded for messages still undelivered when Stop gives up.
+	ErrQueueStopped = errors.New("mail queue is stopped")
+)
+
+// maxRecentFailures caps how many failures the queue remembers for ops.
+const maxRecentFailures = 50
+
+// Failure describes an email that could not be delivered.
+type Failure struct {
+	To       string    `json:"to"`
+	Subject  string    `json:"subject"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Stats summarises the queue's delivery history since startup.
+type Stats struct {
+	Pending        int       `json:"pending"`
+	Sent           int64     `json:"sent"`
+	Failed         int64     `json:"failed"`
+	RecentFailures []Failure `json:"recent_failures"`
+}
+
+// Queue delivers emails in the background through another Mailer, retrying
+// failed sends with exponential backoff. Send never blocks on delivery, so a
+// slow or unavailable mail server cannot hold up the request that triggered
+// the email, and a message waiting for a retry does not hold up the others.
+type Queue struct {
+	mailer   Mailer
+	jobs     chan job
+	attempts int
+	backoff  time.Duration
+
+	// inflight counts messages not yet delivered or given up on, including
+	// those waiting for a retry, so Stop knows when the queue has drained.
+	inflight sync.WaitGroup
+	quit     chan struct{}
+
+	mu        sync.Mutex
+	stopped   bool
+	abandoned bool
+	waiting   map[*job]*time.Timer // messages waiting for a retry
+	sent      int64
+	failed    int64
+	failures  []Failure
+}
+
+// job is a message and the number of the delivery attempt it is due for.
+type job struct {
+	msg     Message
+	attempt int
+}
+
+func NewQueue(m Mailer, size, attempts int, backoff time.Duration) *Queue {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &Queue{
+		mailer:   m,
+		jobs:     make(chan job, size),
+		attempts: attempts,
+		backoff:  backoff,
+		quit:     make(chan struct{}),
+		waiting:  map[*job]*time.Timer{},
+	}
+}
+
+// Start runs the delivery worker in the background.
+func (q *Queue) Start() {
+	go q.run()
+}
+
+// Stop refuses new messages and waits for the queued ones, and any retries
+// they are waiting for, to be delivered or given up on. When ctx ends first,
+// the messages still undelivered are recorded as failures and ctx's error
+// is returned.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		return nil
+	}
+	q.stopped = true
+	q.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		q.inflight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		close(q.quit)
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		q.abandoned = true
+		q.mu.Unlock()
+		close(q.quit)
+		return ctx.Err()
+	}
+}
+
+// Send queues msg for delivery without blocking. It only fails when the
+// queue is full or stopping; delivery errors are logged and recorded instead.
+func (q *Queue) Send(msg Message) error {
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		q.recordFailure(msg, ErrQueueStopped, 0)
+		return ErrQueueStopped
+	}
+	q.inflight.Add(1)
+	q.mu.Unlock()
+
+	select {
+	case q.jobs <- job{msg: msg, attempt: 1}:
+		return nil
+	default:
+		q.inflight.Done()
+		q.recordFailure(msg, ErrQueueFull, 0)
+		return ErrQueueFull
+	}
+}
+
+// Stats returns delivery counters and the most recent failures, newest first.
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	recent := make([]Failure, len(q.failures))
+	for i, f := range q.failures {
+		recent[len(q.failures)-1-i] = f
+	}
+	return Stats{
+		Pending:        len(q.jobs) + len(q.waiting),
+		Sent:           q.sent,
+		Failed:         q.failed,
+		RecentFailures: recent,
+	}
+}
+
+func (q *Queue) run() {
+	for {
+		select {
+		case j := <-q.jobs:
+			q.deliver(j)
+		case <-q.quit:
+			q.abandon()
+			return
+		}
+	}
+}
+
+// deliver makes one delivery attempt and, when it fails and attempts are
+// left, schedules the next one after the backoff instead of waiting for it.
+func (q *Queue) deliver(j job) {
+	err := q.mailer.Send(j.msg)
+	if err == nil {
+		q.mu.Lock()
+		q.sent++
+		q.mu.Unlock()
+		q.inflight.Done()
+		return
+	}
+
+	if j.attempt >= q.attempts {
+		log.Printf("Failed to send email %q to %s after %d attempts: %v", j.msg.Subject, j.msg.To, j.attempt, err)
+		q.recordFailure(j.msg, err, j.attempt)
+		q.inflight.Done()
+		return
+	}
+
+	next := &job{msg: j.msg, attempt: j.attempt + 1}
+	q.mu.Lock()
+	q.waiting[next] = time.AfterFunc(q.backoff<<(j.attempt-1), func() { q.retry(next) })
+	q.mu.Unlock()
+}
+
+// retry puts a message whose backoff is over back on the queue, or records it
+// as failed when the queue is full or has been abandoned.
+func (q *Queue) retry(next *job) {
+	q.mu.Lock()
+	if _, ok := q.waiting[next]; !ok {
+		q.mu.Unlock()
+		return
+	}
+	delete(q.waiting, next)
+	j := *next
+	abandoned := q.abandoned
+	queued := false
+	if !abandoned {
+		select {
+		case q.jobs <- j:
+			queued = true
+		default:
+		}
+	}
+	q.mu.Unlock()
+
+	if queued {
+		return
+	}
+	err := ErrQueueFull
+	if abandoned {
+		err = ErrQueueStopped
+	}
+	q.drop(j, err)
+}
+
+// abandon records the messages still queued or waiting for a retry when
+// Stop gave up on draining.
+func (q *Queue) abandon() {
+	var dropped []job
+	q.mu.Lock()
+	for next, timer := range q.waiting {
+		// A timer that already fired is left to retry, which sees abandoned
+		if timer.Stop() {
+			delete(q.waiting, next)
+			dropped = append(dropped, *next)
+		}
+	}
+	q.mu.Unlock()
+
+	for {
+		select {
+		case j := <-q.jobs:
+			dropped = append(dropped, j)
+		default:
+			for _, j := range dropped {
+				q.drop(j, ErrQueueStopped)
+			}
+			return
+		}
+	}
+}
+
+// drop gives up on a message, after j.attempt-1 failed attempts.
+func (q *Queue) drop(j job, err error) {
+	log.Printf("Dropped email %q to %s after %d attempts: %v", j.msg.Subject, j.msg.To, j.attempt-1, err)
+	q.recordFailure(j.msg, err, j.attempt-1)
+	q.inflight.Done()
+}
+
+func (q *Queue) recordFailure(msg Message, err error, attempts int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.failed++
+	q.failures = append(q.failures, Failure{
+		To:       msg.To,
+		Subject:  msg.Subject,
+		Error:    err.Error(),
+		Attempts: attempts,
+		FailedAt: time.Now().UTC(),
+	})
+	if len(q.failures) > maxRecentFailures {
+		q.failures = q.failures[len(q.failures)-maxRecentFailures:]
+	}
+}
@@ -1,17 +1,39 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"goexpress-api/audit"
+	"goexpress-api/buildinfo"
+	"goexpress-api/cache"
 	"goexpress-api/config"
 	"goexpress-api/database"
 	"goexpress-api/handlers"
+	"goexpress-api/mailer"
 	"goexpress-api/middleware"
+	"goexpress-api/notifier"
+	"goexpress-api/outbox"
+	"goexpress-api/pricing"
+	"goexpress-api/utils"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// shutdownTimeout bounds how long in-flight requests get to finish on
+// SIGINT or SIGTERM.
+const shutdownTimeout = 15 * time.Second
+
+// mailDrainTimeout bounds how long queued emails, and their retries, get to
+// be delivered once everything else has stopped.
+const mailDrainTimeout = 10 * time.Second
+
 // @title GoExpress Delivery Management API
 // @version 1.0
 // @description A comprehensive API for GoExpress delivery operations
@@ -25,15 +47,24 @@ import (
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
+
+// @securityDefinitions.apikey IntegrationKey
+// @in header
+// @name X-API-Key
 func main() {
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("❌ Refusing to start:", err)
+	}
 
 	log.Printf("🚀 Starting GoExpress API Server...")
 	log.Printf("📊 Environment: %s", cfg.Environment)
 	log.Printf("🔧 Port: %s", cfg.Port)
+	log.Printf("⚙️  Config: %s", cfg.Summary())
 
 	// Connect to database
+	database.SetSlowQueryThreshold(cfg.SlowQueryThreshold)
 	db, err := database.New(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal("❌ Failed to connect to database:", err)
@@ -42,27 +73,96 @@ func main() {
 
 	log.Printf("✅ Connected to GoExpress database")
 
-	// Run migrations
-	if err := db.RunMigrations(); err != nil {
-		log.Fatal("❌ Failed to run migrations:", err)
+	utils.SetPageSizes(cfg.DefaultPageSize, cfg.MaxPageSize)
+	if err := utils.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("❌ Invalid TRUSTED_PROXIES:", err)
 	}
 
-	log.Printf("✅ Database migrations completed")
-
 	// Initialize handlers
+	auditLog := audit.NewLog(db.DB)
 	authHandler := handlers.NewAuthHandler(db.DB, cfg.JWTSecret, cfg.JWTRefreshSecret)
+	authHandler.SetAuditLog(auditLog)
+	authHandler.SetImpersonationTTL(cfg.ImpersonationTTL)
+	authHandler.SetRegistrationEnabled(cfg.RegistrationEnabled)
+	trackingAssigner := handlers.NewTrackingAssigner(db.DB, 30*time.Second)
 	shipmentHandler := handlers.NewShipmentHandler(db.DB)
+	shipmentHandler.SetTrackingAssigner(trackingAssigner)
+	shipmentHandler.SetStatsCache(cache.NewTTLShipmentStats(cfg.StatsCacheTTL))
+	shipmentHandler.SetApproximateCounts(cfg.ApproximateCounts)
+	shipmentHandler.SetPriorityMultipliers(cfg.ExpressMultiplier, cfg.OvernightMultiplier)
+	quoteCache := cache.NewTTLQuotes(cfg.QuoteCacheTTL)
+	shipmentHandler.SetQuoteCache(quoteCache)
+	if cfg.QuoteWebhookURL != "" {
+		shipmentHandler.SetQuoteAdjuster(pricing.NewWebhookAdjuster(cfg.QuoteWebhookURL, cfg.QuoteWebhookTimeout))
+	}
+	shipmentHandler.SetTrackingDedupeWindow(cfg.TrackingDedupeWindow)
+	var mailTransport mailer.Mailer = mailer.NewLogMailer()
+	if cfg.SMTPHost != "" {
+		mailTransport = mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+	mailQueue := mailer.NewQueue(mailTransport, cfg.MailQueueSize, cfg.MailMaxAttempts, cfg.MailRetryBackoff)
+	mailQueue.Start()
+	if cfg.ShipmentEmailsEnabled {
+		shipmentHandler.SetMailer(mailQueue)
+	}
+	shipmentHandler.SetResendNotificationInterval(cfg.ResendInterval)
+	shipmentHandler.SetRequireVerifiedCustomers(cfg.RequireVerification)
+	shipmentHandler.SetAuditLog(auditLog)
+	if cfg.StatusNotifications {
+		shipmentHandler.SetNotifier(notifier.New(mailQueue, notifier.NewLogSMSSender()))
+	}
+	if cfg.WelcomeEmailsEnabled {
+		authHandler.SetMailer(mailQueue)
+	}
+	var eventDispatcher outbox.Dispatcher = outbox.LogDispatcher{}
+	if cfg.WebhookURL != "" {
+		eventDispatcher = outbox.NewWebhookDispatcher(cfg.WebhookURL, cfg.WebhookSecret, cfg.WebhookTimeout)
+	}
+	// Customers also get their own shipments' events, at their own endpoints
+	eventDispatcher = outbox.Dispatchers{eventDispatcher, outbox.NewCustomerWebhookDispatcher(db.DB, cfg.WebhookTimeout)}
+	relay := outbox.NewRelay(db.DB, eventDispatcher, cfg.OutboxRelayInterval)
 	zoneHandler := handlers.NewZoneHandler(db.DB)
-	userHandler := handlers.NewUserHandler(db.DB, cfg.JWTSecret)
+	zoneHandler.SetQuoteCache(quoteCache)
+	userHandler := handlers.NewUserHandler(db.DB, cfg.JWTSecret, cfg.PasswordHistorySize)
 	customerHandler := handlers.NewCustomerHandler(db.DB)
+	customerHandler.SetNotifier(notifier.New(mailQueue, notifier.NewLogSMSSender()))
 	driverHandler := handlers.NewDriverHandler(db.DB)
+	driverHandler.SetDefaultCommissionRate(cfg.DriverCommissionRate)
+	maintenance := middleware.NewMaintenanceState(cfg.MaintenanceMode, cfg.MaintenanceBlockReads)
+	adminHandler := handlers.NewAdminHandler(db.DB, maintenance)
+	adminHandler.SetMailQueue(mailQueue)
+	analyticsHandler := handlers.NewAnalyticsHandler(db.DB)
+	documentHandler := handlers.NewDocumentHandler(db.DB, cfg.UploadDir, cfg.FileURLSecret)
+	versionHandler := handlers.NewVersionHandler(db.DB)
+	dispatchHandler := handlers.NewDispatchHandler(db.DB, cfg.DefaultDriverCapacity)
+	dispatchHandler.SetAuditLog(auditLog)
+	trackingLinkHandler := handlers.NewTrackingLinkHandler(db.DB, cfg.TrackingLinkSecret)
+	webhookHandler := handlers.NewWebhookHandler(db.DB)
+
+	// Run migrations in the background so health checks are answered while
+	// they run; RequireReady holds back everything else until they finish.
+	// Background workers need the schema, so they start afterwards.
+	go func() {
+		if err := db.RunMigrations(); err != nil {
+			log.Fatal("❌ Failed to run migrations:", err)
+		}
+
+		trackingAssigner.Start()
+		relay.Start()
+	}()
 
 	// Setup router
 	r := mux.NewRouter()
 
 	// Apply middleware
-	r.Use(middleware.LoggingMiddleware)
-	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.RequestID)
+	r.Use(middleware.LoggingMiddleware(cfg.SlowRequestThreshold))
+	r.Use(middleware.CORSMiddleware(cfg.CORSMaxAge, cfg.CORSExposedHeaders))
+	r.Use(middleware.RequireReady(db.Ready, "/health", "/health/ready"))
+	r.Use(middleware.Maintenance(maintenance, "/health", "/health/ready", "/api/admin/maintenance"))
+	if cfg.CompressionEnabled {
+		r.Use(middleware.Compress(cfg.CompressionMinSize))
+	}
 
 	// API routes
 	api := r.PathPrefix("/api").Subrouter()
@@ -70,60 +170,145 @@ func main() {
 	// Auth routes (public)
 	api.HandleFunc("/auth/register", authHandler.Register).Methods("POST")
 	api.HandleFunc("/auth/login", authHandler.Login).Methods("POST")
+	api.HandleFunc("/auth/introspect", authHandler.Introspect).Methods("POST")
 
 	// Public routes
-	api.HandleFunc("/shipments/{tracking_number}", shipmentHandler.GetShipmentByTracking).Methods("GET")
+	api.HandleFunc("/shipments/{tracking_number:GEX[0-9A-Fa-f]{8}}", shipmentHandler.GetShipmentByTracking).Methods("GET")
+	trackBatchLimiter := middleware.NewRateLimiter(cfg.TrackBatchRateLimit, time.Minute)
+	api.Handle("/shipments/track-batch", middleware.RateLimit(trackBatchLimiter)(http.HandlerFunc(shipmentHandler.TrackBatch))).Methods("POST")
+	api.HandleFunc("/shipments/validate-tracking", shipmentHandler.ValidateTrackingNumbers).Methods("POST")
+	api.HandleFunc("/shipments/tracking-format", shipmentHandler.GetTrackingFormat).Methods("GET")
+	api.HandleFunc("/track", trackingLinkHandler.Track).Methods("GET")
+	api.HandleFunc("/version", versionHandler.GetVersion).Methods("GET")
 	api.HandleFunc("/quote", shipmentHandler.GetQuote).Methods("POST")
+	api.HandleFunc("/quote/all", shipmentHandler.GetAllQuotes).Methods("POST")
+	api.HandleFunc("/quote/multi-leg", shipmentHandler.GetMultiLegQuote).Methods("POST")
 	api.HandleFunc("/zones", zoneHandler.GetZones).Methods("GET")
 
+	// Integration routes (API key)
+	integrations := api.PathPrefix("/integrations").Subrouter()
+	integrations.Use(middleware.APIKeyAuth(cfg.IntegrationAPIKeys))
+	integrations.HandleFunc("/scan-events", shipmentHandler.IngestScanEvents).Methods("POST")
+
 	// Protected routes
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	protected.Use(middleware.RequireActiveUser(handlers.UserIsActive(db.DB)))
+	protected.Use(middleware.AuditImpersonation(auditLog))
+	protected.Use(middleware.Authorize(handlers.RoutePermissions))
+	if cfg.ClientReadOnly {
+		protected.Use(middleware.ClientReadOnly)
+	}
 
 	// User routes (protected)
 	protected.HandleFunc("/users", userHandler.GetUsers).Methods("GET")
 	protected.HandleFunc("/users", userHandler.CreateUser).Methods("POST")
+	protected.HandleFunc("/users/import", userHandler.ImportUsers).Methods("POST")
 	protected.HandleFunc("/users/profile", userHandler.GetProfile).Methods("GET")
 	protected.HandleFunc("/users/profile", userHandler.UpdateProfile).Methods("PUT")
+	protected.HandleFunc("/users/me/export", userHandler.ExportAccount).Methods("GET")
+	protected.HandleFunc("/users/me/delete", userHandler.DeleteAccount).Methods("POST")
+	protected.HandleFunc("/users/me/customers", customerHandler.GetManagedCustomers).Methods("GET")
 	protected.HandleFunc("/users/change-password", userHandler.ChangePassword).Methods("POST")
+	protected.HandleFunc("/users/{id}", userHandler.GetUser).Methods("GET")
 	protected.HandleFunc("/users/{id}", userHandler.UpdateUser).Methods("PUT")
 	protected.HandleFunc("/users/{id}", userHandler.DeleteUser).Methods("DELETE")
 	protected.HandleFunc("/users/{id}/reset-password", userHandler.ResetPassword).Methods("POST")
+	protected.HandleFunc("/users/{id}/activate", userHandler.ActivateUser).Methods("POST")
 
 	// Customer routes (protected)
 	protected.HandleFunc("/customers", customerHandler.GetCustomers).Methods("GET")
 	protected.HandleFunc("/customers", customerHandler.CreateCustomer).Methods("POST")
 	protected.HandleFunc("/customers/stats", customerHandler.GetCustomerStats).Methods("GET")
+	protected.HandleFunc("/customers/over-limit", customerHandler.GetOverLimitCustomers).Methods("GET")
+	protected.HandleFunc("/customers/export", customerHandler.ExportCustomers).Methods("GET")
+	protected.HandleFunc("/customers/me/verification", customerHandler.StartVerification).Methods("POST")
+	protected.HandleFunc("/customers/me/verification/confirm", customerHandler.ConfirmVerification).Methods("POST")
 	protected.HandleFunc("/customers/{id}", customerHandler.GetCustomer).Methods("GET")
 	protected.HandleFunc("/customers/{id}", customerHandler.UpdateCustomer).Methods("PUT")
 	protected.HandleFunc("/customers/{id}", customerHandler.DeleteCustomer).Methods("DELETE")
 	protected.HandleFunc("/customers/{id}/shipments", customerHandler.GetCustomerShipments).Methods("GET")
+	protected.HandleFunc("/customers/{id}/trend", customerHandler.GetCustomerTrend).Methods("GET")
 	protected.HandleFunc("/customers/{id}/addresses", customerHandler.AddCustomerAddress).Methods("POST")
+	protected.HandleFunc("/customers/{id}/notes", customerHandler.GetCustomerNotes).Methods("GET")
+	protected.HandleFunc("/customers/{id}/notes", customerHandler.AddCustomerNote).Methods("POST")
+	protected.HandleFunc("/customers/{id}/manager", customerHandler.AssignAccountManager).Methods("PUT")
+	protected.HandleFunc("/customers/{id}/activate", customerHandler.ActivateCustomer).Methods("POST")
+	protected.HandleFunc("/customers/{id}/verify", customerHandler.VerifyCustomer).Methods("POST")
 
 	// Driver routes (protected)
 	protected.HandleFunc("/drivers", driverHandler.GetDrivers).Methods("GET")
 	protected.HandleFunc("/drivers", driverHandler.CreateDriver).Methods("POST")
 	protected.HandleFunc("/drivers/stats", driverHandler.GetDriverStats).Methods("GET")
+	protected.HandleFunc("/drivers/me/summary", driverHandler.GetMySummary).Methods("GET")
+	protected.HandleFunc("/drivers/me/shipments", driverHandler.GetMyShipments).Methods("GET")
+	protected.HandleFunc("/drivers/me/status", driverHandler.UpdateMyStatus).Methods("PUT")
 	protected.HandleFunc("/drivers/{id}", driverHandler.GetDriver).Methods("GET")
 	protected.HandleFunc("/drivers/{id}", driverHandler.UpdateDriver).Methods("PUT")
 	protected.HandleFunc("/drivers/{id}", driverHandler.DeleteDriver).Methods("DELETE")
 	protected.HandleFunc("/drivers/{id}/shipments", driverHandler.GetDriverShipments).Methods("GET")
+	protected.HandleFunc("/drivers/{id}/earnings", driverHandler.GetDriverEarnings).Methods("GET")
+	protected.HandleFunc("/drivers/{id}/check-in", driverHandler.CheckIn).Methods("POST")
+	protected.HandleFunc("/drivers/{id}/check-out", driverHandler.CheckOut).Methods("POST")
+	protected.HandleFunc("/drivers/{id}/assign-batch", dispatchHandler.AssignBatch).Methods("POST")
+	protected.HandleFunc("/drivers/{id}/offboard", dispatchHandler.OffboardDriver).Methods("POST")
 
 	// Shipment routes (protected)
 	protected.HandleFunc("/shipments", shipmentHandler.GetShipments).Methods("GET")
 	protected.HandleFunc("/shipments", shipmentHandler.CreateShipment).Methods("POST")
-	protected.HandleFunc("/shipments/{id}", shipmentHandler.GetShipmentById).Methods("GET")
-	protected.HandleFunc("/shipments/{id}/tracking-history", shipmentHandler.GetTrackingHistory).Methods("GET")
+	protected.HandleFunc("/shipments/search", shipmentHandler.SearchShipments).Methods("GET")
+	protected.HandleFunc("/shipments/stuck", shipmentHandler.GetStuckShipments).Methods("GET")
+	protected.HandleFunc("/shipments/inactive", shipmentHandler.GetInactiveShipments).Methods("GET")
+	protected.HandleFunc("/shipments/stats", shipmentHandler.GetShipmentStats).Methods("GET")
+	protected.HandleFunc("/shipments/statuses", shipmentHandler.GetShipmentStatuses).Methods("GET")
+	protected.Handle("/shipments/{id}", middleware.ETag(http.HandlerFunc(shipmentHandler.GetShipmentById))).Methods("GET")
+	protected.HandleFunc("/shipments/{id}/full", shipmentHandler.GetFullShipment).Methods("GET")
+	protected.HandleFunc("/shipments/{id}/cost", shipmentHandler.GetShipmentCost).Methods("GET")
+	protected.Handle("/shipments/{id}/tracking-history", middleware.ETag(http.HandlerFunc(shipmentHandler.GetTrackingHistory))).Methods("GET")
 	protected.HandleFunc("/shipments/{id}/status", shipmentHandler.UpdateShipmentStatus).Methods("PUT")
+	protected.HandleFunc("/shipments/{id}/next-statuses", shipmentHandler.GetNextStatuses).Methods("GET")
+	protected.HandleFunc("/shipments/{id}/resend-notification", shipmentHandler.ResendNotification).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/hold", shipmentHandler.HoldShipment).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/release", shipmentHandler.ReleaseShipment).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/reschedule", shipmentHandler.RescheduleShipment).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/return", shipmentHandler.CreateReturn).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/reopen", shipmentHandler.ReopenShipment).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/zone", shipmentHandler.RezoneShipment).Methods("PUT")
+	protected.HandleFunc("/shipments/{id}/respond", shipmentHandler.RespondToAssignment).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/assign", dispatchHandler.AssignDriver).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/auto-assign", dispatchHandler.AutoAssign).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/tracking-link", trackingLinkHandler.CreateTrackingLink).Methods("POST")
+	protected.HandleFunc("/webhooks/verify-sample", webhookHandler.GetVerifySample).Methods("GET")
+	protected.HandleFunc("/webhooks/endpoint", webhookHandler.SetEndpoint).Methods("PUT")
+	protected.HandleFunc("/shipments/{id}/documents", documentHandler.UploadDocument).Methods("POST")
+	protected.HandleFunc("/shipments/{id}/documents/{docId}/url", documentHandler.GetDocumentURL).Methods("GET")
+	protected.HandleFunc("/pickups", shipmentHandler.GetScheduledPickups).Methods("GET")
 
-	// Admin-only routes
-	admin := protected.PathPrefix("").Subrouter()
-	admin.Use(middleware.RequireRole("admin"))
+	// Analytics routes (protected)
+	protected.HandleFunc("/analytics/shipments", analyticsHandler.GetShipmentAnalytics).Methods("GET")
+	protected.HandleFunc("/analytics/revenue", analyticsHandler.GetRevenueAnalytics).Methods("GET")
+	protected.HandleFunc("/analytics/sla", analyticsHandler.GetSLAReport).Methods("GET")
+	protected.HandleFunc("/analytics/delivery-density", analyticsHandler.GetDeliveryDensity).Methods("GET")
 
 	// Zone management (admin only)
-	admin.HandleFunc("/zones", zoneHandler.CreateZone).Methods("POST")
-	admin.HandleFunc("/zones/{id}", zoneHandler.UpdateZone).Methods("PUT")
-	admin.HandleFunc("/zones/{id}", zoneHandler.DeleteZone).Methods("DELETE")
+	protected.HandleFunc("/zones", zoneHandler.CreateZone).Methods("POST")
+	protected.HandleFunc("/zones/load", zoneHandler.GetZoneLoad).Methods("GET")
+	protected.HandleFunc("/zones/{id}", zoneHandler.UpdateZone).Methods("PUT")
+	protected.HandleFunc("/zones/{id}", zoneHandler.PatchZone).Methods("PATCH")
+	protected.HandleFunc("/zones/{id}", zoneHandler.DeleteZone).Methods("DELETE")
+
+	// Maintenance mode (admin only)
+	protected.HandleFunc("/admin/maintenance", adminHandler.GetMaintenance).Methods("GET")
+	protected.HandleFunc("/admin/maintenance", adminHandler.SetMaintenance).Methods("PUT")
+
+	// Data-integrity diagnostics (admin only)
+	protected.HandleFunc("/admin/orphan-shipments", adminHandler.GetOrphanShipments).Methods("GET")
+	protected.HandleFunc("/admin/mail", adminHandler.GetMailStatus).Methods("GET")
+	protected.HandleFunc("/activity", adminHandler.GetActivityFeed).Methods("GET")
+	protected.HandleFunc("/admin/impersonate/{userId}", authHandler.Impersonate).Methods("POST")
+
+	// Signed document downloads (public, authorized by the token itself)
+	r.HandleFunc("/files/{token}", documentHandler.ServeFile).Methods("GET")
 
 	// Swagger documentation
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
@@ -132,22 +317,58 @@ func main() {
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy","service":"goexpress-api","version":"1.0.0"}`))
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "healthy",
+			"service": "goexpress-api",
+			"version": buildinfo.Get().Version,
+		})
 	}).Methods("GET")
 
+	r.HandleFunc("/health/ready", handlers.Readiness(db.Ready)).Methods("GET")
+
 	// Root endpoint
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"message":"Welcome to GoExpress Delivery API","version":"1.0.0","docs":"/swagger/index.html"}`))
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Welcome to GoExpress Delivery API",
+			"version": buildinfo.Get().Version,
+			"docs":    "/swagger/index.html",
+		})
 	}).Methods("GET")
 
 	log.Printf("🌐 GoExpress API Server starting on port %s", cfg.Port)
 	log.Printf("📚 Swagger documentation: http://localhost:%s/swagger/index.html", cfg.Port)
 	log.Printf("🏥 Health check: http://localhost:%s/health", cfg.Port)
-	
-	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
-		log.Fatal("❌ Server failed to start:", err)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("❌ Server failed to start:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("🛑 Shutting down GoExpress API Server")
+
+	// Stop taking requests first, then the workers, so nothing is queued
+	// for a worker that has already stopped.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️  Server did not shut down cleanly: %v", err)
+	}
+	trackingAssigner.Stop()
+	relay.Stop()
+
+	// Mail goes last: the workers above may still have queued some
+	mailCtx, cancelMail := context.WithTimeout(context.Background(), mailDrainTimeout)
+	defer cancelMail()
+	if err := mailQueue.Stop(mailCtx); err != nil {
+		log.Printf("⚠️  Mail queue did not drain, undelivered emails were dropped: %v", err)
 	}
 }
 
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"goexpress-api/utils"
+)
+
+// RequireActiveUser rejects tokens whose user has since been deactivated, so
+// deleting or suspending an account takes effect immediately rather than
+// when its tokens expire. On impersonation tokens the admin behind them must
+// still be active too. It must run after AuthMiddleware.
+func RequireActiveUser(isActive func(userID int) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(UserContextKey).(*utils.Claims)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			userIDs := []int{claims.UserID}
+			if claims.ImpersonatorID != 0 {
+				userIDs = append(userIDs, claims.ImpersonatorID)
+			}
+			for _, userID := range userIDs {
+				active, err := isActive(userID)
+				if err != nil {
+					http.Error(w, "Database error", http.StatusInternalServerError)
+					return
+				}
+				if !active {
+					http.Error(w, "Account is deactivated", http.StatusUnauthorized)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
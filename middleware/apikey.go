@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// APIKeyAuth admits requests carrying one of keys in the X-API-Key header.
+// It guards machine-to-machine endpoints such as carrier integrations; with
+// no keys configured every request is rejected.
+func APIKeyAuth(keys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-API-Key")
+			if key == "" {
+				http.Error(w, "API key required", http.StatusUnauthorized)
+				return
+			}
+
+			valid := false
+			for _, k := range keys {
+				if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+					valid = true
+				}
+			}
+			if !valid {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
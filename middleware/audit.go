@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"goexpress-api/audit"
+	"goexpress-api/utils"
+)
+
+// ActorID returns who is really making the request: the impersonating admin
+// under an impersonation token, otherwise the authenticated user. It is 0 for
+// unauthenticated requests.
+func ActorID(r *http.Request) int {
+	if impersonatorID, ok := r.Context().Value(ImpersonatorContextKey).(int); ok {
+		return impersonatorID
+	}
+	if claims, ok := r.Context().Value(UserContextKey).(*utils.Claims); ok {
+		return claims.UserID
+	}
+	return 0
+}
+
+// AuditImpersonation records every request made under an impersonation token
+// against the admin who made it. It must run after AuthMiddleware.
+func AuditImpersonation(recorder audit.Recorder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			impersonatorID, ok := r.Context().Value(ImpersonatorContextKey).(int)
+			claims, _ := r.Context().Value(UserContextKey).(*utils.Claims)
+			if !ok || claims == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(wrapped, r)
+
+			err := recorder.Record(audit.Entry{
+				ActorID:    impersonatorID,
+				UserID:     claims.UserID,
+				Action:     r.Method + " " + r.URL.Path,
+				StatusCode: wrapped.statusCode,
+			})
+			if err != nil {
+				log.Printf("Failed to audit %s %s by admin %d as user %d: %v", r.Method, r.URL.Path, impersonatorID, claims.UserID, err)
+			}
+		})
+	}
+}
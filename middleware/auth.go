@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -12,20 +13,39 @@ type contextKey string
 
 const (
 	UserContextKey contextKey = "user"
+	// ImpersonatorContextKey holds the id of the admin behind an
+	// impersonation token. UserContextKey holds the impersonated user.
+	ImpersonatorContextKey contextKey = "impersonator"
 )
 
+var (
+	errMissingAuthorization = errors.New("Authorization header required")
+	errInvalidAuthorization = errors.New("Invalid authorization header format, expected: Bearer <token>")
+)
+
+// BearerToken extracts the token from the request's "Authorization: Bearer"
+// header. The header must be exactly the scheme, one space and a token with
+// no whitespace; the scheme is matched case-insensitively.
+func BearerToken(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return "", errMissingAuthorization
+	}
+
+	scheme, tokenString, ok := strings.Cut(authHeader, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || tokenString == "" || strings.ContainsAny(tokenString, " \t") {
+		return "", errInvalidAuthorization
+	}
+
+	return tokenString, nil
+}
+
 func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Authorization header required", http.StatusUnauthorized)
-				return
-			}
-
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			if tokenString == authHeader {
-				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+			tokenString, err := BearerToken(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
 				return
 			}
 
@@ -36,26 +56,10 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 			}
 
 			ctx := context.WithValue(r.Context(), UserContextKey, claims)
+			if claims.ImpersonatorID != 0 {
+				ctx = context.WithValue(ctx, ImpersonatorContextKey, claims.ImpersonatorID)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
-
-func RequireRole(role string) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			claims, ok := r.Context().Value(UserContextKey).(*utils.Claims)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			if claims.Role != role && claims.Role != "admin" {
-				http.Error(w, "Insufficient permissions", http.StatusForbidden)
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	}
-}
\ No newline at end of file
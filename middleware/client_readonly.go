@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"goexpress-api/utils"
+)
+
+// ClientReadOnly rejects writes from client-role callers with 503, e.g.
+// while billing is being reconciled. Reads, and every request from admins
+// and drivers, go through. It must run after AuthMiddleware.
+func ClientReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value(UserContextKey).(*utils.Claims)
+		isRead := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		if isRead || !ok || claims.Role != "client" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":  "Changes by customers are temporarily disabled",
+			"status": "read_only",
+		})
+	})
+}
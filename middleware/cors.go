@@ -3,12 +3,29 @@ package middleware
 import (
 	"github.com/gorilla/handlers"
 	"net/http"
+	"strings"
 )
 
-func CORSMiddleware() func(http.Handler) http.Handler {
-	return handlers.CORS(
+// CORSMiddleware allows cross-origin requests from any origin. Browsers may
+// cache preflight results for maxAge seconds (capped at 600); 0 disables
+// caching. exposedHeaders lists response headers, such as X-Request-ID, that
+// scripts may read; they are announced on preflight and actual responses.
+func CORSMiddleware(maxAge int, exposedHeaders []string) func(http.Handler) http.Handler {
+	cors := handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
 		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
+		handlers.MaxAge(maxAge),
 	)
-}
\ No newline at end of file
+
+	exposed := strings.Join(exposedHeaders, ", ")
+	return func(next http.Handler) http.Handler {
+		wrapped := cors(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exposed != "" && r.Header.Get("Origin") != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposed)
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
@@ -4,28 +4,57 @@ import (
 	"log"
 	"net/http"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		
-		// Create a custom response writer to capture status code
-		wrapped := &responseWriter{
-			ResponseWriter: w,
-			statusCode:     http.StatusOK,
-		}
+// LoggingMiddleware logs each request's route, status and duration, and
+// warns about requests slower than slowThreshold. A zero threshold disables
+// the warning.
+func LoggingMiddleware(slowThreshold time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			// Create a custom response writer to capture status code
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapped, r)
 
-		next.ServeHTTP(wrapped, r)
+			elapsed := time.Since(start)
+			route := routeTemplate(r)
+			requestID := GetRequestID(r)
+			log.Printf(
+				"%s %s %d %v route=%s request_id=%s",
+				r.Method,
+				r.URL.Path,
+				wrapped.statusCode,
+				elapsed,
+				route,
+				requestID,
+			)
 
-		log.Printf(
-			"%s %s %d %v",
-			r.Method,
-			r.URL.Path,
-			wrapped.statusCode,
-			time.Since(start),
-		)
-	})
+			if slowThreshold > 0 && elapsed > slowThreshold {
+				log.Printf("WARN slow request: %s %s took %v (threshold %v) request_id=%s",
+					r.Method, route, elapsed, slowThreshold, requestID)
+			}
+		})
+	}
+}
+
+// routeTemplate returns the matched route's path template, e.g.
+// /api/shipments/{id}, so slow requests group by endpoint rather than by id.
+// It falls back to the request path outside the router.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
 }
 
 type responseWriter struct {
@@ -36,4 +65,4 @@ type responseWriter struct {
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
-}
\ No newline at end of file
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// MaintenanceState is the in-memory maintenance switch. It is seeded from
+// config at startup and can be flipped at runtime by an admin.
+type MaintenanceState struct {
+	enabled    atomic.Bool
+	blockReads atomic.Bool
+}
+
+func NewMaintenanceState(enabled, blockReads bool) *MaintenanceState {
+	state := &MaintenanceState{}
+	state.Set(enabled, blockReads)
+	return state
+}
+
+func (s *MaintenanceState) Set(enabled, blockReads bool) {
+	s.enabled.Store(enabled)
+	s.blockReads.Store(blockReads)
+}
+
+func (s *MaintenanceState) Enabled() bool {
+	return s.enabled.Load()
+}
+
+func (s *MaintenanceState) BlockReads() bool {
+	return s.blockReads.Load()
+}
+
+// Maintenance rejects writes with 503 while maintenance mode is on, and all
+// requests when reads are blocked too. Exempt paths (e.g. /health and the
+// toggle endpoint itself) are always served.
+func Maintenance(state *MaintenanceState, exemptPaths ...string) func(http.Handler) http.Handler {
+	exempt := make(map[string]bool, len(exemptPaths))
+	for _, path := range exemptPaths {
+		exempt[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !state.Enabled() || exempt[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			isRead := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+			if isRead && !state.BlockReads() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":  "Service is temporarily unavailable for maintenance",
+				"status": "maintenance",
+			})
+		})
+	}
+}
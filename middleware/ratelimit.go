@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"goexpress-api/utils"
+)
+
+// RateLimiter allows each client a fixed number of requests per window.
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	clients   map[string]*rateWindow
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		clients: make(map[string]*rateWindow),
+		now:     time.Now,
+	}
+}
+
+// Allow records a request from key and reports whether it is within the
+// limit. When it is not, the returned duration is how long until the
+// client's window resets.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastPrune) >= l.window {
+		for k, cw := range l.clients {
+			if now.Sub(cw.start) >= l.window {
+				delete(l.clients, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	cw, ok := l.clients[key]
+	if !ok || now.Sub(cw.start) >= l.window {
+		cw = &rateWindow{start: now}
+		l.clients[key] = cw
+	}
+
+	if cw.count >= l.limit {
+		return false, cw.start.Add(l.window).Sub(now)
+	}
+	cw.count++
+	return true, 0
+}
+
+// RateLimit rejects requests with 429 once the client (by utils.ClientIP)
+// exceeds the limiter's allowance.
+func RateLimit(limiter *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter := limiter.Allow(utils.ClientIP(r))
+			if !allowed {
+				seconds := int(retryAfter.Round(time.Second) / time.Second)
+				if seconds < 1 {
+					seconds = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"goexpress-api/utils"
+)
+
+// Permissions maps "METHOD /path/template" to the roles allowed to call it.
+// Keys use the mux route template, e.g. "PUT /api/shipments/{id}/status".
+type Permissions map[string][]string
+
+// Allows reports whether role may call the route identified by method and
+// path template. Routes missing from the table are denied.
+func (p Permissions) Allows(method, template, role string) bool {
+	for _, allowed := range p[method+" "+template] {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize enforces perms for every route on the router it is used on. It
+// must run after AuthMiddleware. A route with no entry in the table is
+// refused, so a new endpoint stays closed until it is given roles.
+func Authorize(perms Permissions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(UserContextKey).(*utils.Claims)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			template := ""
+			if route := mux.CurrentRoute(r); route != nil {
+				template, _ = route.GetPathTemplate()
+			}
+			if _, listed := perms[r.Method+" "+template]; !listed {
+				log.Printf("WARN no permissions configured for %s %s", r.Method, template)
+			}
+
+			if !perms.Allows(r.Method, template, claims.Role) {
+				http.Error(w, "Insufficient permissions", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDContextKey holds the request's id.
+const RequestIDContextKey contextKey = "request_id"
+
+// maxRequestIDLength bounds ids accepted from clients and proxies.
+const maxRequestIDLength = 128
+
+// RequestID tags each request with an id, reusing a sane X-Request-ID from
+// the client or proxy and generating one otherwise. The id is echoed in the
+// X-Request-ID response header so clients can quote it when reporting issues.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), RequestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID returns the request's id, or "" outside RequestID.
+func GetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(RequestIDContextKey).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
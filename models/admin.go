@@ -0,0 +1,18 @@
+package models
+
+type MaintenanceStatus struct {
+	Enabled    bool `json:"enabled"`
+	BlockReads bool `json:"block_reads"`
+}
+
+// ActivityEvent is one entry in the admin activity feed. Type is
+// shipment_created, status_changed, user_created, zone_created or
+// zone_updated; the IDs of whatever the event is about are set.
+type ActivityEvent struct {
+	Type       string  `json:"type"`
+	OccurredAt UTCTime `json:"occurred_at"`
+	Summary    string  `json:"summary"`
+	ShipmentID *int    `json:"shipment_id,omitempty"`
+	UserID     *int    `json:"user_id,omitempty"`
+	ZoneID     *int    `json:"zone_id,omitempty"`
+}
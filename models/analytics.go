@@ -0,0 +1,59 @@
+package models
+
+type ShipmentStatusBucket struct {
+	Period UTCTime        `json:"period"`
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+}
+
+type ShipmentAnalytics struct {
+	From     UTCTime                `json:"from"`
+	To       UTCTime                `json:"to"`
+	Interval string                 `json:"interval"`
+	Buckets  []ShipmentStatusBucket `json:"buckets"`
+}
+
+type RevenueGroup struct {
+	ZoneID    *int     `json:"zone_id,omitempty"`
+	ZoneName  string   `json:"zone_name,omitempty"`
+	Period    *UTCTime `json:"period,omitempty"`
+	Shipments int      `json:"shipments"`
+	Revenue   float64  `json:"revenue"`
+}
+
+type RevenueReport struct {
+	From         UTCTime        `json:"from"`
+	To           UTCTime        `json:"to"`
+	GroupBy      string         `json:"group_by"`
+	Groups       []RevenueGroup `json:"groups"`
+	TotalRevenue float64        `json:"total_revenue"`
+}
+
+type ZoneSLA struct {
+	ZoneID     int     `json:"zone_id"`
+	ZoneName   string  `json:"zone_name"`
+	SLAHours   int     `json:"sla_hours"`
+	Delivered  int     `json:"delivered"`
+	Breached   int     `json:"breached"`
+	BreachRate float64 `json:"breach_rate"` // breached / delivered, 0 when nothing was delivered
+}
+
+type SLAReport struct {
+	From  UTCTime   `json:"from"`
+	To    UTCTime   `json:"to"`
+	Zones []ZoneSLA `json:"zones"`
+}
+
+// DestinationDensity counts deliveries to one destination. Destinations
+// differing only in case or surrounding spaces are counted together, under
+// their most common spelling.
+type DestinationDensity struct {
+	Destination string `json:"destination"`
+	Deliveries  int    `json:"deliveries"`
+}
+
+type DeliveryDensity struct {
+	From         UTCTime              `json:"from"`
+	To           UTCTime              `json:"to"`
+	Destinations []DestinationDensity `json:"destinations"`
+}
@@ -1,9 +1,5 @@
 package models
 
-import (
-	"time"
-)
-
 type Customer struct {
 	ID              int       `json:"id" db:"id"`
 	UserID          int       `json:"user_id" db:"user_id"`
@@ -18,8 +14,12 @@ type Customer struct {
 	CreditLimit     float64   `json:"credit_limit" db:"credit_limit"`
 	PaymentTerms    string    `json:"payment_terms" db:"payment_terms"`
 	Notes           string    `json:"notes" db:"notes"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+	NotificationChannel string `json:"notification_channel" db:"notification_channel"` // email, sms
+	EmailVerifiedAt *UTCTime  `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	PhoneVerifiedAt *UTCTime  `json:"phone_verified_at,omitempty" db:"phone_verified_at"`
+	AccountManagerID *int     `json:"account_manager_id" db:"account_manager_id"` // admin user managing the account
+	CreatedAt       UTCTime   `json:"created_at" db:"created_at"`
+	UpdatedAt       UTCTime   `json:"updated_at" db:"updated_at"`
 	
 	// Joined fields from users table
 	Name            string    `json:"name" db:"name"`
@@ -28,7 +28,7 @@ type Customer struct {
 	// Calculated fields
 	TotalShipments  int       `json:"total_shipments"`
 	TotalSpent      float64   `json:"total_spent"`
-	LastShipment    *time.Time `json:"last_shipment"`
+	LastShipment    *UTCTime   `json:"last_shipment"`
 }
 
 type CustomerAddress struct {
@@ -43,8 +43,8 @@ type CustomerAddress struct {
 	PostalCode  string    `json:"postal_code" db:"postal_code"`
 	Country     string    `json:"country" db:"country"`
 	IsDefault   bool      `json:"is_default" db:"is_default"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	CreatedAt   UTCTime   `json:"created_at" db:"created_at"`
+	UpdatedAt   UTCTime   `json:"updated_at" db:"updated_at"`
 }
 
 type CustomerStats struct {
@@ -82,6 +82,7 @@ type UpdateCustomerRequest struct {
 	Status          string  `json:"status" validate:"required,oneof=active inactive suspended"`
 	CreditLimit     float64 `json:"credit_limit"`
 	PaymentTerms    string  `json:"payment_terms"`
+	NotificationChannel string `json:"notification_channel" validate:"omitempty,oneof=email sms"`
 	Notes           string  `json:"notes"`
 }
 
@@ -108,6 +109,69 @@ type CustomerShipmentHistory struct {
 	Stats     struct {
 		TotalShipments int     `json:"total_shipments"`
 		TotalSpent     float64 `json:"total_spent"`
-		LastShipment   *time.Time `json:"last_shipment"`
+		LastShipment   *UTCTime   `json:"last_shipment"`
 	} `json:"stats"`
 }
+
+// AssignAccountManagerRequest sets a customer's account manager; a null
+// manager_id removes the current one.
+type AssignAccountManagerRequest struct {
+	ManagerID *int `json:"manager_id"`
+}
+
+type StartVerificationRequest struct {
+	Channel string `json:"channel" validate:"required,oneof=email sms"`
+}
+
+type ConfirmVerificationRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// VerificationStatus reports which of a customer's contact details are
+// verified. Either one is enough to ship.
+type VerificationStatus struct {
+	EmailVerified bool `json:"email_verified"`
+	PhoneVerified bool `json:"phone_verified"`
+	Verified      bool `json:"verified"`
+}
+
+// CustomerTrendMonth is one calendar month of a customer's shipping.
+// Cancelled shipments are left out of both figures.
+type CustomerTrendMonth struct {
+	Month     UTCTime `json:"month"`
+	Shipments int     `json:"shipments"`
+	Spend     float64 `json:"spend"`
+}
+
+type CustomerTrend struct {
+	CustomerID int                  `json:"customer_id"`
+	Months     []CustomerTrendMonth `json:"months"`
+}
+
+// CustomerOverLimit is a customer whose outstanding balance exceeds their
+// credit limit, by Overage.
+type CustomerOverLimit struct {
+	CustomerID  int     `json:"customer_id"`
+	UserID      int     `json:"user_id"`
+	CompanyName string  `json:"company_name"`
+	Name        string  `json:"name"`
+	Email       string  `json:"email"`
+	CreditLimit float64 `json:"credit_limit"`
+	Balance     float64 `json:"balance"`
+	Overage     float64 `json:"overage"`
+}
+
+// CustomerNote is one entry in the staff note thread on a customer.
+// AuthorID is nil once the author's user is gone.
+type CustomerNote struct {
+	ID         int     `json:"id"`
+	CustomerID int     `json:"customer_id"`
+	AuthorID   *int    `json:"author_id"`
+	AuthorName string  `json:"author_name"`
+	Body       string  `json:"body"`
+	CreatedAt  UTCTime `json:"created_at"`
+}
+
+type CreateCustomerNoteRequest struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
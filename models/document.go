@@ -0,0 +1,17 @@
+package models
+
+type ShipmentDocument struct {
+	ID          int     `json:"id" db:"id"`
+	ShipmentID  int     `json:"shipment_id" db:"shipment_id"`
+	FileName    string  `json:"file_name" db:"file_name"`
+	ContentType string  `json:"content_type" db:"content_type"`
+	Size        int64   `json:"size" db:"size"`
+	StoragePath string  `json:"-" db:"storage_path"`
+	UploadedBy  int     `json:"uploaded_by" db:"uploaded_by"`
+	CreatedAt   UTCTime `json:"created_at" db:"created_at"`
+}
+
+type DocumentURLResponse struct {
+	URL       string  `json:"url"`
+	ExpiresAt UTCTime `json:"expires_at"`
+}
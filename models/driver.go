@@ -1,8 +1,17 @@
 package models
 
-import (
-	"time"
-)
+// VehicleTypes lists the vehicle types a driver profile may declare.
+var VehicleTypes = []string{"bicycle", "motorcycle", "car", "van", "truck"}
+
+// IsVehicleType reports whether v is one of VehicleTypes.
+func IsVehicleType(v string) bool {
+	for _, t := range VehicleTypes {
+		if t == v {
+			return true
+		}
+	}
+	return false
+}
 
 type Driver struct {
 	ID                   int       `json:"id" db:"id"`
@@ -19,8 +28,11 @@ type Driver struct {
 	Rating               float64   `json:"rating" db:"rating"`
 	TotalDeliveries      int       `json:"total_deliveries" db:"total_deliveries"`
 	SuccessfulDeliveries int       `json:"successful_deliveries,omitempty" db:"successful_deliveries"`
-	CreatedAt            time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
+	MaxConcurrentShipments *int    `json:"max_concurrent_shipments" db:"max_concurrent_shipments"` // nil uses the default
+	MaxWeight            *float64  `json:"max_weight,omitempty" db:"max_weight"` // kg per shipment; nil means no limit
+	CommissionRate       *float64  `json:"commission_rate,omitempty" db:"commission_rate"` // nil uses the default
+	CreatedAt            UTCTime   `json:"created_at" db:"created_at"`
+	UpdatedAt            UTCTime   `json:"updated_at" db:"updated_at"`
 }
 
 type DriverStats struct {
@@ -32,6 +44,33 @@ type DriverStats struct {
 	AverageRating    float64 `json:"average_rating"`
 }
 
+// DriverEarnings is a driver's commission on the shipments they delivered
+// in [From, To).
+type DriverEarnings struct {
+	DriverID       int       `json:"driver_id"`
+	From           UTCTime   `json:"from"`
+	To             UTCTime   `json:"to"`
+	CommissionRate float64   `json:"commission_rate"`
+	Deliveries     int       `json:"deliveries"`
+	DeliveredCost  float64   `json:"delivered_cost"`
+	Earnings       float64   `json:"earnings"`
+}
+
+// DriverSummary is the dashboard overview of a driver's own shipments.
+type DriverSummary struct {
+	Open           int     `json:"open"`
+	InTransit      int     `json:"in_transit"`
+	DeliveredToday int     `json:"delivered_today"`
+	Rating         float64 `json:"rating"`
+}
+
+type DriverShift struct {
+	ID        int      `json:"id" db:"id"`
+	DriverID  int      `json:"driver_id" db:"driver_id"`
+	StartedAt UTCTime  `json:"started_at" db:"started_at"`
+	EndedAt   *UTCTime `json:"ended_at,omitempty" db:"ended_at"`
+}
+
 // Request/Response models
 type CreateDriverRequest struct {
 	Name            string `json:"name" validate:"required"`
@@ -39,9 +78,12 @@ type CreateDriverRequest struct {
 	Password        string `json:"password" validate:"required,min=6"`
 	Phone           string `json:"phone"`
 	LicenseNumber   string `json:"license_number"`
-	VehicleType     string `json:"vehicle_type"`
+	VehicleType     string `json:"vehicle_type" validate:"omitempty,oneof=bicycle motorcycle car van truck"`
 	VehicleNumber   string `json:"vehicle_number"`
 	CurrentLocation string `json:"current_location"`
+	MaxConcurrentShipments *int `json:"max_concurrent_shipments" validate:"omitempty,gt=0"`
+	MaxWeight       *float64 `json:"max_weight" validate:"omitempty,gt=0"`
+	CommissionRate  *float64 `json:"commission_rate" validate:"omitempty,gte=0,lte=1"`
 }
 
 type UpdateDriverRequest struct {
@@ -49,10 +91,39 @@ type UpdateDriverRequest struct {
 	Email           string `json:"email" validate:"required,email"`
 	Phone           string `json:"phone"`
 	LicenseNumber   string `json:"license_number"`
-	VehicleType     string `json:"vehicle_type"`
+	VehicleType     string `json:"vehicle_type" validate:"omitempty,oneof=bicycle motorcycle car van truck"`
 	VehicleNumber   string `json:"vehicle_number"`
 	Status          string `json:"status" validate:"required,oneof=available busy offline"`
 	CurrentLocation string `json:"current_location"`
+	MaxConcurrentShipments *int `json:"max_concurrent_shipments" validate:"omitempty,gt=0"`
+	MaxWeight       *float64 `json:"max_weight" validate:"omitempty,gt=0"`
+	CommissionRate  *float64 `json:"commission_rate" validate:"omitempty,gte=0,lte=1"`
 }
 
+// DriverStatusRequest sets a driver's own availability. Only available
+// drivers are picked by auto-assign. Available and busy need an open shift;
+// offline ends it.
+type DriverStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=available busy offline"`
+}
+
+type AssignDriverRequest struct {
+	DriverID int `json:"driver_id" validate:"required"`
+}
+
+// OffboardDriverRequest names the driver taking over an offboarded driver's
+// open shipments. Without one they go back to unassigned.
+type OffboardDriverRequest struct {
+	ReassignTo *int `json:"reassign_to"`
+}
+
+type OffboardDriverResponse struct {
+	DriverID     int        `json:"driver_id"`
+	ReassignedTo *int       `json:"reassigned_to"`
+	Shipments    []Shipment `json:"shipments"`
+}
 
+type AssignBatchRequest struct {
+	ZoneID int `json:"zone_id" validate:"required"`
+	Limit  int `json:"limit" validate:"omitempty,gt=0"` // defaults to the driver's remaining capacity
+}
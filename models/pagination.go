@@ -0,0 +1,21 @@
+package models
+
+// PaginatedResponse is the envelope for paginated list endpoints. Data holds
+// the page's items.
+type PaginatedResponse struct {
+	Data       interface{} `json:"data"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	Total      int         `json:"total"`
+	TotalPages int         `json:"total_pages"`
+}
+
+func NewPaginatedResponse(data interface{}, page, limit, total int) PaginatedResponse {
+	return PaginatedResponse{
+		Data:       data,
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: (total + limit - 1) / limit,
+	}
+}
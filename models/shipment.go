@@ -4,18 +4,68 @@ import (
 	"time"
 )
 
+// ShipmentStatuses lists every status a shipment may be in. Shipments
+// created in async mode start in pending_tracking until they have a tracking
+// number.
+var ShipmentStatuses = []string{"pending_tracking", "pending", "picked_up", "in_transit", "out_for_delivery", "delivered", "cancelled"}
+
+// IsShipmentStatus reports whether s is one of ShipmentStatuses.
+func IsShipmentStatus(s string) bool {
+	for _, status := range ShipmentStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
 type Shipment struct {
 	ID             int       `json:"id" db:"id"`
 	TrackingNumber string    `json:"tracking_number" db:"tracking_number"`
+	Reference      string    `json:"reference" db:"reference"` // sequential per year, e.g. GEX-2025-000123
 	Origin         string    `json:"origin" db:"origin" validate:"required"`
 	Destination    string    `json:"destination" db:"destination" validate:"required"`
 	Weight         float64   `json:"weight" db:"weight" validate:"required,gt=0"`
 	ZoneID         int       `json:"zone_id" db:"zone_id" validate:"required"`
+	Priority       string    `json:"priority" db:"priority"` // standard, express, overnight
 	Status         string    `json:"status" db:"status"`
 	CustomerID     int       `json:"customer_id" db:"customer_id"`
 	DriverID       *int      `json:"driver_id" db:"driver_id"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	AcceptedAt     *UTCTime  `json:"accepted_at,omitempty" db:"accepted_at"` // when the driver accepted the assignment
+	PickupScheduledAt *UTCTime   `json:"pickup_scheduled_at,omitempty" db:"pickup_scheduled_at"`
+	PickupWindow   *int      `json:"pickup_window,omitempty" db:"pickup_window"` // minutes
+	EstimatedDelivery *UTCTime `json:"estimated_delivery,omitempty" db:"estimated_delivery"`
+	DeliveryWindow *int      `json:"delivery_window,omitempty" db:"delivery_window"` // minutes
+	Cost           float64   `json:"cost" db:"cost"`
+	Discount       float64   `json:"discount" db:"discount"` // promo code discount already taken off cost
+	ReturnOf       *int      `json:"return_of,omitempty" db:"return_of"`
+	DeliveredAt    *UTCTime  `json:"delivered_at,omitempty" db:"delivered_at"`
+	SLABreached    *bool     `json:"sla_breached,omitempty" db:"sla_breached"` // set once delivered
+	OnHold         bool      `json:"on_hold" db:"on_hold"`
+	HoldReason     *string   `json:"hold_reason,omitempty" db:"hold_reason"`
+	CreatedAt      UTCTime   `json:"created_at" db:"created_at"`
+	UpdatedAt      UTCTime   `json:"updated_at" db:"updated_at"`
+}
+
+// Shipment priority levels. Higher priorities cost more and are handled first.
+const (
+	PriorityStandard  = "standard"
+	PriorityExpress   = "express"
+	PriorityOvernight = "overnight"
+)
+
+// Priorities lists the shipment priority levels, lowest first.
+var Priorities = []string{PriorityStandard, PriorityExpress, PriorityOvernight}
+
+// PriorityRank orders priorities: the higher the rank, the sooner a shipment
+// is handled. Unknown priorities rank as standard.
+func PriorityRank(priority string) int {
+	for i, p := range Priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return 0
 }
 
 type ShipmentRequest struct {
@@ -23,6 +73,99 @@ type ShipmentRequest struct {
 	Destination string  `json:"destination" validate:"required"`
 	Weight      float64 `json:"weight" validate:"required,gt=0"`
 	ZoneID      int     `json:"zone_id" validate:"required"`
+	Priority    string  `json:"priority" validate:"omitempty,oneof=standard express overnight"` // default standard
+	PickupScheduledAt *time.Time `json:"pickup_scheduled_at"`
+	PickupWindow      *int       `json:"pickup_window" validate:"omitempty,gt=0"` // minutes
+	PromoCode         string     `json:"promo_code"`
+}
+
+// NextStatusesResponse lists the statuses the caller may move a shipment to.
+type NextStatusesResponse struct {
+	Status       string   `json:"status"`
+	OnHold       bool     `json:"on_hold"`
+	NextStatuses []string `json:"next_statuses"`
+}
+
+// HoldRequest pauses a shipment; Location is recorded on the tracking update.
+type HoldRequest struct {
+	Reason   string `json:"reason" validate:"required"`
+	Location string `json:"location"`
+}
+
+// ReleaseRequest resumes a held shipment.
+type ReleaseRequest struct {
+	Location string `json:"location"`
+}
+
+// RezoneRequest moves a shipment to another zone, repricing it.
+type RezoneRequest struct {
+	ZoneID int `json:"zone_id" validate:"required"`
+}
+
+// AssignmentResponse is the assigned driver's answer to an assignment.
+// Reason is kept with the response for staff; customers never see it.
+type AssignmentResponse struct {
+	Accept *bool  `json:"accept" validate:"required"`
+	Reason string `json:"reason"`
+}
+
+// RescheduleRequest moves a shipment's expected delivery to a new date, with
+// an optional window length. Reason is kept on the tracking update.
+type RescheduleRequest struct {
+	EstimatedDelivery time.Time `json:"estimated_delivery" validate:"required"`
+	DeliveryWindow    *int      `json:"delivery_window" validate:"omitempty,gt=0"` // minutes
+	Reason            string    `json:"reason" validate:"max=500"`
+}
+
+type ReturnRequest struct {
+	Force bool `json:"force"` // admin only: allow returning a shipment that is not delivered
+}
+
+// ShipmentStats counts shipments. When Approximate is set, Total is the
+// planner's row estimate rather than an exact count; ByStatus is always exact.
+type ShipmentStats struct {
+	Total       int            `json:"total"`
+	Approximate bool           `json:"approximate"`
+	ByStatus    map[string]int `json:"by_status"`
+}
+
+// Clone returns a copy that does not share the ByStatus map.
+func (s ShipmentStats) Clone() ShipmentStats {
+	byStatus := make(map[string]int, len(s.ByStatus))
+	for status, count := range s.ByStatus {
+		byStatus[status] = count
+	}
+	return ShipmentStats{Total: s.Total, Approximate: s.Approximate, ByStatus: byStatus}
+}
+
+type ShipmentDriver struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// StuckShipment is an assigned shipment with the time it entered its current
+// status: its latest tracking update in that status, or its creation when it
+// has none.
+type StuckShipment struct {
+	Shipment
+	Driver        *ShipmentDriver `json:"driver"`
+	InStatusSince UTCTime         `json:"in_status_since"`
+}
+
+// InactiveShipment is an open shipment with the time of its latest tracking
+// update, or of its creation when it has none.
+type InactiveShipment struct {
+	Shipment
+	LastEventAt UTCTime `json:"last_event_at"`
+}
+
+// ShipmentSearchResult is a shipment found by search, with the field that
+// matched: tracking_number, origin or destination (first match in that
+// order).
+type ShipmentSearchResult struct {
+	Shipment
+	MatchedField string `json:"matched_field"`
 }
 
 type ShipmentResponse struct {
@@ -31,15 +174,100 @@ type ShipmentResponse struct {
 	Zone           Zone             `json:"zone"`
 }
 
+// ShipmentCustomerDetails is the customer block of a full shipment. Contact
+// details are only filled in for admins.
+type ShipmentCustomerDetails struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email,omitempty"`
+	CompanyName   string `json:"company_name,omitempty"`
+	ContactPerson string `json:"contact_person,omitempty"`
+	Phone         string `json:"phone,omitempty"`
+}
+
+// ShipmentDriverDetails is the driver block of a full shipment. Contact
+// details and the vehicle number are only filled in for admins.
+type ShipmentDriverDetails struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email,omitempty"`
+	Phone         string `json:"phone,omitempty"`
+	VehicleType   string `json:"vehicle_type,omitempty"`
+	VehicleNumber string `json:"vehicle_number,omitempty"`
+}
+
+type FullShipmentResponse struct {
+	ShipmentResponse
+	Customer ShipmentCustomerDetails `json:"customer"`
+	Driver   *ShipmentDriverDetails  `json:"driver"`
+}
+
+type ZonePickups struct {
+	ZoneID   int        `json:"zone_id"`
+	ZoneName string     `json:"zone_name"`
+	Pickups  []Shipment `json:"pickups"`
+}
+
 type QuoteRequest struct {
+	Weight    float64 `json:"weight" validate:"required,gt=0"`
+	ZoneID    int     `json:"zone_id" validate:"required"`
+	Priority  string  `json:"priority" validate:"omitempty,oneof=standard express overnight"` // default standard
+	PromoCode string  `json:"promo_code"`
+}
+
+// QuoteLeg is one zone of a multi-leg quote.
+type QuoteLeg struct {
 	Weight float64 `json:"weight" validate:"required,gt=0"`
 	ZoneID int     `json:"zone_id" validate:"required"`
 }
 
+type QuoteAllRequest struct {
+	Weight float64 `json:"weight" validate:"required,gt=0"`
+}
+
+// MaxQuoteLegs caps the number of legs in a multi-leg quote.
+const MaxQuoteLegs = 10
+
+// MultiLegQuoteRequest prices a shipment routed through several zones, in order.
+type MultiLegQuoteRequest struct {
+	Legs []QuoteLeg `json:"legs" validate:"required,min=1,dive"`
+}
+
+type MultiLegQuoteResponse struct {
+	Legs       []QuoteResponse `json:"legs"`
+	TotalPrice float64         `json:"total_price"`
+}
+
+// CostComponent is one line of a shipment's price. Discounts are negative.
+type CostComponent struct {
+	Code   string  `json:"code"` // base, priority, discount
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+}
+
+// CostBreakdown itemizes a shipment's stored cost; its components sum to
+// Total.
+type CostBreakdown struct {
+	ShipmentID int             `json:"shipment_id"`
+	Components []CostComponent `json:"components"`
+	Total      float64         `json:"total"`
+}
+
 type QuoteResponse struct {
 	Weight    float64 `json:"weight"`
 	ZoneID    int     `json:"zone_id"`
 	ZoneName  string  `json:"zone_name"`
 	PricePerKg float64 `json:"price_per_kg"`
 	TotalPrice float64 `json:"total_price"`
-}
\ No newline at end of file
+	Priority   string  `json:"priority,omitempty"`
+	PriorityMultiplier float64 `json:"priority_multiplier,omitempty"`
+	PrioritySurcharge  float64 `json:"priority_surcharge,omitempty"` // already included in total_price
+	PromoCode  string  `json:"promo_code,omitempty"`
+	Discount   float64 `json:"discount,omitempty"` // already taken off total_price
+	Adjusted   bool    `json:"adjusted,omitempty"` // total_price was set by the external pricing engine
+}
+
+// QuoteAdjustment is the external pricing engine's answer to a quote.
+type QuoteAdjustment struct {
+	TotalPrice *float64 `json:"total_price"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// UTCTime is a timestamp that always serializes as UTC RFC3339 with a
+// trailing "Z", whatever offset it was read from the database with.
+type UTCTime struct {
+	time.Time
+}
+
+func NewUTCTime(t time.Time) UTCTime {
+	return UTCTime{t.UTC()}
+}
+
+func (t UTCTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format(time.RFC3339))
+}
+
+// Scan implements sql.Scanner so UTCTime can be used directly as a scan destination.
+func (t *UTCTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		t.Time = v.UTC()
+	case nil:
+		t.Time = time.Time{}
+	default:
+		return fmt.Errorf("cannot scan %T into UTCTime", value)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (t UTCTime) Value() (driver.Value, error) {
+	return t.Time, nil
+}
@@ -1,20 +1,140 @@
 package models
 
-import (
-	"time"
-)
+import "time"
 
 type TrackingUpdate struct {
 	ID         int       `json:"id" db:"id"`
 	ShipmentID int       `json:"shipment_id" db:"shipment_id"`
 	Status     string    `json:"status" db:"status" validate:"required"`
 	Location   string    `json:"location" db:"location"`
-	Timestamp  time.Time `json:"timestamp" db:"timestamp"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	Note       string    `json:"note,omitempty" db:"note"`
+	Timestamp  UTCTime   `json:"timestamp" db:"timestamp"`
+	CreatedAt  UTCTime   `json:"created_at" db:"created_at"`
 }
 
 type TrackingUpdateRequest struct {
 	ShipmentID int    `json:"shipment_id" validate:"required"`
 	Status     string `json:"status" validate:"required"`
 	Location   string `json:"location"`
-}
\ No newline at end of file
+}
+
+// MaxTrackBatchSize caps the tracking numbers accepted by one batch lookup.
+const MaxTrackBatchSize = 50
+
+type TrackBatchRequest struct {
+	TrackingNumbers []string `json:"tracking_numbers" validate:"required,min=1"`
+}
+
+// TrackBatchResult is the current state of one requested tracking number.
+// Found is false, and the other fields empty, for unknown numbers.
+type TrackBatchResult struct {
+	TrackingNumber string   `json:"tracking_number"`
+	Found          bool     `json:"found"`
+	Status         string   `json:"status,omitempty"`
+	LastLocation   string   `json:"last_location,omitempty"`
+	UpdatedAt      *UTCTime `json:"updated_at,omitempty"`
+}
+
+// MaxValidateTrackingBatch caps the tracking numbers accepted by one format
+// check.
+const MaxValidateTrackingBatch = 1000
+
+// TrackingFormat describes how tracking numbers are built, with a freshly
+// generated Example that was not assigned to any shipment.
+type TrackingFormat struct {
+	Prefix  string `json:"prefix"`
+	Length  int    `json:"length"`
+	Example string `json:"example"`
+}
+
+type TrackingValidation struct {
+	TrackingNumber string `json:"tracking_number"`
+	Valid          bool   `json:"valid"`
+}
+
+type TrackingLinkRequest struct {
+	ExpiresInHours int `json:"expires_in_hours" validate:"omitempty,gt=0"` // 0 for a link that never expires
+}
+
+type TrackingLinkResponse struct {
+	URL       string   `json:"url"`
+	Token     string   `json:"token"`
+	ExpiresAt *UTCTime `json:"expires_at,omitempty"`
+}
+
+// TrackedShipment is what a tracking link shows. It leaves out the tracking
+// number, which would keep working on the public tracking route after the
+// link expires, and the customer and driver.
+type TrackedShipment struct {
+	Status            string           `json:"status"`
+	Origin            string           `json:"origin"`
+	Destination       string           `json:"destination"`
+	Priority          string           `json:"priority"`
+	OnHold            bool             `json:"on_hold"`
+	EstimatedDelivery *UTCTime         `json:"estimated_delivery,omitempty"`
+	DeliveredAt       *UTCTime         `json:"delivered_at,omitempty"`
+	TrackingUpdates   []TrackingUpdate `json:"tracking_updates"`
+}
+
+// MaxScanEventsBatch caps the scan events accepted by one carrier feed upload.
+const MaxScanEventsBatch = 500
+
+// ScanEvent is a carrier's report that a shipment reached a status. A
+// missing timestamp means now.
+type ScanEvent struct {
+	TrackingNumber string     `json:"tracking_number"`
+	Status         string     `json:"status"`
+	Location       string     `json:"location"`
+	Timestamp      *time.Time `json:"timestamp"`
+}
+
+// ScanEventResult reports whether one scan event was applied, and why not
+// when it was rejected. Retryable marks events that failed on our side and
+// should be sent again; rejected events should not.
+type ScanEventResult struct {
+	TrackingNumber string `json:"tracking_number"`
+	Status         string `json:"status"`
+	Applied        bool   `json:"applied"`
+	Retryable      bool   `json:"retryable,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+type ScanEventsResponse struct {
+	Applied  int               `json:"applied"`
+	Rejected int               `json:"rejected"`
+	Failed   int               `json:"failed"`
+	Results  []ScanEventResult `json:"results"`
+}
+
+// ShipmentStatusEvent is the webhook payload sent when a shipment changes
+// status.
+type ShipmentStatusEvent struct {
+	ShipmentID     int     `json:"shipment_id"`
+	TrackingNumber string  `json:"tracking_number"`
+	Reference      string  `json:"reference"`
+	Status         string  `json:"status"`
+	Location       string  `json:"location,omitempty"`
+	OccurredAt     UTCTime `json:"occurred_at"`
+}
+
+// WebhookSample is a sample webhook delivery for integrators testing their
+// signature verification. Body is sent verbatim and Signature arrives in the
+// Header.
+type WebhookSample struct {
+	Header    string `json:"header"`
+	Algorithm string `json:"algorithm"`
+	Body      string `json:"body"`
+	Signature string `json:"signature"`
+}
+
+// WebhookEndpointRequest sets where a customer's webhooks are sent.
+type WebhookEndpointRequest struct {
+	URL string `json:"url" validate:"required,url"`
+}
+
+// WebhookEndpoint is a customer's webhook URL and the secret its deliveries
+// are signed with. The secret is only returned when it is issued.
+type WebhookEndpoint struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
@@ -1,17 +1,14 @@
 package models
 
-import (
-	"time"
-)
-
 type User struct {
 	ID           int       `json:"id" db:"id"`
 	Name         string    `json:"name" db:"name" validate:"required"`
 	Email        string    `json:"email" db:"email" validate:"required,email"`
 	PasswordHash string    `json:"-" db:"password_hash"`
 	Role         string    `json:"role" db:"role" validate:"required,oneof=admin driver client"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	IsActive     bool      `json:"is_active" db:"is_active"` // false once the user is deleted
+	CreatedAt    UTCTime   `json:"created_at" db:"created_at"`
+	UpdatedAt    UTCTime   `json:"updated_at" db:"updated_at"`
 }
 
 type UserRegistration struct {
@@ -32,12 +29,40 @@ type AuthResponse struct {
 	User         User   `json:"user"`
 }
 
+// ImpersonationResponse is a short-lived access token for acting as User.
+// There is no refresh token.
+type ImpersonationResponse struct {
+	Token     string  `json:"token"`
+	ExpiresAt UTCTime `json:"expires_at"`
+	User      User    `json:"user"`
+}
+
+type IntrospectRequest struct {
+	Token string `json:"token"`
+}
+
+// IntrospectResponse mirrors OAuth 2.0 token introspection (RFC 7662).
+// Only Active is set for inactive tokens.
+type IntrospectResponse struct {
+	Active    bool     `json:"active"`
+	UserID    int      `json:"user_id,omitempty"`
+	Role      string   `json:"role,omitempty"`
+	ExpiresAt *UTCTime `json:"expires_at,omitempty"`
+	// ImpersonatorID is set when an admin is impersonating the user.
+	ImpersonatorID int `json:"impersonator_id,omitempty"`
+}
+
 // New user management models
 type UpdateProfileRequest struct {
 	Name  string `json:"name" validate:"required"`
 	Email string `json:"email" validate:"required,email"`
 }
 
+// DeleteAccountRequest confirms an account deletion with the current password.
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
 type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password" validate:"required"`
 	NewPassword     string `json:"new_password" validate:"required,min=6"`
@@ -61,6 +86,21 @@ type ResetPasswordRequest struct {
 	NewPassword string `json:"new_password" validate:"required,min=6"`
 }
 
+// Bulk import models
+type UserImportResult struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email"`
+	Status string `json:"status"` // created, error, skipped
+	UserID int    `json:"user_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type UserImportResponse struct {
+	Created int                `json:"created"`
+	Failed  int                `json:"failed"`
+	Results []UserImportResult `json:"results"`
+}
+
 // User statistics for dashboard
 type UserStats struct {
 	TotalUsers    int `json:"total_users"`
@@ -70,3 +110,13 @@ type UserStats struct {
 	ActiveUsers   int `json:"active_users"`
 	InactiveUsers int `json:"inactive_users"`
 }
+
+// AccountExport is everything stored about a user, as returned by the
+// self-service data export.
+type AccountExport struct {
+	ExportedAt UTCTime           `json:"exported_at"`
+	User       User              `json:"user"`
+	Customer   *Customer         `json:"customer"`
+	Addresses  []CustomerAddress `json:"addresses"`
+	Shipments  []Shipment        `json:"shipments"`
+}
@@ -0,0 +1,8 @@
+package models
+
+import "goexpress-api/buildinfo"
+
+type VersionResponse struct {
+	buildinfo.Info
+	SchemaVersion *int64 `json:"schema_version,omitempty"`
+}
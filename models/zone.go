@@ -1,13 +1,26 @@
 package models
 
-import (
-	"time"
-)
-
 type Zone struct {
 	ID         int       `json:"id" db:"id"`
 	Name       string    `json:"name" db:"name" validate:"required"`
 	PricePerKg float64   `json:"price_per_kg" db:"price_per_kg" validate:"required,gt=0"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
-}
\ No newline at end of file
+	SLAHours   int       `json:"sla_hours" db:"sla_hours" validate:"omitempty,gt=0"` // promised delivery time
+	DailyCapacity *int   `json:"daily_capacity,omitempty" db:"daily_capacity" validate:"omitempty,gt=0"` // shipments created per UTC day; nil means no limit
+	CreatedAt  UTCTime   `json:"created_at" db:"created_at"`
+	UpdatedAt  UTCTime   `json:"updated_at" db:"updated_at"`
+}
+
+// PatchZoneRequest updates only the fields that are present.
+type PatchZoneRequest struct {
+	Name       *string  `json:"name"`
+	PricePerKg *float64 `json:"price_per_kg"`
+	SLAHours   *int     `json:"sla_hours"`
+	DailyCapacity *int  `json:"daily_capacity"`
+}
+
+// ZoneLoad is a zone with its active shipments.
+type ZoneLoad struct {
+	Zone
+	ActiveShipments int     `json:"active_shipments"`
+	AverageWeight   float64 `json:"average_weight"`
+}
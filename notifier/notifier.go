@@ -0,0 +1,71 @@
+// Package notifier delivers customer notifications over email or SMS.
+package notifier
+
+import (
+	"log"
+
+	"goexpress-api/mailer"
+)
+
+// Notification channels a customer may prefer.
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// SMS is a text message to a single phone number.
+type SMS struct {
+	To   string
+	Body string
+}
+
+// SMSSender delivers text messages. Implementations must be safe for
+// concurrent use.
+type SMSSender interface {
+	Send(msg SMS) error
+}
+
+// Notifier sends notifications over each supported channel.
+type Notifier interface {
+	SendEmail(msg mailer.Message) error
+	SendSMS(msg SMS) error
+}
+
+// LogSMSSender writes text messages to the log instead of sending them. It is
+// used when no SMS gateway is configured.
+type LogSMSSender struct{}
+
+func NewLogSMSSender() *LogSMSSender {
+	return &LogSMSSender{}
+}
+
+func (s *LogSMSSender) Send(msg SMS) error {
+	log.Printf("📱 SMS to %s: %s", msg.To, msg.Body)
+	return nil
+}
+
+// MultiChannel sends email through a Mailer and SMS through an SMSSender.
+type MultiChannel struct {
+	mailer mailer.Mailer
+	sms    SMSSender
+}
+
+func New(m mailer.Mailer, sms SMSSender) *MultiChannel {
+	return &MultiChannel{mailer: m, sms: sms}
+}
+
+func (n *MultiChannel) SendEmail(msg mailer.Message) error {
+	return n.mailer.Send(msg)
+}
+
+func (n *MultiChannel) SendSMS(msg SMS) error {
+	return n.sms.Send(msg)
+}
+
+// Nop discards every notification. It is the default when notifications
+// are disabled.
+type Nop struct{}
+
+func (Nop) SendEmail(msg mailer.Message) error { return nil }
+
+func (Nop) SendSMS(msg SMS) error { return nil }
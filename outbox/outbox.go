@@ -0,0 +1,386 @@
+// Package outbox reliably delivers shipment events to webhooks. Events are
+// written to the event_outbox table in the same transaction as the change
+// they describe, and a background Relay dispatches them afterwards.
+package outbox
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"goexpress-api/utils"
+	"github.com/lib/pq"
+)
+
+// EventShipmentStatusChanged is written whenever a shipment moves to a new
+// status.
+const EventShipmentStatusChanged = "shipment.status_changed"
+
+// EventWebhookSample is only ever used for sample payloads, so a sample can
+// never be mistaken for a real event.
+const EventWebhookSample = "webhook.sample"
+
+const (
+	relayBatchSize = 100
+	// relayMaxAttempts stops retrying events that keep failing, leaving
+	// them in the table for ops to inspect.
+	relayMaxAttempts = 10
+	// relayClaimTTL is how long a claimed event is reserved for the relay
+	// that claimed it. It must outlast dispatching a whole batch; claims are
+	// released as soon as each event is marked.
+	relayClaimTTL = 15 * time.Minute
+)
+
+// Event is an outbox row waiting to be delivered.
+type Event struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	ShipmentID int             `json:"shipment_id"`
+	Payload    json.RawMessage `json:"payload"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Write records an event in tx, so it is only ever published if tx commits.
+func Write(tx *sql.Tx, eventType string, shipmentID int, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO event_outbox (event_type, shipment_id, payload)
+		VALUES ($1, $2, $3)`,
+		eventType, shipmentID, data,
+	)
+	return err
+}
+
+// Dispatcher delivers an event. Implementations must be safe for concurrent
+// use; an error leaves the event to be retried.
+type Dispatcher interface {
+	Dispatch(Event) error
+}
+
+// LogDispatcher writes events to the log instead of delivering them. It is
+// used when no webhook is configured.
+type LogDispatcher struct{}
+
+func (LogDispatcher) Dispatch(e Event) error {
+	log.Printf("📣 Event %d %s for shipment %d", e.ID, e.Type, e.ShipmentID)
+	return nil
+}
+
+// WebhookDispatcher POSTs each event as JSON to a URL, signed with
+// utils.SignWebhook when a secret is set. Any non-2xx response is a failure.
+type WebhookDispatcher struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookDispatcher(url, secret string, timeout time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (d *WebhookDispatcher) Dispatch(e Event) error {
+	return post(d.client, d.url, d.secret, e)
+}
+
+// CustomerWebhookDispatcher POSTs each event to the webhook URL of the
+// customer who owns the shipment, signed with that customer's own secret.
+// Events for customers without a webhook URL are skipped.
+type CustomerWebhookDispatcher struct {
+	db     *sql.DB
+	client *http.Client
+}
+
+func NewCustomerWebhookDispatcher(db *sql.DB, timeout time.Duration) *CustomerWebhookDispatcher {
+	return &CustomerWebhookDispatcher{
+		db:     db,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (d *CustomerWebhookDispatcher) Dispatch(e Event) error {
+	var url, secret sql.NullString
+	err := d.db.QueryRow(`
+		SELECT c.webhook_url, c.webhook_secret
+		FROM shipments s
+		JOIN customers c ON c.user_id = s.customer_id
+		WHERE s.id = $1`, e.ShipmentID).Scan(&url, &secret)
+	if err == sql.ErrNoRows || (err == nil && url.String == "") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return post(d.client, url.String, secret.String, e)
+}
+
+// Dispatchers hands every event to each of its dispatchers in turn and
+// fails if any of them does. A failed event is retried against all of
+// them, so receivers may see an event more than once.
+type Dispatchers []Dispatcher
+
+func (ds Dispatchers) Dispatch(e Event) error {
+	var errs []error
+	for _, d := range ds {
+		if err := d.Dispatch(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post sends e as JSON to url, signed with utils.SignWebhook when a secret
+// is set. Any non-2xx response is a failure.
+func post(client *http.Client, url, secret string, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GoExpress-Event", e.Type)
+	if secret != "" {
+		req.Header.Set(utils.WebhookSignatureHeader, utils.SignWebhook(secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Relay dispatches pending outbox events in order, marking each delivered
+// once its dispatcher succeeds. Events are claimed before they are
+// dispatched, so several instances can relay from the same table without
+// holding locks during delivery.
+type Relay struct {
+	db         *sql.DB
+	dispatcher Dispatcher
+	interval   time.Duration
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewRelay(db *sql.DB, dispatcher Dispatcher, interval time.Duration) *Relay {
+	return &Relay{
+		db:         db,
+		dispatcher: dispatcher,
+		interval:   interval,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start runs the relay in the background, polling every interval. If the
+// polling loop ever stops, e.g. on a panic, it is restarted after an
+// interval, so deliveries never stop silently. It does nothing once the
+// relay has been stopped.
+func (r *Relay) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started || r.stopped {
+		return
+	}
+	r.started = true
+	go r.supervise()
+}
+
+// Stop stops the relay and waits for the delivery in progress, if any, to
+// finish. Events claimed but not yet sent are released for the next run.
+func (r *Relay) Stop() {
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return
+	}
+	r.stopped = true
+	close(r.stop)
+	started := r.started
+	r.mu.Unlock()
+
+	if started {
+		<-r.done
+	}
+}
+
+func (r *Relay) stopping() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *Relay) supervise() {
+	defer close(r.done)
+
+	for {
+		if r.run() {
+			return
+		}
+		log.Printf("⚠️  Outbox relay stopped, restarting in %s", r.interval)
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// run polls until it is stopped, returning true, or panics; the panic is
+// logged and run returns false.
+func (r *Relay) run() (stopped bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("❌ Outbox relay panicked: %v", p)
+		}
+	}()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while full batches suggest a backlog
+		for !r.stopping() {
+			n, err := r.Flush()
+			if err != nil {
+				log.Printf("Failed to relay outbox events: %v", err)
+			}
+			if err != nil || n < relayBatchSize {
+				break
+			}
+		}
+		select {
+		case <-r.stop:
+			return true
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush dispatches one batch of pending events and returns how many it
+// attempted. The batch is claimed and committed first, so no transaction or
+// row lock is held while the dispatcher runs. Failed events are retried on a
+// later flush.
+func (r *Relay) Flush() (int, error) {
+	events, err := r.claim()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range events {
+		if r.stopping() {
+			return i, r.release(events[i:])
+		}
+
+		if dispatchErr := r.dispatch(e); dispatchErr != nil {
+			log.Printf("Failed to deliver event %d %s: %v", e.ID, e.Type, dispatchErr)
+			_, err = r.db.Exec(`
+				UPDATE event_outbox SET attempts = attempts + 1, last_error = $2, claimed_until = NULL
+				WHERE id = $1`,
+				e.ID, dispatchErr.Error(),
+			)
+		} else {
+			_, err = r.db.Exec(`
+				UPDATE event_outbox SET attempts = attempts + 1, delivered_at = CURRENT_TIMESTAMP, claimed_until = NULL
+				WHERE id = $1`,
+				e.ID,
+			)
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// claim reserves the next batch of pending events for relayClaimTTL and
+// returns them in order. Events claimed by another relay are skipped until
+// their claim expires.
+func (r *Relay) claim() ([]Event, error) {
+	rows, err := r.db.Query(`
+		UPDATE event_outbox SET claimed_until = CURRENT_TIMESTAMP + $3::int * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM event_outbox
+			WHERE delivered_at IS NULL AND attempts < $1
+				AND (claimed_until IS NULL OR claimed_until < CURRENT_TIMESTAMP)
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, COALESCE(shipment_id, 0), payload, created_at`,
+		relayMaxAttempts, relayBatchSize, int(relayClaimTTL/time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.ShipmentID, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not preserve the subquery's order
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// release hands claimed events back without counting an attempt.
+func (r *Relay) release(events []Event) error {
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	_, err := r.db.Exec("UPDATE event_outbox SET claimed_until = NULL WHERE id = ANY($1)", pq.Array(ids))
+	return err
+}
+
+// dispatch delivers e, turning a dispatcher panic into an error so that one
+// bad event is retried like any failed delivery instead of stopping the
+// batch.
+func (r *Relay) dispatch(e Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("dispatcher panicked: %v", p)
+		}
+	}()
+	return r.dispatcher.Dispatch(e)
+}
@@ -0,0 +1,60 @@
+// Package pricing lets an external pricing engine adjust GoExpress quotes.
+package pricing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"goexpress-api/models"
+)
+
+// Adjuster returns the total an external engine wants charged for a quote.
+// Callers fall back to the quote's own total when it fails.
+type Adjuster interface {
+	Adjust(quote models.QuoteResponse) (float64, error)
+}
+
+// WebhookAdjuster POSTs each quote as JSON to a URL and reads the adjusted
+// total from a models.QuoteAdjustment response. Any non-2xx response, or a
+// total that is negative or not a number, is a failure.
+type WebhookAdjuster struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookAdjuster(url string, timeout time.Duration) *WebhookAdjuster {
+	return &WebhookAdjuster{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (a *WebhookAdjuster) Adjust(quote models.QuoteResponse) (float64, error) {
+	body, err := json.Marshal(quote)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := a.client.Post(a.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("pricing webhook responded %s", resp.Status)
+	}
+
+	var adjustment models.QuoteAdjustment
+	if err := json.NewDecoder(resp.Body).Decode(&adjustment); err != nil {
+		return 0, fmt.Errorf("pricing webhook: %w", err)
+	}
+	if adjustment.TotalPrice == nil || *adjustment.TotalPrice < 0 || math.IsNaN(*adjustment.TotalPrice) || math.IsInf(*adjustment.TotalPrice, 0) {
+		return 0, errors.New("pricing webhook returned no valid total_price")
+	}
+	return math.Round(*adjustment.TotalPrice*100) / 100, nil
+}
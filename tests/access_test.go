@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"goexpress-api/handlers"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotFoundPolicy_HidesOtherUsersResources(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ownerID := createTestUser(t, db, "Policy Owner", "policyowner@goexpress.com", "client")
+	prowlerID := createTestUser(t, db, "Policy Prowler", "prowler@goexpress.com", "client")
+	shipmentID := seedShipment(t, db, "GEX00C0FFEE", 1, ownerID, "pending", 1500, "2025-07-01 09:00:00")
+
+	var customerID int
+	err := db.QueryRow(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone)
+		VALUES ($1, 'Owner SARL', 'Policy Owner', '+22670000001') RETURNING id`,
+		ownerID,
+	).Scan(&customerID)
+	assert.NoError(t, err)
+
+	get := func(action func(http.ResponseWriter, *http.Request), id, userID int, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/resource/"+strconv.Itoa(id), nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": strconv.Itoa(id)})
+		rr := httptest.NewRecorder()
+		action(rr, req)
+		return rr
+	}
+
+	cases := []struct {
+		name   string
+		action func(http.ResponseWriter, *http.Request)
+		id     int
+	}{
+		{"shipment", handlers.NewShipmentHandler(db.DB).GetShipmentById, shipmentID},
+		{"tracking history", handlers.NewShipmentHandler(db.DB).GetTrackingHistory, shipmentID},
+		{"customer", handlers.NewCustomerHandler(db.DB).GetCustomer, customerID},
+		{"user", handlers.NewUserHandler(db.DB, "test-secret", 5).GetUser, ownerID},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			someoneElses := get(tc.action, tc.id, prowlerID, "client")
+			missing := get(tc.action, 99999, prowlerID, "client")
+
+			assert.Equal(t, http.StatusNotFound, someoneElses.Code)
+			assert.Equal(t, missing.Code, someoneElses.Code)
+			assert.Equal(t, missing.Body.String(), someoneElses.Body.String())
+
+			assert.Equal(t, http.StatusOK, get(tc.action, tc.id, ownerID, "client").Code)
+			assert.Equal(t, http.StatusOK, get(tc.action, tc.id, 1, "admin").Code)
+			assert.Equal(t, http.StatusNotFound, get(tc.action, 99999, 1, "admin").Code)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goexpress-api/handlers"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminHandler_GetOrphanShipments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewAdminHandler(db.DB, middleware.NewMaintenanceState(false, false))
+	customerID := createTestUser(t, db, "Orphan Client", "orphan@goexpress.com", "client")
+
+	orphanID := seedShipment(t, db, "GEX0BAD0001", 1, customerID, "in_transit", 1000, "2025-07-01 09:00:00")
+	trackedID := seedShipment(t, db, "GEX0000A001", 1, customerID, "pending", 1000, "2025-07-01 10:00:00")
+	_, err := db.Exec(`INSERT INTO tracking_updates (shipment_id, status, location) VALUES ($1, 'pending', 'Ouagadougou')`, trackedID)
+	assert.NoError(t, err)
+
+	// Async shipments have no tracking update until the assigner runs
+	_, err = db.Exec(`
+		INSERT INTO shipments (origin, destination, weight, zone_id, customer_id, status, cost)
+		VALUES ('Ouagadougou', 'Banfora', 1, 1, $1, 'pending_tracking', 500)`,
+		customerID,
+	)
+	assert.NoError(t, err)
+
+	req := withClaims(httptest.NewRequest("GET", "/api/admin/orphan-shipments", nil), 1, "admin")
+	rr := httptest.NewRecorder()
+	handler.GetOrphanShipments(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var shipments []models.Shipment
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipments))
+	var ids []int
+	for _, s := range shipments {
+		ids = append(ids, s.ID)
+	}
+	assert.Equal(t, []int{orphanID}, ids)
+}
+
+func TestAdminHandler_GetActivityFeed(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewAdminHandler(db.DB, middleware.NewMaintenanceState(false, false))
+	shipmentHandler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Activity Client", "activity@goexpress.com", "client")
+
+	body := `{"origin": "Ouagadougou", "destination": "Koudougou", "weight": 2, "zone_id": 1}`
+	req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBufferString(body)), clientID, "client")
+	rr := httptest.NewRecorder()
+	shipmentHandler.CreateShipment(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var shipment models.Shipment
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+
+	_, err := db.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location, timestamp)
+		VALUES ($1, 'picked_up', 'Ouagadougou', NOW() + INTERVAL '1 minute')`,
+		shipment.ID,
+	)
+	assert.NoError(t, err)
+
+	getFeed := func(query string) *httptest.ResponseRecorder {
+		req := withClaims(httptest.NewRequest("GET", "/api/activity"+query, nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.GetActivityFeed(rr, req)
+		return rr
+	}
+
+	rr = getFeed("")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var events []models.ActivityEvent
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &events))
+
+	if assert.NotEmpty(t, events) {
+		assert.Equal(t, "status_changed", events[0].Type, "newest first")
+	}
+	var created, userCreated bool
+	for i, e := range events {
+		if i > 0 {
+			assert.False(t, e.OccurredAt.After(events[i-1].OccurredAt.Time), "feed is ordered newest first")
+		}
+		if e.Type == "shipment_created" && e.ShipmentID != nil && *e.ShipmentID == shipment.ID {
+			created = true
+			assert.Contains(t, e.Summary, shipment.TrackingNumber)
+		}
+		if e.Type == "user_created" && e.UserID != nil && *e.UserID == clientID {
+			userCreated = true
+		}
+		if e.Type == "status_changed" {
+			assert.NotContains(t, e.Summary, " is pending", "initial tracking updates are not status changes")
+		}
+	}
+	assert.True(t, created, "feed includes the shipment's creation")
+	assert.True(t, userCreated, "feed includes the client's creation")
+
+	t.Run("limit", func(t *testing.T) {
+		rr := getFeed("?limit=2")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var events []models.ActivityEvent
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &events))
+		assert.Len(t, events, 2)
+
+		assert.Equal(t, http.StatusBadRequest, getFeed("?limit=0").Code)
+		assert.Equal(t, http.StatusBadRequest, getFeed("?limit=201").Code)
+	})
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"goexpress-api/adminsetup"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminSetup_ParseOptions(t *testing.T) {
+	noEnv := func(string) string { return "" }
+
+	t.Run("password is required", func(t *testing.T) {
+		_, err := adminsetup.ParseOptions(nil, noEnv, &bytes.Buffer{})
+		assert.ErrorIs(t, err, adminsetup.ErrNoPassword)
+	})
+
+	t.Run("password from environment", func(t *testing.T) {
+		env := func(key string) string {
+			if key == adminsetup.PasswordEnv {
+				return "from-env-secret"
+			}
+			return ""
+		}
+		opts, err := adminsetup.ParseOptions([]string{"-dry-run"}, env, &bytes.Buffer{})
+		assert.NoError(t, err)
+		assert.Equal(t, "from-env-secret", opts.Password)
+		assert.True(t, opts.DryRun)
+		assert.Equal(t, "admin@goexpress.com", opts.Email)
+	})
+
+	t.Run("flag overrides environment", func(t *testing.T) {
+		env := func(string) string { return "from-env-secret" }
+		opts, err := adminsetup.ParseOptions([]string{"-password", "from-flag-secret", "-email", "ops@goexpress.com"}, env, &bytes.Buffer{})
+		assert.NoError(t, err)
+		assert.Equal(t, "from-flag-secret", opts.Password)
+		assert.Equal(t, "ops@goexpress.com", opts.Email)
+		assert.False(t, opts.DryRun)
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		_, err := adminsetup.ParseOptions([]string{"-bogus"}, noEnv, &bytes.Buffer{})
+		assert.Error(t, err)
+	})
+}
+
+func TestAdminSetup_DryRun(t *testing.T) {
+	opts, err := adminsetup.ParseOptions([]string{"-dry-run", "-password", "s3cret-value"}, func(string) string { return "" }, &bytes.Buffer{})
+	assert.NoError(t, err)
+
+	// A nil database proves nothing is written
+	var output bytes.Buffer
+	assert.NoError(t, adminsetup.Run(nil, opts, &output))
+	assert.Contains(t, output.String(), "Dry run")
+	assert.Contains(t, output.String(), "admin@goexpress.com")
+	assert.False(t, strings.Contains(output.String(), "s3cret-value"))
+
+	opts.ShowPassword = true
+	output.Reset()
+	assert.NoError(t, adminsetup.Run(nil, opts, &output))
+	assert.Contains(t, output.String(), "s3cret-value")
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyticsHandler_ShipmentAnalytics(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewAnalyticsHandler(db.DB)
+	clientID := createTestUser(t, db, "Analytics Client", "analytics@goexpress.com", "client")
+
+	seedShipment(t, db, "GEX00000001", 1, clientID, "pending", 7, "2025-03-01 09:00:00")
+	seedShipment(t, db, "GEX00000002", 1, clientID, "pending", 7, "2025-03-01 15:00:00")
+	seedShipment(t, db, "GEX00000003", 1, clientID, "delivered", 7, "2025-03-01 18:00:00")
+	seedShipment(t, db, "GEX00000004", 1, clientID, "in_transit", 7, "2025-03-02 10:00:00")
+
+	req := withClaims(httptest.NewRequest("GET", "/api/analytics/shipments?from=2025-03-01&to=2025-03-02&interval=day", nil), 1, "admin")
+	rr := httptest.NewRecorder()
+	handler.GetShipmentAnalytics(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var response models.ShipmentAnalytics
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Len(t, response.Buckets, 2)
+
+	assert.Equal(t, 3, response.Buckets[0].Total)
+	assert.Equal(t, 2, response.Buckets[0].Counts["pending"])
+	assert.Equal(t, 1, response.Buckets[0].Counts["delivered"])
+
+	assert.Equal(t, 1, response.Buckets[1].Total)
+	assert.Equal(t, 1, response.Buckets[1].Counts["in_transit"])
+}
+
+func TestAnalyticsHandler_RevenueAnalytics(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewAnalyticsHandler(db.DB)
+	clientID := createTestUser(t, db, "Revenue Client", "revenue@goexpress.com", "client")
+
+	seedShipment(t, db, "GEX00000011", 1, clientID, "delivered", 10.50, "2025-03-01 09:00:00")
+	seedShipment(t, db, "GEX00000012", 1, clientID, "pending", 4.25, "2025-03-02 09:00:00")
+	seedShipment(t, db, "GEX00000013", 2, clientID, "delivered", 20.00, "2025-03-02 12:00:00")
+	seedShipment(t, db, "GEX00000014", 2, clientID, "cancelled", 99.00, "2025-03-02 13:00:00")
+
+	req := withClaims(httptest.NewRequest("GET", "/api/analytics/revenue?from=2025-03-01&to=2025-03-02&group_by=zone", nil), 1, "admin")
+	rr := httptest.NewRecorder()
+	handler.GetRevenueAnalytics(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var report models.RevenueReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+
+	revenueByZone := make(map[int]float64)
+	for _, group := range report.Groups {
+		revenueByZone[*group.ZoneID] = group.Revenue
+	}
+	assert.InDelta(t, 14.75, revenueByZone[1], 0.001)
+	assert.InDelta(t, 20.00, revenueByZone[2], 0.001)
+	assert.InDelta(t, 34.75, report.TotalRevenue, 0.001)
+}
+
+func TestAnalyticsHandler_SLAReport(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	clientID := createTestUser(t, db, "SLA Client", "sla@goexpress.com", "client")
+	_, err := db.Exec("UPDATE zones SET sla_hours = 24 WHERE id = 1")
+	assert.NoError(t, err)
+
+	deliver := func(trackingNumber, deliveredAt string) int {
+		id := seedShipment(t, db, trackingNumber, 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+		_, err := db.Exec("UPDATE shipments SET status = 'delivered', delivered_at = $1 WHERE id = $2", deliveredAt, id)
+		assert.NoError(t, err)
+		return id
+	}
+	onTimeID := deliver("GEX05A00001", "2025-07-01 20:00:00")
+	lateID := deliver("GEX05A00002", "2025-07-03 09:00:00")
+	openID := seedShipment(t, db, "GEX05A00003", 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+
+	shipmentHandler := handlers.NewShipmentHandler(db.DB)
+	getShipment := func(id int) models.Shipment {
+		req := httptest.NewRequest("GET", "/api/shipments/"+strconv.Itoa(id), nil)
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": strconv.Itoa(id)})
+		rr := httptest.NewRecorder()
+		shipmentHandler.GetShipmentById(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.ShipmentResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response.Shipment
+	}
+
+	t.Run("shipments expose sla_breached once delivered", func(t *testing.T) {
+		if onTime := getShipment(onTimeID); assert.NotNil(t, onTime.SLABreached) {
+			assert.False(t, *onTime.SLABreached)
+		}
+		if late := getShipment(lateID); assert.NotNil(t, late.SLABreached) {
+			assert.True(t, *late.SLABreached)
+		}
+		assert.Nil(t, getShipment(openID).SLABreached)
+	})
+
+	t.Run("report summarizes breach rate per zone", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("GET", "/api/analytics/sla?from=2025-07-01&to=2025-07-03", nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handlers.NewAnalyticsHandler(db.DB).GetSLAReport(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var report models.SLAReport
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+
+		var zone *models.ZoneSLA
+		for i := range report.Zones {
+			if report.Zones[i].ZoneID == 1 {
+				zone = &report.Zones[i]
+			}
+		}
+		if assert.NotNil(t, zone) {
+			assert.Equal(t, 24, zone.SLAHours)
+			assert.Equal(t, 2, zone.Delivered)
+			assert.Equal(t, 1, zone.Breached)
+			assert.Equal(t, 0.5, zone.BreachRate)
+		}
+	})
+
+	t.Run("delivering a shipment stamps delivered_at", func(t *testing.T) {
+		_, err := db.Exec("UPDATE shipments SET status = 'delivered' WHERE id = $1", openID)
+		assert.NoError(t, err)
+		assert.NotNil(t, getShipment(openID).DeliveredAt)
+	})
+}
+
+func TestAnalyticsHandler_DeliveryDensity(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	clientID := createTestUser(t, db, "Density Client", "density@goexpress.com", "client")
+
+	deliver := func(trackingNumber, destination, deliveredAt string) {
+		id := seedShipment(t, db, trackingNumber, 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+		_, err := db.Exec("UPDATE shipments SET status = 'delivered', destination = $1, delivered_at = $2 WHERE id = $3",
+			destination, deliveredAt, id)
+		assert.NoError(t, err)
+	}
+	deliver("GEX0DE00001", "Koudougou", "2025-07-02 10:00:00")
+	deliver("GEX0DE00002", "Bobo-Dioulasso", "2025-07-02 11:00:00")
+	deliver("GEX0DE00003", " bobo-dioulasso", "2025-07-03 11:00:00")
+	deliver("GEX0DE00004", "Bobo-Dioulasso", "2025-07-04 11:00:00")
+	deliver("GEX0DE00005", "Koudougou", "2025-08-01 10:00:00") // outside the range
+	seedShipment(t, db, "GEX0DE00006", 1, clientID, "in_transit", 1000, "2025-07-02 09:00:00")
+
+	req := withClaims(httptest.NewRequest("GET", "/api/analytics/delivery-density?from=2025-07-01&to=2025-07-31", nil), 1, "admin")
+	rr := httptest.NewRecorder()
+	handlers.NewAnalyticsHandler(db.DB).GetDeliveryDensity(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var density models.DeliveryDensity
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &density))
+	assert.Equal(t, []models.DestinationDensity{
+		{Destination: "Bobo-Dioulasso", Deliveries: 3},
+		{Destination: "Koudougou", Deliveries: 1},
+	}, density.Destinations)
+}
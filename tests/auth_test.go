@@ -3,12 +3,17 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"goexpress-api/handlers"
+	"goexpress-api/mailer"
 	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -82,6 +87,139 @@ func TestAuthHandler_Register(t *testing.T) {
 
 		assert.Equal(t, http.StatusConflict, rr.Code)
 	})
+
+	t.Run("duplicate email differing in case", func(t *testing.T) {
+		user := models.UserRegistration{
+			Name:     "Test User 3",
+			Email:    "Test@GoExpress.com",
+			Password: "password123",
+			Role:     "client",
+		}
+
+		jsonData, _ := json.Marshal(user)
+		req := httptest.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.Register(rr, req)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("emails are stored lowercased", func(t *testing.T) {
+		user := models.UserRegistration{
+			Name:     "Mixed Case",
+			Email:    "Mixed.Case@GoExpress.com",
+			Password: "password123",
+			Role:     "client",
+		}
+
+		jsonData, _ := json.Marshal(user)
+		req := httptest.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.Register(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var response models.AuthResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, "mixed.case@goexpress.com", response.User.Email)
+	})
+
+	t.Run("database rejects differing-case duplicates", func(t *testing.T) {
+		_, err := db.Exec(`
+			INSERT INTO users (name, email, password_hash, role)
+			VALUES ('Direct Insert', 'TEST@goexpress.com', 'x', 'client')`)
+		assert.Error(t, err)
+	})
+}
+
+// failingMailer simulates an unreachable mail server.
+type failingMailer struct{}
+
+func (failingMailer) Send(msg mailer.Message) error {
+	return errors.New("connection refused")
+}
+
+func TestAuthHandler_RegisterDisabled(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewAuthHandler(db.DB, "test-secret", "test-refresh-secret")
+	handler.SetRegistrationEnabled(false)
+
+	jsonData, _ := json.Marshal(models.UserRegistration{
+		Name:     "Walk In",
+		Email:    "walkin@goexpress.com",
+		Password: "password123",
+		Role:     "client",
+	})
+	req := httptest.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.Register(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM users WHERE email = 'walkin@goexpress.com'").Scan(&count)
+	assert.Equal(t, 0, count)
+
+	// Admins can still create accounts
+	userHandler := handlers.NewUserHandler(db.DB, "test-secret", 5)
+	jsonData, _ = json.Marshal(models.CreateUserRequest{
+		Name:     "Walk In",
+		Email:    "walkin@goexpress.com",
+		Password: "password123",
+		Role:     "client",
+	})
+	req = withClaims(httptest.NewRequest("POST", "/api/users", bytes.NewBuffer(jsonData)), 1, "admin")
+	rr = httptest.NewRecorder()
+	userHandler.CreateUser(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+}
+
+func TestAuthHandler_RegisterWhenMailerFails(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queue := mailer.NewQueue(failingMailer{}, 10, 2, time.Millisecond)
+	queue.Start()
+	handler := handlers.NewAuthHandler(db.DB, "test-secret", "test-refresh-secret")
+	handler.SetMailer(queue)
+
+	jsonData, _ := json.Marshal(models.UserRegistration{
+		Name:     "Mail Outage",
+		Email:    "mailoutage@goexpress.com",
+		Password: "password123",
+		Role:     "client",
+	})
+	req := httptest.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.Register(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// The failed send is retried in the background and then surfaced to admins.
+	assert.Eventually(t, func() bool { return queue.Stats().Failed == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	admin := handlers.NewAdminHandler(db.DB, nil)
+	admin.SetMailQueue(queue)
+	req = withClaims(httptest.NewRequest("GET", "/api/admin/mail", nil), 1, "admin")
+	rr = httptest.NewRecorder()
+	admin.GetMailStatus(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var stats mailer.Stats
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	assert.Equal(t, int64(0), stats.Sent)
+	if assert.Len(t, stats.RecentFailures, 1) {
+		assert.Equal(t, "mailoutage@goexpress.com", stats.RecentFailures[0].To)
+		assert.Equal(t, 2, stats.RecentFailures[0].Attempts)
+		assert.Equal(t, "connection refused", stats.RecentFailures[0].Error)
+	}
 }
 
 func TestAuthHandler_Login(t *testing.T) {
@@ -163,4 +301,78 @@ func TestAuthHandler_Login(t *testing.T) {
 
 		assert.Equal(t, http.StatusUnauthorized, rr.Code)
 	})
-}
\ No newline at end of file
+}
+func TestAuthHandler_Introspect(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewAuthHandler(db.DB, "test-secret", "test-refresh-secret")
+	userID := createTestUser(t, db, "Introspect User", "introspect@goexpress.com", "driver")
+
+	introspect := func(token string) models.IntrospectResponse {
+		body, _ := json.Marshal(models.IntrospectRequest{Token: token})
+		rr := httptest.NewRecorder()
+		handler.Introspect(rr, httptest.NewRequest("POST", "/api/auth/introspect", bytes.NewBuffer(body)))
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.IntrospectResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("active token", func(t *testing.T) {
+		token, err := utils.GenerateJWT(userID, "introspect@goexpress.com", "driver", "test-secret")
+		assert.NoError(t, err)
+
+		response := introspect(token)
+		assert.True(t, response.Active)
+		assert.Equal(t, userID, response.UserID)
+		assert.Equal(t, "driver", response.Role)
+		assert.NotNil(t, response.ExpiresAt)
+	})
+
+	t.Run("token from authorization header", func(t *testing.T) {
+		token, _ := utils.GenerateJWT(userID, "introspect@goexpress.com", "driver", "test-secret")
+		req := httptest.NewRequest("POST", "/api/auth/introspect", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.Introspect(rr, req)
+
+		var response models.IntrospectResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.True(t, response.Active)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		claims := &utils.Claims{
+			UserID: userID,
+			Email:  "introspect@goexpress.com",
+			Role:   "driver",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+			},
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		assert.NoError(t, err)
+
+		response := introspect(token)
+		assert.False(t, response.Active)
+		assert.Zero(t, response.UserID)
+		assert.Nil(t, response.ExpiresAt)
+	})
+
+	t.Run("token signed with another secret", func(t *testing.T) {
+		token, _ := utils.GenerateJWT(userID, "introspect@goexpress.com", "driver", "other-secret")
+		assert.False(t, introspect(token).Active)
+	})
+
+	t.Run("revoked token of a deleted user", func(t *testing.T) {
+		deletedID := createTestUser(t, db, "Deleted User", "deleted@goexpress.com", "client")
+		token, _ := utils.GenerateJWT(deletedID, "deleted@goexpress.com", "client", "test-secret")
+		_, err := db.Exec("DELETE FROM users WHERE id = $1", deletedID)
+		assert.NoError(t, err)
+
+		assert.False(t, introspect(token).Active)
+	})
+}
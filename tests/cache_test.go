@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"goexpress-api/cache"
+	"goexpress-api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLShipmentStats(t *testing.T) {
+	stats := models.ShipmentStats{Total: 3, ByStatus: map[string]int{"pending": 2, "delivered": 1}}
+
+	t.Run("miss until set", func(t *testing.T) {
+		c := cache.NewTTLShipmentStats(time.Minute)
+		_, ok := c.Get()
+		assert.False(t, ok)
+
+		c.Set(stats)
+		cached, ok := c.Get()
+		assert.True(t, ok)
+		assert.Equal(t, stats, cached)
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		c := cache.NewTTLShipmentStats(time.Minute)
+		c.Set(stats)
+		c.Invalidate()
+		_, ok := c.Get()
+		assert.False(t, ok)
+	})
+
+	t.Run("expires after ttl", func(t *testing.T) {
+		c := cache.NewTTLShipmentStats(20 * time.Millisecond)
+		c.Set(stats)
+		time.Sleep(30 * time.Millisecond)
+		_, ok := c.Get()
+		assert.False(t, ok)
+	})
+
+	t.Run("zero ttl disables caching", func(t *testing.T) {
+		c := cache.NewTTLShipmentStats(0)
+		c.Set(stats)
+		_, ok := c.Get()
+		assert.False(t, ok)
+	})
+
+	t.Run("callers cannot mutate the cached map", func(t *testing.T) {
+		c := cache.NewTTLShipmentStats(time.Minute)
+		c.Set(stats)
+		cached, _ := c.Get()
+		cached.ByStatus["pending"] = 99
+
+		cached, _ = c.Get()
+		assert.Equal(t, 2, cached.ByStatus["pending"])
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		c := cache.NewTTLShipmentStats(time.Minute)
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				switch i % 3 {
+				case 0:
+					c.Set(stats)
+				case 1:
+					c.Get()
+				default:
+					c.Invalidate()
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
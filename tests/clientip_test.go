@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"goexpress-api/middleware"
+	"goexpress-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	assert.NoError(t, utils.SetTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"}))
+	defer utils.SetTrustedProxies(nil)
+
+	request := func(remoteAddr string, headers map[string]string) *http.Request {
+		req := httptest.NewRequest("GET", "/api/track/GEX12345678", nil)
+		req.RemoteAddr = remoteAddr
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+
+	t.Run("ignores forwarding headers from an untrusted peer", func(t *testing.T) {
+		req := request("198.51.100.7:5000", map[string]string{
+			"X-Forwarded-For": "203.0.113.9",
+			"X-Real-IP":       "203.0.113.9",
+		})
+		assert.Equal(t, "198.51.100.7", utils.ClientIP(req))
+	})
+
+	t.Run("uses X-Forwarded-For from a trusted proxy", func(t *testing.T) {
+		req := request("10.1.2.3:5000", map[string]string{"X-Forwarded-For": "203.0.113.9"})
+		assert.Equal(t, "203.0.113.9", utils.ClientIP(req))
+	})
+
+	t.Run("skips trusted hops and spoofed entries", func(t *testing.T) {
+		req := request("10.1.2.3:5000", map[string]string{
+			"X-Forwarded-For": "1.2.3.4, 203.0.113.9, 192.0.2.1, 10.4.5.6",
+		})
+		assert.Equal(t, "203.0.113.9", utils.ClientIP(req))
+	})
+
+	t.Run("falls back to X-Real-IP", func(t *testing.T) {
+		req := request("192.0.2.1:443", map[string]string{"X-Real-IP": "203.0.113.9"})
+		assert.Equal(t, "203.0.113.9", utils.ClientIP(req))
+	})
+
+	t.Run("uses the peer when a trusted proxy forwards nothing", func(t *testing.T) {
+		assert.Equal(t, "10.1.2.3", utils.ClientIP(request("10.1.2.3:5000", nil)))
+	})
+
+	t.Run("rejects invalid proxies", func(t *testing.T) {
+		assert.Error(t, utils.SetTrustedProxies([]string{"not-an-ip"}))
+		assert.Error(t, utils.SetTrustedProxies([]string{"10.0.0.0/33"}))
+	})
+}
+
+func TestRateLimitMiddleware_TrustedProxy(t *testing.T) {
+	assert.NoError(t, utils.SetTrustedProxies([]string{"10.0.0.0/8"}))
+	defer utils.SetTrustedProxies(nil)
+
+	handler := middleware.RateLimit(middleware.NewRateLimiter(1, time.Minute))(http.HandlerFunc(okHandler))
+
+	serve := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest("POST", "/api/shipments/track-batch", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("10.0.0.5:5000", "203.0.113.9"))
+	assert.Equal(t, http.StatusOK, serve("10.0.0.5:5001", "203.0.113.10"), "clients behind the proxy are limited separately")
+	assert.Equal(t, http.StatusTooManyRequests, serve("10.0.0.6:5000", "203.0.113.9"))
+
+	assert.Equal(t, http.StatusOK, serve("198.51.100.7:5000", "203.0.113.11"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("198.51.100.7:5001", "203.0.113.12"), "untrusted peers cannot spoof new addresses")
+}
@@ -0,0 +1,470 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerHandler_GetCustomerTrend(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewCustomerHandler(db.DB)
+	userID := createTestUser(t, db, "Trend Buyer", "trend@goexpress.com", "client")
+	otherID := createTestUser(t, db, "Other Buyer", "other@goexpress.com", "client")
+
+	var customerID int
+	err := db.QueryRow(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone)
+		VALUES ($1, 'Trend SARL', 'Issa Kabore', '+22670000001') RETURNING id`,
+		userID,
+	).Scan(&customerID)
+	assert.NoError(t, err)
+
+	// Mid-month timestamps keep the buckets clear of timezone edges.
+	now := time.Now()
+	monthsAgo := func(n int) string {
+		return time.Date(now.Year(), now.Month()-time.Month(n), 15, 12, 0, 0, 0, time.Local).Format("2006-01-02 15:04:05")
+	}
+	seedShipment(t, db, "GEX0E0D0001", 1, userID, "delivered", 1000, monthsAgo(2))
+	seedShipment(t, db, "GEX0E0D0002", 1, userID, "delivered", 500, monthsAgo(2))
+	seedShipment(t, db, "GEX0E0D0003", 1, userID, "cancelled", 9000, monthsAgo(2))
+	seedShipment(t, db, "GEX0E0D0004", 1, userID, "pending", 250, monthsAgo(0))
+	seedShipment(t, db, "GEX0E0D0005", 1, userID, "delivered", 700, monthsAgo(13))
+	seedShipment(t, db, "GEX0E0D0006", 1, otherID, "delivered", 300, monthsAgo(0))
+
+	getTrend := func(query string, userID int, role string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(customerID)
+		req := httptest.NewRequest("GET", "/api/customers/"+id+"/trend"+query, nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetCustomerTrend(rr, req)
+		return rr
+	}
+
+	t.Run("buckets shipments by month", func(t *testing.T) {
+		rr := getTrend("", 1, "admin")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var trend models.CustomerTrend
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &trend))
+		assert.Equal(t, customerID, trend.CustomerID)
+		assert.Len(t, trend.Months, 12)
+
+		byMonth := map[string]models.CustomerTrendMonth{}
+		for _, month := range trend.Months {
+			byMonth[month.Month.Local().Format("2006-01")] = month
+		}
+		key := func(n int) string { return monthsAgo(n)[:7] }
+
+		assert.Equal(t, 2, byMonth[key(2)].Shipments)
+		assert.Equal(t, 1500.0, byMonth[key(2)].Spend)
+		assert.Equal(t, 1, byMonth[key(0)].Shipments)
+		assert.Equal(t, 250.0, byMonth[key(0)].Spend)
+		assert.Equal(t, 0, byMonth[key(1)].Shipments)
+		assert.Equal(t, key(0), trend.Months[11].Month.Local().Format("2006-01"))
+	})
+
+	t.Run("months widens the window", func(t *testing.T) {
+		rr := getTrend("?months=14", userID, "client")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var trend models.CustomerTrend
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &trend))
+		assert.Len(t, trend.Months, 14)
+		assert.Equal(t, 1, trend.Months[0].Shipments)
+		assert.Equal(t, 700.0, trend.Months[0].Spend)
+	})
+
+	t.Run("invalid months", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, getTrend("?months=0", 1, "admin").Code)
+		assert.Equal(t, http.StatusBadRequest, getTrend("?months=abc", 1, "admin").Code)
+	})
+
+	t.Run("other clients cannot see it", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, getTrend("", otherID, "client").Code)
+	})
+}
+
+func TestCustomerHandler_GetOverLimitCustomers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewCustomerHandler(db.DB)
+	createCustomer := func(name, email string, creditLimit float64) (customerID, userID int) {
+		userID = createTestUser(t, db, name, email, "client")
+		err := db.QueryRow(`
+			INSERT INTO customers (user_id, company_name, contact_person, phone, credit_limit)
+			VALUES ($1, $2, $2, '+22670000002', $3) RETURNING id`,
+			userID, name, creditLimit,
+		).Scan(&customerID)
+		assert.NoError(t, err)
+		return customerID, userID
+	}
+
+	overID, overUser := createCustomer("Over Limit", "over@goexpress.com", 1000)
+	_, underUser := createCustomer("Under Limit", "under@goexpress.com", 1000)
+	_, unlimitedUser := createCustomer("No Limit", "nolimit@goexpress.com", 0)
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	seedShipment(t, db, "GEX0F0E0001", 1, overUser, "pending", 800, now)
+	seedShipment(t, db, "GEX0F0E0002", 1, overUser, "in_transit", 500, now)
+	seedShipment(t, db, "GEX0F0E0003", 1, overUser, "delivered", 5000, now)
+	seedShipment(t, db, "GEX0F0E0004", 1, overUser, "cancelled", 9000, now)
+	seedShipment(t, db, "GEX0F0E0005", 1, underUser, "pending", 900, now)
+	seedShipment(t, db, "GEX0F0E0006", 1, underUser, "delivered", 4000, now)
+	seedShipment(t, db, "GEX0F0E0007", 1, unlimitedUser, "pending", 2500, now)
+
+	req := withClaims(httptest.NewRequest("GET", "/api/customers/over-limit", nil), 1, "admin")
+	rr := httptest.NewRecorder()
+	handler.GetOverLimitCustomers(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var customers []models.CustomerOverLimit
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &customers))
+	if assert.Len(t, customers, 1) {
+		assert.Equal(t, overID, customers[0].CustomerID)
+		assert.Equal(t, 1000.0, customers[0].CreditLimit)
+		assert.Equal(t, 1300.0, customers[0].Balance)
+		assert.Equal(t, 300.0, customers[0].Overage)
+	}
+
+	t.Run("clients are refused", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := withClaims(httptest.NewRequest("GET", "/api/customers/over-limit", nil), overUser, "client")
+		authorized("GET", "/api/customers/over-limit", handler.GetOverLimitCustomers).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestCustomerHandler_ExportCustomers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewCustomerHandler(db.DB)
+	activeUser := createTestUser(t, db, "Export Active", "export-active@goexpress.com", "client")
+	inactiveUser := createTestUser(t, db, "Export Inactive", "export-inactive@goexpress.com", "client")
+	_, err := db.Exec(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone, status) VALUES
+		($1, 'Active SARL', 'Awa Traore', '+22670000003', 'active'),
+		($2, 'Inactive SARL', 'Paul Some', '+22670000004', 'inactive')`,
+		activeUser, inactiveUser,
+	)
+	assert.NoError(t, err)
+
+	seedShipment(t, db, "GEX0E0F0001", 1, activeUser, "delivered", 1000, "2025-07-01 09:00:00")
+	seedShipment(t, db, "GEX0E0F0002", 1, activeUser, "pending", 500, "2025-07-03 09:00:00")
+
+	export := func(query string) [][]string {
+		req := withClaims(httptest.NewRequest("GET", "/api/customers/export"+query, nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.ExportCustomers(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		assert.NoError(t, err)
+		return records
+	}
+
+	t.Run("header and stats columns", func(t *testing.T) {
+		records := export("?format=csv")
+		if !assert.Len(t, records, 3) {
+			return
+		}
+		assert.Equal(t, []string{
+			"id", "company_name", "contact_person", "name", "email", "phone", "business_type",
+			"status", "credit_limit", "total_shipments", "total_spent", "last_shipment", "created_at",
+		}, records[0])
+
+		active := records[1]
+		assert.Equal(t, "Active SARL", active[1])
+		assert.Equal(t, "export-active@goexpress.com", active[4])
+		assert.Equal(t, "active", active[7])
+		assert.Equal(t, "2", active[9])
+		assert.Equal(t, "1500.00", active[10])
+		assert.Contains(t, active[11], "2025-07-03")
+
+		inactive := records[2]
+		assert.Equal(t, "0", inactive[9])
+		assert.Equal(t, "0.00", inactive[10])
+		assert.Equal(t, "", inactive[11])
+	})
+
+	t.Run("honors the status filter", func(t *testing.T) {
+		records := export("?status=inactive")
+		if assert.Len(t, records, 2) {
+			assert.Equal(t, "Inactive SARL", records[1][1])
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("GET", "/api/customers/export?format=xlsx", nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.ExportCustomers(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("cells are never read as formulas", func(t *testing.T) {
+		formulaUser := createTestUser(t, db, "-2+3", "export-formula@goexpress.com", "client")
+		_, err := db.Exec(`
+			INSERT INTO customers (user_id, company_name, contact_person, phone, status)
+			VALUES ($1, '=HYPERLINK("http://evil.example","Click")', '@SUM(A1:A2)', '+22670000005', 'suspended')`,
+			formulaUser,
+		)
+		assert.NoError(t, err)
+
+		records := export("?status=suspended")
+		if assert.Len(t, records, 2) {
+			assert.Equal(t, `'=HYPERLINK("http://evil.example","Click")`, records[1][1])
+			assert.Equal(t, "'@SUM(A1:A2)", records[1][2])
+			assert.Equal(t, "'-2+3", records[1][3])
+			assert.Equal(t, "'+22670000005", records[1][5])
+		}
+	})
+}
+
+func TestCustomerHandler_CustomerNotes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewCustomerHandler(db.DB)
+	adminID := createTestUser(t, db, "Notes Admin", "notes-admin@goexpress.com", "admin")
+	clientID := createTestUser(t, db, "Notes Client", "notes-client@goexpress.com", "client")
+	var customerID int
+	err := db.QueryRow(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone)
+		VALUES ($1, 'Notes SARL', 'Notes Client', '+22670000004') RETURNING id`,
+		clientID,
+	).Scan(&customerID)
+	assert.NoError(t, err)
+	id := strconv.Itoa(customerID)
+
+	addNote := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/customers/"+id+"/notes", bytes.NewBufferString(body))
+		req = mux.SetURLVars(withClaims(req, adminID, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.AddCustomerNote(rr, req)
+		return rr
+	}
+
+	rr := addNote(id, `{"body": "Prefers morning pickups"}`)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var first models.CustomerNote
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &first))
+	assert.Equal(t, customerID, first.CustomerID)
+	if assert.NotNil(t, first.AuthorID) {
+		assert.Equal(t, adminID, *first.AuthorID)
+	}
+	assert.Equal(t, "Notes Admin", first.AuthorName)
+
+	assert.Equal(t, http.StatusCreated, addNote(id, `{"body": "Agreed net-30 payment terms"}`).Code)
+
+	req := httptest.NewRequest("GET", "/api/customers/"+id+"/notes", nil)
+	req = mux.SetURLVars(withClaims(req, adminID, "admin"), map[string]string{"id": id})
+	rr = httptest.NewRecorder()
+	handler.GetCustomerNotes(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var notes []models.CustomerNote
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &notes))
+	if assert.Len(t, notes, 2) {
+		assert.Equal(t, "Agreed net-30 payment terms", notes[0].Body)
+		assert.Equal(t, "Prefers morning pickups", notes[1].Body)
+	}
+
+	t.Run("empty body is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, addNote(id, `{"body": ""}`).Code)
+	})
+
+	t.Run("unknown customer", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, addNote("99999", `{"body": "Lost"}`).Code)
+	})
+
+	t.Run("clients are refused", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/customers/"+id+"/notes", nil)
+		req = mux.SetURLVars(withClaims(req, clientID, "client"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		authorized("GET", "/api/customers/{id}/notes", handler.GetCustomerNotes).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestCustomerHandler_AccountManager(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewCustomerHandler(db.DB)
+	managerID := createTestUser(t, db, "Account Manager", "manager@goexpress.com", "admin")
+	otherAdminID := createTestUser(t, db, "Other Admin", "other-admin@goexpress.com", "admin")
+	driverID := createTestUser(t, db, "Not A Manager", "not-manager@goexpress.com", "driver")
+	createCustomer := func(company, email string) int {
+		userID := createTestUser(t, db, company, email, "client")
+		var customerID int
+		err := db.QueryRow(`
+			INSERT INTO customers (user_id, company_name, contact_person, phone)
+			VALUES ($1, $2, $2, '+22670000005') RETURNING id`,
+			userID, company,
+		).Scan(&customerID)
+		assert.NoError(t, err)
+		return customerID
+	}
+	zetaID := createCustomer("Zeta Logistics", "zeta@goexpress.com")
+	alphaID := createCustomer("Alpha Trading", "alpha@goexpress.com")
+	createCustomer("Unmanaged SARL", "unmanaged@goexpress.com")
+
+	assign := func(customerID int, body string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(customerID)
+		req := httptest.NewRequest("PUT", "/api/customers/"+id+"/manager", bytes.NewBufferString(body))
+		req = mux.SetURLVars(withClaims(req, otherAdminID, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.AssignAccountManager(rr, req)
+		return rr
+	}
+	managed := func(userID int) []models.Customer {
+		req := withClaims(httptest.NewRequest("GET", "/api/users/me/customers", nil), userID, "admin")
+		rr := httptest.NewRecorder()
+		handler.GetManagedCustomers(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var customers []models.Customer
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &customers))
+		return customers
+	}
+
+	body := `{"manager_id": ` + strconv.Itoa(managerID) + `}`
+	rr := assign(zetaID, body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var customer models.Customer
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &customer))
+	if assert.NotNil(t, customer.AccountManagerID) {
+		assert.Equal(t, managerID, *customer.AccountManagerID)
+	}
+	assert.Equal(t, http.StatusOK, assign(alphaID, body).Code)
+
+	customers := managed(managerID)
+	if assert.Len(t, customers, 2) {
+		assert.Equal(t, alphaID, customers[0].ID)
+		assert.Equal(t, zetaID, customers[1].ID)
+	}
+	assert.Empty(t, managed(otherAdminID))
+
+	t.Run("manager must be an admin", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, assign(zetaID, `{"manager_id": `+strconv.Itoa(driverID)+`}`).Code)
+		assert.Equal(t, http.StatusBadRequest, assign(zetaID, `{"manager_id": 99999}`).Code)
+	})
+
+	t.Run("unknown customer", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, assign(99999, body).Code)
+	})
+
+	t.Run("null removes the manager", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, assign(zetaID, `{"manager_id": null}`).Code)
+		customers := managed(managerID)
+		if assert.Len(t, customers, 1) {
+			assert.Equal(t, alphaID, customers[0].ID)
+		}
+	})
+}
+
+func TestCustomerHandler_UpdateCustomer(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewCustomerHandler(db.DB)
+	clientID := createTestUser(t, db, "Update Client", "update-client@goexpress.com", "client")
+	strangerID := createTestUser(t, db, "Update Stranger", "update-stranger@goexpress.com", "client")
+	var customerID int
+	err := db.QueryRow(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone, phone_verified_at)
+		VALUES ($1, 'Sahel Imports', 'Issa', '+22670000006', CURRENT_TIMESTAMP) RETURNING id`,
+		clientID,
+	).Scan(&customerID)
+	assert.NoError(t, err)
+
+	update := func(userID int, role string, req models.UpdateCustomerRequest) *httptest.ResponseRecorder {
+		id := strconv.Itoa(customerID)
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest("PUT", "/api/customers/"+id, bytes.NewBuffer(body))
+		r = mux.SetURLVars(withClaims(r, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.UpdateCustomer(rr, r)
+		return rr
+	}
+	details := models.UpdateCustomerRequest{
+		CompanyName:   "Sahel Imports",
+		ContactPerson: "Issa",
+		Phone:         "+22670000006",
+		Status:        "active",
+	}
+
+	t.Run("owner switches to SMS notifications", func(t *testing.T) {
+		req := details
+		req.NotificationChannel = "sms"
+		rr := update(clientID, "client", req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var customer models.Customer
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &customer))
+		assert.Equal(t, "sms", customer.NotificationChannel)
+		assert.NotNil(t, customer.PhoneVerifiedAt)
+
+		var channel string
+		db.QueryRow("SELECT notification_channel FROM customers WHERE id = $1", customerID).Scan(&channel)
+		assert.Equal(t, "sms", channel)
+	})
+
+	t.Run("omitted channel keeps the preference", func(t *testing.T) {
+		rr := update(clientID, "client", details)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var customer models.Customer
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &customer))
+		assert.Equal(t, "sms", customer.NotificationChannel)
+	})
+
+	t.Run("unknown channel is rejected", func(t *testing.T) {
+		req := details
+		req.NotificationChannel = "pigeon"
+		assert.Equal(t, http.StatusBadRequest, update(clientID, "client", req).Code)
+	})
+
+	t.Run("changing the phone clears its verification", func(t *testing.T) {
+		req := details
+		req.Phone = "+22670000007"
+		rr := update(clientID, "client", req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var customer models.Customer
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &customer))
+		assert.Nil(t, customer.PhoneVerifiedAt)
+		details.Phone = req.Phone
+	})
+
+	t.Run("only admins change status and credit limit", func(t *testing.T) {
+		req := details
+		req.CreditLimit = 5000
+		assert.Equal(t, http.StatusForbidden, update(clientID, "client", req).Code)
+
+		rr := update(1, "admin", req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var customer models.Customer
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &customer))
+		assert.Equal(t, 5000.0, customer.CreditLimit)
+	})
+
+	t.Run("other clients get a 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, update(strangerID, "client", details).Code)
+	})
+}
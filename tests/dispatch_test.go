@@ -0,0 +1,428 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"goexpress-api/audit"
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatchHandler_DriverCapacity(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDispatchHandler(db.DB, 5)
+	customerID := createTestUser(t, db, "Dispatch Client", "dispatchclient@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Capped Driver", "capped@goexpress.com", "driver")
+
+	_, err := db.Exec(`INSERT INTO driver_profiles (user_id, max_concurrent_shipments) VALUES ($1, 2)`, driverID)
+	assert.NoError(t, err)
+
+	assign := func(shipmentID, driverID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		body, _ := json.Marshal(models.AssignDriverRequest{DriverID: driverID})
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/assign", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.AssignDriver(rr, req)
+		return rr
+	}
+
+	autoAssign := func(shipmentID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/auto-assign", nil)
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.AutoAssign(rr, req)
+		return rr
+	}
+
+	var shipmentIDs []int
+	for i, tracking := range []string{"GEXCAP00001", "GEXCAP00002", "GEXCAP00003", "GEXCAP00004"} {
+		shipmentIDs = append(shipmentIDs, seedShipment(t, db, tracking, 1, customerID, "pending", 1500, "2025-07-0"+strconv.Itoa(i+1)+" 10:00:00"))
+	}
+
+	t.Run("assigns up to the driver's capacity", func(t *testing.T) {
+		for _, shipmentID := range shipmentIDs[:2] {
+			rr := assign(shipmentID, driverID)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			var shipment models.Shipment
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+			if assert.NotNil(t, shipment.DriverID) {
+				assert.Equal(t, driverID, *shipment.DriverID)
+			}
+		}
+	})
+
+	t.Run("rejects assignment beyond capacity", func(t *testing.T) {
+		rr := assign(shipmentIDs[2], driverID)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+
+		var assigned *int
+		db.QueryRow("SELECT driver_id FROM shipments WHERE id = $1", shipmentIDs[2]).Scan(&assigned)
+		assert.Nil(t, assigned)
+	})
+
+	t.Run("reassigning an already assigned shipment does not count against the cap", func(t *testing.T) {
+		rr := assign(shipmentIDs[0], driverID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("delivered shipments free up capacity", func(t *testing.T) {
+		_, err := db.Exec("UPDATE shipments SET status = 'delivered' WHERE id = $1", shipmentIDs[0])
+		assert.NoError(t, err)
+
+		rr := assign(shipmentIDs[2], driverID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("auto-assign skips drivers at capacity", func(t *testing.T) {
+		_, err := db.Exec("UPDATE users SET driver_status = 'available' WHERE id = $1", driverID)
+		assert.NoError(t, err)
+
+		rr := autoAssign(shipmentIDs[3])
+		assert.Equal(t, http.StatusConflict, rr.Code)
+
+		spareID := createTestUser(t, db, "Spare Driver", "spare@goexpress.com", "driver")
+		_, err = db.Exec("UPDATE users SET driver_status = 'available' WHERE id = $1", spareID)
+		assert.NoError(t, err)
+
+		rr = autoAssign(shipmentIDs[3])
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		if assert.NotNil(t, shipment.DriverID) {
+			assert.Equal(t, spareID, *shipment.DriverID)
+		}
+	})
+
+	t.Run("unknown driver", func(t *testing.T) {
+		rr := assign(shipmentIDs[3], 99999)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestDispatchHandler_VehicleMaxWeight(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDispatchHandler(db.DB, 5)
+	customerID := createTestUser(t, db, "Heavy Client", "heavyclient@goexpress.com", "client")
+	motorcycleID := createTestUser(t, db, "Moto Driver", "moto@goexpress.com", "driver")
+	truckID := createTestUser(t, db, "Truck Driver", "truck@goexpress.com", "driver")
+
+	_, err := db.Exec(`
+		INSERT INTO driver_profiles (user_id, vehicle_type, max_weight) VALUES
+		($1, 'motorcycle', 30), ($2, 'truck', NULL)`,
+		motorcycleID, truckID,
+	)
+	assert.NoError(t, err)
+
+	heavyID := seedShipment(t, db, "GEX0EA00001", 1, customerID, "pending", 1500, "2025-07-01 10:00:00")
+	_, err = db.Exec("UPDATE shipments SET weight = 200 WHERE id = $1", heavyID)
+	assert.NoError(t, err)
+	lightID := seedShipment(t, db, "GEX0EA00002", 1, customerID, "pending", 1500, "2025-07-01 10:00:00")
+
+	assign := func(shipmentID, driverID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		body, _ := json.Marshal(models.AssignDriverRequest{DriverID: driverID})
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/assign", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.AssignDriver(rr, req)
+		return rr
+	}
+
+	t.Run("rejects a shipment heavier than the vehicle can carry", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, assign(heavyID, motorcycleID).Code)
+
+		var assigned *int
+		db.QueryRow("SELECT driver_id FROM shipments WHERE id = $1", heavyID).Scan(&assigned)
+		assert.Nil(t, assigned)
+	})
+
+	t.Run("assigns within the vehicle's capacity", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, assign(lightID, motorcycleID).Code)
+	})
+
+	t.Run("auto-assign skips vehicles too small", func(t *testing.T) {
+		_, err := db.Exec("UPDATE users SET driver_status = 'available' WHERE id IN ($1, $2)", motorcycleID, truckID)
+		assert.NoError(t, err)
+
+		// The motorcycle is the least loaded, so only its weight limit keeps
+		// the heavy shipment from it
+		_, err = db.Exec("UPDATE shipments SET status = 'delivered' WHERE id = $1", lightID)
+		assert.NoError(t, err)
+		busyID := seedShipment(t, db, "GEX0EA00003", 1, customerID, "pending", 1500, "2025-07-01 10:00:00")
+		assert.Equal(t, http.StatusOK, assign(busyID, truckID).Code)
+
+		id := strconv.Itoa(heavyID)
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/auto-assign", nil)
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.AutoAssign(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		if assert.NotNil(t, shipment.DriverID) {
+			assert.Equal(t, truckID, *shipment.DriverID)
+		}
+	})
+}
+
+func TestDispatchHandler_AssignBatch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDispatchHandler(db.DB, 10)
+	customerID := createTestUser(t, db, "Batch Shipper", "batchshipper@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Batch Driver", "batchdriver@goexpress.com", "driver")
+	otherDriverID := createTestUser(t, db, "Busy Driver", "busydriver@goexpress.com", "driver")
+
+	_, err := db.Exec(`INSERT INTO driver_profiles (user_id, max_concurrent_shipments) VALUES ($1, 3)`, driverID)
+	assert.NoError(t, err)
+
+	// One open shipment already counts against the driver's cap of 3
+	existingID := seedShipment(t, db, "GEXBA000001", 2, customerID, "in_transit", 900, "2025-07-01 08:00:00")
+	oldestID := seedShipment(t, db, "GEXBA000002", 1, customerID, "pending", 900, "2025-07-01 09:00:00")
+	secondID := seedShipment(t, db, "GEXBA000003", 1, customerID, "pending", 900, "2025-07-01 10:00:00")
+	newestID := seedShipment(t, db, "GEXBA000004", 1, customerID, "pending", 900, "2025-07-01 11:00:00")
+	takenID := seedShipment(t, db, "GEXBA000005", 1, customerID, "pending", 900, "2025-07-01 07:00:00")
+	seedShipment(t, db, "GEXBA000006", 1, customerID, "delivered", 900, "2025-07-01 06:00:00")
+	seedShipment(t, db, "GEXBA000007", 2, customerID, "pending", 900, "2025-07-01 06:00:00")
+
+	_, err = db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, existingID)
+	assert.NoError(t, err)
+	_, err = db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", otherDriverID, takenID)
+	assert.NoError(t, err)
+
+	assignBatch := func(body string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(driverID)
+		req := httptest.NewRequest("POST", "/api/drivers/"+id+"/assign-batch", bytes.NewBufferString(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.AssignBatch(rr, req)
+		return rr
+	}
+
+	t.Run("assigns the oldest unassigned pending shipments up to the cap", func(t *testing.T) {
+		rr := assignBatch(`{"zone_id": 1, "limit": 5}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var assigned []models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &assigned))
+		var ids []int
+		for _, s := range assigned {
+			ids = append(ids, s.ID)
+			if assert.NotNil(t, s.DriverID) {
+				assert.Equal(t, driverID, *s.DriverID)
+			}
+		}
+		assert.Equal(t, []int{oldestID, secondID}, ids)
+
+		var unassigned *int
+		db.QueryRow("SELECT driver_id FROM shipments WHERE id = $1", newestID).Scan(&unassigned)
+		assert.Nil(t, unassigned)
+	})
+
+	t.Run("driver at capacity", func(t *testing.T) {
+		rr := assignBatch(`{"zone_id": 1}`)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("limit caps the batch", func(t *testing.T) {
+		_, err := db.Exec("UPDATE driver_profiles SET max_concurrent_shipments = 10 WHERE user_id = $1", driverID)
+		assert.NoError(t, err)
+
+		rr := assignBatch(`{"zone_id": 2, "limit": 1}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var assigned []models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &assigned))
+		assert.Len(t, assigned, 1)
+	})
+
+	t.Run("unknown zone", func(t *testing.T) {
+		rr := assignBatch(`{"zone_id": 999}`)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestDispatchHandler_PriorityFirst(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDispatchHandler(db.DB, 10)
+	driverHandler := handlers.NewDriverHandler(db.DB)
+	customerID := createTestUser(t, db, "Priority Shipper", "priorityshipper@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Priority Driver", "prioritydriver@goexpress.com", "driver")
+
+	standardID := seedShipment(t, db, "GEX9A000001", 1, customerID, "pending", 900, "2025-07-01 08:00:00")
+	expressID := seedShipment(t, db, "GEX9A000002", 1, customerID, "pending", 900, "2025-07-01 09:00:00")
+	overnightID := seedShipment(t, db, "GEX9A000003", 1, customerID, "pending", 900, "2025-07-01 10:00:00")
+	olderExpressID := seedShipment(t, db, "GEX9A000004", 1, customerID, "pending", 900, "2025-07-01 07:00:00")
+	_, err := db.Exec(`
+		UPDATE shipments SET priority = CASE id WHEN $1 THEN 'express' WHEN $2 THEN 'overnight' WHEN $3 THEN 'express' END
+		WHERE id IN ($1, $2, $3)`,
+		expressID, overnightID, olderExpressID,
+	)
+	assert.NoError(t, err)
+
+	ids := func(shipments []models.Shipment) []int {
+		var ids []int
+		for _, s := range shipments {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	id := strconv.Itoa(driverID)
+
+	t.Run("batch assignment takes higher priorities first", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/drivers/"+id+"/assign-batch", bytes.NewBufferString(`{"zone_id": 1, "limit": 3}`))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.AssignBatch(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var assigned []models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &assigned))
+		assert.Equal(t, []int{overnightID, olderExpressID, expressID}, ids(assigned))
+	})
+
+	t.Run("manifest lists higher priorities first", func(t *testing.T) {
+		_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, standardID)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/api/drivers/"+id+"/shipments", nil)
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		driverHandler.GetDriverShipments(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var manifest []models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &manifest))
+		assert.Equal(t, []int{overnightID, expressID, olderExpressID, standardID}, ids(manifest))
+	})
+}
+
+func TestDispatchHandler_OffboardDriver(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDispatchHandler(db.DB, 5)
+	handler.SetAuditLog(audit.NewLog(db.DB))
+	driverHandler := handlers.NewDriverHandler(db.DB)
+	customerID := createTestUser(t, db, "Offboard Client", "offboardclient@goexpress.com", "client")
+	leavingID := createTestUser(t, db, "Leaving Driver", "leaving@goexpress.com", "driver")
+	takeoverID := createTestUser(t, db, "Takeover Driver", "takeover@goexpress.com", "driver")
+
+	var openIDs []int
+	for _, tracking := range []string{"GEX0FF00001", "GEX0FF00002"} {
+		id := seedShipment(t, db, tracking, 1, customerID, "in_transit", 1000, "2025-07-01 09:00:00")
+		openIDs = append(openIDs, id)
+	}
+	deliveredID := seedShipment(t, db, "GEX0FF00003", 1, customerID, "delivered", 1000, "2025-06-01 09:00:00")
+	_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = ANY($2)", leavingID, pq.Array(append(openIDs, deliveredID)))
+	assert.NoError(t, err)
+
+	driverOf := func(shipmentID int) *int {
+		var driverID *int
+		db.QueryRow("SELECT driver_id FROM shipments WHERE id = $1", shipmentID).Scan(&driverID)
+		return driverID
+	}
+
+	t.Run("deleting a driver with open shipments is blocked", func(t *testing.T) {
+		id := strconv.Itoa(leavingID)
+		req := httptest.NewRequest("DELETE", "/api/drivers/"+id, nil)
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		driverHandler.DeleteDriver(rr, req)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("unavailable without an audit log", func(t *testing.T) {
+		id := strconv.Itoa(leavingID)
+		body, _ := json.Marshal(models.OffboardDriverRequest{ReassignTo: &takeoverID})
+		req := httptest.NewRequest("POST", "/api/drivers/"+id+"/offboard", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handlers.NewDispatchHandler(db.DB, 5).OffboardDriver(rr, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	})
+
+	t.Run("shipments are not handed to an inactive driver", func(t *testing.T) {
+		inactiveID := createTestUser(t, db, "Inactive Driver", "inactive-takeover@goexpress.com", "driver")
+		_, err := db.Exec("UPDATE users SET is_active = false WHERE id = $1", inactiveID)
+		assert.NoError(t, err)
+
+		id := strconv.Itoa(leavingID)
+		body, _ := json.Marshal(models.OffboardDriverRequest{ReassignTo: &inactiveID})
+		req := httptest.NewRequest("POST", "/api/drivers/"+id+"/offboard", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.OffboardDriver(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+
+		shipmentID := strconv.Itoa(openIDs[0])
+		body, _ = json.Marshal(models.AssignDriverRequest{DriverID: inactiveID})
+		req = httptest.NewRequest("POST", "/api/shipments/"+shipmentID+"/assign", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": shipmentID})
+		rr = httptest.NewRecorder()
+		handler.AssignDriver(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+
+		for _, shipmentID := range openIDs {
+			if assert.NotNil(t, driverOf(shipmentID)) {
+				assert.Equal(t, leavingID, *driverOf(shipmentID))
+			}
+		}
+	})
+
+	t.Run("offboarding reassigns open shipments", func(t *testing.T) {
+		id := strconv.Itoa(leavingID)
+		body, _ := json.Marshal(models.OffboardDriverRequest{ReassignTo: &takeoverID})
+		req := httptest.NewRequest("POST", "/api/drivers/"+id+"/offboard", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.OffboardDriver(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.OffboardDriverResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Len(t, response.Shipments, 2)
+
+		for _, shipmentID := range openIDs {
+			if assert.NotNil(t, driverOf(shipmentID)) {
+				assert.Equal(t, takeoverID, *driverOf(shipmentID))
+			}
+			var updates int
+			db.QueryRow("SELECT COUNT(*) FROM tracking_updates WHERE shipment_id = $1 AND status IN ('reassigned', 'unassigned')", shipmentID).Scan(&updates)
+			assert.Zero(t, updates, "offboarding stays off the customer's timeline")
+		}
+
+		var audited int
+		db.QueryRow("SELECT COUNT(*) FROM audit_log WHERE actor_id = 1 AND user_id = $1 AND action = $2",
+			leavingID, "offboard_driver "+id+"->"+strconv.Itoa(takeoverID)).Scan(&audited)
+		assert.Equal(t, 1, audited)
+		// Delivered history stays with the driver who delivered it
+		if assert.NotNil(t, driverOf(deliveredID)) {
+			assert.Equal(t, leavingID, *driverOf(deliveredID))
+		}
+
+		var active bool
+		db.QueryRow("SELECT is_active FROM users WHERE id = $1", leavingID).Scan(&active)
+		assert.False(t, active)
+	})
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/textproto"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const documentTestSecret = "test-secret"
+
+func TestDocumentHandler_SignedURL(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDocumentHandler(db.DB, t.TempDir(), documentTestSecret)
+	clientID := createTestUser(t, db, "Document Client", "documents@goexpress.com", "client")
+	shipmentID := seedShipment(t, db, "GEX0000D001", 1, clientID, "delivered", 10, "2025-03-01 09:00:00")
+	id := strconv.Itoa(shipmentID)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "proof.txt")
+	assert.NoError(t, err)
+	part.Write([]byte("signed for by J. Doe"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/shipments/"+id+"/documents", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req = mux.SetURLVars(withClaims(req, clientID, "client"), map[string]string{"id": id})
+	rr := httptest.NewRecorder()
+	handler.UploadDocument(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var doc models.ShipmentDocument
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc))
+	docID := strconv.Itoa(doc.ID)
+
+	req = httptest.NewRequest("GET", "/api/shipments/"+id+"/documents/"+docID+"/url", nil)
+	req = mux.SetURLVars(withClaims(req, clientID, "client"), map[string]string{"id": id, "docId": docID})
+	rr = httptest.NewRecorder()
+	handler.GetDocumentURL(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var signed models.DocumentURLResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &signed))
+	assert.True(t, strings.HasPrefix(signed.URL, "/files/"))
+
+	token := strings.TrimPrefix(signed.URL, "/files/")
+	req = mux.SetURLVars(httptest.NewRequest("GET", signed.URL, nil), map[string]string{"token": token})
+	rr = httptest.NewRecorder()
+	handler.ServeFile(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "signed for by J. Doe", rr.Body.String())
+
+	// Someone else's shipment looks the same as one that does not exist
+	strangerID := createTestUser(t, db, "Document Stranger", "docstranger@goexpress.com", "client")
+	for _, shipment := range []string{id, "99999"} {
+		req = httptest.NewRequest("GET", "/api/shipments/"+shipment+"/documents/"+docID+"/url", nil)
+		req = mux.SetURLVars(withClaims(req, strangerID, "client"), map[string]string{"id": shipment, "docId": docID})
+		rr = httptest.NewRecorder()
+		handler.GetDocumentURL(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestDocumentHandler_ServeFileContentTypes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDocumentHandler(db.DB, t.TempDir(), documentTestSecret)
+	clientID := createTestUser(t, db, "Document Client", "documents@goexpress.com", "client")
+	shipmentID := seedShipment(t, db, "GEX0000D002", 1, clientID, "delivered", 10, "2025-03-01 09:00:00")
+	id := strconv.Itoa(shipmentID)
+
+	serve := func(fileName, contentType, content string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="file"; filename="`+fileName+`"`)
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		assert.NoError(t, err)
+		part.Write([]byte(content))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/documents", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req = mux.SetURLVars(withClaims(req, clientID, "client"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.UploadDocument(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var doc models.ShipmentDocument
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc))
+
+		token := utils.SignFileToken(doc.ID, time.Now().Add(time.Minute), documentTestSecret)
+		req = mux.SetURLVars(httptest.NewRequest("GET", "/files/"+token, nil), map[string]string{"token": token})
+		rr = httptest.NewRecorder()
+		handler.ServeFile(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr
+	}
+
+	t.Run("allowed types are shown inline", func(t *testing.T) {
+		rr := serve("label.pdf", "application/pdf", "%PDF-1.4")
+		assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(rr.Header().Get("Content-Disposition"), "inline;"))
+		assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+	})
+
+	t.Run("other types are downloaded as attachments", func(t *testing.T) {
+		for _, contentType := range []string{"text/html", "image/svg+xml"} {
+			rr := serve("page.html", contentType, "<script>alert(1)</script>")
+			assert.Equal(t, "application/octet-stream", rr.Header().Get("Content-Type"))
+			assert.True(t, strings.HasPrefix(rr.Header().Get("Content-Disposition"), "attachment;"))
+			assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+		}
+	})
+}
+
+func TestDocumentHandler_ServeFileRejectsBadTokens(t *testing.T) {
+	handler := handlers.NewDocumentHandler(nil, t.TempDir(), documentTestSecret)
+
+	serve := func(token string) int {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/files/"+token, nil), map[string]string{"token": token})
+		rr := httptest.NewRecorder()
+		handler.ServeFile(rr, req)
+		return rr.Code
+	}
+
+	t.Run("expired token", func(t *testing.T) {
+		token := utils.SignFileToken(1, time.Now().Add(-time.Minute), documentTestSecret)
+		assert.Equal(t, http.StatusGone, serve(token))
+	})
+
+	t.Run("tampered token", func(t *testing.T) {
+		token := utils.SignFileToken(1, time.Now().Add(time.Minute), documentTestSecret)
+		assert.Equal(t, http.StatusForbidden, serve("2"+strings.TrimPrefix(token, "1")))
+	})
+
+	t.Run("token signed with another secret", func(t *testing.T) {
+		token := utils.SignFileToken(1, time.Now().Add(time.Minute), "other-secret")
+		assert.Equal(t, http.StatusForbidden, serve(token))
+	})
+}
@@ -0,0 +1,475 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriverHandler_Shifts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDriverHandler(db.DB)
+	driverID := createTestUser(t, db, "Shift Driver", "shift@goexpress.com", "driver")
+	otherDriverID := createTestUser(t, db, "Other Driver", "otherdriver@goexpress.com", "driver")
+
+	shiftRequest := func(action func(http.ResponseWriter, *http.Request), path string, userID int, role string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(driverID)
+		req := httptest.NewRequest("POST", "/api/drivers/"+id+"/"+path, nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		action(rr, req)
+		return rr
+	}
+
+	driverStatus := func() string {
+		var status string
+		db.QueryRow("SELECT driver_status FROM users WHERE id = $1", driverID).Scan(&status)
+		return status
+	}
+
+	assert.Equal(t, "offline", driverStatus())
+
+	t.Run("other drivers cannot check in for someone else", func(t *testing.T) {
+		rr := shiftRequest(handler.CheckIn, "check-in", otherDriverID, "driver")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("check-in marks the driver available", func(t *testing.T) {
+		rr := shiftRequest(handler.CheckIn, "check-in", driverID, "driver")
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var shift models.DriverShift
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shift))
+		assert.Equal(t, driverID, shift.DriverID)
+		assert.Nil(t, shift.EndedAt)
+		assert.Equal(t, "available", driverStatus())
+	})
+
+	t.Run("double check-in is rejected", func(t *testing.T) {
+		rr := shiftRequest(handler.CheckIn, "check-in", driverID, "driver")
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("admin can check the driver out", func(t *testing.T) {
+		rr := shiftRequest(handler.CheckOut, "check-out", 1, "admin")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shift models.DriverShift
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shift))
+		assert.NotNil(t, shift.EndedAt)
+		assert.Equal(t, "offline", driverStatus())
+	})
+
+	t.Run("only checked-in drivers are listed as available", func(t *testing.T) {
+		shiftRequest(handler.CheckIn, "check-in", driverID, "driver")
+
+		req := withClaims(httptest.NewRequest("GET", "/api/drivers?status=available", nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.GetDrivers(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var drivers []models.Driver
+		decodePage(t, rr.Body.Bytes(), &drivers)
+		assert.Len(t, drivers, 1)
+		assert.Equal(t, driverID, drivers[0].ID)
+	})
+}
+
+func TestDriverHandler_GetDriversByVehicleType(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDriverHandler(db.DB)
+	vanID := createTestUser(t, db, "Van Driver", "van@goexpress.com", "driver")
+	motoID := createTestUser(t, db, "Moto Driver", "moto@goexpress.com", "driver")
+	offlineVanID := createTestUser(t, db, "Offline Van Driver", "offlinevan@goexpress.com", "driver")
+
+	for id, vehicle := range map[int]string{vanID: "van", motoID: "motorcycle", offlineVanID: "van"} {
+		_, err := db.Exec(`INSERT INTO driver_profiles (user_id, vehicle_type) VALUES ($1, $2)`, id, vehicle)
+		assert.NoError(t, err)
+	}
+	_, err := db.Exec("UPDATE users SET driver_status = 'available' WHERE id IN ($1, $2)", vanID, motoID)
+	assert.NoError(t, err)
+
+	getDrivers := func(query string) *httptest.ResponseRecorder {
+		req := withClaims(httptest.NewRequest("GET", "/api/drivers?"+query, nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.GetDrivers(rr, req)
+		return rr
+	}
+
+	driverIDs := func(rr *httptest.ResponseRecorder) []int {
+		var drivers []models.Driver
+		decodePage(t, rr.Body.Bytes(), &drivers)
+		var ids []int
+		for _, d := range drivers {
+			assert.Equal(t, "van", d.VehicleType)
+			ids = append(ids, d.ID)
+		}
+		return ids
+	}
+
+	t.Run("filters by vehicle type", func(t *testing.T) {
+		rr := getDrivers("vehicle_type=van")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.ElementsMatch(t, []int{vanID, offlineVanID}, driverIDs(rr))
+	})
+
+	t.Run("combines with the status filter", func(t *testing.T) {
+		rr := getDrivers("vehicle_type=van&status=available")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.ElementsMatch(t, []int{vanID}, driverIDs(rr))
+	})
+
+	t.Run("rejects unknown vehicle types", func(t *testing.T) {
+		rr := getDrivers("vehicle_type=spaceship")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestDriverHandler_GetDriverEarnings(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDriverHandler(db.DB)
+	handler.SetDefaultCommissionRate(0.1)
+	clientID := createTestUser(t, db, "Earnings Client", "earningsclient@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Earnings Driver", "earnings@goexpress.com", "driver")
+	otherDriverID := createTestUser(t, db, "Other Earner", "otherearner@goexpress.com", "driver")
+
+	deliver := func(tracking string, cost float64, deliveredAt string) {
+		id := seedShipment(t, db, tracking, 1, clientID, "in_transit", cost, "2025-06-01 09:00:00")
+		_, err := db.Exec("UPDATE shipments SET driver_id = $1, status = 'delivered', delivered_at = $2 WHERE id = $3",
+			driverID, deliveredAt, id)
+		assert.NoError(t, err)
+	}
+	deliver("GEXEARN0001", 1000, "2025-07-02 10:00:00")
+	deliver("GEXEARN0002", 2500, "2025-07-15 16:00:00")
+	deliver("GEXEARN0003", 4000, "2025-08-01 09:00:00") // outside the period
+	openID := seedShipment(t, db, "GEXEARN0004", 1, clientID, "in_transit", 3000, "2025-07-03 09:00:00")
+	_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, openID)
+	assert.NoError(t, err)
+
+	getEarnings := func(userID int, role string) (int, models.DriverEarnings) {
+		id := strconv.Itoa(driverID)
+		req := httptest.NewRequest("GET", "/api/drivers/"+id+"/earnings?from=2025-07-01&to=2025-07-31", nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetDriverEarnings(rr, req)
+
+		var earnings models.DriverEarnings
+		json.Unmarshal(rr.Body.Bytes(), &earnings)
+		return rr.Code, earnings
+	}
+
+	t.Run("driver sees commission on delivered cost in the period", func(t *testing.T) {
+		code, earnings := getEarnings(driverID, "driver")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, earnings.Deliveries)
+		assert.Equal(t, 3500.0, earnings.DeliveredCost)
+		assert.Equal(t, 0.1, earnings.CommissionRate)
+		assert.Equal(t, 350.0, earnings.Earnings)
+	})
+
+	t.Run("per-driver rate overrides the default", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO driver_profiles (user_id, commission_rate) VALUES ($1, 0.15)", driverID)
+		assert.NoError(t, err)
+
+		code, earnings := getEarnings(1, "admin")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 0.15, earnings.CommissionRate)
+		assert.Equal(t, 525.0, earnings.Earnings)
+	})
+
+	t.Run("other drivers cannot see them", func(t *testing.T) {
+		code, _ := getEarnings(otherDriverID, "driver")
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+}
+
+func TestDriverHandler_GetDriversPagination(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDriverHandler(db.DB)
+	var availableIDs []int
+	for _, name := range []string{"Ama", "Bintou", "Cheick", "Djeneba", "Eli"} {
+		id := createTestUser(t, db, name+" Driver", strings.ToLower(name)+"@goexpress.com", "driver")
+		_, err := db.Exec("UPDATE users SET driver_status = 'available' WHERE id = $1", id)
+		assert.NoError(t, err)
+		availableIDs = append(availableIDs, id)
+	}
+	createTestUser(t, db, "Offline Driver", "offline@goexpress.com", "driver")
+
+	getDrivers := func(query string) (int, []models.Driver, models.PaginatedResponse) {
+		req := withClaims(httptest.NewRequest("GET", "/api/drivers?"+query, nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.GetDrivers(rr, req)
+
+		var drivers []models.Driver
+		if rr.Code != http.StatusOK {
+			return rr.Code, nil, models.PaginatedResponse{}
+		}
+		page := decodePage(t, rr.Body.Bytes(), &drivers)
+		return rr.Code, drivers, page
+	}
+
+	t.Run("pages through filtered drivers", func(t *testing.T) {
+		var seen []int
+		for pageNumber := 1; pageNumber <= 3; pageNumber++ {
+			code, drivers, page := getDrivers("status=available&limit=2&page=" + strconv.Itoa(pageNumber))
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, 5, page.Total)
+			assert.Equal(t, 3, page.TotalPages)
+			assert.Equal(t, pageNumber, page.Page)
+			for _, d := range drivers {
+				assert.Equal(t, "available", d.Status)
+				seen = append(seen, d.ID)
+			}
+		}
+		assert.ElementsMatch(t, availableIDs, seen)
+	})
+
+	t.Run("searches by name or email", func(t *testing.T) {
+		code, drivers, page := getDrivers("search=djeneba")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 1, page.Total)
+		if assert.Len(t, drivers, 1) {
+			assert.Equal(t, availableIDs[3], drivers[0].ID)
+		}
+	})
+
+	t.Run("falls back to the first page for an invalid page", func(t *testing.T) {
+		code, _, page := getDrivers("page=0&limit=abc")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 1, page.Page)
+		assert.Equal(t, utils.DefaultPageSize, page.Limit)
+	})
+}
+
+func TestDriverHandler_GetMySummary(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDriverHandler(db.DB)
+	clientID := createTestUser(t, db, "Summary Client", "summaryclient@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Summary Driver", "summary@goexpress.com", "driver")
+	otherDriverID := createTestUser(t, db, "Busy Driver", "busydriver@goexpress.com", "driver")
+
+	assignTo := func(id, driverID int) {
+		_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, id)
+		assert.NoError(t, err)
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	assignTo(seedShipment(t, db, "GEX5UM00001", 1, clientID, "pending", 1000, now), driverID)
+	assignTo(seedShipment(t, db, "GEX5UM00002", 1, clientID, "in_transit", 1000, now), driverID)
+	assignTo(seedShipment(t, db, "GEX5UM00003", 1, clientID, "in_transit", 1000, now), driverID)
+	assignTo(seedShipment(t, db, "GEX5UM00004", 1, clientID, "in_transit", 1000, now), otherDriverID)
+	deliveredToday := seedShipment(t, db, "GEX5UM00005", 1, clientID, "in_transit", 1000, now)
+	assignTo(deliveredToday, driverID)
+	_, err := db.Exec("UPDATE shipments SET status = 'delivered' WHERE id = $1", deliveredToday)
+	assert.NoError(t, err)
+	deliveredEarlier := seedShipment(t, db, "GEX5UM00006", 1, clientID, "in_transit", 1000, "2025-01-01 09:00:00")
+	assignTo(deliveredEarlier, driverID)
+	_, err = db.Exec("UPDATE shipments SET status = 'delivered', delivered_at = '2025-01-02 09:00:00' WHERE id = $1", deliveredEarlier)
+	assert.NoError(t, err)
+
+	getSummary := func(userID int, role string) *httptest.ResponseRecorder {
+		req := withClaims(httptest.NewRequest("GET", "/api/drivers/me/summary", nil), userID, role)
+		rr := httptest.NewRecorder()
+		handler.GetMySummary(rr, req)
+		return rr
+	}
+
+	t.Run("counts the driver's own shipments", func(t *testing.T) {
+		rr := getSummary(driverID, "driver")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var summary models.DriverSummary
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &summary))
+		assert.Equal(t, 3, summary.Open)
+		assert.Equal(t, 2, summary.InTransit)
+		assert.Equal(t, 1, summary.DeliveredToday)
+		assert.Equal(t, 4.5, summary.Rating)
+	})
+
+	t.Run("only drivers have a summary", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("GET", "/api/drivers/me/summary", nil), clientID, "client")
+		rr := httptest.NewRecorder()
+		authorized("GET", "/api/drivers/me/summary", handler.GetMySummary).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestDriverHandler_DriverShipments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDriverHandler(db.DB)
+	customerID := createTestUser(t, db, "Manifest Client", "manifest@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Manifest Driver", "manifest-driver@goexpress.com", "driver")
+	otherDriverID := createTestUser(t, db, "Other Manifest Driver", "manifest-other@goexpress.com", "driver")
+
+	mineID := seedShipment(t, db, "GEX0DA00001", 1, customerID, "in_transit", 900, "2025-07-01 09:00:00")
+	theirsID := seedShipment(t, db, "GEX0DA00002", 1, customerID, "in_transit", 900, "2025-07-01 10:00:00")
+	_, err := db.Exec("UPDATE shipments SET driver_id = CASE id WHEN $1 THEN $2 ELSE $3 END WHERE id IN ($1, $4)",
+		mineID, driverID, otherDriverID, theirsID)
+	assert.NoError(t, err)
+
+	shipmentIDs := func(rr *httptest.ResponseRecorder) []int {
+		var shipments []models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipments))
+		var ids []int
+		for _, s := range shipments {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	byID := func(driverPath, userID int, role string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(driverPath)
+		req := httptest.NewRequest("GET", "/api/drivers/"+id+"/shipments", nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetDriverShipments(rr, req)
+		return rr
+	}
+
+	t.Run("self endpoint lists only the caller's shipments", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("GET", "/api/drivers/me/shipments", nil), driverID, "driver")
+		rr := httptest.NewRecorder()
+		handler.GetMyShipments(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, []int{mineID}, shipmentIDs(rr))
+	})
+
+	t.Run("drivers may read their own shipments by id", func(t *testing.T) {
+		rr := byID(driverID, driverID, "driver")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, []int{mineID}, shipmentIDs(rr))
+	})
+
+	t.Run("drivers cannot read another driver's shipments", func(t *testing.T) {
+		rr := byID(otherDriverID, driverID, "driver")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "GEX0DA00002")
+	})
+
+	t.Run("admins may read any driver's shipments", func(t *testing.T) {
+		rr := byID(otherDriverID, 1, "admin")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, []int{theirsID}, shipmentIDs(rr))
+	})
+
+	t.Run("self endpoint is for drivers only", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := withClaims(httptest.NewRequest("GET", "/api/drivers/me/shipments", nil), customerID, "client")
+		authorized("GET", "/api/drivers/me/shipments", handler.GetMyShipments).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestDriverHandler_UpdateMyStatus(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewDriverHandler(db.DB)
+	dispatch := handlers.NewDispatchHandler(db.DB, 5)
+	driverID := createTestUser(t, db, "Break Driver", "break@goexpress.com", "driver")
+	clientID := createTestUser(t, db, "Break Client", "breakclient@goexpress.com", "client")
+
+	setStatus := func(userID int, role, status string) *httptest.ResponseRecorder {
+		body := strings.NewReader(`{"status":"` + status + `"}`)
+		req := withClaims(httptest.NewRequest("PUT", "/api/drivers/me/status", body), userID, role)
+		rr := httptest.NewRecorder()
+		handler.UpdateMyStatus(rr, req)
+		return rr
+	}
+
+	autoAssign := func(shipmentID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/auto-assign", nil)
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		dispatch.AutoAssign(rr, req)
+		return rr
+	}
+
+	shipmentID := seedShipment(t, db, "GEX0EB00001", 1, clientID, "pending", 7, "2025-07-01 10:00:00")
+
+	openShifts := func() int {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM driver_shifts WHERE driver_id = $1 AND ended_at IS NULL", driverID).Scan(&n)
+		return n
+	}
+
+	t.Run("checked-out drivers cannot make themselves available", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, setStatus(driverID, "driver", "available").Code)
+		assert.Equal(t, http.StatusConflict, setStatus(driverID, "driver", "busy").Code)
+		assert.Equal(t, http.StatusConflict, autoAssign(shipmentID).Code)
+	})
+
+	id := strconv.Itoa(driverID)
+	req := mux.SetURLVars(withClaims(httptest.NewRequest("POST", "/api/drivers/"+id+"/check-in", nil), driverID, "driver"), map[string]string{"id": id})
+	rr := httptest.NewRecorder()
+	handler.CheckIn(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	t.Run("busy drivers are not auto-assigned", func(t *testing.T) {
+		rr := setStatus(driverID, "driver", "busy")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var driver models.Driver
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &driver))
+		assert.Equal(t, driverID, driver.ID)
+		assert.Equal(t, "busy", driver.Status)
+
+		assert.Equal(t, http.StatusConflict, autoAssign(shipmentID).Code)
+	})
+
+	t.Run("available drivers are auto-assigned", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, setStatus(driverID, "driver", "available").Code)
+
+		rr := autoAssign(shipmentID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		if assert.NotNil(t, shipment.DriverID) {
+			assert.Equal(t, driverID, *shipment.DriverID)
+		}
+	})
+
+	t.Run("rejects unknown statuses", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, setStatus(driverID, "driver", "napping").Code)
+	})
+
+	t.Run("going offline ends the shift", func(t *testing.T) {
+		assert.Equal(t, 1, openShifts())
+		rr := setStatus(driverID, "driver", "offline")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 0, openShifts())
+
+		var driver models.Driver
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &driver))
+		assert.Equal(t, "offline", driver.Status)
+	})
+
+	t.Run("drivers only", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("PUT", "/api/drivers/me/status", strings.NewReader(`{"status":"offline"}`)), 1, "admin")
+		rr := httptest.NewRecorder()
+		authorized("PUT", "/api/drivers/me/status", handler.UpdateMyStatus).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
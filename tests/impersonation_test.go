@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"goexpress-api/audit"
+	"goexpress-api/handlers"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthHandler_Impersonate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	secret := "test-secret"
+	handler := handlers.NewAuthHandler(db.DB, secret, "test-refresh-secret")
+	handler.SetAuditLog(audit.NewLog(db.DB))
+
+	adminID := createTestUser(t, db, "Support Admin", "support@goexpress.com", "admin")
+	otherAdminID := createTestUser(t, db, "Other Admin", "otheradmin@goexpress.com", "admin")
+	clientID := createTestUser(t, db, "Impersonated Client", "impersonated@goexpress.com", "client")
+
+	impersonate := func(userID int, role string, targetID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(targetID)
+		req := httptest.NewRequest("POST", "/api/admin/impersonate/"+id, nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"userId": id})
+		rr := httptest.NewRecorder()
+		handler.Impersonate(rr, req)
+		return rr
+	}
+
+	auditEntries := func(action string) (count, actorID, userID int) {
+		db.QueryRow(`
+			SELECT COUNT(*), COALESCE(MAX(actor_id), 0), COALESCE(MAX(user_id), 0)
+			FROM audit_log WHERE action = $1`, action,
+		).Scan(&count, &actorID, &userID)
+		return
+	}
+
+	var token string
+	t.Run("issues a short-lived token naming the admin", func(t *testing.T) {
+		rr := impersonate(adminID, "admin", clientID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.ImpersonationResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, clientID, response.User.ID)
+		token = response.Token
+
+		claims, err := utils.ValidateJWT(token, secret)
+		if assert.NoError(t, err) {
+			assert.Equal(t, clientID, claims.UserID)
+			assert.Equal(t, "client", claims.Role)
+			assert.Equal(t, adminID, claims.ImpersonatorID)
+			assert.WithinDuration(t, time.Now().Add(15*time.Minute), claims.ExpiresAt.Time, time.Minute)
+		}
+
+		count, actorID, userID := auditEntries(audit.ActionImpersonate)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, adminID, actorID)
+		assert.Equal(t, clientID, userID)
+	})
+
+	t.Run("admins cannot be impersonated", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, impersonate(adminID, "admin", otherAdminID).Code)
+	})
+
+	t.Run("only admins can impersonate", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, impersonate(clientID, "client", clientID).Code)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, impersonate(adminID, "admin", 999999).Code)
+	})
+
+	t.Run("actions are audited with the admin as actor", func(t *testing.T) {
+		router := mux.NewRouter()
+		router.Use(middleware.AuthMiddleware(secret))
+		router.Use(middleware.AuditImpersonation(audit.NewLog(db.DB)))
+		var actorID int
+		router.HandleFunc("/api/shipments", func(w http.ResponseWriter, r *http.Request) {
+			actorID = middleware.ActorID(r)
+			w.WriteHeader(http.StatusCreated)
+		}).Methods("POST")
+
+		serve := func(token string) {
+			req := httptest.NewRequest("POST", "/api/shipments", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		serve(token)
+		assert.Equal(t, adminID, actorID)
+		count, auditedActor, auditedUser := auditEntries("POST /api/shipments")
+		assert.Equal(t, 1, count)
+		assert.Equal(t, adminID, auditedActor)
+		assert.Equal(t, clientID, auditedUser)
+
+		var status int
+		db.QueryRow("SELECT status_code FROM audit_log WHERE action = 'POST /api/shipments'").Scan(&status)
+		assert.Equal(t, http.StatusCreated, status)
+
+		// The client acting as themselves is not audited
+		ownToken, _ := utils.GenerateJWT(clientID, "impersonated@goexpress.com", "client", secret)
+		serve(ownToken)
+		assert.Equal(t, clientID, actorID)
+		count, _, _ = auditEntries("POST /api/shipments")
+		assert.Equal(t, 1, count)
+	})
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"goexpress-api/mailer"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyMailer fails the first send to each address in failFirst, then
+// delivers, recording what it delivered.
+type flakyMailer struct {
+	mu        sync.Mutex
+	failFirst map[string]bool
+	sent      chan mailer.Message
+}
+
+func (m *flakyMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	fail := m.failFirst[msg.To]
+	m.failFirst[msg.To] = false
+	m.mu.Unlock()
+
+	if fail {
+		return errors.New("connection reset")
+	}
+	m.sent <- msg
+	return nil
+}
+
+func TestMailQueue_RetriesDoNotBlockOtherMessages(t *testing.T) {
+	mail := &flakyMailer{failFirst: map[string]bool{"slow@goexpress.com": true}, sent: make(chan mailer.Message, 2)}
+	queue := mailer.NewQueue(mail, 10, 3, 500*time.Millisecond)
+	queue.Start()
+
+	assert.NoError(t, queue.Send(mailer.Message{To: "slow@goexpress.com", Subject: "First"}))
+	assert.NoError(t, queue.Send(mailer.Message{To: "fast@goexpress.com", Subject: "Second"}))
+
+	select {
+	case msg := <-mail.sent:
+		assert.Equal(t, "fast@goexpress.com", msg.To)
+	case <-time.After(250 * time.Millisecond):
+		t.Fatal("second message waited for the first one's retry")
+	}
+	assert.Equal(t, 1, queue.Stats().Pending)
+
+	select {
+	case msg := <-mail.sent:
+		assert.Equal(t, "slow@goexpress.com", msg.To)
+	case <-time.After(2 * time.Second):
+		t.Fatal("first message was not retried")
+	}
+}
+
+func TestMailQueue_Stop(t *testing.T) {
+	t.Run("drains queued messages and retries", func(t *testing.T) {
+		mail := &flakyMailer{failFirst: map[string]bool{"retry@goexpress.com": true}, sent: make(chan mailer.Message, 2)}
+		queue := mailer.NewQueue(mail, 10, 3, 20*time.Millisecond)
+		queue.Start()
+
+		assert.NoError(t, queue.Send(mailer.Message{To: "retry@goexpress.com"}))
+		assert.NoError(t, queue.Send(mailer.Message{To: "queued@goexpress.com"}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		assert.NoError(t, queue.Stop(ctx))
+		assert.Equal(t, int64(2), queue.Stats().Sent)
+
+		assert.ErrorIs(t, queue.Send(mailer.Message{To: "late@goexpress.com"}), mailer.ErrQueueStopped)
+	})
+
+	t.Run("gives up at the deadline", func(t *testing.T) {
+		queue := mailer.NewQueue(failingMailer{}, 10, 3, time.Hour)
+		queue.Start()
+
+		assert.NoError(t, queue.Send(mailer.Message{To: "outage@goexpress.com"}))
+		assert.Eventually(t, func() bool { return queue.Stats().Failed == 0 && queue.Stats().Pending == 1 }, time.Second, 5*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, queue.Stop(ctx), context.DeadlineExceeded)
+
+		assert.Eventually(t, func() bool { return queue.Stats().Failed == 1 }, time.Second, 5*time.Millisecond)
+		stats := queue.Stats()
+		assert.Equal(t, 0, stats.Pending)
+		if assert.Len(t, stats.RecentFailures, 1) {
+			assert.Equal(t, mailer.ErrQueueStopped.Error(), stats.RecentFailures[0].Error)
+			assert.Equal(t, 1, stats.RecentFailures[0].Attempts)
+		}
+	})
+}
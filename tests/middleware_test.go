@@ -0,0 +1,353 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goexpress-api/middleware"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	state := middleware.NewMaintenanceState(true, false)
+	handler := middleware.Maintenance(state, "/health")(http.HandlerFunc(okHandler))
+
+	serve := func(method, path string) int {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr.Code
+	}
+
+	t.Run("writes are blocked while reads pass", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, serve("POST", "/api/shipments"))
+		assert.Equal(t, http.StatusServiceUnavailable, serve("DELETE", "/api/zones/1"))
+		assert.Equal(t, http.StatusOK, serve("GET", "/api/zones"))
+	})
+
+	t.Run("blocking reads", func(t *testing.T) {
+		state.Set(true, true)
+		defer state.Set(true, false)
+
+		assert.Equal(t, http.StatusServiceUnavailable, serve("GET", "/api/zones"))
+		assert.Equal(t, http.StatusOK, serve("GET", "/health"))
+	})
+
+	t.Run("toggled off at runtime", func(t *testing.T) {
+		state.Set(false, false)
+		assert.Equal(t, http.StatusOK, serve("POST", "/api/shipments"))
+	})
+}
+
+func TestRequireReadyMiddleware(t *testing.T) {
+	var ready atomic.Bool
+	handler := middleware.RequireReady(ready.Load, "/health")(http.HandlerFunc(okHandler))
+
+	serve := func(path string) int {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/api/zones"))
+	assert.Equal(t, http.StatusOK, serve("/health"))
+
+	ready.Store(true)
+	assert.Equal(t, http.StatusOK, serve("/api/zones"))
+}
+
+func TestClientReadOnlyMiddleware(t *testing.T) {
+	handler := middleware.ClientReadOnly(http.HandlerFunc(okHandler))
+
+	serve := func(method, role string) int {
+		req := withClaims(httptest.NewRequest(method, "/api/shipments", nil), 1, role)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("client writes are blocked", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, serve("POST", "client"))
+		assert.Equal(t, http.StatusServiceUnavailable, serve("PUT", "client"))
+		assert.Equal(t, http.StatusServiceUnavailable, serve("DELETE", "client"))
+	})
+
+	t.Run("client reads pass", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("GET", "client"))
+	})
+
+	t.Run("admin and driver writes pass", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("POST", "admin"))
+		assert.Equal(t, http.StatusOK, serve("PUT", "driver"))
+	})
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	handler := middleware.RateLimit(middleware.NewRateLimiter(2, time.Minute))(http.HandlerFunc(okHandler))
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/shipments/track-batch", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, serve("198.51.100.7:5000").Code)
+	assert.Equal(t, http.StatusOK, serve("198.51.100.7:5001").Code)
+
+	rr := serve("198.51.100.7:5002")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, serve("203.0.113.9:5000").Code, "other clients have their own allowance")
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	largeJSON := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"payload": strings.Repeat("shipment ", 500)})
+	}
+	smallJSON := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}
+	largePDF := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(bytes.Repeat([]byte("%PDF"), 1000))
+	}
+
+	serve := func(handler http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/shipments", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		middleware.Compress(1024)(handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("large JSON is gzip-encoded when requested", func(t *testing.T) {
+		rr := serve(largeJSON, "gzip, deflate")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+		assert.Contains(t, rr.Header().Values("Vary"), "Accept-Encoding")
+
+		zr, err := gzip.NewReader(rr.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var decoded map[string]string
+		assert.NoError(t, json.NewDecoder(zr).Decode(&decoded))
+		assert.Equal(t, strings.Repeat("shipment ", 500), decoded["payload"])
+	})
+
+	t.Run("deflate when gzip is refused", func(t *testing.T) {
+		rr := serve(largeJSON, "gzip;q=0, deflate")
+		assert.Equal(t, "deflate", rr.Header().Get("Content-Encoding"))
+
+		zr, err := zlib.NewReader(rr.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var decoded map[string]string
+		assert.NoError(t, json.NewDecoder(zr).Decode(&decoded))
+		assert.Equal(t, strings.Repeat("shipment ", 500), decoded["payload"])
+	})
+
+	t.Run("not compressed without Accept-Encoding", func(t *testing.T) {
+		rr := serve(largeJSON, "")
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Contains(t, rr.Body.String(), "shipment shipment")
+	})
+
+	t.Run("small responses are left alone", func(t *testing.T) {
+		rr := serve(smallJSON, "gzip")
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, `{"ok":true}`, rr.Body.String())
+	})
+
+	t.Run("PDFs are left alone", func(t *testing.T) {
+		rr := serve(largePDF, "gzip")
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, 4000, rr.Body.Len())
+	})
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	handler := middleware.CORSMiddleware(300, []string{"X-Request-ID", "Retry-After"})(http.HandlerFunc(okHandler))
+
+	t.Run("preflight carries max-age and exposed headers", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/api/shipments", nil)
+		req.Header.Set("Origin", "https://app.goexpress.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, "300", rr.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, "X-Request-ID, Retry-After", rr.Header().Get("Access-Control-Expose-Headers"))
+	})
+
+	t.Run("actual responses expose headers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/zones", nil)
+		req.Header.Set("Origin", "https://app.goexpress.com")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+	})
+
+	t.Run("same-origin requests are left alone", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/zones", nil))
+		assert.Empty(t, rr.Header().Get("Access-Control-Expose-Headers"))
+	})
+}
+
+func TestAuthMiddleware_BearerScheme(t *testing.T) {
+	const secret = "bearer-test-secret"
+	token, err := utils.GenerateJWT(7, "bearer@goexpress.com", "client", secret)
+	assert.NoError(t, err)
+
+	serve := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/shipments", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rr := httptest.NewRecorder()
+		middleware.AuthMiddleware(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+			if assert.True(t, ok) {
+				assert.Equal(t, 7, claims.UserID)
+			}
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, serve("Bearer "+token).Code)
+	assert.Equal(t, http.StatusOK, serve("bearer "+token).Code, "the scheme is case-insensitive")
+
+	for _, header := range []string{
+		token,
+		"Token " + token,
+		"Bearer",
+		"Bearer ",
+		"Bearer  " + token,
+		"Bearer " + token + " ",
+		" Bearer " + token,
+	} {
+		rr := serve(header)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "header %q", header)
+		assert.Contains(t, rr.Body.String(), "expected: Bearer <token>", "header %q", header)
+	}
+
+	rr := serve("")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Authorization header required")
+}
+
+func TestAPIKeyAuthMiddleware(t *testing.T) {
+	serve := func(keys []string, key string) int {
+		req := httptest.NewRequest("POST", "/api/integrations/scan-events", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		middleware.APIKeyAuth(keys)(http.HandlerFunc(okHandler)).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	keys := []string{"carrier-one", "carrier-two"}
+	assert.Equal(t, http.StatusOK, serve(keys, "carrier-two"))
+	assert.Equal(t, http.StatusUnauthorized, serve(keys, ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(keys, "carrier-three"))
+	assert.Equal(t, http.StatusUnauthorized, serve(nil, "carrier-one"), "no keys configured")
+}
+
+func TestLoggingMiddleware_SlowRequest(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	router := mux.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(middleware.LoggingMiddleware(20 * time.Millisecond))
+	router.HandleFunc("/api/shipments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(target, requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("X-Request-ID", requestID)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("/api/shipments/7", "req-7")
+	assert.Equal(t, "req-7", rr.Header().Get("X-Request-ID"))
+	assert.Contains(t, logs.String(), "route=/api/shipments/{id} request_id=req-7")
+	assert.NotContains(t, logs.String(), "WARN")
+
+	serve("/api/shipments/8?slow=1", "req-8")
+	assert.Contains(t, logs.String(), "WARN slow request: GET /api/shipments/{id} took")
+	assert.Contains(t, logs.String(), "request_id=req-8")
+}
+
+func TestRequestIDMiddleware_GeneratesID(t *testing.T) {
+	var seen string
+	handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = middleware.GetRequestID(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("X-Request-ID", "bad id with spaces")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Len(t, seen, 32)
+	assert.Equal(t, seen, rr.Header().Get("X-Request-ID"))
+}
+
+func TestRequireActiveUserMiddleware(t *testing.T) {
+	active := map[int]bool{1: true, 2: false}
+	handler := middleware.RequireActiveUser(func(userID int) (bool, error) {
+		return active[userID], nil
+	})(http.HandlerFunc(okHandler))
+
+	serve := func(claims *utils.Claims) int {
+		req := httptest.NewRequest("GET", "/api/shipments", nil)
+		if claims != nil {
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(&utils.Claims{UserID: 1}))
+	assert.Equal(t, http.StatusUnauthorized, serve(&utils.Claims{UserID: 2}), "deactivated user")
+	assert.Equal(t, http.StatusUnauthorized, serve(&utils.Claims{UserID: 3}), "unknown user")
+	assert.Equal(t, http.StatusUnauthorized, serve(&utils.Claims{UserID: 1, ImpersonatorID: 2}), "deactivated impersonator")
+	assert.Equal(t, http.StatusUnauthorized, serve(nil))
+}
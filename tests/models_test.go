@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"goexpress-api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTCTime_MarshalJSON(t *testing.T) {
+	// 10:30 in Ouagadougou is 10:30 UTC, 12:30 in Paris summer time
+	paris := time.FixedZone("CEST", 2*60*60)
+	created := time.Date(2025, 7, 4, 12, 30, 0, 0, paris)
+
+	zone := models.Zone{
+		ID:         1,
+		Name:       "Local Express",
+		PricePerKg: 3.5,
+		CreatedAt:  models.UTCTime{Time: created},
+		UpdatedAt:  models.NewUTCTime(created),
+	}
+
+	data, err := json.Marshal(zone)
+	assert.NoError(t, err)
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "2025-07-04T10:30:00Z", decoded["created_at"])
+	assert.Equal(t, "2025-07-04T10:30:00Z", decoded["updated_at"])
+}
+
+func TestUTCTime_Scan(t *testing.T) {
+	var ts models.UTCTime
+	local := time.Date(2025, 7, 4, 12, 30, 0, 0, time.FixedZone("", 2*60*60))
+
+	assert.NoError(t, ts.Scan(local))
+	assert.Equal(t, time.UTC, ts.Location())
+	assert.True(t, ts.Equal(local))
+
+	assert.NoError(t, ts.Scan(nil))
+	assert.True(t, ts.IsZero())
+}
@@ -0,0 +1,288 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"goexpress-api/outbox"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingDispatcher keeps the events it is given, failing while err is set.
+type recordingDispatcher struct {
+	mu     sync.Mutex
+	events []outbox.Event
+	err    error
+}
+
+func (d *recordingDispatcher) Dispatch(e outbox.Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+	d.events = append(d.events, e)
+	return nil
+}
+
+// panickingDispatcher panics on events for one shipment and records the rest.
+type panickingDispatcher struct {
+	recordingDispatcher
+	shipmentID int
+}
+
+func (d *panickingDispatcher) Dispatch(e outbox.Event) error {
+	if e.ShipmentID == d.shipmentID {
+		panic("malformed event")
+	}
+	return d.recordingDispatcher.Dispatch(e)
+}
+
+// lockCheckingDispatcher records whether each event's row could be locked
+// while it was being dispatched.
+type lockCheckingDispatcher struct {
+	db       *sql.DB
+	lockErrs []error
+}
+
+func (d *lockCheckingDispatcher) Dispatch(e outbox.Event) error {
+	var id int64
+	err := d.db.QueryRow("SELECT id FROM event_outbox WHERE id = $1 FOR UPDATE NOWAIT", e.ID).Scan(&id)
+	d.lockErrs = append(d.lockErrs, err)
+	return nil
+}
+
+func TestShipmentHandler_UpdateShipmentStatusWritesOutboxEvent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Outbox Client", "outbox@goexpress.com", "client")
+	shipmentID := seedShipment(t, db, "GEX0B0E0001", 1, clientID, "picked_up", 1000, "2025-07-01 09:00:00")
+
+	updateStatus := func(status string) int {
+		id := strconv.Itoa(shipmentID)
+		body, _ := json.Marshal(map[string]string{"status": status, "location": "Koudougou"})
+		req := httptest.NewRequest("PUT", "/api/shipments/"+id+"/status", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.UpdateShipmentStatus(rr, req)
+		return rr.Code
+	}
+
+	events := func() []models.ShipmentStatusEvent {
+		rows, err := db.Query(`
+			SELECT payload FROM event_outbox
+			WHERE shipment_id = $1 AND event_type = $2 ORDER BY id`,
+			shipmentID, outbox.EventShipmentStatusChanged)
+		assert.NoError(t, err)
+		defer rows.Close()
+
+		var events []models.ShipmentStatusEvent
+		for rows.Next() {
+			var payload []byte
+			assert.NoError(t, rows.Scan(&payload))
+			var event models.ShipmentStatusEvent
+			assert.NoError(t, json.Unmarshal(payload, &event))
+			events = append(events, event)
+		}
+		return events
+	}
+
+	assert.Equal(t, http.StatusOK, updateStatus("in_transit"))
+	if written := events(); assert.Len(t, written, 1) {
+		assert.Equal(t, "in_transit", written[0].Status)
+		assert.Equal(t, "GEX0B0E0001", written[0].TrackingNumber)
+		assert.Equal(t, "Koudougou", written[0].Location)
+		assert.NotEmpty(t, written[0].Reference)
+	}
+
+	// A repeated update records nothing new, so publishes nothing either
+	assert.Equal(t, http.StatusOK, updateStatus("in_transit"))
+	assert.Len(t, events(), 1)
+
+	// A rejected update leaves no event behind
+	_, err := db.Exec("UPDATE shipments SET on_hold = true, hold_reason = 'customs' WHERE id = $1", shipmentID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, updateStatus("delivered"))
+	assert.Len(t, events(), 1)
+
+	t.Run("events only exist if their transaction commits", func(t *testing.T) {
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, outbox.Write(tx, outbox.EventShipmentStatusChanged, shipmentID, models.ShipmentStatusEvent{Status: "cancelled"}))
+		assert.NoError(t, tx.Rollback())
+		assert.Len(t, events(), 1)
+	})
+}
+
+func TestOutboxRelay_Flush(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	write := func(shipmentID int, status string) {
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, outbox.Write(tx, outbox.EventShipmentStatusChanged, shipmentID, models.ShipmentStatusEvent{ShipmentID: shipmentID, Status: status}))
+		assert.NoError(t, tx.Commit())
+	}
+	pending := func() int {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM event_outbox WHERE delivered_at IS NULL").Scan(&n)
+		return n
+	}
+
+	write(1, "in_transit")
+	write(2, "delivered")
+
+	dispatcher := &recordingDispatcher{err: errors.New("connection refused")}
+	relay := outbox.NewRelay(db.DB, dispatcher, time.Minute)
+
+	t.Run("failed deliveries stay pending", func(t *testing.T) {
+		n, err := relay.Flush()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 2, pending())
+
+		var attempts int
+		var lastError string
+		db.QueryRow("SELECT attempts, last_error FROM event_outbox ORDER BY id LIMIT 1").Scan(&attempts, &lastError)
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, "connection refused", lastError)
+	})
+
+	t.Run("delivered in order and marked delivered", func(t *testing.T) {
+		dispatcher.err = nil
+		n, err := relay.Flush()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 0, pending())
+
+		if assert.Len(t, dispatcher.events, 2) {
+			assert.Equal(t, 1, dispatcher.events[0].ShipmentID)
+			assert.Equal(t, 2, dispatcher.events[1].ShipmentID)
+			var payload models.ShipmentStatusEvent
+			assert.NoError(t, json.Unmarshal(dispatcher.events[1].Payload, &payload))
+			assert.Equal(t, "delivered", payload.Status)
+		}
+
+		n, err = relay.Flush()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n, "delivered events are not sent again")
+	})
+}
+
+func TestOutboxRelay_FlushSurvivesDispatcherPanic(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, shipmentID := range []int{1, 2, 3} {
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, outbox.Write(tx, outbox.EventShipmentStatusChanged, shipmentID, models.ShipmentStatusEvent{ShipmentID: shipmentID}))
+		assert.NoError(t, tx.Commit())
+	}
+
+	dispatcher := &panickingDispatcher{shipmentID: 2}
+	relay := outbox.NewRelay(db.DB, dispatcher, time.Minute)
+
+	n, err := relay.Flush()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	// Events after the panicking one are still delivered
+	if assert.Len(t, dispatcher.events, 2) {
+		assert.Equal(t, 1, dispatcher.events[0].ShipmentID)
+		assert.Equal(t, 3, dispatcher.events[1].ShipmentID)
+	}
+
+	// The panicking event is left pending, like any failed delivery
+	var attempts int
+	var lastError string
+	var delivered bool
+	err = db.QueryRow(`
+		SELECT attempts, last_error, delivered_at IS NOT NULL
+		FROM event_outbox WHERE shipment_id = 2`,
+	).Scan(&attempts, &lastError, &delivered)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.Contains(t, lastError, "malformed event")
+	assert.False(t, delivered)
+}
+
+func TestOutboxRelay_FlushClaimsBeforeDispatching(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, shipmentID := range []int{1, 2, 3} {
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, outbox.Write(tx, outbox.EventShipmentStatusChanged, shipmentID, models.ShipmentStatusEvent{ShipmentID: shipmentID}))
+		assert.NoError(t, tx.Commit())
+	}
+
+	// Shipment 2's event is claimed by another relay, shipment 3's claim
+	// has expired.
+	_, err := db.Exec("UPDATE event_outbox SET claimed_until = CURRENT_TIMESTAMP + INTERVAL '1 hour' WHERE shipment_id = 2")
+	assert.NoError(t, err)
+	_, err = db.Exec("UPDATE event_outbox SET claimed_until = CURRENT_TIMESTAMP - INTERVAL '1 minute' WHERE shipment_id = 3")
+	assert.NoError(t, err)
+
+	dispatcher := &lockCheckingDispatcher{db: db.DB}
+	relay := outbox.NewRelay(db.DB, dispatcher, time.Minute)
+
+	n, err := relay.Flush()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n, "events claimed elsewhere are skipped")
+	for i, lockErr := range dispatcher.lockErrs {
+		assert.NoError(t, lockErr, "event %d was locked while dispatching", i)
+	}
+
+	var claimed int
+	db.QueryRow("SELECT COUNT(*) FROM event_outbox WHERE delivered_at IS NOT NULL AND claimed_until IS NOT NULL").Scan(&claimed)
+	assert.Equal(t, 0, claimed, "claims are released once events are marked")
+}
+
+func TestOutboxRelay_Stop(t *testing.T) {
+	// Flushes fail against an unreachable database; only the lifecycle
+	// matters here.
+	unreachable, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	assert.NoError(t, err)
+	defer unreachable.Close()
+
+	stopped := func(relay *outbox.Relay) bool {
+		done := make(chan struct{})
+		go func() {
+			relay.Stop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return true
+		case <-time.After(5 * time.Second):
+			return false
+		}
+	}
+
+	running := outbox.NewRelay(unreachable, &recordingDispatcher{}, 10*time.Millisecond)
+	running.Start()
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, stopped(running), "a running relay stops")
+	assert.True(t, stopped(running), "stopping twice is harmless")
+
+	idle := outbox.NewRelay(unreachable, &recordingDispatcher{}, 10*time.Millisecond)
+	assert.True(t, stopped(idle), "a relay that never started stops")
+	idle.Start()
+	assert.True(t, stopped(idle), "starting after stop does nothing")
+}
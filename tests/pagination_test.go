@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"goexpress-api/config"
+	"goexpress-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePagination_PageSizes(t *testing.T) {
+	t.Setenv("DEFAULT_PAGE_SIZE", "10")
+	t.Setenv("MAX_PAGE_SIZE", "50")
+	cfg := config.Load()
+	utils.SetPageSizes(cfg.DefaultPageSize, cfg.MaxPageSize)
+	defer utils.SetPageSizes(utils.DefaultPageSize, utils.MaxPageSize)
+
+	parse := func(query string) utils.Pagination {
+		return utils.ParsePagination(httptest.NewRequest("GET", "/api/drivers?"+query, nil))
+	}
+
+	t.Run("uses the configured default", func(t *testing.T) {
+		p := parse("")
+		assert.Equal(t, 1, p.Page)
+		assert.Equal(t, 10, p.Limit)
+	})
+
+	t.Run("clamps limits above the configured max", func(t *testing.T) {
+		p := parse("page=3&limit=500")
+		assert.Equal(t, 50, p.Limit)
+		assert.Equal(t, 100, p.Offset())
+	})
+
+	t.Run("keeps limits within the max", func(t *testing.T) {
+		assert.Equal(t, 25, parse("limit=25").Limit)
+	})
+}
+
+func TestParsePagination_MalformedParams(t *testing.T) {
+	parse := func(query string) utils.Pagination {
+		return utils.ParsePagination(httptest.NewRequest("GET", "/api/drivers?"+query, nil))
+	}
+
+	defaults := utils.Pagination{Page: 1, Limit: utils.DefaultPageSize}
+	for _, query := range []string{
+		"page=abc&limit=-5",
+		"page=0&limit=0",
+		"page=-3&limit=xyz",
+		"page=1.5&limit=2e3",
+		"page=&limit=",
+		"page=99999999999999999999",
+	} {
+		assert.Equal(t, defaults, parse(query), query)
+	}
+
+	t.Run("keeps the valid param when only the other is malformed", func(t *testing.T) {
+		assert.Equal(t, utils.Pagination{Page: 3, Limit: utils.DefaultPageSize}, parse("page=3&limit=lots"))
+		assert.Equal(t, utils.Pagination{Page: 1, Limit: 10}, parse("page=first&limit=10"))
+	})
+
+	t.Run("clamps oversized limits", func(t *testing.T) {
+		assert.Equal(t, utils.MaxPageSize, parse("limit=100000").Limit)
+	})
+
+	t.Run("clamps huge pages so the offset cannot overflow", func(t *testing.T) {
+		p := parse("page=9223372036854775807&limit=100")
+		assert.Equal(t, utils.MaxPage, p.Page)
+		assert.Equal(t, (utils.MaxPage-1)*100, p.Offset())
+	})
+}
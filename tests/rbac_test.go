@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goexpress-api/handlers"
+	"goexpress-api/middleware"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// authorized mounts h at template behind the route permission table, the
+// way main.go does, so handler tests can exercise role checks.
+func authorized(method, template string, h http.HandlerFunc) http.Handler {
+	router := mux.NewRouter()
+	router.Handle(template, middleware.Authorize(handlers.RoutePermissions)(h)).Methods(method)
+	return router
+}
+
+func TestAuthorizeMiddleware(t *testing.T) {
+	const secret = "test-secret"
+
+	router := mux.NewRouter()
+	protected := router.PathPrefix("/api").Subrouter()
+	protected.Use(middleware.AuthMiddleware(secret))
+	protected.Use(middleware.Authorize(handlers.RoutePermissions))
+	protected.HandleFunc("/users", okHandler).Methods("GET")
+	protected.HandleFunc("/users/{id}/activate", okHandler).Methods("POST")
+	protected.HandleFunc("/shipments", okHandler).Methods("GET")
+	protected.HandleFunc("/drivers/me/summary", okHandler).Methods("GET")
+	protected.HandleFunc("/unlisted", okHandler).Methods("GET")
+
+	serve := func(method, target, role string) int {
+		token, err := utils.GenerateJWT(1, role+"@goexpress.com", role, secret)
+		assert.NoError(t, err)
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("client is blocked from an admin route", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve("GET", "/api/users", "client"))
+		assert.Equal(t, http.StatusForbidden, serve("POST", "/api/users/7/activate", "client"))
+	})
+
+	t.Run("client is allowed on a permitted route", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("GET", "/api/shipments", "client"))
+	})
+
+	t.Run("admin is allowed on admin routes", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("GET", "/api/users", "admin"))
+		assert.Equal(t, http.StatusOK, serve("POST", "/api/users/7/activate", "admin"))
+	})
+
+	t.Run("roles are not implied by admin", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve("GET", "/api/drivers/me/summary", "admin"))
+		assert.Equal(t, http.StatusOK, serve("GET", "/api/drivers/me/summary", "driver"))
+	})
+
+	t.Run("routes missing from the table are refused", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve("GET", "/api/unlisted", "admin"))
+	})
+
+	t.Run("missing token is still unauthorized", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/users", nil))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"testing"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactCustomer(t *testing.T) {
+	customer := models.Customer{
+		ID:             7,
+		UserID:         42,
+		CompanyName:    "Sahel Traders",
+		Name:           "Awa Traore",
+		Email:          "awa@sahel.example",
+		Phone:          "+226 70 00 00 00",
+		AlternatePhone: "+226 71 00 00 00",
+	}
+
+	adminView := customer
+	handlers.RedactCustomer(&utils.Claims{UserID: 1, Role: "admin"}, &adminView)
+	assert.Equal(t, customer, adminView)
+
+	ownView := customer
+	handlers.RedactCustomer(&utils.Claims{UserID: 42, Role: "client"}, &ownView)
+	assert.Equal(t, customer, ownView)
+
+	clientView := customer
+	handlers.RedactCustomer(&utils.Claims{UserID: 99, Role: "client"}, &clientView)
+	assert.Empty(t, clientView.Email)
+	assert.Empty(t, clientView.Phone)
+	assert.Empty(t, clientView.AlternatePhone)
+	assert.Equal(t, customer.CompanyName, clientView.CompanyName)
+	assert.Equal(t, customer.Name, clientView.Name)
+}
+
+func TestRedactDriver(t *testing.T) {
+	driver := models.Driver{ID: 5, Name: "Moussa Ouedraogo", Email: "moussa@goexpress.com", Phone: "+226 76 00 00 00"}
+
+	adminView := driver
+	handlers.RedactDriver(&utils.Claims{UserID: 1, Role: "admin"}, &adminView)
+	assert.Equal(t, driver, adminView)
+
+	clientView := driver
+	handlers.RedactDriver(&utils.Claims{UserID: 99, Role: "client"}, &clientView)
+	assert.Empty(t, clientView.Email)
+	assert.Empty(t, clientView.Phone)
+	assert.Equal(t, driver.Name, clientView.Name)
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"goexpress-api/utils"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRetry(t *testing.T) {
+	t.Run("retries transient errors until success", func(t *testing.T) {
+		calls := 0
+		err := utils.WithRetry(func() error {
+			calls++
+			if calls <= 2 {
+				return driver.ErrBadConn
+			}
+			return nil
+		}, 3, time.Millisecond)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		calls := 0
+		err := utils.WithRetry(func() error {
+			calls++
+			return &pq.Error{Code: "57P01"}
+		}, 3, time.Millisecond)
+
+		assert.Error(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		for _, permanent := range []error{&pq.Error{Code: "23505"}, sql.ErrNoRows, errors.New("boom")} {
+			calls := 0
+			err := utils.WithRetry(func() error {
+				calls++
+				return permanent
+			}, 3, time.Millisecond)
+
+			assert.Equal(t, permanent, err)
+			assert.Equal(t, 1, calls)
+		}
+	})
+}
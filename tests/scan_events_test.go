@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShipmentHandler_IngestScanEvents(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Scan Client", "scanclient@goexpress.com", "client")
+	pickedUpID := seedShipment(t, db, "GEX5CA0E001", 1, clientID, "picked_up", 1000, "2025-07-01 09:00:00")
+	deliveredID := seedShipment(t, db, "GEX5CA0E002", 1, clientID, "delivered", 1000, "2025-07-01 09:00:00")
+	heldID := seedShipment(t, db, "GEX5CA0E003", 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+	_, err := db.Exec("UPDATE shipments SET on_hold = true, hold_reason = 'customs' WHERE id = $1", heldID)
+	assert.NoError(t, err)
+
+	body := `[
+		{"tracking_number": "GEX5CA0E001", "status": "in_transit", "location": "Koudougou", "timestamp": "2025-07-02T08:00:00Z"},
+		{"tracking_number": "GEX5CA0E001", "status": "out_for_delivery", "location": "Bobo-Dioulasso"},
+		{"tracking_number": "GEX5CA0E002", "status": "in_transit"},
+		{"tracking_number": "GEX5CA0E003", "status": "delivered"},
+		{"tracking_number": "GEX5CA0E001", "status": "cancelled"},
+		{"tracking_number": "GEX5CA0E099", "status": "delivered"},
+		{"tracking_number": "GEX5CA0E001", "status": "teleported"}
+	]`
+	req := httptest.NewRequest("POST", "/api/integrations/scan-events", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	handler.IngestScanEvents(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var response models.ScanEventsResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Applied)
+	assert.Equal(t, 5, response.Rejected)
+	if assert.Len(t, response.Results, 7) {
+		applied := []bool{true, true, false, false, false, false, false}
+		for i, result := range response.Results {
+			assert.Equal(t, applied[i], result.Applied, "event %d", i)
+			assert.Equal(t, applied[i], result.Error == "", "event %d", i)
+		}
+		assert.Equal(t, "invalid transition from delivered to in_transit", response.Results[2].Error)
+		assert.Equal(t, "shipment is on hold", response.Results[3].Error)
+		assert.Equal(t, "invalid transition from out_for_delivery to cancelled", response.Results[4].Error, "carriers cannot cancel")
+		assert.Equal(t, "shipment not found", response.Results[5].Error)
+		assert.Equal(t, "unknown status", response.Results[6].Error)
+	}
+
+	status := func(id int) string {
+		var s string
+		db.QueryRow("SELECT status FROM shipments WHERE id = $1", id).Scan(&s)
+		return s
+	}
+	assert.Equal(t, "out_for_delivery", status(pickedUpID))
+	assert.Equal(t, "delivered", status(deliveredID))
+	assert.Equal(t, "in_transit", status(heldID))
+
+	var updates int
+	db.QueryRow("SELECT COUNT(*) FROM tracking_updates WHERE shipment_id = $1", pickedUpID).Scan(&updates)
+	assert.Equal(t, 2, updates)
+
+	var scannedAt string
+	db.QueryRow(`
+		SELECT to_char(timestamp, 'YYYY-MM-DD HH24:MI') FROM tracking_updates
+		WHERE shipment_id = $1 AND status = 'in_transit'`, pickedUpID).Scan(&scannedAt)
+	assert.Equal(t, "2025-07-02 08:00", scannedAt, "the carrier's scan time is kept")
+
+	t.Run("rejects an empty batch", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/integrations/scan-events", bytes.NewBufferString(`[]`))
+		rr := httptest.NewRecorder()
+		handler.IngestScanEvents(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestShipmentHandler_IngestScanEventsDatabaseFailure(t *testing.T) {
+	// Nothing listens on this port, so every event fails on our side.
+	unreachable, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	assert.NoError(t, err)
+	defer unreachable.Close()
+	handler := handlers.NewShipmentHandler(unreachable)
+
+	body := `[
+		{"tracking_number": "GEX5CA0E001", "status": "in_transit"},
+		{"tracking_number": "GEX5CA0E001", "status": "teleported"}
+	]`
+	req := httptest.NewRequest("POST", "/api/integrations/scan-events", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	handler.IngestScanEvents(rr, req)
+	assert.Equal(t, http.StatusMultiStatus, rr.Code, "failures are reported per event, not as a 500")
+
+	var response models.ScanEventsResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, 0, response.Applied)
+	assert.Equal(t, 1, response.Rejected)
+	assert.Equal(t, 1, response.Failed)
+	if assert.Len(t, response.Results, 2) {
+		assert.True(t, response.Results[0].Retryable)
+		assert.NotEmpty(t, response.Results[0].Error)
+		assert.False(t, response.Results[1].Retryable, "rejected events are not retried")
+	}
+}
@@ -1,14 +1,19 @@
 package tests
 
 import (
+	"context"
+	"encoding/json"
 	"log"
-	"os"
-	"path/filepath"
+	"net/http"
 	"testing"
 
 	"goexpress-api/database"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/utils"
 
 	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
 )
 
 func setupTestDB(t *testing.T) *database.DB {
@@ -22,26 +27,75 @@ func setupTestDB(t *testing.T) *database.DB {
 
 	// Clean up tables before each test
 	_, err = db.Exec(`
+		DROP TABLE IF EXISTS audit_log;
+		DROP TABLE IF EXISTS event_outbox;
+		DROP TABLE IF EXISTS password_history;
+		DROP TABLE IF EXISTS driver_shifts;
+		DROP TABLE IF EXISTS driver_profiles;
+		DROP TABLE IF EXISTS shipment_documents;
+		DROP TABLE IF EXISTS tracking_links;
+		DROP TABLE IF EXISTS assignment_responses;
 		DROP TABLE IF EXISTS tracking_updates;
 		DROP TABLE IF EXISTS shipments;
+		DROP TABLE IF EXISTS shipment_sequences;
+		DROP TABLE IF EXISTS promo_codes;
+		DROP TABLE IF EXISTS customer_addresses;
+		DROP TABLE IF EXISTS customer_notes;
+		DROP TABLE IF EXISTS customers;
 		DROP TABLE IF EXISTS zones;
 		DROP TABLE IF EXISTS users;
+		DROP TABLE IF EXISTS schema_migrations;
 	`)
 	if err != nil {
 		log.Printf("Warning: failed to clean up tables: %v", err)
 	}
 
 	// Run migrations
-	migrationPath := filepath.Join("..", "supabase", "migrations", "20250704001632_weathered_block.sql")
-	migrationSQL, err := os.ReadFile(migrationPath)
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	return db
+}
+
+
+// withClaims attaches authenticated user claims to a request, as AuthMiddleware would.
+func withClaims(req *http.Request, userID int, role string) *http.Request {
+	claims := &utils.Claims{UserID: userID, Email: "test@goexpress.com", Role: role}
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+}
+
+// createTestUser inserts a user directly and returns its id.
+func createTestUser(t *testing.T, db *database.DB, name, email, role string) int {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO users (name, email, password_hash, role) 
+		VALUES ($1, $2, 'not-a-real-hash', $3) RETURNING id`,
+		name, email, role,
+	).Scan(&id)
 	if err != nil {
-		t.Fatalf("Failed to read migration file: %v", err)
+		t.Fatalf("Failed to create test user: %v", err)
 	}
+	return id
+}
 
-	_, err = db.Exec(string(migrationSQL))
+// seedShipment inserts a shipment with an explicit cost and creation time and returns its id.
+func seedShipment(t *testing.T, db *database.DB, trackingNumber string, zoneID, customerID int, status string, cost float64, createdAt string) int {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO shipments (tracking_number, origin, destination, weight, zone_id, customer_id, status, cost, created_at)
+		VALUES ($1, 'Ouagadougou', 'Bobo-Dioulasso', 2, $2, $3, $4, $5, $6) RETURNING id`,
+		trackingNumber, zoneID, customerID, status, cost, createdAt,
+	).Scan(&id)
 	if err != nil {
-		t.Fatalf("Failed to run migrations: %v", err)
+		t.Fatalf("Failed to seed shipment: %v", err)
 	}
+	return id
+}
 
-	return db
+// decodePage decodes a paginated response, unmarshalling its items into data.
+func decodePage(t *testing.T, body []byte, data interface{}) models.PaginatedResponse {
+	page := models.PaginatedResponse{Data: data}
+	assert.NoError(t, json.Unmarshal(body, &page))
+	return page
 }
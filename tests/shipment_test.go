@@ -0,0 +1,2129 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goexpress-api/audit"
+	"goexpress-api/cache"
+	"goexpress-api/handlers"
+	"goexpress-api/mailer"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/notifier"
+	"goexpress-api/pricing"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShipmentHandler_ScheduledPickups(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Pickup Client", "pickup@goexpress.com", "client")
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	pickupAt := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 10, 0, 0, 0, time.UTC)
+	window := 120
+
+	t.Run("create scheduled pickup and list it", func(t *testing.T) {
+		body, _ := json.Marshal(models.ShipmentRequest{
+			Origin:            "Ouagadougou",
+			Destination:       "Bobo-Dioulasso",
+			Weight:            2.5,
+			ZoneID:            1,
+			PickupScheduledAt: &pickupAt,
+			PickupWindow:      &window,
+		})
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), clientID, "client")
+		rr := httptest.NewRecorder()
+		handler.CreateShipment(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var created models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		assert.NotNil(t, created.PickupScheduledAt)
+
+		req = withClaims(httptest.NewRequest("GET", "/api/pickups?date="+pickupAt.Format("2006-01-02"), nil), 1, "admin")
+		rr = httptest.NewRecorder()
+		handler.GetScheduledPickups(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var groups []models.ZonePickups
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &groups))
+		assert.Len(t, groups, 1)
+		assert.Equal(t, 1, groups[0].ZoneID)
+		assert.Len(t, groups[0].Pickups, 1)
+		assert.Equal(t, created.ID, groups[0].Pickups[0].ID)
+	})
+
+	t.Run("pickup in the past is rejected", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		body, _ := json.Marshal(models.ShipmentRequest{
+			Origin:            "Ouagadougou",
+			Destination:       "Koudougou",
+			Weight:            1,
+			ZoneID:            1,
+			PickupScheduledAt: &past,
+		})
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), clientID, "client")
+		rr := httptest.NewRecorder()
+		handler.CreateShipment(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestShipmentHandler_CreateReturn(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Return Client", "return@goexpress.com", "client")
+	otherID := createTestUser(t, db, "Other Client", "other@goexpress.com", "client")
+
+	deliveredID := seedShipment(t, db, "GEX0000R001", 1, clientID, "delivered", 10, "2025-03-01 09:00:00")
+	pendingID := seedShipment(t, db, "GEX0000R002", 1, clientID, "pending", 10, "2025-03-01 09:00:00")
+
+	returnRequest := func(shipmentID, userID int, role, body string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/return", bytes.NewBufferString(body))
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.CreateReturn(rr, req)
+		return rr
+	}
+
+	t.Run("owner returns a delivered shipment", func(t *testing.T) {
+		rr := returnRequest(deliveredID, clientID, "client", "")
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var ret models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &ret))
+		assert.Equal(t, deliveredID, *ret.ReturnOf)
+		assert.Equal(t, "Bobo-Dioulasso", ret.Origin)
+		assert.Equal(t, "Ouagadougou", ret.Destination)
+		assert.Equal(t, "pending", ret.Status)
+		assert.NotEqual(t, "GEX0000R001", ret.TrackingNumber)
+
+		var trackingCount int
+		db.QueryRow("SELECT COUNT(*) FROM tracking_updates WHERE shipment_id = $1", ret.ID).Scan(&trackingCount)
+		assert.Equal(t, 1, trackingCount)
+	})
+
+	t.Run("second return is rejected", func(t *testing.T) {
+		rr := returnRequest(deliveredID, clientID, "client", "")
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("concurrent returns create only one", func(t *testing.T) {
+		racedID := seedShipment(t, db, "GEX0000R003", 1, clientID, "delivered", 10, "2025-03-01 09:00:00")
+
+		codes := make(chan int, 5)
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes <- returnRequest(racedID, clientID, "client", "").Code
+			}()
+		}
+		wg.Wait()
+		close(codes)
+
+		created := 0
+		for code := range codes {
+			if code == http.StatusCreated {
+				created++
+			} else {
+				assert.Equal(t, http.StatusConflict, code)
+			}
+		}
+		assert.Equal(t, 1, created)
+
+		var returns int
+		db.QueryRow("SELECT COUNT(*) FROM shipments WHERE return_of = $1", racedID).Scan(&returns)
+		assert.Equal(t, 1, returns)
+	})
+
+	t.Run("non-delivered shipment is rejected", func(t *testing.T) {
+		rr := returnRequest(pendingID, clientID, "client", `{"force": true}`)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("other customers cannot return", func(t *testing.T) {
+		rr := returnRequest(pendingID, otherID, "client", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("admin can force a return", func(t *testing.T) {
+		rr := returnRequest(pendingID, 1, "admin", `{"force": true}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	})
+}
+
+func TestShipmentHandler_GetAllQuotes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+
+	req := httptest.NewRequest("POST", "/api/quote/all", bytes.NewBufferString(`{"weight": 2}`))
+	rr := httptest.NewRecorder()
+	handler.GetAllQuotes(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var quotes []models.QuoteResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &quotes))
+	assert.Len(t, quotes, 5)
+
+	for i := 1; i < len(quotes); i++ {
+		assert.LessOrEqual(t, quotes[i-1].TotalPrice, quotes[i].TotalPrice)
+	}
+	for _, quote := range quotes {
+		assert.Equal(t, 2.0, quote.Weight)
+		assert.InDelta(t, quote.PricePerKg*2, quote.TotalPrice, 0.001)
+	}
+}
+
+func TestShipmentHandler_GetQuoteCache(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	quoteCache := cache.NewTTLQuotes(time.Hour)
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetQuoteCache(quoteCache)
+	zoneHandler := handlers.NewZoneHandler(db.DB)
+	zoneHandler.SetQuoteCache(quoteCache)
+
+	quote := func(body string) models.QuoteResponse {
+		req := httptest.NewRequest("POST", "/api/quote", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		handler.GetQuote(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.QuoteResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response
+	}
+
+	_, err := db.Exec("UPDATE zones SET price_per_kg = 2 WHERE id = 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, quote(`{"weight": 3, "zone_id": 1}`).TotalPrice)
+
+	t.Run("identical quote is served from the cache", func(t *testing.T) {
+		// Changed behind the handlers' back, so only a cache miss would see it
+		_, err := db.Exec("UPDATE zones SET price_per_kg = 5 WHERE id = 1")
+		assert.NoError(t, err)
+
+		assert.Equal(t, 6.0, quote(`{"weight": 3, "zone_id": 1}`).TotalPrice)
+		// A different weight is a different entry
+		assert.Equal(t, 5.0, quote(`{"weight": 1, "zone_id": 1}`).TotalPrice)
+	})
+
+	t.Run("zone price update invalidates it", func(t *testing.T) {
+		req := httptest.NewRequest("PATCH", "/api/zones/1", bytes.NewBufferString(`{"price_per_kg": 4}`))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+		zoneHandler.PatchZone(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		assert.Equal(t, 12.0, quote(`{"weight": 3, "zone_id": 1}`).TotalPrice)
+	})
+}
+
+func TestShipmentHandler_GetQuoteAdjuster(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("UPDATE zones SET price_per_kg = 2 WHERE id = 1")
+	assert.NoError(t, err)
+
+	quote := func(handler *handlers.ShipmentHandler) models.QuoteResponse {
+		req := httptest.NewRequest("POST", "/api/quote", bytes.NewBufferString(`{"weight": 3, "zone_id": 1}`))
+		rr := httptest.NewRecorder()
+		handler.GetQuote(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.QuoteResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("external engine adjusts the total", func(t *testing.T) {
+		var received models.QuoteResponse
+		engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&received)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"total_price": 5.5}`))
+		}))
+		defer engine.Close()
+
+		handler := handlers.NewShipmentHandler(db.DB)
+		handler.SetQuoteAdjuster(pricing.NewWebhookAdjuster(engine.URL, time.Second))
+
+		response := quote(handler)
+		assert.Equal(t, 6.0, received.TotalPrice)
+		assert.Equal(t, 5.5, response.TotalPrice)
+		assert.True(t, response.Adjusted)
+	})
+
+	t.Run("created shipments are charged the adjusted total", func(t *testing.T) {
+		engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"total_price": 5.5}`))
+		}))
+		defer engine.Close()
+
+		handler := handlers.NewShipmentHandler(db.DB)
+		handler.SetQuoteAdjuster(pricing.NewWebhookAdjuster(engine.URL, time.Second))
+
+		body, _ := json.Marshal(models.ShipmentRequest{
+			Origin:      "Ouagadougou",
+			Destination: "Bobo-Dioulasso",
+			Weight:      3,
+			ZoneID:      1,
+		})
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.CreateShipment(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var created models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		assert.Equal(t, quote(handler).TotalPrice, created.Cost)
+		assert.Equal(t, 5.5, created.Cost)
+	})
+
+	t.Run("timeout falls back to the internal quote", func(t *testing.T) {
+		release := make(chan struct{})
+		engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer engine.Close()
+		defer close(release)
+
+		handler := handlers.NewShipmentHandler(db.DB)
+		handler.SetQuoteAdjuster(pricing.NewWebhookAdjuster(engine.URL, 50*time.Millisecond))
+
+		response := quote(handler)
+		assert.Equal(t, 6.0, response.TotalPrice)
+		assert.False(t, response.Adjusted)
+	})
+}
+
+func TestShipmentHandler_GetMultiLegQuote(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	quote := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/quote/multi-leg", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		handler.GetMultiLegQuote(rr, req)
+		return rr
+	}
+
+	t.Run("total is the sum of the legs", func(t *testing.T) {
+		rr := quote(`{"legs": [{"zone_id": 1, "weight": 2}, {"zone_id": 3, "weight": 2}, {"zone_id": 1, "weight": 1.5}]}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.MultiLegQuoteResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Len(t, response.Legs, 3)
+
+		var sum float64
+		for _, leg := range response.Legs {
+			assert.InDelta(t, leg.PricePerKg*leg.Weight, leg.TotalPrice, 0.001)
+			sum += leg.TotalPrice
+		}
+		assert.InDelta(t, sum, response.TotalPrice, 0.001)
+		assert.Equal(t, 3, response.Legs[1].ZoneID)
+	})
+
+	t.Run("unknown zone", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, quote(`{"legs": [{"zone_id": 1, "weight": 2}, {"zone_id": 999, "weight": 2}]}`).Code)
+	})
+
+	t.Run("needs at least one leg", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, quote(`{"legs": []}`).Code)
+	})
+
+	t.Run("at most MaxQuoteLegs legs", func(t *testing.T) {
+		legs := strings.Repeat(`{"zone_id": 1, "weight": 1},`, models.MaxQuoteLegs)
+		assert.Equal(t, http.StatusOK, quote(`{"legs": [`+strings.TrimSuffix(legs, ",")+`]}`).Code)
+
+		rr := quote(`{"legs": [` + legs + `{"zone_id": 1, "weight": 1}]}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "At most "+strconv.Itoa(models.MaxQuoteLegs)+" legs")
+	})
+}
+
+func TestShipmentHandler_CreateShipmentAsync(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	mail := &recordingMailer{sent: make(chan mailer.Message, 1)}
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetMailer(mail)
+	assigner := handlers.NewTrackingAssigner(db.DB, 100*time.Millisecond)
+	handler.SetTrackingAssigner(assigner)
+	assigner.Start()
+	defer assigner.Stop()
+	clientID := createTestUser(t, db, "Async Client", "async@goexpress.com", "client")
+
+	body := []byte(`{"origin": "Ouagadougou", "destination": "Bobo-Dioulasso", "weight": 2, "zone_id": 1}`)
+	req := withClaims(httptest.NewRequest("POST", "/api/shipments?async=true", bytes.NewBuffer(body)), clientID, "client")
+	rr := httptest.NewRecorder()
+	handler.CreateShipment(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	var shipment models.Shipment
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+	assert.NotZero(t, shipment.ID)
+	assert.Equal(t, "pending_tracking", shipment.Status)
+	assert.Empty(t, shipment.TrackingNumber)
+
+	var trackingNumber sql.NullString
+	var status string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		db.QueryRow("SELECT tracking_number, status FROM shipments WHERE id = $1", shipment.ID).Scan(&trackingNumber, &status)
+		if trackingNumber.Valid {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	assert.True(t, trackingNumber.Valid)
+	assert.Len(t, trackingNumber.String, 11)
+	assert.Equal(t, "pending", status)
+
+	var trackingCount int
+	db.QueryRow("SELECT COUNT(*) FROM tracking_updates WHERE shipment_id = $1", shipment.ID).Scan(&trackingCount)
+	assert.Equal(t, 1, trackingCount)
+
+	// The confirmation waits for the tracking number it announces
+	select {
+	case msg := <-mail.sent:
+		assert.Equal(t, "async@goexpress.com", msg.To)
+		assert.Contains(t, msg.Subject, trackingNumber.String)
+		assert.Contains(t, msg.Body, "Tracking number: "+trackingNumber.String)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no confirmation email was sent")
+	}
+}
+
+// recordingMailer hands every sent message to a channel so tests can wait
+// for emails sent in the background.
+type recordingMailer struct {
+	sent chan mailer.Message
+}
+
+func (m *recordingMailer) Send(msg mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+func TestShipmentHandler_CreateShipmentSendsConfirmation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	mail := &recordingMailer{sent: make(chan mailer.Message, 1)}
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetMailer(mail)
+	clientID := createTestUser(t, db, "Mail Client", "mailclient@goexpress.com", "client")
+
+	body := []byte(`{"origin": "Ouagadougou", "destination": "Koudougou", "weight": 3, "zone_id": 1}`)
+	req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), clientID, "client")
+	rr := httptest.NewRecorder()
+	handler.CreateShipment(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var shipment models.Shipment
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+	assert.NotEmpty(t, shipment.TrackingNumber)
+
+	select {
+	case msg := <-mail.sent:
+		assert.Equal(t, "mailclient@goexpress.com", msg.To)
+		assert.Contains(t, msg.Subject, shipment.TrackingNumber)
+		assert.Contains(t, msg.Body, shipment.TrackingNumber)
+		assert.Contains(t, msg.Body, "Estimated delivery")
+	case <-time.After(5 * time.Second):
+		t.Fatal("confirmation email was not sent")
+	}
+}
+
+func TestShipmentHandler_ResendNotification(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	mail := &recordingMailer{sent: make(chan mailer.Message, 2)}
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetMailer(mail)
+	handler.SetResendNotificationInterval(time.Minute)
+	clientID := createTestUser(t, db, "Resend Client", "resend@goexpress.com", "client")
+	otherClientID := createTestUser(t, db, "Other Resend", "otherresend@goexpress.com", "client")
+	shipmentID := seedShipment(t, db, "GEX5E4D0001", 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+	_, err := db.Exec("INSERT INTO tracking_updates (shipment_id, status, location) VALUES ($1, 'in_transit', 'Koudougou')", shipmentID)
+	assert.NoError(t, err)
+
+	resend := func(userID int, role string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/resend-notification", nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.ResendNotification(rr, req)
+		return rr
+	}
+
+	t.Run("other clients cannot resend", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, resend(otherClientID, "client").Code)
+	})
+
+	t.Run("drivers cannot resend", func(t *testing.T) {
+		id := strconv.Itoa(shipmentID)
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments/"+id+"/resend-notification", nil), 2, "driver")
+		rr := httptest.NewRecorder()
+		authorized("POST", "/api/shipments/{id}/resend-notification", handler.ResendNotification).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("owner gets the current status emailed", func(t *testing.T) {
+		assert.Equal(t, http.StatusAccepted, resend(clientID, "client").Code)
+
+		select {
+		case msg := <-mail.sent:
+			assert.Equal(t, "resend@goexpress.com", msg.To)
+			assert.Contains(t, msg.Body, "GEX5E4D0001")
+			assert.Contains(t, msg.Body, "in_transit")
+			assert.Contains(t, msg.Body, "Koudougou")
+		default:
+			t.Fatal("notification was not sent")
+		}
+	})
+
+	t.Run("a rapid second resend is throttled", func(t *testing.T) {
+		rr := resend(1, "admin")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+		assert.Empty(t, mail.sent)
+	})
+}
+
+// recordingNotifier hands every notification to a channel so tests can wait
+// for notifications sent in the background.
+type recordingNotifier struct {
+	emails chan mailer.Message
+	sms    chan notifier.SMS
+}
+
+func (n *recordingNotifier) SendEmail(msg mailer.Message) error {
+	n.emails <- msg
+	return nil
+}
+
+func (n *recordingNotifier) SendSMS(msg notifier.SMS) error {
+	n.sms <- msg
+	return nil
+}
+
+func TestShipmentHandler_StatusChangeNotificationChannel(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	notifications := &recordingNotifier{emails: make(chan mailer.Message, 1), sms: make(chan notifier.SMS, 1)}
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetNotifier(notifications)
+	smsClientID := createTestUser(t, db, "SMS Client", "smsclient@goexpress.com", "client")
+	var customerID int
+	err := db.QueryRow(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone)
+		VALUES ($1, 'Faso Textiles', 'Awa', '+22670000001') RETURNING id`,
+		smsClientID,
+	).Scan(&customerID)
+	assert.NoError(t, err)
+
+	// The customer picks SMS through the API
+	preference, _ := json.Marshal(models.UpdateCustomerRequest{
+		CompanyName:         "Faso Textiles",
+		ContactPerson:       "Awa",
+		Phone:               "+22670000001",
+		Status:              "active",
+		NotificationChannel: "sms",
+	})
+	customerPath := "/api/customers/" + strconv.Itoa(customerID)
+	req := httptest.NewRequest("PUT", customerPath, bytes.NewBuffer(preference))
+	req = mux.SetURLVars(withClaims(req, smsClientID, "client"), map[string]string{"id": strconv.Itoa(customerID)})
+	rr := httptest.NewRecorder()
+	handlers.NewCustomerHandler(db.DB).UpdateCustomer(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	shipmentID := seedShipment(t, db, "GEX5A500001", 1, smsClientID, "picked_up", 1000, "2025-07-01 09:00:00")
+
+	id := strconv.Itoa(shipmentID)
+	req = httptest.NewRequest("PUT", "/api/shipments/"+id+"/status", bytes.NewBufferString(`{"status": "in_transit", "location": "Koudougou"}`))
+	req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+	rr = httptest.NewRecorder()
+	handler.UpdateShipmentStatus(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	select {
+	case msg := <-notifications.sms:
+		assert.Equal(t, "+22670000001", msg.To)
+		assert.Contains(t, msg.Body, "GEX5A500001")
+		assert.Contains(t, msg.Body, "in_transit")
+	case <-notifications.emails:
+		t.Fatal("customer preferring SMS was emailed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("status change notification was not sent")
+	}
+}
+
+func TestShipmentHandler_CreateShipmentSequentialReferences(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Reference Client", "reference@goexpress.com", "client")
+
+	const creates = 10
+	references := make(chan string, creates)
+	var wg sync.WaitGroup
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := []byte(`{"origin": "Ouagadougou", "destination": "Koudougou", "weight": 3, "zone_id": 1}`)
+			req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), clientID, "client")
+			rr := httptest.NewRecorder()
+			handler.CreateShipment(rr, req)
+			if rr.Code != http.StatusCreated {
+				references <- ""
+				return
+			}
+			var shipment models.Shipment
+			json.Unmarshal(rr.Body.Bytes(), &shipment)
+			references <- shipment.Reference
+		}()
+	}
+	wg.Wait()
+	close(references)
+
+	var got []string
+	for reference := range references {
+		got = append(got, reference)
+	}
+
+	year := time.Now().Year()
+	var want []string
+	for n := 1; n <= creates; n++ {
+		want = append(want, fmt.Sprintf("GEX-%d-%06d", year, n))
+	}
+	assert.ElementsMatch(t, want, got)
+}
+
+func TestShipmentHandler_CreateShipmentTrackingNumberCollision(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Collision Client", "collision@goexpress.com", "client")
+	seedShipment(t, db, "GEXC0111DE0", 1, clientID, "pending", 1000, "2025-07-01 09:00:00")
+
+	create := func() *httptest.ResponseRecorder {
+		body := []byte(`{"origin": "Ouagadougou", "destination": "Koudougou", "weight": 3, "zone_id": 1}`)
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), clientID, "client")
+		rr := httptest.NewRecorder()
+		handler.CreateShipment(rr, req)
+		return rr
+	}
+	shipmentCount := func() int {
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM shipments WHERE customer_id = $1", clientID).Scan(&count)
+		return count
+	}
+
+	t.Run("a colliding number is retried", func(t *testing.T) {
+		numbers := []string{"GEXC0111DE0", "GEXC0111DE1"}
+		handler.SetTrackingNumberGenerator(func() (string, error) {
+			next := numbers[0]
+			numbers = numbers[1:]
+			return next, nil
+		})
+
+		rr := create()
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.Equal(t, "GEXC0111DE1", shipment.TrackingNumber)
+	})
+
+	t.Run("persistent collisions are reported as a conflict", func(t *testing.T) {
+		handler.SetTrackingNumberGenerator(func() (string, error) { return "GEXC0111DE0", nil })
+
+		rr := create()
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Contains(t, rr.Body.String(), "unique tracking number")
+		assert.Equal(t, 2, shipmentCount())
+	})
+}
+
+func TestShipmentHandler_GetShipmentsSorting(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Sort Client", "sort@goexpress.com", "client")
+
+	weights := map[string]float64{"GEX50A00001": 7.5, "GEX50A00002": 1.25, "GEX50A00003": 3}
+	for trackingNumber, weight := range weights {
+		id := seedShipment(t, db, trackingNumber, 1, clientID, "pending", 1000, "2025-07-01 09:00:00")
+		_, err := db.Exec("UPDATE shipments SET weight = $1 WHERE id = $2", weight, id)
+		assert.NoError(t, err)
+	}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req := withClaims(httptest.NewRequest("GET", "/api/shipments"+query, nil), clientID, "client")
+		rr := httptest.NewRecorder()
+		handler.GetShipments(rr, req)
+		return rr
+	}
+
+	t.Run("weight ascending", func(t *testing.T) {
+		rr := list("?sort=weight&order=asc")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipments []models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipments))
+		var got []float64
+		for _, s := range shipments {
+			got = append(got, s.Weight)
+		}
+		assert.Equal(t, []float64{1.25, 3, 7.5}, got)
+	})
+
+	t.Run("unknown sort field", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, list("?sort=cost%3BDROP%20TABLE%20shipments").Code)
+	})
+
+	t.Run("unknown order", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, list("?sort=weight&order=sideways").Code)
+	})
+}
+
+func TestShipmentHandler_GetStuckShipments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Stuck Client", "stuck@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Stuck Driver", "stuckdriver@goexpress.com", "driver")
+
+	staleID := seedShipment(t, db, "GEX0000S001", 1, clientID, "pending", 10, "2025-03-01 09:00:00")
+	freshID := seedShipment(t, db, "GEX0000S002", 1, clientID, "pending", 10, "2025-03-01 09:00:00")
+	staleTransitID := seedShipment(t, db, "GEX0000S003", 1, clientID, "in_transit", 10, "2025-03-01 09:00:00")
+	unassignedID := seedShipment(t, db, "GEX0000S004", 1, clientID, "pending", 10, "2025-03-01 09:00:00")
+
+	_, err := db.Exec(`UPDATE shipments SET driver_id = $1 WHERE id IN ($2, $3, $4)`,
+		driverID, staleID, freshID, staleTransitID)
+	assert.NoError(t, err)
+
+	// Only tracking updates in the current status count: the fresh shipment
+	// went back to pending recently, while the edit below bumps the stale
+	// one's updated_at without changing its status
+	_, err = db.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location, note, timestamp) VALUES
+			($1, 'pending', 'Ouagadougou', NULL, CURRENT_TIMESTAMP - INTERVAL '3 hours'),
+			($2, 'pending', 'Ouagadougou', NULL, CURRENT_TIMESTAMP - INTERVAL '30 minutes'),
+			($3, 'in_transit', 'Koudougou', NULL, CURRENT_TIMESTAMP - INTERVAL '3 hours'),
+			($4, 'pending', 'Ouagadougou', NULL, CURRENT_TIMESTAMP - INTERVAL '3 hours')`,
+		staleID, freshID, staleTransitID, unassignedID,
+	)
+	assert.NoError(t, err)
+	_, err = db.Exec(`UPDATE shipments SET weight = 3 WHERE id = $1`, staleID)
+	assert.NoError(t, err)
+
+	req := withClaims(httptest.NewRequest("GET", "/api/shipments/stuck?status=pending&older_than=2h", nil), 1, "admin")
+	rr := httptest.NewRecorder()
+	handler.GetStuckShipments(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var shipments []models.StuckShipment
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipments))
+	assert.Len(t, shipments, 1)
+	assert.Equal(t, staleID, shipments[0].ID)
+	assert.NotNil(t, shipments[0].Driver)
+	assert.Equal(t, "Stuck Driver", shipments[0].Driver.Name)
+
+	t.Run("invalid duration", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("GET", "/api/shipments/stuck?older_than=soon", nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.GetStuckShipments(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestShipmentHandler_GetInactiveShipments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Inactive Client", "inactive@goexpress.com", "client")
+
+	idleID := seedShipment(t, db, "GEX0000D001", 1, clientID, "in_transit", 10, "2025-03-01 09:00:00")
+	movingID := seedShipment(t, db, "GEX0000D002", 1, clientID, "in_transit", 10, "2025-03-01 09:00:00")
+	deliveredID := seedShipment(t, db, "GEX0000D003", 1, clientID, "delivered", 10, "2025-03-01 09:00:00")
+
+	_, err := db.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location, timestamp) VALUES
+			($1, 'in_transit', 'Koudougou', '2025-03-02 09:00:00'),
+			($2, 'picked_up', 'Ouagadougou', '2025-03-02 09:00:00'),
+			($2, 'in_transit', 'Koudougou', '2025-03-20 09:00:00'),
+			($3, 'delivered', 'Bobo-Dioulasso', '2025-03-02 09:00:00')`,
+		idleID, movingID, deliveredID,
+	)
+	assert.NoError(t, err)
+
+	req := withClaims(httptest.NewRequest("GET", "/api/shipments/inactive?since=2025-03-10", nil), 1, "admin")
+	rr := httptest.NewRecorder()
+	handler.GetInactiveShipments(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var shipments []models.InactiveShipment
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipments))
+	assert.Len(t, shipments, 1)
+	assert.Equal(t, idleID, shipments[0].ID)
+	assert.Equal(t, "2025-03-02", shipments[0].LastEventAt.Format("2006-01-02"))
+
+	t.Run("since is required", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("GET", "/api/shipments/inactive", nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.GetInactiveShipments(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestShipmentHandler_GetShipmentStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetStatsCache(cache.NewTTLShipmentStats(time.Hour))
+	clientID := createTestUser(t, db, "Stats Client", "stats@goexpress.com", "client")
+	seedShipment(t, db, "GEX0000C001", 1, clientID, "pending", 10, "2025-03-01 09:00:00")
+
+	getStats := func() models.ShipmentStats {
+		req := withClaims(httptest.NewRequest("GET", "/api/shipments/stats", nil), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.GetShipmentStats(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var stats models.ShipmentStats
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+		return stats
+	}
+
+	stats := getStats()
+	assert.Equal(t, 1, stats.Total)
+	assert.Equal(t, 1, stats.ByStatus["pending"])
+
+	// Rows written behind the handler's back are not seen until the cache is invalidated
+	seedShipment(t, db, "GEX0000C002", 1, clientID, "pending", 10, "2025-03-01 09:00:00")
+	assert.Equal(t, 1, getStats().Total)
+
+	body := []byte(`{"origin": "Ouagadougou", "destination": "Bobo-Dioulasso", "weight": 2, "zone_id": 1}`)
+	req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), clientID, "client")
+	rr := httptest.NewRecorder()
+	handler.CreateShipment(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	stats = getStats()
+	assert.Equal(t, 3, stats.Total)
+	assert.Equal(t, 3, stats.ByStatus["pending"])
+}
+
+func TestShipmentHandler_GetShipmentStats_Approximate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetApproximateCounts(true)
+	clientID := createTestUser(t, db, "Approx Client", "approx@goexpress.com", "client")
+	for i := 1; i <= 5; i++ {
+		status := "pending"
+		if i > 3 {
+			status = "delivered"
+		}
+		seedShipment(t, db, fmt.Sprintf("GEX0A9C%04d", i), 1, clientID, status, 10, "2025-03-01 09:00:00")
+	}
+	_, err := db.Exec("ANALYZE shipments")
+	assert.NoError(t, err)
+	// Too rare, and too recent, for the planner's statistics to know about
+	seedShipment(t, db, "GEX0A9C0006", 1, clientID, "cancelled", 10, "2025-03-01 09:00:00")
+
+	req := withClaims(httptest.NewRequest("GET", "/api/shipments/stats", nil), 1, "admin")
+	rr := httptest.NewRecorder()
+	handler.GetShipmentStats(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var stats models.ShipmentStats
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	assert.True(t, stats.Approximate)
+	assert.Greater(t, stats.Total, 0)
+	// Per-status counts stay exact
+	assert.Equal(t, 3, stats.ByStatus["pending"])
+	assert.Equal(t, 2, stats.ByStatus["delivered"])
+	assert.Equal(t, 1, stats.ByStatus["cancelled"])
+}
+
+func TestShipmentHandler_CreateShipment_ZoneDailyCapacity(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Capacity Client", "capacity@goexpress.com", "client")
+	adminID := createTestUser(t, db, "Capacity Admin", "capacity-admin@goexpress.com", "admin")
+	_, err := db.Exec("UPDATE zones SET daily_capacity = 2 WHERE id = 1")
+	assert.NoError(t, err)
+
+	// Neither yesterday's shipments nor cancelled ones use up today's capacity
+	yesterday := time.Now().UTC().Add(-24 * time.Hour).Format("2006-01-02 15:04:05")
+	seedShipment(t, db, "GEX0CA90001", 1, clientID, "pending", 10, yesterday)
+	seedShipment(t, db, "GEX0CA90002", 1, clientID, "cancelled", 10, time.Now().UTC().Format("2006-01-02 15:04:05"))
+
+	create := func(userID int, role string, zoneID int) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"origin": "Ouagadougou", "destination": "Bobo-Dioulasso", "weight": 2, "zone_id": %d}`, zoneID)
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBufferString(body)), userID, role)
+		rr := httptest.NewRecorder()
+		handler.CreateShipment(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusCreated, create(clientID, "client", 1).Code)
+	assert.Equal(t, http.StatusCreated, create(clientID, "client", 1).Code)
+
+	rr := create(clientID, "client", 1)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "daily capacity")
+	resetsAt := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	assert.Contains(t, rr.Body.String(), resetsAt.Format(time.RFC3339))
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	t.Run("other zones are unaffected", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, create(clientID, "client", 3).Code)
+	})
+
+	t.Run("admins can override", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, create(adminID, "admin", 1).Code)
+	})
+}
+
+func TestShipmentHandler_TrackBatch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Batch Client", "batch@goexpress.com", "client")
+
+	inTransitID := seedShipment(t, db, "GEXBA7C0001", 1, clientID, "in_transit", 1500, "2025-07-01 09:00:00")
+	seedShipment(t, db, "GEXBA7C0002", 2, clientID, "pending", 900, "2025-07-02 09:00:00")
+
+	_, err := db.Exec(`
+		INSERT INTO tracking_updates (shipment_id, status, location, timestamp) VALUES
+		($1, 'picked_up', 'Ouagadougou', '2025-07-01 10:00:00'),
+		($1, 'in_transit', 'Koudougou', '2025-07-01 14:00:00')`,
+		inTransitID,
+	)
+	assert.NoError(t, err)
+
+	track := func(numbers []string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.TrackBatchRequest{TrackingNumbers: numbers})
+		req := httptest.NewRequest("POST", "/api/shipments/track-batch", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		handler.TrackBatch(rr, req)
+		return rr
+	}
+
+	t.Run("mix of known and unknown numbers", func(t *testing.T) {
+		rr := track([]string{"GEXBA7C0001", "GEXDEADBEEF", "GEXBA7C0002", "not-a-number"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var results []models.TrackBatchResult
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &results))
+		if !assert.Len(t, results, 4) {
+			return
+		}
+
+		assert.Equal(t, "GEXBA7C0001", results[0].TrackingNumber)
+		assert.True(t, results[0].Found)
+		assert.Equal(t, "in_transit", results[0].Status)
+		assert.Equal(t, "Koudougou", results[0].LastLocation)
+
+		assert.Equal(t, models.TrackBatchResult{TrackingNumber: "GEXDEADBEEF"}, results[1])
+
+		assert.True(t, results[2].Found)
+		assert.Equal(t, "pending", results[2].Status)
+		assert.Empty(t, results[2].LastLocation)
+
+		assert.Equal(t, models.TrackBatchResult{TrackingNumber: "not-a-number"}, results[3])
+	})
+
+	t.Run("rejects more than 50 numbers", func(t *testing.T) {
+		numbers := make([]string, models.MaxTrackBatchSize+1)
+		for i := range numbers {
+			numbers[i] = "GEXBA7C0001"
+		}
+		rr := track(numbers)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("rejects an empty batch", func(t *testing.T) {
+		rr := track(nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestShipmentHandler_UpdateShipmentStatusOwnership(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Status Client", "statusclient@goexpress.com", "client")
+	assignedID := createTestUser(t, db, "Assigned Driver", "assigned@goexpress.com", "driver")
+	otherDriverID := createTestUser(t, db, "Unassigned Driver", "unassigned@goexpress.com", "driver")
+
+	shipmentID := seedShipment(t, db, "GEXA551E001", 1, clientID, "pending", 1500, "2025-07-01 09:00:00")
+	_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", assignedID, shipmentID)
+	assert.NoError(t, err)
+
+	updateStatus := func(userID int, role, status string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		body, _ := json.Marshal(map[string]string{"status": status, "location": "Koudougou"})
+		req := withClaims(httptest.NewRequest("PUT", "/api/shipments/"+id+"/status", bytes.NewBuffer(body)), userID, role)
+		rr := httptest.NewRecorder()
+		authorized("PUT", "/api/shipments/{id}/status", handler.UpdateShipmentStatus).ServeHTTP(rr, req)
+		return rr
+	}
+
+	currentStatus := func() string {
+		var status string
+		db.QueryRow("SELECT status FROM shipments WHERE id = $1", shipmentID).Scan(&status)
+		return status
+	}
+
+	t.Run("unassigned driver cannot see the shipment", func(t *testing.T) {
+		rr := updateStatus(otherDriverID, "driver", "in_transit")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "pending", currentStatus())
+	})
+
+	t.Run("client is forbidden", func(t *testing.T) {
+		rr := updateStatus(clientID, "client", "delivered")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Equal(t, "pending", currentStatus())
+	})
+
+	t.Run("assigned driver succeeds", func(t *testing.T) {
+		rr := updateStatus(assignedID, "driver", "in_transit")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "in_transit", currentStatus())
+	})
+
+	t.Run("admin succeeds", func(t *testing.T) {
+		rr := updateStatus(1, "admin", "delivered")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "delivered", currentStatus())
+	})
+}
+
+func TestShipmentHandler_GetShipmentByIdOwnership(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	ownerID := createTestUser(t, db, "Owner Client", "ownerclient@goexpress.com", "client")
+	otherClientID := createTestUser(t, db, "Other Client", "otherclient@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Carrier Driver", "carrier@goexpress.com", "driver")
+
+	shipmentID := seedShipment(t, db, "GEX0000BEEF", 1, ownerID, "pending", 1500, "2025-07-01 09:00:00")
+	_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, shipmentID)
+	assert.NoError(t, err)
+
+	getShipment := func(userID int, role string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		req := httptest.NewRequest("GET", "/api/shipments/"+id, nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetShipmentById(rr, req)
+		return rr
+	}
+
+	t.Run("client reads their own shipment", func(t *testing.T) {
+		rr := getShipment(ownerID, "client")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.ShipmentResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, shipmentID, response.Shipment.ID)
+	})
+
+	t.Run("client cannot read someone else's shipment", func(t *testing.T) {
+		rr := getShipment(otherClientID, "client")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "GEX0000BEEF")
+	})
+
+	t.Run("assigned driver and admin can read it", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, getShipment(driverID, "driver").Code)
+		assert.Equal(t, http.StatusOK, getShipment(1, "admin").Code)
+	})
+}
+
+func TestShipmentHandler_HoldBlocksStatusChanges(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Hold Client", "hold@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Hold Driver", "holddriver@goexpress.com", "driver")
+	shipmentID := seedShipment(t, db, "GEX0401D001", 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+	_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, shipmentID)
+	assert.NoError(t, err)
+
+	id := strconv.Itoa(shipmentID)
+	call := func(action func(http.ResponseWriter, *http.Request), path, body string, userID int, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+path, bytes.NewBufferString(body))
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		action(rr, req)
+		return rr
+	}
+	updateStatus := func(status string) int {
+		return call(handler.UpdateShipmentStatus, "/status", `{"status": "`+status+`"}`, driverID, "driver").Code
+	}
+	currentStatus := func() string {
+		var status string
+		db.QueryRow("SELECT status FROM shipments WHERE id = $1", shipmentID).Scan(&status)
+		return status
+	}
+
+	t.Run("hold requires a reason", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, call(handler.HoldShipment, "/hold", `{}`, driverID, "driver").Code)
+	})
+
+	t.Run("clients cannot hold shipments", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments/"+id+"/hold", bytes.NewBufferString(`{"reason": "customs"}`)), clientID, "client")
+		rr := httptest.NewRecorder()
+		authorized("POST", "/api/shipments/{id}/hold", handler.HoldShipment).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("held shipment cannot change status", func(t *testing.T) {
+		rr := call(handler.HoldShipment, "/hold", `{"reason": "Awaiting customs clearance", "location": "Border post"}`, driverID, "driver")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.True(t, shipment.OnHold)
+		if assert.NotNil(t, shipment.HoldReason) {
+			assert.Equal(t, "Awaiting customs clearance", *shipment.HoldReason)
+		}
+
+		assert.Equal(t, http.StatusConflict, updateStatus("delivered"))
+		assert.Equal(t, "in_transit", currentStatus())
+
+		assert.Equal(t, http.StatusConflict, call(handler.HoldShipment, "/hold", `{"reason": "again"}`, 1, "admin").Code)
+	})
+
+	t.Run("released shipment can change status again", func(t *testing.T) {
+		rr := call(handler.ReleaseShipment, "/release", "", 1, "admin")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.False(t, shipment.OnHold)
+		assert.Nil(t, shipment.HoldReason)
+
+		assert.Equal(t, http.StatusOK, updateStatus("delivered"))
+		assert.Equal(t, "delivered", currentStatus())
+
+		assert.Equal(t, http.StatusConflict, call(handler.ReleaseShipment, "/release", "", 1, "admin").Code)
+	})
+
+	t.Run("hold and release are tracked", func(t *testing.T) {
+		var holds, releases int
+		db.QueryRow("SELECT COUNT(*) FROM tracking_updates WHERE shipment_id = $1 AND status = 'on_hold'", shipmentID).Scan(&holds)
+		db.QueryRow("SELECT COUNT(*) FROM tracking_updates WHERE shipment_id = $1 AND status = 'released'", shipmentID).Scan(&releases)
+		assert.Equal(t, 1, holds)
+		assert.Equal(t, 1, releases)
+	})
+}
+
+func TestShipmentHandler_GetFullShipment(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Full Client", "fullclient@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Full Driver", "fulldriver@goexpress.com", "driver")
+	shipmentID := seedShipment(t, db, "GEX0F000001", 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+
+	_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, shipmentID)
+	assert.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone)
+		VALUES ($1, 'Full SARL', 'Awa Ouedraogo', '+22670000004')`,
+		clientID,
+	)
+	assert.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO driver_profiles (user_id, phone, vehicle_type, vehicle_number)
+		VALUES ($1, '+22670000005', 'van', '11 GN 4321')`,
+		driverID,
+	)
+	assert.NoError(t, err)
+
+	getFull := func(userID int, role string) models.FullShipmentResponse {
+		id := strconv.Itoa(shipmentID)
+		req := httptest.NewRequest("GET", "/api/shipments/"+id+"/full", nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetFullShipment(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.FullShipmentResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("admin sees customer and driver details", func(t *testing.T) {
+		response := getFull(1, "admin")
+		assert.Equal(t, shipmentID, response.Shipment.ID)
+		assert.Equal(t, 1, response.Zone.ID)
+
+		assert.Equal(t, clientID, response.Customer.ID)
+		assert.Equal(t, "Full SARL", response.Customer.CompanyName)
+		assert.Equal(t, "Awa Ouedraogo", response.Customer.ContactPerson)
+		assert.Equal(t, "+22670000004", response.Customer.Phone)
+
+		if assert.NotNil(t, response.Driver) {
+			assert.Equal(t, "Full Driver", response.Driver.Name)
+			assert.Equal(t, "van", response.Driver.VehicleType)
+			assert.Equal(t, "11 GN 4321", response.Driver.VehicleNumber)
+		}
+	})
+
+	t.Run("customer gets a reduced view", func(t *testing.T) {
+		response := getFull(clientID, "client")
+		assert.Equal(t, "Full SARL", response.Customer.CompanyName)
+		if assert.NotNil(t, response.Driver) {
+			assert.Equal(t, "Full Driver", response.Driver.Name)
+			assert.Empty(t, response.Driver.Phone)
+			assert.Empty(t, response.Driver.VehicleNumber)
+		}
+	})
+}
+
+func TestShipmentHandler_GetShipmentByIdETag(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "ETag Client", "etag@goexpress.com", "client")
+	shipmentID := seedShipment(t, db, "GEXE7A60001", 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+
+	id := strconv.Itoa(shipmentID)
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/shipments/"+id, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		req = mux.SetURLVars(withClaims(req, clientID, "client"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		middleware.ETag(http.HandlerFunc(handler.GetShipmentById)).ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := get("")
+	assert.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	t.Run("unchanged shipment is not modified", func(t *testing.T) {
+		rr := get(etag)
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Empty(t, rr.Body.String())
+	})
+
+	t.Run("a status change gives a new tag", func(t *testing.T) {
+		_, err := db.Exec("UPDATE shipments SET status = 'delivered' WHERE id = $1", shipmentID)
+		assert.NoError(t, err)
+
+		rr := get(etag)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+	})
+}
+
+func TestShipmentHandler_GetShipmentStatuses(t *testing.T) {
+	handler := handlers.NewShipmentHandler(nil)
+
+	req := withClaims(httptest.NewRequest("GET", "/api/shipments/statuses", nil), 2, "client")
+	rr := httptest.NewRecorder()
+	handler.GetShipmentStatuses(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var statuses []string
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &statuses))
+	assert.Equal(t, models.ShipmentStatuses, statuses)
+	assert.Contains(t, statuses, "pending")
+	assert.Contains(t, statuses, "delivered")
+	assert.Contains(t, statuses, "cancelled")
+}
+
+func TestShipmentHandler_UpdateShipmentStatusRejectsUnknownStatus(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Unknown Status Client", "unknownstatus@goexpress.com", "client")
+	shipmentID := seedShipment(t, db, "GEX5747U501", 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+
+	id := strconv.Itoa(shipmentID)
+	req := httptest.NewRequest("PUT", "/api/shipments/"+id+"/status", bytes.NewBufferString(`{"status": "teleported"}`))
+	req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+	rr := httptest.NewRecorder()
+	handler.UpdateShipmentStatus(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var status string
+	db.QueryRow("SELECT status FROM shipments WHERE id = $1", shipmentID).Scan(&status)
+	assert.Equal(t, "in_transit", status)
+}
+
+func TestShipmentHandler_GetNextStatuses(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Next Client", "nextclient@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Next Driver", "nextdriver@goexpress.com", "driver")
+	shipmentID := seedShipment(t, db, "GEX0NEXT001", 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+	_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, shipmentID)
+	assert.NoError(t, err)
+
+	id := strconv.Itoa(shipmentID)
+	nextStatuses := func(userID int, role string) (int, models.NextStatusesResponse) {
+		req := httptest.NewRequest("GET", "/api/shipments/"+id+"/next-statuses", nil)
+		req = mux.SetURLVars(withClaims(req, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetNextStatuses(rr, req)
+
+		var response models.NextStatusesResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr.Code, response
+	}
+
+	t.Run("admin gets every transition", func(t *testing.T) {
+		code, response := nextStatuses(1, "admin")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "in_transit", response.Status)
+		assert.Equal(t, []string{"out_for_delivery", "delivered", "cancelled"}, response.NextStatuses)
+	})
+
+	t.Run("driver cannot cancel", func(t *testing.T) {
+		code, response := nextStatuses(driverID, "driver")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"out_for_delivery", "delivered"}, response.NextStatuses)
+	})
+
+	t.Run("client cannot change the status", func(t *testing.T) {
+		code, response := nextStatuses(clientID, "client")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Empty(t, response.NextStatuses)
+	})
+
+	t.Run("held shipment has no transitions", func(t *testing.T) {
+		_, err := db.Exec("UPDATE shipments SET on_hold = true, hold_reason = 'customs' WHERE id = $1", shipmentID)
+		assert.NoError(t, err)
+
+		code, response := nextStatuses(1, "admin")
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, response.OnHold)
+		assert.Empty(t, response.NextStatuses)
+	})
+}
+
+func TestShipmentHandler_UpdateShipmentStatusDeduplicates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetTrackingDedupeWindow(time.Minute)
+	clientID := createTestUser(t, db, "Dedupe Client", "dedupe@goexpress.com", "client")
+	shipmentID := seedShipment(t, db, "GEX0DD00001", 1, clientID, "pending", 1000, "2025-07-01 09:00:00")
+
+	id := strconv.Itoa(shipmentID)
+	update := func(body string) {
+		req := httptest.NewRequest("PUT", "/api/shipments/"+id+"/status", bytes.NewBufferString(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.UpdateShipmentStatus(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	trackingRows := func() int {
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM tracking_updates WHERE shipment_id = $1", shipmentID).Scan(&count)
+		return count
+	}
+
+	update(`{"status": "in_transit", "location": "Koudougou"}`)
+	update(`{"status": "in_transit", "location": "Koudougou"}`)
+	assert.Equal(t, 1, trackingRows())
+
+	update(`{"status": "in_transit", "location": "Boromo"}`)
+	assert.Equal(t, 2, trackingRows())
+}
+
+func TestShipmentHandler_PromoCodes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Promo Client", "promo@goexpress.com", "client")
+
+	_, err := db.Exec(`
+		INSERT INTO promo_codes (code, percent_off, valid_until, max_uses) VALUES
+			('WELCOME10', 10, CURRENT_TIMESTAMP + INTERVAL '1 day', 1),
+			('SUMMER24', 20, CURRENT_TIMESTAMP - INTERVAL '1 day', NULL)`)
+	assert.NoError(t, err)
+
+	var pricePerKg float64
+	assert.NoError(t, db.QueryRow("SELECT price_per_kg FROM zones WHERE id = 1").Scan(&pricePerKg))
+	fullPrice := math.Round(4*pricePerKg*100) / 100
+
+	quote := func(code string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.QuoteRequest{Weight: 4, ZoneID: 1, PromoCode: code})
+		rr := httptest.NewRecorder()
+		handler.GetQuote(rr, httptest.NewRequest("POST", "/api/quote", bytes.NewBuffer(body)))
+		return rr
+	}
+	createShipment := func(code string) *httptest.ResponseRecorder {
+		body := []byte(`{"origin": "Ouagadougou", "destination": "Kaya", "weight": 4, "zone_id": 1, "promo_code": "` + code + `"}`)
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), clientID, "client")
+		rr := httptest.NewRecorder()
+		handler.CreateShipment(rr, req)
+		return rr
+	}
+
+	t.Run("valid code discounts the quote", func(t *testing.T) {
+		rr := quote("welcome10")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.QuoteResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, "WELCOME10", response.PromoCode)
+		assert.InDelta(t, fullPrice*0.1, response.Discount, 0.01)
+		assert.InDelta(t, fullPrice-response.Discount, response.TotalPrice, 0.001)
+	})
+
+	t.Run("valid code discounts the shipment and counts a use", func(t *testing.T) {
+		rr := createShipment("WELCOME10")
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.InDelta(t, fullPrice*0.1, shipment.Discount, 0.01)
+		assert.InDelta(t, fullPrice-shipment.Discount, shipment.Cost, 0.001)
+
+		var used int
+		db.QueryRow("SELECT used_count FROM promo_codes WHERE code = 'WELCOME10'").Scan(&used)
+		assert.Equal(t, 1, used)
+	})
+
+	t.Run("exhausted code is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, createShipment("WELCOME10").Code)
+	})
+
+	t.Run("expired code is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, quote("SUMMER24").Code)
+
+		var before, after int
+		db.QueryRow("SELECT COUNT(*) FROM shipments").Scan(&before)
+		assert.Equal(t, http.StatusBadRequest, createShipment("SUMMER24").Code)
+		db.QueryRow("SELECT COUNT(*) FROM shipments").Scan(&after)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("unknown code is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, quote("NOPE").Code)
+	})
+}
+
+func TestShipmentHandler_ValidateTrackingNumbers(t *testing.T) {
+	// No database: validation is purely about the format
+	handler := handlers.NewShipmentHandler(nil)
+
+	validate := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/shipments/validate-tracking", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		handler.ValidateTrackingNumbers(rr, req)
+		return rr
+	}
+
+	rr := validate(`["GEX1A2B3C4D", "GEX12345", "ABC1A2B3C4D", "", "GEXFFFFFFFF"]`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var results []models.TrackingValidation
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &results))
+	assert.Equal(t, []models.TrackingValidation{
+		{TrackingNumber: "GEX1A2B3C4D", Valid: true},
+		{TrackingNumber: "GEX12345", Valid: false},
+		{TrackingNumber: "ABC1A2B3C4D", Valid: false},
+		{TrackingNumber: "", Valid: false},
+		{TrackingNumber: "GEXFFFFFFFF", Valid: true},
+	}, results)
+
+	assert.Equal(t, http.StatusBadRequest, validate(`[]`).Code)
+	assert.Equal(t, http.StatusBadRequest, validate(`{"tracking_numbers": ["GEX1A2B3C4D"]}`).Code)
+}
+
+func TestShipmentHandler_GetTrackingFormat(t *testing.T) {
+	// No database: the example is generated but never persisted
+	handler := handlers.NewShipmentHandler(nil)
+
+	req := httptest.NewRequest("GET", "/api/shipments/tracking-format", nil)
+	rr := httptest.NewRecorder()
+	handler.GetTrackingFormat(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var format models.TrackingFormat
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &format))
+	assert.Equal(t, "GEX", format.Prefix)
+	assert.Equal(t, 11, format.Length)
+	assert.Len(t, format.Example, format.Length)
+	assert.True(t, utils.ValidateTrackingNumber(format.Example), "example %q should validate", format.Example)
+}
+
+func TestShipmentHandler_ReopenShipment(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetAuditLog(audit.NewLog(db.DB))
+	clientID := createTestUser(t, db, "Reopen Client", "reopen@goexpress.com", "client")
+	otherID := createTestUser(t, db, "Recreate Client", "recreate@goexpress.com", "client")
+
+	cancelledID := seedShipment(t, db, "GEX0C0FFEE1", 1, clientID, "cancelled", 1500, "2025-07-01 09:00:00")
+	pendingID := seedShipment(t, db, "GEX0C0FFEE2", 1, otherID, "pending", 1500, "2025-07-01 09:00:00")
+	recreatedID := seedShipment(t, db, "GEX0C0FFEE3", 1, otherID, "cancelled", 1500, "2025-07-02 09:00:00")
+	seedShipment(t, db, "GEX0C0FFEE4", 1, otherID, "pending", 1500, "2025-07-02 10:00:00")
+
+	driverID := createTestUser(t, db, "Reopen Driver", "reopendriver@goexpress.com", "driver")
+	_, err := db.Exec("UPDATE shipments SET driver_id = $1, accepted_at = CURRENT_TIMESTAMP WHERE id = $2", driverID, cancelledID)
+	assert.NoError(t, err)
+
+	reopen := func(shipmentID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/reopen", nil)
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.ReopenShipment(rr, req)
+		return rr
+	}
+
+	t.Run("cancelled shipment goes back to pending", func(t *testing.T) {
+		rr := reopen(cancelledID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.Equal(t, "pending", shipment.Status)
+		assert.Nil(t, shipment.DriverID)
+		assert.Nil(t, shipment.AcceptedAt)
+
+		var trackingStatus string
+		db.QueryRow(`
+			SELECT status FROM tracking_updates WHERE shipment_id = $1
+			ORDER BY timestamp DESC, id DESC LIMIT 1`, cancelledID,
+		).Scan(&trackingStatus)
+		assert.Equal(t, "pending", trackingStatus)
+
+		var actorID, userID int
+		err := db.QueryRow(`
+			SELECT actor_id, user_id FROM audit_log WHERE action = $1`,
+			"reopen_shipment "+strconv.Itoa(cancelledID),
+		).Scan(&actorID, &userID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, actorID)
+		assert.Equal(t, clientID, userID)
+	})
+
+	t.Run("non-cancelled shipment is rejected", func(t *testing.T) {
+		rr := reopen(pendingID)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("re-created shipment is rejected", func(t *testing.T) {
+		rr := reopen(recreatedID)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Contains(t, rr.Body.String(), "re-created")
+
+		var status string
+		db.QueryRow("SELECT status FROM shipments WHERE id = $1", recreatedID).Scan(&status)
+		assert.Equal(t, "cancelled", status)
+	})
+
+	t.Run("unknown shipment", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, reopen(99999).Code)
+	})
+}
+
+func TestShipmentHandler_RezoneShipment(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetAuditLog(audit.NewLog(db.DB))
+	clientID := createTestUser(t, db, "Rezone Client", "rezone@goexpress.com", "client")
+
+	// Seeded shipments weigh 2kg; zone 1 costs 3.50/kg and zone 3 8.50/kg
+	shipmentID := seedShipment(t, db, "GEX0D0E0001", 1, clientID, "in_transit", 7, "2025-07-01 09:00:00")
+	promoID := seedShipment(t, db, "GEX0D0E0002", 1, clientID, "pending", 6.3, "2025-07-01 09:00:00")
+	deliveredID := seedShipment(t, db, "GEX0D0E0003", 1, clientID, "delivered", 7, "2025-07-01 09:00:00")
+
+	_, err := db.Exec(`
+		WITH promo AS (
+			INSERT INTO promo_codes (code, percent_off) VALUES ('REZONE10', 10) RETURNING id
+		)
+		UPDATE shipments SET promo_code_id = (SELECT id FROM promo), discount = 0.7 WHERE id = $1`,
+		promoID,
+	)
+	assert.NoError(t, err)
+
+	rezone := func(shipmentID, zoneID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		body, _ := json.Marshal(models.RezoneRequest{ZoneID: zoneID})
+		req := httptest.NewRequest("PUT", "/api/shipments/"+id+"/zone", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.RezoneShipment(rr, req)
+		return rr
+	}
+
+	t.Run("cost follows the new zone's pricing", func(t *testing.T) {
+		rr := rezone(shipmentID, 3)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.Equal(t, 3, shipment.ZoneID)
+		assert.Equal(t, 17.0, shipment.Cost)
+		assert.Equal(t, "in_transit", shipment.Status)
+
+		var cost float64
+		db.QueryRow("SELECT cost FROM shipments WHERE id = $1", shipmentID).Scan(&cost)
+		assert.Equal(t, 17.0, cost)
+
+		var status, note string
+		db.QueryRow(`
+			SELECT status, note FROM tracking_updates WHERE shipment_id = $1
+			ORDER BY timestamp DESC, id DESC LIMIT 1`, shipmentID,
+		).Scan(&status, &note)
+		assert.Equal(t, "in_transit", status)
+		assert.Contains(t, note, "Re-zoned to National Express")
+
+		var audited int
+		db.QueryRow("SELECT COUNT(*) FROM audit_log WHERE action = $1",
+			fmt.Sprintf("rezone_shipment %d 1->3", shipmentID)).Scan(&audited)
+		assert.Equal(t, 1, audited)
+	})
+
+	t.Run("redeemed promo code is applied again", func(t *testing.T) {
+		rr := rezone(promoID, 3)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.Equal(t, 15.3, shipment.Cost)
+		assert.Equal(t, 1.7, shipment.Discount)
+	})
+
+	t.Run("delivered shipments are refused", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, rezone(deliveredID, 3).Code)
+	})
+
+	t.Run("unknown zone", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, rezone(shipmentID, 9999).Code)
+	})
+}
+
+func TestShipmentHandler_RescheduleShipment(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Reschedule Client", "reschedule@goexpress.com", "client")
+	otherID := createTestUser(t, db, "Other Client", "reschedule-other@goexpress.com", "client")
+
+	shipmentID := seedShipment(t, db, "GEX0D0F0001", 1, clientID, "in_transit", 7, "2025-07-01 09:00:00")
+	deliveredID := seedShipment(t, db, "GEX0D0F0002", 1, clientID, "delivered", 7, "2025-07-01 09:00:00")
+
+	newDate := time.Now().UTC().Add(72 * time.Hour).Truncate(time.Second)
+	window := 120
+
+	reschedule := func(shipmentID, userID int, role string, req models.RescheduleRequest) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest("POST", "/api/shipments/"+id+"/reschedule", bytes.NewBuffer(body))
+		r = mux.SetURLVars(withClaims(r, userID, role), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.RescheduleShipment(rr, r)
+		return rr
+	}
+
+	t.Run("owner moves the estimate and a tracking update is written", func(t *testing.T) {
+		rr := reschedule(shipmentID, clientID, "client", models.RescheduleRequest{
+			EstimatedDelivery: newDate,
+			DeliveryWindow:    &window,
+			Reason:            "Recipient away",
+		})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		if assert.NotNil(t, shipment.EstimatedDelivery) {
+			assert.True(t, newDate.Equal(shipment.EstimatedDelivery.Time))
+		}
+		if assert.NotNil(t, shipment.DeliveryWindow) {
+			assert.Equal(t, 120, *shipment.DeliveryWindow)
+		}
+		assert.Equal(t, "in_transit", shipment.Status)
+
+		var status, note string
+		db.QueryRow(`
+			SELECT status, note FROM tracking_updates WHERE shipment_id = $1
+			ORDER BY timestamp DESC, id DESC LIMIT 1`, shipmentID,
+		).Scan(&status, &note)
+		assert.Equal(t, "rescheduled", status)
+		assert.Contains(t, note, newDate.Format(time.RFC3339))
+		assert.Contains(t, note, "Recipient away")
+	})
+
+	t.Run("delivered shipments cannot be rescheduled", func(t *testing.T) {
+		rr := reschedule(deliveredID, clientID, "client", models.RescheduleRequest{EstimatedDelivery: newDate})
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("date must be in the future", func(t *testing.T) {
+		rr := reschedule(shipmentID, clientID, "client", models.RescheduleRequest{EstimatedDelivery: time.Now().Add(-time.Hour)})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("other clients and unassigned drivers get a 404", func(t *testing.T) {
+		rr := reschedule(shipmentID, otherID, "client", models.RescheduleRequest{EstimatedDelivery: newDate})
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+
+		driverID := createTestUser(t, db, "Reschedule Driver", "reschedule-driver@goexpress.com", "driver")
+		rr = reschedule(shipmentID, driverID, "driver", models.RescheduleRequest{EstimatedDelivery: newDate})
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+
+		_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, shipmentID)
+		assert.NoError(t, err)
+		rr = reschedule(shipmentID, driverID, "driver", models.RescheduleRequest{EstimatedDelivery: newDate})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
+
+func TestShipmentHandler_RespondToAssignment(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	handler.SetAuditLog(audit.NewLog(db.DB))
+	clientID := createTestUser(t, db, "Respond Client", "respond@goexpress.com", "client")
+	driverID := createTestUser(t, db, "Respond Driver", "respond-driver@goexpress.com", "driver")
+	otherDriverID := createTestUser(t, db, "Other Driver", "other-driver@goexpress.com", "driver")
+
+	assigned := func(trackingNumber string) int {
+		id := seedShipment(t, db, trackingNumber, 1, clientID, "pending", 1000, "2025-07-01 09:00:00")
+		_, err := db.Exec("UPDATE shipments SET driver_id = $1 WHERE id = $2", driverID, id)
+		assert.NoError(t, err)
+		return id
+	}
+
+	respond := func(shipmentID, userID int, accept bool, reason string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		body, _ := json.Marshal(models.AssignmentResponse{Accept: &accept, Reason: reason})
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/respond", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, userID, "driver"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.RespondToAssignment(rr, req)
+		return rr
+	}
+
+	lastResponse := func(shipmentID int) (respondent int, accepted bool, reason string) {
+		db.QueryRow(`
+			SELECT driver_id, accepted, COALESCE(reason, '') FROM assignment_responses WHERE shipment_id = $1
+			ORDER BY created_at DESC, id DESC LIMIT 1`, shipmentID,
+		).Scan(&respondent, &accepted, &reason)
+		return respondent, accepted, reason
+	}
+	trackingUpdates := func(shipmentID int) int {
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM tracking_updates WHERE shipment_id = $1", shipmentID).Scan(&count)
+		return count
+	}
+
+	t.Run("accepting keeps the driver and records acceptance", func(t *testing.T) {
+		shipmentID := assigned("GEX0A0C0001")
+		rr := respond(shipmentID, driverID, true, "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.Equal(t, "pending", shipment.Status)
+		assert.NotNil(t, shipment.AcceptedAt)
+		if assert.NotNil(t, shipment.DriverID) {
+			assert.Equal(t, driverID, *shipment.DriverID)
+		}
+
+		respondent, accepted, _ := lastResponse(shipmentID)
+		assert.Equal(t, driverID, respondent)
+		assert.True(t, accepted)
+		assert.Zero(t, trackingUpdates(shipmentID), "responses stay off the customer timeline")
+
+		// Only once, and it can no longer be declined
+		assert.Equal(t, http.StatusConflict, respond(shipmentID, driverID, true, "").Code)
+		assert.Equal(t, http.StatusConflict, respond(shipmentID, driverID, false, "changed my mind").Code)
+	})
+
+	t.Run("declining returns the shipment to unassigned", func(t *testing.T) {
+		shipmentID := assigned("GEX0A0C0002")
+		rr := respond(shipmentID, driverID, false, "vehicle broke down")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.Nil(t, shipment.DriverID)
+		assert.Equal(t, "pending", shipment.Status)
+
+		respondent, accepted, reason := lastResponse(shipmentID)
+		assert.Equal(t, driverID, respondent)
+		assert.False(t, accepted)
+		assert.Equal(t, "vehicle broke down", reason)
+		assert.Zero(t, trackingUpdates(shipmentID), "responses stay off the customer timeline")
+
+		var actorID int
+		err := db.QueryRow("SELECT actor_id FROM audit_log WHERE action = $1",
+			fmt.Sprintf("decline_assignment %d", shipmentID)).Scan(&actorID)
+		assert.NoError(t, err)
+		assert.Equal(t, driverID, actorID)
+	})
+
+	t.Run("only the assigned driver may respond", func(t *testing.T) {
+		shipmentID := assigned("GEX0A0C0003")
+		assert.Equal(t, http.StatusNotFound, respond(shipmentID, otherDriverID, true, "").Code)
+	})
+
+	t.Run("picked up shipments cannot be declined", func(t *testing.T) {
+		shipmentID := assigned("GEX0A0C0004")
+		_, err := db.Exec("UPDATE shipments SET status = 'picked_up' WHERE id = $1", shipmentID)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, respond(shipmentID, driverID, false, "").Code)
+	})
+}
+
+func TestListEndpoints_EmptyResultIsArray(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	shipmentHandler := handlers.NewShipmentHandler(db.DB)
+	userHandler := handlers.NewUserHandler(db.DB, "test-secret", 5)
+	clientID := createTestUser(t, db, "Empty Client", "empty@goexpress.com", "client")
+
+	list := func(h http.HandlerFunc, target string, userID int, role string) string {
+		req := withClaims(httptest.NewRequest("GET", target, nil), userID, role)
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+
+	t.Run("shipments", func(t *testing.T) {
+		assert.JSONEq(t, `[]`, list(shipmentHandler.GetShipments, "/api/shipments", clientID, "client"))
+	})
+
+	t.Run("users", func(t *testing.T) {
+		assert.JSONEq(t, `[]`, list(userHandler.GetUsers, "/api/users?role=nobody", 1, "admin"))
+	})
+}
+
+func TestShipmentHandler_SearchShipments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Search Client", "search@goexpress.com", "client")
+	otherID := createTestUser(t, db, "Other Search Client", "other-search@goexpress.com", "client")
+
+	// Seeded shipments go from Ouagadougou to Bobo-Dioulasso
+	originID := seedShipment(t, db, "GEX05EA0001", 1, clientID, "pending", 1000, "2025-07-01 09:00:00")
+	destinationID := seedShipment(t, db, "GEX05EA0002", 1, clientID, "pending", 1000, "2025-07-02 09:00:00")
+	_, err := db.Exec("UPDATE shipments SET origin = 'Koudougou', destination = 'Ouahigouya' WHERE id = $1", destinationID)
+	assert.NoError(t, err)
+	seedShipment(t, db, "GEX05EA0003", 1, otherID, "pending", 1000, "2025-07-03 09:00:00")
+
+	search := func(q string, userID int, role string) []models.ShipmentSearchResult {
+		req := withClaims(httptest.NewRequest("GET", "/api/shipments/search?q="+q, nil), userID, role)
+		rr := httptest.NewRecorder()
+		handler.SearchShipments(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var results []models.ShipmentSearchResult
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &results))
+		return results
+	}
+
+	t.Run("reports an origin match", func(t *testing.T) {
+		results := search("ouaga", clientID, "client")
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, originID, results[0].ID)
+			assert.Equal(t, "origin", results[0].MatchedField)
+		}
+	})
+
+	t.Run("reports tracking and destination matches", func(t *testing.T) {
+		results := search("ouahi", clientID, "client")
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, destinationID, results[0].ID)
+			assert.Equal(t, "destination", results[0].MatchedField)
+		}
+
+		results = search("05ea0001", clientID, "client")
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, "tracking_number", results[0].MatchedField)
+		}
+	})
+
+	t.Run("admins search every shipment", func(t *testing.T) {
+		assert.Len(t, search("05EA", 1, "admin"), 3)
+	})
+
+	t.Run("wildcards match literally", func(t *testing.T) {
+		assert.Empty(t, search("%25", 1, "admin"))
+		assert.Empty(t, search("_", 1, "admin"))
+		assert.Empty(t, search("GEX05EA000_", 1, "admin"))
+		assert.Empty(t, search(`%5C`, 1, "admin"))
+	})
+
+	t.Run("missing query", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("GET", "/api/shipments/search", nil), clientID, "client")
+		rr := httptest.NewRecorder()
+		handler.SearchShipments(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestShipmentHandler_PriorityPricing(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Priority Client", "priority@goexpress.com", "client")
+
+	quote := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/quote", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		handler.GetQuote(rr, req)
+		return rr
+	}
+	priced := func(priority string) models.QuoteResponse {
+		rr := quote(`{"weight": 2, "zone_id": 1, "priority": "` + priority + `"}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response models.QuoteResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response
+	}
+
+	// Zone 1 charges 3.50/kg, so 2 kg is 7.00 at standard priority
+	standard := priced("standard")
+	assert.Equal(t, 7.0, standard.TotalPrice)
+	assert.Equal(t, 0.0, standard.PrioritySurcharge)
+
+	express := priced("express")
+	assert.Equal(t, 1.5, express.PriorityMultiplier)
+	assert.Equal(t, 10.5, express.TotalPrice)
+	assert.Equal(t, 3.5, express.PrioritySurcharge)
+
+	assert.Equal(t, 14.0, priced("overnight").TotalPrice)
+
+	rr := quote(`{"weight": 2, "zone_id": 1}`)
+	var defaulted models.QuoteResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &defaulted))
+	assert.Equal(t, "standard", defaulted.Priority)
+	assert.Equal(t, 7.0, defaulted.TotalPrice)
+
+	assert.Equal(t, http.StatusBadRequest, quote(`{"weight": 2, "zone_id": 1, "priority": "urgent"}`).Code)
+
+	t.Run("multipliers are configurable", func(t *testing.T) {
+		handler.SetPriorityMultipliers(1.25, 3)
+		defer handler.SetPriorityMultipliers(1.5, 2)
+
+		assert.Equal(t, 8.75, priced("express").TotalPrice)
+		assert.Equal(t, 21.0, priced("overnight").TotalPrice)
+	})
+
+	t.Run("shipments store the priority and itemize the surcharge", func(t *testing.T) {
+		_, err := db.Exec(`INSERT INTO promo_codes (code, percent_off) VALUES ('RUSH10', 10)`)
+		assert.NoError(t, err)
+
+		body := `{"origin": "Ouagadougou", "destination": "Kaya", "weight": 2, "zone_id": 1, "priority": "express", "promo_code": "RUSH10"}`
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBufferString(body)), clientID, "client")
+		rr := httptest.NewRecorder()
+		handler.CreateShipment(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var shipment models.Shipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+		assert.Equal(t, "express", shipment.Priority)
+		// The promo is taken off the price including the surcharge
+		assert.Equal(t, 9.45, shipment.Cost)
+		assert.Equal(t, 1.05, shipment.Discount)
+
+		id := strconv.Itoa(shipment.ID)
+		req = httptest.NewRequest("GET", "/api/shipments/"+id+"/cost", nil)
+		req = mux.SetURLVars(withClaims(req, clientID, "client"), map[string]string{"id": id})
+		rr = httptest.NewRecorder()
+		handler.GetShipmentCost(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var breakdown models.CostBreakdown
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &breakdown))
+		if assert.Len(t, breakdown.Components, 3) {
+			assert.Equal(t, models.CostComponent{Code: "base", Label: breakdown.Components[0].Label, Amount: 7}, breakdown.Components[0])
+			assert.Equal(t, "priority", breakdown.Components[1].Code)
+			assert.Equal(t, 3.5, breakdown.Components[1].Amount)
+			assert.Equal(t, "discount", breakdown.Components[2].Code)
+			assert.Equal(t, -1.05, breakdown.Components[2].Amount)
+		}
+	})
+}
+
+func TestShipmentHandler_GetShipmentCost(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewShipmentHandler(db.DB)
+	clientID := createTestUser(t, db, "Cost Client", "cost@goexpress.com", "client")
+	otherID := createTestUser(t, db, "Other Cost Client", "other-cost@goexpress.com", "client")
+
+	_, err := db.Exec(`INSERT INTO promo_codes (code, percent_off) VALUES ('COST15', 15)`)
+	assert.NoError(t, err)
+
+	body := []byte(`{"origin": "Ouagadougou", "destination": "Kaya", "weight": 3, "zone_id": 1, "promo_code": "COST15"}`)
+	req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), clientID, "client")
+	rr := httptest.NewRecorder()
+	handler.CreateShipment(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var shipment models.Shipment
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipment))
+
+	getCost := func(shipmentID, userID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		req := httptest.NewRequest("GET", "/api/shipments/"+id+"/cost", nil)
+		req = mux.SetURLVars(withClaims(req, userID, "client"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetShipmentCost(rr, req)
+		return rr
+	}
+
+	sum := func(components []models.CostComponent) float64 {
+		var total float64
+		for _, c := range components {
+			total += c.Amount
+		}
+		return total
+	}
+
+	t.Run("components sum to the stored cost", func(t *testing.T) {
+		rr := getCost(shipment.ID, clientID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var breakdown models.CostBreakdown
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &breakdown))
+		assert.Equal(t, shipment.Cost, breakdown.Total)
+		if assert.Len(t, breakdown.Components, 2) {
+			assert.Equal(t, "base", breakdown.Components[0].Code)
+			assert.Equal(t, "discount", breakdown.Components[1].Code)
+			assert.Equal(t, -shipment.Discount, breakdown.Components[1].Amount)
+		}
+		assert.InDelta(t, shipment.Cost, sum(breakdown.Components), 0.001)
+	})
+
+	t.Run("derived for shipments without a stored breakdown", func(t *testing.T) {
+		legacyID := seedShipment(t, db, "GEX0C05E001", 1, clientID, "pending", 7, "2025-07-01 09:00:00")
+
+		var breakdown models.CostBreakdown
+		assert.NoError(t, json.Unmarshal(getCost(legacyID, clientID).Body.Bytes(), &breakdown))
+		assert.Len(t, breakdown.Components, 1)
+		assert.InDelta(t, 7.0, sum(breakdown.Components), 0.001)
+	})
+
+	t.Run("other clients cannot see it", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, getCost(shipment.ID, otherID).Code)
+	})
+}
+
+func TestTrackingAssigner_Stop(t *testing.T) {
+	// The sweeps fail against an unreachable database; only the lifecycle
+	// matters here.
+	unreachable, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	assert.NoError(t, err)
+	defer unreachable.Close()
+
+	stopped := func(assigner *handlers.TrackingAssigner) bool {
+		done := make(chan struct{})
+		go func() {
+			assigner.Stop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return true
+		case <-time.After(5 * time.Second):
+			return false
+		}
+	}
+
+	running := handlers.NewTrackingAssigner(unreachable, 10*time.Millisecond)
+	running.Start()
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, stopped(running), "a running assigner stops")
+	assert.True(t, stopped(running), "stopping twice is harmless")
+
+	idle := handlers.NewTrackingAssigner(unreachable, 10*time.Millisecond)
+	assert.True(t, stopped(idle), "an assigner that never started stops")
+	idle.Start()
+	assert.True(t, stopped(idle), "starting after stop does nothing")
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackingLinkHandler_ResolvesLink(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewTrackingLinkHandler(db.DB, "test-tracking-secret")
+	ownerID := createTestUser(t, db, "Link Owner", "linkowner@goexpress.com", "client")
+	strangerID := createTestUser(t, db, "Link Stranger", "linkstranger@goexpress.com", "client")
+	shipmentID := seedShipment(t, db, "GEXF00D1234", 1, ownerID, "in_transit", 1200, "2025-07-03 08:00:00")
+
+	createLink := func(userID int, body string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shipmentID)
+		req := httptest.NewRequest("POST", "/api/shipments/"+id+"/tracking-link", bytes.NewBufferString(body))
+		req = mux.SetURLVars(withClaims(req, userID, "client"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.CreateTrackingLink(rr, req)
+		return rr
+	}
+
+	t.Run("only the owner can mint a link", func(t *testing.T) {
+		rr := createLink(strangerID, "")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("minted link resolves the shipment", func(t *testing.T) {
+		rr := createLink(ownerID, `{"expires_in_hours": 24}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var link models.TrackingLinkResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &link))
+		assert.NotNil(t, link.ExpiresAt)
+		assert.True(t, strings.HasPrefix(link.URL, "/api/track?token="))
+		assert.NotContains(t, link.Token, "GEXF00D1234")
+
+		rr = httptest.NewRecorder()
+		handler.Track(rr, httptest.NewRequest("GET", link.URL, nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		assert.NotContains(t, rr.Body.String(), "GEXF00D1234")
+		assert.NotContains(t, rr.Body.String(), "tracking_number")
+
+		var response models.TrackedShipment
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, "in_transit", response.Status)
+	})
+
+	t.Run("links without expiry", func(t *testing.T) {
+		rr := createLink(ownerID, "")
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var link models.TrackingLinkResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &link))
+		assert.Nil(t, link.ExpiresAt)
+
+		rr = httptest.NewRecorder()
+		handler.Track(rr, httptest.NewRequest("GET", link.URL, nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
+
+func TestTrackingLinkHandler_RejectsBadTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewTrackingLinkHandler(db.DB, "test-tracking-secret")
+	ownerID := createTestUser(t, db, "Link Owner", "linkowner@goexpress.com", "client")
+	seedShipment(t, db, "GEXF00D1234", 1, ownerID, "in_transit", 1200, "2025-07-03 08:00:00")
+
+	track := func(token string) int {
+		req := httptest.NewRequest("GET", "/api/track?token="+url.QueryEscape(token), nil)
+		rr := httptest.NewRecorder()
+		handler.Track(rr, req)
+		return rr.Code
+	}
+
+	t.Run("tampered token", func(t *testing.T) {
+		token, err := utils.SignTrackingToken("GEXF00D1234", time.Now().Add(time.Hour), "test-tracking-secret")
+		assert.NoError(t, err)
+
+		parts := strings.Split(token, ".")
+		parts[1] = strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10)
+		assert.Equal(t, http.StatusForbidden, track(strings.Join(parts, ".")))
+	})
+
+	t.Run("token signed with another secret", func(t *testing.T) {
+		token, err := utils.SignTrackingToken("GEXF00D1234", time.Time{}, "another-secret")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, track(token))
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		token, err := utils.SignTrackingToken("GEXF00D1234", time.Now().Add(-time.Minute), "test-tracking-secret")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusGone, track(token))
+	})
+
+	t.Run("file tokens are not tracking tokens", func(t *testing.T) {
+		token := utils.SignFileToken(1, time.Now().Add(time.Minute), "test-tracking-secret")
+		assert.Equal(t, http.StatusForbidden, track(token))
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, track(""))
+	})
+}
+
+func TestTrackingToken_SealsTrackingNumber(t *testing.T) {
+	token, err := utils.SignTrackingToken("GEXF00D1234", time.Time{}, "test-tracking-secret")
+	assert.NoError(t, err)
+
+	payload := strings.Split(token, ".")[0]
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(decoded), "GEXF00D1234")
+
+	trackingNumber, err := utils.VerifyTrackingToken(token, "test-tracking-secret", time.Now().AddDate(10, 0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "GEXF00D1234", trackingNumber)
+}
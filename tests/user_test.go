@@ -0,0 +1,459 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"goexpress-api/handlers"
+	"goexpress-api/middleware"
+	"goexpress-api/models"
+	"goexpress-api/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCSVUploadRequest(t *testing.T, url, csvData string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "users.csv")
+	assert.NoError(t, err)
+	part.Write([]byte(csvData))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", url, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUserHandler_ImportUsers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewUserHandler(db.DB, "test-secret", 5)
+
+	_, err := db.Exec(`INSERT INTO users (name, email, password_hash, role) VALUES ('Existing Driver', 'existing@goexpress.com', 'hash', 'driver')`)
+	assert.NoError(t, err)
+
+	csvData := "name,email,role,password\n" +
+		"Driver One,driver1@goexpress.com,driver,password123\n" +
+		"Driver Two,driver2@goexpress.com,driver,password123\n" +
+		"Driver One Again,driver1@goexpress.com,driver,password123\n" +
+		"Existing Driver,existing@goexpress.com,driver,password123\n"
+
+	t.Run("mix of valid and duplicate rows", func(t *testing.T) {
+		req := withClaims(newCSVUploadRequest(t, "/api/users/import", csvData), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.ImportUsers(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response models.UserImportResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, response.Created)
+		assert.Equal(t, 2, response.Failed)
+		assert.Len(t, response.Results, 4)
+		assert.Equal(t, "created", response.Results[0].Status)
+		assert.Equal(t, "created", response.Results[1].Status)
+		assert.Equal(t, "error", response.Results[2].Status)
+		assert.Equal(t, "error", response.Results[3].Status)
+
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM users WHERE email IN ('driver1@goexpress.com', 'driver2@goexpress.com')").Scan(&count)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("strict mode imports nothing on failure", func(t *testing.T) {
+		strictCSV := "name,email,role,password\n" +
+			"Driver Three,driver3@goexpress.com,driver,password123\n" +
+			"Existing Driver,existing@goexpress.com,driver,password123\n"
+
+		req := withClaims(newCSVUploadRequest(t, "/api/users/import?strict=true", strictCSV), 1, "admin")
+		rr := httptest.NewRecorder()
+		handler.ImportUsers(rr, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM users WHERE email = 'driver3@goexpress.com'").Scan(&count)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("non-admin forbidden", func(t *testing.T) {
+		req := withClaims(newCSVUploadRequest(t, "/api/users/import", csvData), 2, "client")
+		rr := httptest.NewRecorder()
+		authorized("POST", "/api/users/import", handler.ImportUsers).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestUserHandler_PasswordHistory(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewUserHandler(db.DB, "test-secret", 5)
+
+	hash, err := utils.HashPassword("password123")
+	assert.NoError(t, err)
+	var userID int
+	err = db.QueryRow(`
+		INSERT INTO users (name, email, password_hash, role) 
+		VALUES ('History User', 'history@goexpress.com', $1, 'client') RETURNING id`,
+		hash,
+	).Scan(&userID)
+	assert.NoError(t, err)
+
+	changePassword := func(current, next string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.ChangePasswordRequest{
+			CurrentPassword: current,
+			NewPassword:     next,
+			ConfirmPassword: next,
+		})
+		req := withClaims(httptest.NewRequest("POST", "/api/users/change-password", bytes.NewBuffer(body)), userID, "client")
+		rr := httptest.NewRecorder()
+		handler.ChangePassword(rr, req)
+		return rr
+	}
+
+	rr := changePassword("password123", "newpass456")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	t.Run("reusing the previous password is rejected", func(t *testing.T) {
+		rr := changePassword("newpass456", "password123")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("admin reset to a recent password is rejected", func(t *testing.T) {
+		id := strconv.Itoa(userID)
+		body := bytes.NewBufferString(`{"new_password": "newpass456"}`)
+		req := withClaims(httptest.NewRequest("POST", "/api/users/"+id+"/reset-password", body), 1, "admin")
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.ResetPassword(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("a new password is accepted", func(t *testing.T) {
+		rr := changePassword("newpass456", "freshpass789")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("passwords further back in the history are rejected", func(t *testing.T) {
+		rr := changePassword("freshpass789", "password123")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestUserHandler_PasswordHistoryKeepsLastN(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// The last two passwords, counting the current one, may not be reused
+	handler := handlers.NewUserHandler(db.DB, "test-secret", 2)
+
+	hash, err := utils.HashPassword("first111")
+	assert.NoError(t, err)
+	var userID int
+	err = db.QueryRow(`
+		INSERT INTO users (name, email, password_hash, role) 
+		VALUES ('Window User', 'window@goexpress.com', $1, 'client') RETURNING id`,
+		hash,
+	).Scan(&userID)
+	assert.NoError(t, err)
+
+	changePassword := func(current, next string) int {
+		body, _ := json.Marshal(models.ChangePasswordRequest{
+			CurrentPassword: current,
+			NewPassword:     next,
+			ConfirmPassword: next,
+		})
+		req := withClaims(httptest.NewRequest("POST", "/api/users/change-password", bytes.NewBuffer(body)), userID, "client")
+		rr := httptest.NewRecorder()
+		handler.ChangePassword(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, changePassword("first111", "first111"))
+	assert.Equal(t, http.StatusOK, changePassword("first111", "second222"))
+	assert.Equal(t, http.StatusOK, changePassword("second222", "third333"))
+
+	t.Run("the newest history entry is the current password", func(t *testing.T) {
+		var current, newest string
+		err := db.QueryRow("SELECT password_hash FROM users WHERE id = $1", userID).Scan(&current)
+		assert.NoError(t, err)
+		err = db.QueryRow(`
+			SELECT password_hash FROM password_history WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC LIMIT 1`, userID).Scan(&newest)
+		assert.NoError(t, err)
+		assert.Equal(t, current, newest)
+
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM password_history WHERE user_id = $1", userID).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("passwords within the last N are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, changePassword("third333", "third333"))
+		assert.Equal(t, http.StatusBadRequest, changePassword("third333", "second222"))
+	})
+
+	t.Run("the password before the last N may be reused", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, changePassword("third333", "first111"))
+	})
+}
+
+func TestUserHandler_ActivateUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewUserHandler(db.DB, "test-secret", 5)
+	authHandler := handlers.NewAuthHandler(db.DB, "test-secret", "test-refresh-secret")
+
+	hash, err := utils.HashPassword("password123")
+	assert.NoError(t, err)
+	var userID int
+	err = db.QueryRow(`
+		INSERT INTO users (name, email, password_hash, role) 
+		VALUES ('Dormant User', 'dormant@goexpress.com', $1, 'client') RETURNING id`,
+		hash,
+	).Scan(&userID)
+	assert.NoError(t, err)
+
+	adminRequest := func(action func(http.ResponseWriter, *http.Request), method, path string, id int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": strconv.Itoa(id)})
+		rr := httptest.NewRecorder()
+		action(rr, req)
+		return rr
+	}
+
+	login := func() int {
+		body, _ := json.Marshal(models.UserLogin{Email: "dormant@goexpress.com", Password: "password123"})
+		rr := httptest.NewRecorder()
+		authHandler.Login(rr, httptest.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(body)))
+		return rr.Code
+	}
+
+	// A token issued before the user was deactivated.
+	token, err := utils.GenerateJWT(userID, "dormant@goexpress.com", "client", "test-secret")
+	assert.NoError(t, err)
+	protected := middleware.AuthMiddleware("test-secret")(
+		middleware.RequireActiveUser(handlers.UserIsActive(db.DB))(http.HandlerFunc(okHandler)),
+	)
+	useToken := func() int {
+		req := httptest.NewRequest("GET", "/api/users/profile", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		protected.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, useToken())
+
+	t.Run("deleting deactivates instead of removing", func(t *testing.T) {
+		rr := adminRequest(handler.DeleteUser, "DELETE", "/api/users/"+strconv.Itoa(userID), userID)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+
+		var active bool
+		assert.NoError(t, db.QueryRow("SELECT is_active FROM users WHERE id = $1", userID).Scan(&active))
+		assert.False(t, active)
+		assert.Equal(t, http.StatusForbidden, login())
+		assert.Equal(t, http.StatusUnauthorized, useToken(), "tokens issued before deactivation are rejected")
+	})
+
+	t.Run("activate restores the user", func(t *testing.T) {
+		rr := adminRequest(handler.ActivateUser, "POST", "/api/users/"+strconv.Itoa(userID)+"/activate", userID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var user models.User
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &user))
+		assert.Equal(t, userID, user.ID)
+		assert.True(t, user.IsActive)
+		assert.Equal(t, http.StatusOK, login())
+		assert.Equal(t, http.StatusOK, useToken())
+	})
+
+	t.Run("already active", func(t *testing.T) {
+		rr := adminRequest(handler.ActivateUser, "POST", "/api/users/"+strconv.Itoa(userID)+"/activate", userID)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		rr := adminRequest(handler.ActivateUser, "POST", "/api/users/99999/activate", 99999)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		req := withClaims(httptest.NewRequest("POST", "/api/users/"+strconv.Itoa(userID)+"/activate", nil), userID, "client")
+		rr := httptest.NewRecorder()
+		authorized("POST", "/api/users/{id}/activate", handler.ActivateUser).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestCustomerHandler_ActivateCustomer(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewCustomerHandler(db.DB)
+	userID := createTestUser(t, db, "Acme Buyer", "acme@goexpress.com", "client")
+
+	var customerID int
+	err := db.QueryRow(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone)
+		VALUES ($1, 'Acme SARL', 'Awa Ouedraogo', '+22670000000') RETURNING id`,
+		userID,
+	).Scan(&customerID)
+	assert.NoError(t, err)
+
+	adminRequest := func(action func(http.ResponseWriter, *http.Request), method string) *httptest.ResponseRecorder {
+		id := strconv.Itoa(customerID)
+		req := httptest.NewRequest(method, "/api/customers/"+id, nil)
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		action(rr, req)
+		return rr
+	}
+
+	rr := adminRequest(handler.DeleteCustomer, "DELETE")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = adminRequest(handler.ActivateCustomer, "POST")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var customer models.Customer
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &customer))
+	assert.Equal(t, customerID, customer.ID)
+	assert.Equal(t, "active", customer.Status)
+	assert.Equal(t, "acme@goexpress.com", customer.Email)
+
+	rr = adminRequest(handler.ActivateCustomer, "POST")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestUserHandler_ExportAccount(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewUserHandler(db.DB, "test-secret", 5)
+	userID := createTestUser(t, db, "Export Owner", "exportowner@goexpress.com", "client")
+	otherID := createTestUser(t, db, "Export Other", "exportother@goexpress.com", "client")
+
+	ownID := seedShipment(t, db, "GEX0E000001", 1, userID, "pending", 1000, "2025-07-01 09:00:00")
+	seedShipment(t, db, "GEX0E000002", 1, otherID, "pending", 1000, "2025-07-01 10:00:00")
+
+	var customerID int
+	err := db.QueryRow(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone)
+		VALUES ($1, 'Export SARL', 'Export Owner', '+22670000002') RETURNING id`,
+		userID,
+	).Scan(&customerID)
+	assert.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO customer_addresses (customer_id, type, label, address_line1, city, state, postal_code, country)
+		VALUES ($1, 'shipping', 'office', 'Avenue Kwame Nkrumah', 'Ouagadougou', 'Centre', '01 BP 1000', 'Burkina Faso')`,
+		customerID,
+	)
+	assert.NoError(t, err)
+
+	req := withClaims(httptest.NewRequest("GET", "/api/users/me/export", nil), userID, "client")
+	rr := httptest.NewRecorder()
+	handler.ExportAccount(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), "attachment")
+
+	var export models.AccountExport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &export))
+	assert.Equal(t, userID, export.User.ID)
+	assert.Equal(t, "exportowner@goexpress.com", export.User.Email)
+	if assert.NotNil(t, export.Customer) {
+		assert.Equal(t, customerID, export.Customer.ID)
+	}
+	if assert.Len(t, export.Addresses, 1) {
+		assert.Equal(t, "Ouagadougou", export.Addresses[0].City)
+	}
+	if assert.Len(t, export.Shipments, 1) {
+		assert.Equal(t, ownID, export.Shipments[0].ID)
+	}
+}
+
+func TestUserHandler_DeleteAccount(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewUserHandler(db.DB, "test-secret", 5)
+	userID := createTestUser(t, db, "Erase Me", "eraseme@goexpress.com", "client")
+	hash, err := utils.HashPassword("correct-horse")
+	assert.NoError(t, err)
+	_, err = db.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", hash, userID)
+	assert.NoError(t, err)
+
+	shipmentID := seedShipment(t, db, "GEX0DE00001", 1, userID, "delivered", 1000, "2025-07-01 09:00:00")
+	_, err = db.Exec(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone, tax_id)
+		VALUES ($1, 'Erase SARL', 'Erase Me', '+22670000003', 'BF-123')`,
+		userID,
+	)
+	assert.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO customer_notes (customer_id, body)
+		SELECT id, 'Prefers calls to Erase Me on +22670000003' FROM customers WHERE user_id = $1`,
+		userID,
+	)
+	assert.NoError(t, err)
+
+	deleteAccount := func(password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.DeleteAccountRequest{Password: password})
+		req := withClaims(httptest.NewRequest("POST", "/api/users/me/delete", bytes.NewBuffer(body)), userID, "client")
+		rr := httptest.NewRecorder()
+		handler.DeleteAccount(rr, req)
+		return rr
+	}
+
+	t.Run("wrong password", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, deleteAccount("wrong").Code)
+
+		var email string
+		db.QueryRow("SELECT email FROM users WHERE id = $1", userID).Scan(&email)
+		assert.Equal(t, "eraseme@goexpress.com", email)
+	})
+
+	t.Run("scrubs personal data and keeps shipments", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, deleteAccount("correct-horse").Code)
+
+		var name, email, passwordHash string
+		var active bool
+		err := db.QueryRow("SELECT name, email, password_hash, is_active FROM users WHERE id = $1", userID).
+			Scan(&name, &email, &passwordHash, &active)
+		assert.NoError(t, err)
+		assert.NotEqual(t, "Erase Me", name)
+		assert.NotContains(t, email, "eraseme")
+		assert.False(t, active)
+		assert.False(t, utils.CheckPasswordHash("correct-horse", passwordHash))
+
+		var companyName, phone string
+		var taxID *string
+		err = db.QueryRow("SELECT company_name, phone, tax_id FROM customers WHERE user_id = $1", userID).
+			Scan(&companyName, &phone, &taxID)
+		assert.NoError(t, err)
+		assert.NotEqual(t, "Erase SARL", companyName)
+		assert.Empty(t, phone)
+		assert.Nil(t, taxID)
+
+		var notes int
+		db.QueryRow("SELECT COUNT(*) FROM customer_notes n JOIN customers c ON c.id = n.customer_id WHERE c.user_id = $1", userID).Scan(&notes)
+		assert.Equal(t, 0, notes)
+
+		var customerID int
+		err = db.QueryRow("SELECT customer_id FROM shipments WHERE id = $1", shipmentID).Scan(&customerID)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, customerID)
+	})
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"goexpress-api/handlers"
+	"goexpress-api/mailer"
+	"goexpress-api/models"
+	"goexpress-api/notifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerVerification_GatesShipmentCreation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	shipments := handlers.NewShipmentHandler(db.DB)
+	shipments.SetRequireVerifiedCustomers(true)
+	notifications := &recordingNotifier{emails: make(chan mailer.Message, 1), sms: make(chan notifier.SMS, 1)}
+	customers := handlers.NewCustomerHandler(db.DB)
+	customers.SetNotifier(notifications)
+
+	clientID := createTestUser(t, db, "Unverified Client", "unverified@goexpress.com", "client")
+	_, err := db.Exec(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone)
+		VALUES ($1, 'Sahel Crafts', 'Ibrahim', '+22670000002')`, clientID)
+	assert.NoError(t, err)
+
+	createShipment := func(userID int, role string) int {
+		body, _ := json.Marshal(models.ShipmentRequest{
+			Origin:      "Ouagadougou",
+			Destination: "Bobo-Dioulasso",
+			Weight:      2,
+			ZoneID:      1,
+		})
+		req := withClaims(httptest.NewRequest("POST", "/api/shipments", bytes.NewBuffer(body)), userID, role)
+		rr := httptest.NewRecorder()
+		shipments.CreateShipment(rr, req)
+		return rr.Code
+	}
+
+	post := func(handler http.HandlerFunc, target, body string) *httptest.ResponseRecorder {
+		req := withClaims(httptest.NewRequest("POST", target, bytes.NewBufferString(body)), clientID, "client")
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	t.Run("unverified customer is blocked", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, createShipment(clientID, "client"))
+	})
+
+	t.Run("admins bypass verification", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, createShipment(1, "admin"))
+	})
+
+	var code string
+	t.Run("code is sent by SMS", func(t *testing.T) {
+		rr := post(customers.StartVerification, "/api/customers/me/verification", `{"channel": "sms"}`)
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+
+		msg := <-notifications.sms
+		assert.Equal(t, "+22670000002", msg.To)
+		code = regexp.MustCompile(`\d{6}`).FindString(msg.Body)
+		assert.NotEmpty(t, code)
+	})
+
+	t.Run("wrong code is rejected", func(t *testing.T) {
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+		rr := post(customers.ConfirmVerification, "/api/customers/me/verification/confirm", `{"code": "`+wrong+`"}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, http.StatusForbidden, createShipment(clientID, "client"))
+	})
+
+	t.Run("confirmed customer can ship", func(t *testing.T) {
+		rr := post(customers.ConfirmVerification, "/api/customers/me/verification/confirm", `{"code": "`+code+`"}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var status models.VerificationStatus
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+		assert.True(t, status.PhoneVerified)
+		assert.False(t, status.EmailVerified)
+		assert.True(t, status.Verified)
+
+		assert.Equal(t, http.StatusCreated, createShipment(clientID, "client"))
+	})
+
+	t.Run("codes cannot be reused", func(t *testing.T) {
+		rr := post(customers.ConfirmVerification, "/api/customers/me/verification/confirm", `{"code": "`+code+`"}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestCustomerVerification_Limits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	notifications := &recordingNotifier{emails: make(chan mailer.Message, 10), sms: make(chan notifier.SMS, 10)}
+	customers := handlers.NewCustomerHandler(db.DB)
+	customers.SetNotifier(notifications)
+
+	clientID := createTestUser(t, db, "Guessing Client", "guessing@goexpress.com", "client")
+	_, err := db.Exec(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone)
+		VALUES ($1, 'Guess SARL', 'Moussa', '+22670000008')`, clientID)
+	assert.NoError(t, err)
+
+	post := func(handler http.HandlerFunc, target, body string) *httptest.ResponseRecorder {
+		req := withClaims(httptest.NewRequest("POST", target, bytes.NewBufferString(body)), clientID, "client")
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+	start := func() int {
+		return post(customers.StartVerification, "/api/customers/me/verification", `{"channel": "email"}`).Code
+	}
+	// Moves the last code back in time, as if the resend interval had passed
+	waitToResend := func() {
+		_, err := db.Exec("UPDATE customers SET verification_sent_at = verification_sent_at - INTERVAL '2 minutes' WHERE user_id = $1", clientID)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, http.StatusAccepted, start())
+	msg := <-notifications.emails
+	code := regexp.MustCompile(`\d{6}`).FindString(msg.Body)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	t.Run("parallel guesses cannot exceed the attempt limit", func(t *testing.T) {
+		const guesses = 20
+		codes := make(chan int, guesses)
+		var wg sync.WaitGroup
+		for i := 0; i < guesses; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes <- post(customers.ConfirmVerification, "/api/customers/me/verification/confirm", `{"code": "`+wrong+`"}`).Code
+			}()
+		}
+		wg.Wait()
+		close(codes)
+
+		checked := 0
+		for code := range codes {
+			if code == http.StatusBadRequest {
+				checked++
+			} else {
+				assert.Equal(t, http.StatusTooManyRequests, code)
+			}
+		}
+		assert.Equal(t, 5, checked)
+
+		// The right code no longer works either
+		rr := post(customers.ConfirmVerification, "/api/customers/me/verification/confirm", `{"code": "`+code+`"}`)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	})
+
+	t.Run("codes cannot be requested back to back", func(t *testing.T) {
+		assert.Equal(t, http.StatusTooManyRequests, start())
+		waitToResend()
+		assert.Equal(t, http.StatusAccepted, start())
+	})
+
+	t.Run("codes are capped per hour", func(t *testing.T) {
+		// Two codes were sent above
+		for i := 0; i < 3; i++ {
+			waitToResend()
+			assert.Equal(t, http.StatusAccepted, start())
+		}
+		waitToResend()
+		assert.Equal(t, http.StatusTooManyRequests, start())
+	})
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goexpress-api/buildinfo"
+	"goexpress-api/database"
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionHandler_GetVersion(t *testing.T) {
+	handler := handlers.NewVersionHandler(nil)
+
+	t.Run("defaults to dev", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.GetVersion(rr, httptest.NewRequest("GET", "/api/version", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var info map[string]string
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &info))
+		assert.Equal(t, "dev", info["version"])
+		assert.Equal(t, "dev", info["commit"])
+		assert.Equal(t, "dev", info["build_date"])
+	})
+
+	t.Run("reports injected build metadata", func(t *testing.T) {
+		defer func(version, commit, buildDate string) {
+			buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate = version, commit, buildDate
+		}(buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
+		buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate = "1.4.0", "abc1234", "2025-07-01T12:00:00Z"
+
+		rr := httptest.NewRecorder()
+		handler.GetVersion(rr, httptest.NewRequest("GET", "/api/version", nil))
+
+		var info models.VersionResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &info))
+		assert.Equal(t, buildinfo.Info{Version: "1.4.0", Commit: "abc1234", BuildDate: "2025-07-01T12:00:00Z"}, info.Info)
+	})
+}
+
+func TestVersionHandler_SchemaVersion(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewVersionHandler(db.DB)
+	rr := httptest.NewRecorder()
+	handler.GetVersion(rr, httptest.NewRequest("GET", "/api/version", nil))
+
+	var info models.VersionResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &info))
+	expected, err := database.SchemaVersion(db.DB)
+	assert.NoError(t, err)
+	assert.NotNil(t, info.SchemaVersion)
+	assert.Equal(t, expected, *info.SchemaVersion)
+}
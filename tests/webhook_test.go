@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"goexpress-api/outbox"
+	"goexpress-api/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignWebhook(t *testing.T) {
+	secret := "whsec-test"
+	body := []byte(`{"type":"shipment.status_changed"}`)
+
+	// The documented scheme: "sha256=" + hex(HMAC-SHA256(secret, raw body))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.Equal(t, expected, utils.SignWebhook(secret, body))
+	assert.NotEqual(t, expected, utils.SignWebhook("other-secret", body))
+	assert.NotEqual(t, expected, utils.SignWebhook(secret, append(body, ' ')))
+}
+
+func TestWebhookHandler_GetVerifySample(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	h := handlers.NewWebhookHandler(db.DB)
+	aliceID := createTestUser(t, db, "Hook Alice", "hookalice@goexpress.com", "client")
+	bobID := createTestUser(t, db, "Hook Bob", "hookbob@goexpress.com", "client")
+	for _, id := range []int{aliceID, bobID} {
+		_, err := db.Exec(`
+			INSERT INTO customers (user_id, company_name, contact_person, phone)
+			VALUES ($1, 'Hook SARL', 'Hook', '+22670000009')`, id)
+		require.NoError(t, err)
+	}
+
+	setEndpoint := func(userID int) models.WebhookEndpoint {
+		body := strings.NewReader(`{"url":"https://hooks.example.com/goexpress"}`)
+		req := withClaims(httptest.NewRequest("PUT", "/api/webhooks/endpoint", body), userID, "client")
+		rr := httptest.NewRecorder()
+		authorized("PUT", "/api/webhooks/endpoint", h.SetEndpoint).ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var endpoint models.WebhookEndpoint
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&endpoint))
+		return endpoint
+	}
+	getSample := func(userID int, role string) *httptest.ResponseRecorder {
+		req := withClaims(httptest.NewRequest("GET", "/api/webhooks/verify-sample", nil), userID, role)
+		rr := httptest.NewRecorder()
+		authorized("GET", "/api/webhooks/verify-sample", h.GetVerifySample).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("conflict before an endpoint is set", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, getSample(aliceID, "client").Code)
+	})
+
+	alice := setEndpoint(aliceID)
+	bob := setEndpoint(bobID)
+	require.NotEmpty(t, alice.Secret)
+	require.NotEqual(t, alice.Secret, bob.Secret)
+
+	t.Run("returns a body signed with the customer's own secret", func(t *testing.T) {
+		rr := getSample(aliceID, "client")
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var sample models.WebhookSample
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&sample))
+		assert.Equal(t, utils.WebhookSignatureHeader, sample.Header)
+		assert.Equal(t, utils.SignWebhook(alice.Secret, []byte(sample.Body)), sample.Signature)
+		assert.NotEqual(t, utils.SignWebhook(bob.Secret, []byte(sample.Body)), sample.Signature)
+
+		var event outbox.Event
+		require.NoError(t, json.Unmarshal([]byte(sample.Body), &event))
+		assert.Equal(t, outbox.EventWebhookSample, event.Type)
+	})
+
+	t.Run("setting the endpoint again rotates the secret", func(t *testing.T) {
+		rotated := setEndpoint(aliceID)
+		assert.NotEqual(t, alice.Secret, rotated.Secret)
+
+		var sample models.WebhookSample
+		rr := getSample(aliceID, "client")
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&sample))
+		assert.Equal(t, utils.SignWebhook(rotated.Secret, []byte(sample.Body)), sample.Signature)
+	})
+
+	t.Run("customers only", func(t *testing.T) {
+		for _, role := range []string{"admin", "driver"} {
+			assert.Equal(t, http.StatusForbidden, getSample(aliceID, role).Code, role)
+		}
+	})
+}
+
+func TestWebhookDispatcher_SignsDeliveries(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(utils.WebhookSignatureHeader)
+	}))
+	defer server.Close()
+
+	dispatcher := outbox.NewWebhookDispatcher(server.URL, "whsec-test", time.Second)
+	require.NoError(t, dispatcher.Dispatch(outbox.Event{ID: 1, Type: outbox.EventShipmentStatusChanged}))
+	assert.Equal(t, utils.SignWebhook("whsec-test", body), signature)
+}
+
+func TestCustomerWebhookDispatcher_SignsWithCustomerSecret(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	var body []byte
+	var signature string
+	deliveries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries++
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(utils.WebhookSignatureHeader)
+	}))
+	defer server.Close()
+
+	hookedID := createTestUser(t, db, "Hooked Client", "hooked@goexpress.com", "client")
+	plainID := createTestUser(t, db, "Plain Client", "plain@goexpress.com", "client")
+	_, err := db.Exec(`
+		INSERT INTO customers (user_id, company_name, contact_person, phone, webhook_url, webhook_secret) VALUES
+			($1, 'Hooked SARL', 'Hooked', '+22670000010', $3, 'whsec-customer'),
+			($2, 'Plain SARL', 'Plain', '+22670000011', NULL, NULL)`,
+		hookedID, plainID, server.URL,
+	)
+	require.NoError(t, err)
+	hookedShipment := seedShipment(t, db, "GEX0000HOOK", 1, hookedID, "pending", 1500, "2025-07-01 09:00:00")
+	plainShipment := seedShipment(t, db, "GEX000PLAIN", 1, plainID, "pending", 1500, "2025-07-01 09:00:00")
+
+	dispatcher := outbox.NewCustomerWebhookDispatcher(db.DB, time.Second)
+	require.NoError(t, dispatcher.Dispatch(outbox.Event{ID: 1, Type: outbox.EventShipmentStatusChanged, ShipmentID: hookedShipment}))
+	assert.Equal(t, 1, deliveries)
+	assert.Equal(t, utils.SignWebhook("whsec-customer", body), signature)
+
+	// Customers without an endpoint are skipped, not failed
+	require.NoError(t, dispatcher.Dispatch(outbox.Event{ID: 2, Type: outbox.EventShipmentStatusChanged, ShipmentID: plainShipment}))
+	assert.Equal(t, 1, deliveries)
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goexpress-api/handlers"
+	"goexpress-api/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZones_UpdatedAtTrigger(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	backdate := func(zoneID int) {
+		_, err := db.Exec(`UPDATE zones SET updated_at = CURRENT_TIMESTAMP - INTERVAL '1 day' WHERE id = $1`, zoneID)
+		assert.NoError(t, err)
+	}
+
+	recentlyUpdated := func(zoneID int) bool {
+		var recent bool
+		err := db.QueryRow(`SELECT updated_at > CURRENT_TIMESTAMP - INTERVAL '1 hour' FROM zones WHERE id = $1`, zoneID).Scan(&recent)
+		assert.NoError(t, err)
+		return recent
+	}
+
+	t.Run("explicit updated_at is kept", func(t *testing.T) {
+		backdate(1)
+		assert.False(t, recentlyUpdated(1))
+	})
+
+	t.Run("plain update bumps updated_at", func(t *testing.T) {
+		backdate(1)
+		_, err := db.Exec(`UPDATE zones SET price_per_kg = 4.00 WHERE id = 1`)
+		assert.NoError(t, err)
+		assert.True(t, recentlyUpdated(1))
+	})
+
+	t.Run("UpdateZone bumps updated_at", func(t *testing.T) {
+		backdate(2)
+
+		body, _ := json.Marshal(models.Zone{Name: "Regional Standard", PricePerKg: 2.75})
+		req := httptest.NewRequest("PUT", "/api/zones/2", bytes.NewBuffer(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": "2"})
+		rr := httptest.NewRecorder()
+		handlers.NewZoneHandler(db.DB).UpdateZone(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.True(t, recentlyUpdated(2))
+	})
+}
+
+func TestZoneHandler_PatchZone(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewZoneHandler(db.DB)
+
+	var originalName string
+	assert.NoError(t, db.QueryRow("SELECT name FROM zones WHERE id = 1").Scan(&originalName))
+
+	patch := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/zones/"+id, bytes.NewBufferString(body))
+		req = mux.SetURLVars(withClaims(req, 1, "admin"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.PatchZone(rr, req)
+		return rr
+	}
+
+	t.Run("changing only the price keeps the name", func(t *testing.T) {
+		rr := patch("1", `{"price_per_kg": 4.25}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var zone models.Zone
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &zone))
+		assert.Equal(t, originalName, zone.Name)
+		assert.Equal(t, 4.25, zone.PricePerKg)
+	})
+
+	t.Run("changing only the name keeps the price", func(t *testing.T) {
+		rr := patch("1", `{"name": "Local Express Plus"}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var zone models.Zone
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &zone))
+		assert.Equal(t, "Local Express Plus", zone.Name)
+		assert.Equal(t, 4.25, zone.PricePerKg)
+	})
+
+	t.Run("price must be positive when present", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, patch("1", `{"price_per_kg": 0}`).Code)
+	})
+
+	t.Run("empty patch", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, patch("1", `{}`).Code)
+	})
+
+	t.Run("unknown zone", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, patch("999", `{"price_per_kg": 2}`).Code)
+	})
+}
+
+func TestZoneHandler_GetZoneLoad(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := handlers.NewZoneHandler(db.DB)
+	clientID := createTestUser(t, db, "Load Client", "loadclient@goexpress.com", "client")
+
+	// Zone 1: two active shipments of 2kg and 5kg, plus closed ones that don't count
+	seedShipment(t, db, "GEX10AD0001", 1, clientID, "pending", 1000, "2025-07-01 09:00:00")
+	heavyID := seedShipment(t, db, "GEX10AD0002", 1, clientID, "in_transit", 1000, "2025-07-01 09:00:00")
+	_, err := db.Exec("UPDATE shipments SET weight = 5 WHERE id = $1", heavyID)
+	assert.NoError(t, err)
+	seedShipment(t, db, "GEX10AD0003", 1, clientID, "delivered", 1000, "2025-07-01 09:00:00")
+	seedShipment(t, db, "GEX10AD0004", 1, clientID, "cancelled", 1000, "2025-07-01 09:00:00")
+	// Zone 2: one active 2kg shipment
+	seedShipment(t, db, "GEX10AD0005", 2, clientID, "picked_up", 1000, "2025-07-01 09:00:00")
+
+	req := withClaims(httptest.NewRequest("GET", "/api/zones/load", nil), 1, "admin")
+	rr := httptest.NewRecorder()
+	handler.GetZoneLoad(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var loads []models.ZoneLoad
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &loads))
+
+	var zoneCount int
+	db.QueryRow("SELECT COUNT(*) FROM zones").Scan(&zoneCount)
+	assert.Len(t, loads, zoneCount, "zones without shipments are listed too")
+
+	byID := make(map[int]models.ZoneLoad)
+	for _, load := range loads {
+		byID[load.ID] = load
+	}
+	assert.Equal(t, 2, byID[1].ActiveShipments)
+	assert.Equal(t, 3.5, byID[1].AverageWeight)
+	assert.Equal(t, 1, byID[2].ActiveShipments)
+	assert.Equal(t, 2.0, byID[2].AverageWeight)
+	assert.Equal(t, 0, byID[3].ActiveShipments)
+	assert.Equal(t, 0.0, byID[3].AverageWeight)
+	assert.NotEmpty(t, byID[1].Name)
+}
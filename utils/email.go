@@ -0,0 +1,10 @@
+package utils
+
+import "strings"
+
+// NormalizeEmail returns an email address in the form it is stored and looked
+// up in: trimmed and lowercased, so addresses that differ only by case belong
+// to the same account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -11,6 +11,9 @@ type Claims struct {
 	UserID int    `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// ImpersonatorID is the admin acting as the user, set only on
+	// impersonation tokens.
+	ImpersonatorID int `json:"impersonator_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -29,6 +32,25 @@ func GenerateJWT(userID int, email, role, secret string) (string, error) {
 	return token.SignedString([]byte(secret))
 }
 
+// GenerateImpersonationJWT issues an access token for the user that also
+// names the admin acting as them. It expires at expiresAt and cannot be
+// refreshed.
+func GenerateImpersonationJWT(impersonatorID, userID int, email, role, secret string, expiresAt time.Time) (string, error) {
+	claims := &Claims{
+		UserID:         userID,
+		Email:          email,
+		Role:           role,
+		ImpersonatorID: impersonatorID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
 func GenerateRefreshToken(userID int, email, role, secret string) (string, error) {
 	claims := &Claims{
 		UserID: userID,
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	// MaxPage caps the requested page so its offset can never overflow.
+	// Pages that far out are empty anyway.
+	MaxPage = 1000000
+)
+
+var (
+	defaultPageSize = DefaultPageSize
+	maxPageSize     = MaxPageSize
+)
+
+// SetPageSizes sets the page size used when no limit is requested and the
+// ceiling requested limits are clamped to. It is meant to be called once at
+// startup; a default above the maximum is lowered to it.
+func SetPageSizes(defaultSize, maxSize int) {
+	if maxSize < 1 {
+		maxSize = MaxPageSize
+	}
+	if defaultSize < 1 {
+		defaultSize = DefaultPageSize
+	}
+	if defaultSize > maxSize {
+		defaultSize = maxSize
+	}
+	defaultPageSize = defaultSize
+	maxPageSize = maxSize
+}
+
+// Pagination is a 1-based page of Limit items.
+type Pagination struct {
+	Page  int
+	Limit int
+}
+
+// Offset is the number of items before the page.
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+// ParsePagination reads the page and limit query params, defaulting to the
+// first page of the default page size. Missing, non-numeric or non-positive
+// values fall back to those defaults, and pages and limits above their
+// maximums are clamped, so any query yields a usable page.
+func ParsePagination(r *http.Request) Pagination {
+	p := Pagination{Page: 1, Limit: defaultPageSize}
+
+	if page, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && page > 0 {
+		p.Page = page
+	}
+	if p.Page > MaxPage {
+		p.Page = MaxPage
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 {
+		p.Limit = limit
+	}
+	if p.Limit > maxPageSize {
+		p.Limit = maxPageSize
+	}
+
+	return p
+}
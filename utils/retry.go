@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WithRetry calls fn up to attempts times while it fails with a transient
+// database error, sleeping backoff before the first retry and doubling it
+// after each one. Any other error, and the last transient one, is returned
+// as is. Only wrap operations that are safe to repeat.
+func WithRetry(fn func() error, attempts int, backoff time.Duration) error {
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = fn(); err == nil || !IsTransientDBError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// IsTransientDBError reports whether err is likely to go away on retry:
+// dropped or refused connections, server shutdowns during failover,
+// serialization failures and deadlocks. Constraint violations, missing rows
+// and other errors are not transient.
+func IsTransientDBError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08": // connection exception
+			return true
+		}
+		switch pqErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"53300", // too_many_connections
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidFileToken = errors.New("invalid file token")
+	ErrExpiredFileToken = errors.New("file token expired")
+
+	ErrInvalidTrackingToken = errors.New("invalid tracking token")
+	ErrExpiredTrackingToken = errors.New("tracking token expired")
+)
+
+// SignFileToken returns a token granting access to a document until expiresAt.
+// The token has the form "<docID>.<expiry unix>.<signature>".
+func SignFileToken(documentID int, expiresAt time.Time, secret string) string {
+	payload := fmt.Sprintf("%d.%d", documentID, expiresAt.Unix())
+	return payload + "." + tokenSignature("file", payload, secret)
+}
+
+// VerifyFileToken checks a token's signature and expiry and returns the document ID it grants.
+func VerifyFileToken(token, secret string, now time.Time) (int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidFileToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(tokenSignature("file", payload, secret))) {
+		return 0, ErrInvalidFileToken
+	}
+
+	documentID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, ErrInvalidFileToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidFileToken
+	}
+	if now.Unix() > expiresAt {
+		return 0, ErrExpiredFileToken
+	}
+
+	return documentID, nil
+}
+
+// SignTrackingToken returns a token that resolves to trackingNumber until
+// expiresAt, or forever when expiresAt is zero. The token has the form
+// "<sealed tracking number>.<expiry unix or 0>.<signature>"; the tracking
+// number is encrypted so holding a link does not reveal it.
+func SignTrackingToken(trackingNumber string, expiresAt time.Time, secret string) (string, error) {
+	block, err := aes.NewCipher(trackingTokenKey(secret))
+	if err != nil {
+		return "", err
+	}
+
+	sealed := make([]byte, aes.BlockSize+len(trackingNumber))
+	iv := sealed[:aes.BlockSize]
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	cipher.NewCTR(block, iv).XORKeyStream(sealed[aes.BlockSize:], []byte(trackingNumber))
+
+	var expiry int64
+	if !expiresAt.IsZero() {
+		expiry = expiresAt.Unix()
+	}
+
+	payload := fmt.Sprintf("%s.%d", base64.RawURLEncoding.EncodeToString(sealed), expiry)
+	return payload + "." + tokenSignature("track", payload, secret), nil
+}
+
+// VerifyTrackingToken checks a token's signature and expiry and returns the
+// tracking number it resolves to.
+func VerifyTrackingToken(token, secret string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidTrackingToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(tokenSignature("track", payload, secret))) {
+		return "", ErrInvalidTrackingToken
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(sealed) <= aes.BlockSize {
+		return "", ErrInvalidTrackingToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalidTrackingToken
+	}
+	if expiresAt != 0 && now.Unix() > expiresAt {
+		return "", ErrExpiredTrackingToken
+	}
+
+	block, err := aes.NewCipher(trackingTokenKey(secret))
+	if err != nil {
+		return "", err
+	}
+	trackingNumber := make([]byte, len(sealed)-aes.BlockSize)
+	cipher.NewCTR(block, sealed[:aes.BlockSize]).XORKeyStream(trackingNumber, sealed[aes.BlockSize:])
+
+	return string(trackingNumber), nil
+}
+
+// trackingTokenKey derives the AES-256 key that seals tracking numbers, kept
+// separate from the key tokens are signed with.
+func trackingTokenKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("track-key"))
+	return mac.Sum(nil)
+}
+
+// tokenSignature signs payload for one purpose, so a token minted for one
+// kind of link can never verify as another.
+func tokenSignature(purpose, payload, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -6,16 +6,22 @@ import (
 	"strings"
 )
 
+// GoExpress tracking number format: the prefix followed by 8 uppercase hex
+// characters, e.g. GEX1A2B3C4D.
+const (
+	TrackingNumberPrefix = "GEX"
+	TrackingNumberLength = len(TrackingNumberPrefix) + 8
+)
+
 func GenerateTrackingNumber() (string, error) {
 	bytes := make([]byte, 4)
 	if _, err := rand.Read(bytes); err != nil {
 		return "", err
 	}
 	
-	// GoExpress tracking number format: GEX + 8 characters
-	return fmt.Sprintf("GEX%X", bytes), nil
+	return fmt.Sprintf("%s%X", TrackingNumberPrefix, bytes), nil
 }
 
 func ValidateTrackingNumber(trackingNumber string) bool {
-	return strings.HasPrefix(trackingNumber, "GEX") && len(trackingNumber) == 11
+	return strings.HasPrefix(trackingNumber, TrackingNumberPrefix) && len(trackingNumber) == TrackingNumberLength
 }
\ No newline at end of file
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// WebhookSignatureHeader carries the signature of each webhook GoExpress sends.
+const WebhookSignatureHeader = "X-GoExpress-Signature"
+
+// SignWebhook returns the signature sent with a webhook body: "sha256="
+// followed by the hex-encoded HMAC-SHA256 of the raw body, keyed with the
+// webhook secret. Receivers recompute it over the bytes they received and
+// compare in constant time.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewWebhookSecret returns a random secret for signing one customer's
+// webhooks.
+func NewWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}